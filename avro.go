@@ -0,0 +1,343 @@
+package kafkatools
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// avroSchema is a parsed Avro schema, type is the primitive type or record, enum, array, map, union or fixed. Logical
+// types are decoded as their underlying type.
+type avroSchema struct {
+	typ      string
+	name     string
+	fields   []avroField
+	symbols  []string
+	items    *avroSchema
+	branches []*avroSchema
+	size     int
+}
+
+type avroField struct {
+	name   string
+	schema *avroSchema
+}
+
+// avroPrimitives are the types which are complete without further attributes
+var avroPrimitives = map[string]bool{
+	"null": true, "boolean": true, "int": true, "long": true, "float": true, "double": true, "bytes": true, "string": true,
+}
+
+// parseAvroSchema parses the JSON representation of a schema
+func parseAvroSchema(schema []byte) (*avroSchema, error) {
+	var parsed interface{}
+	if err := json.Unmarshal(schema, &parsed); err != nil {
+		return nil, fmt.Errorf("invalid avro schema: %v", err)
+	}
+	parser := avroSchemaParser{named: make(map[string]*avroSchema)}
+	return parser.parse(parsed, "")
+}
+
+// avroSchemaParser resolves the references to the named types (records, enums and fixed) defined so far, by full or
+// simple name
+type avroSchemaParser struct {
+	named map[string]*avroSchema
+}
+
+func (p *avroSchemaParser) parse(schema interface{}, namespace string) (*avroSchema, error) {
+	switch schema := schema.(type) {
+	case string:
+		if avroPrimitives[schema] {
+			return &avroSchema{typ: schema}, nil
+		}
+		if named, ok := p.named[schema]; ok {
+			return named, nil
+		}
+		if named, ok := p.named[namespace+"."+schema]; ok {
+			return named, nil
+		}
+		return nil, fmt.Errorf("unknown avro type %q", schema)
+	case []interface{}:
+		union := &avroSchema{typ: "union"}
+		for _, branch := range schema {
+			parsed, err := p.parse(branch, namespace)
+			if err != nil {
+				return nil, err
+			}
+			union.branches = append(union.branches, parsed)
+		}
+		return union, nil
+	case map[string]interface{}:
+		return p.parseComplex(schema, namespace)
+	default:
+		return nil, fmt.Errorf("invalid avro schema %v", schema)
+	}
+}
+
+func (p *avroSchemaParser) parseComplex(schema map[string]interface{}, namespace string) (*avroSchema, error) {
+	typ, ok := schema["type"].(string)
+	if !ok {
+		// {"type": {...}} nests a schema
+		return p.parse(schema["type"], namespace)
+	}
+
+	parsed := &avroSchema{typ: typ}
+	switch typ {
+	case "record", "error", "enum", "fixed":
+		if typ == "error" {
+			parsed.typ = "record"
+		}
+		name, _ := schema["name"].(string)
+		if name == "" {
+			return nil, fmt.Errorf("avro %s without a name", typ)
+		}
+		// Full names carry their namespace, which is inherited by the types defined inside them
+		if dot := strings.LastIndex(name, "."); dot != -1 {
+			namespace = name[:dot]
+		} else {
+			if ns, ok := schema["namespace"].(string); ok {
+				namespace = ns
+			}
+			if namespace != "" {
+				name = namespace + "." + name
+			}
+		}
+		parsed.name = name
+		// Records may refer to themselves, so they are named before their fields are parsed
+		p.named[name] = parsed
+		p.named[name[strings.LastIndex(name, ".")+1:]] = parsed
+	}
+
+	switch parsed.typ {
+	case "record":
+		fields, _ := schema["fields"].([]interface{})
+		for _, field := range fields {
+			field, _ := field.(map[string]interface{})
+			name, _ := field["name"].(string)
+			fieldSchema, err := p.parse(field["type"], namespace)
+			if err != nil {
+				return nil, fmt.Errorf("field %s of %s: %v", name, parsed.name, err)
+			}
+			parsed.fields = append(parsed.fields, avroField{name: name, schema: fieldSchema})
+		}
+	case "enum":
+		symbols, _ := schema["symbols"].([]interface{})
+		for _, symbol := range symbols {
+			symbol, _ := symbol.(string)
+			parsed.symbols = append(parsed.symbols, symbol)
+		}
+	case "fixed":
+		size, _ := schema["size"].(float64)
+		parsed.size = int(size)
+	case "array", "map":
+		items := schema["items"]
+		if typ == "map" {
+			items = schema["values"]
+		}
+		var err error
+		if parsed.items, err = p.parse(items, namespace); err != nil {
+			return nil, err
+		}
+	default:
+		if !avroPrimitives[typ] {
+			return nil, fmt.Errorf("unknown avro type %q", typ)
+		}
+	}
+	return parsed, nil
+}
+
+// decodeAvro renders the Avro binary encoding of a value of the schema as JSON, the fields of records are kept in the
+// order of the schema. Unions are rendered as the value of their branch, bytes and fixed values are base64 encoded.
+func decodeAvro(schema *avroSchema, data []byte) ([]byte, error) {
+	decoder := avroDecoder{data: data}
+	value, err := decoder.decode(schema)
+	if err != nil {
+		return nil, err
+	}
+	if len(decoder.data) > 0 {
+		return nil, fmt.Errorf("%d unexpected bytes after the avro value", len(decoder.data))
+	}
+	return json.Marshal(value)
+}
+
+// avroRecord is a decoded record, it is encoded as a JSON object with the fields in order
+type avroRecord []avroRecordField
+
+type avroRecordField struct {
+	name  string
+	value interface{}
+}
+
+func (r avroRecord) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, field := range r {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		name, _ := json.Marshal(field.name)
+		value, err := json.Marshal(field.value)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(name)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+type avroDecoder struct {
+	data []byte
+}
+
+func (d *avroDecoder) read(n int64) ([]byte, error) {
+	if n < 0 || n > int64(len(d.data)) {
+		return nil, fmt.Errorf("avro value is truncated: %d bytes needed, %d left", n, len(d.data))
+	}
+	bytes := d.data[:n]
+	d.data = d.data[n:]
+	return bytes, nil
+}
+
+// readLong reads a zig-zag encoded variable-length integer, which encodes ints and longs as well as lengths and counts
+func (d *avroDecoder) readLong() (int64, error) {
+	value, n := binary.Varint(d.data)
+	if n <= 0 {
+		return 0, fmt.Errorf("invalid avro long")
+	}
+	d.data = d.data[n:]
+	return value, nil
+}
+
+func (d *avroDecoder) decode(schema *avroSchema) (interface{}, error) {
+	switch schema.typ {
+	case "null":
+		return nil, nil
+	case "boolean":
+		value, err := d.read(1)
+		if err != nil {
+			return nil, err
+		}
+		return value[0] != 0, nil
+	case "int", "long":
+		return d.readLong()
+	case "float":
+		value, err := d.read(4)
+		if err != nil {
+			return nil, err
+		}
+		return jsonFloat(float64(math.Float32frombits(binary.LittleEndian.Uint32(value)))), nil
+	case "double":
+		value, err := d.read(8)
+		if err != nil {
+			return nil, err
+		}
+		return jsonFloat(math.Float64frombits(binary.LittleEndian.Uint64(value))), nil
+	case "bytes", "string":
+		length, err := d.readLong()
+		if err != nil {
+			return nil, err
+		}
+		value, err := d.read(length)
+		if err != nil {
+			return nil, err
+		}
+		if schema.typ == "string" {
+			return string(value), nil
+		}
+		return value, nil
+	case "fixed":
+		return d.read(int64(schema.size))
+	case "enum":
+		index, err := d.readLong()
+		if err != nil {
+			return nil, err
+		}
+		if index < 0 || index >= int64(len(schema.symbols)) {
+			return nil, fmt.Errorf("invalid symbol %d of enum %s", index, schema.name)
+		}
+		return schema.symbols[index], nil
+	case "union":
+		index, err := d.readLong()
+		if err != nil {
+			return nil, err
+		}
+		if index < 0 || index >= int64(len(schema.branches)) {
+			return nil, fmt.Errorf("invalid union branch %d", index)
+		}
+		return d.decode(schema.branches[index])
+	case "record":
+		record := make(avroRecord, 0, len(schema.fields))
+		for _, field := range schema.fields {
+			value, err := d.decode(field.schema)
+			if err != nil {
+				return nil, err
+			}
+			record = append(record, avroRecordField{name: field.name, value: value})
+		}
+		return record, nil
+	case "array":
+		items := []interface{}{}
+		err := d.readBlocks(func() error {
+			item, err := d.decode(schema.items)
+			items = append(items, item)
+			return err
+		})
+		return items, err
+	case "map":
+		values := avroRecord{}
+		err := d.readBlocks(func() error {
+			key, err := d.decode(&avroSchema{typ: "string"})
+			if err != nil {
+				return err
+			}
+			value, err := d.decode(schema.items)
+			values = append(values, avroRecordField{name: key.(string), value: value})
+			return err
+		})
+		return values, err
+	default:
+		return nil, fmt.Errorf("unknown avro type %q", schema.typ)
+	}
+}
+
+// readBlocks reads the blocks of an array or map, every block starts with its number of items, a negative count is
+// followed by the size of the block in bytes. An empty block ends the items.
+func (d *avroDecoder) readBlocks(readItem func() error) error {
+	for {
+		count, err := d.readLong()
+		if err != nil {
+			return err
+		}
+		if count == 0 {
+			return nil
+		}
+		if count < 0 {
+			count = -count
+			if _, err := d.readLong(); err != nil {
+				return err
+			}
+		}
+		if count > int64(len(d.data)) && count > 1<<20 {
+			return fmt.Errorf("avro value is truncated: block of %d items", count)
+		}
+		for i := int64(0); i < count; i++ {
+			if err := readItem(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// jsonFloat returns the float, or its name for NaN and the infinities which JSON can't represent
+func jsonFloat(value float64) interface{} {
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return fmt.Sprint(value)
+	}
+	return value
+}
@@ -0,0 +1,98 @@
+package kafkatools
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+// avroLong encodes a long in the zig-zag variable-length encoding
+func avroLong(value int64) []byte {
+	return binary.AppendVarint(nil, value)
+}
+
+func avroString(value string) []byte {
+	return append(avroLong(int64(len(value))), value...)
+}
+
+func TestDecodeAvro(t *testing.T) {
+	schema, err := parseAvroSchema([]byte(`{
+		"type": "record", "name": "Customer", "namespace": "shop",
+		"fields": [
+			{"name": "id", "type": "long"},
+			{"name": "name", "type": ["null", "string"]},
+			{"name": "tier", "type": {"type": "enum", "name": "Tier", "symbols": ["FREE", "PAID"]}},
+			{"name": "tags", "type": {"type": "array", "items": "string"}},
+			{"name": "limits", "type": {"type": "map", "values": "int"}},
+			{"name": "verified", "type": "boolean"},
+			{"name": "hash", "type": {"type": "fixed", "name": "Hash", "size": 2}},
+			{"name": "referrer", "type": ["null", "shop.Customer"]},
+			{"name": "previous", "type": "Tier"}
+		]
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var data []byte
+	data = append(data, avroLong(-42)...)
+	data = append(data, avroLong(1)...)
+	data = append(data, avroString("Ada")...)
+	data = append(data, avroLong(1)...)
+	// The tags are written as a block of a negative count followed by its size
+	data = append(data, avroLong(-2)...)
+	data = append(data, avroLong(4)...)
+	data = append(data, avroString("a")...)
+	data = append(data, avroString("b")...)
+	data = append(data, avroLong(0)...)
+	data = append(data, avroLong(1)...)
+	data = append(data, avroString("orders")...)
+	data = append(data, avroLong(10)...)
+	data = append(data, avroLong(0)...)
+	data = append(data, 1, 0xca, 0xfe)
+	// The referrer is a customer without referrer
+	data = append(data, avroLong(1)...)
+	data = append(data, avroLong(7)...)
+	data = append(data, avroLong(0)...)
+	data = append(data, avroLong(0)...)
+	data = append(data, avroLong(0)...)
+	data = append(data, avroLong(0)...)
+	data = append(data, 0, 0, 0)
+	data = append(data, avroLong(0)...)
+	data = append(data, avroLong(0)...)
+	data = append(data, avroLong(0)...)
+
+	decoded, err := decodeAvro(schema, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"id":-42,"name":"Ada","tier":"PAID","tags":["a","b"],"limits":{"orders":10},"verified":true,"hash":"yv4=",` +
+		`"referrer":{"id":7,"name":null,"tier":"FREE","tags":[],"limits":{},"verified":false,"hash":"AAA=","referrer":null,"previous":"FREE"},` +
+		`"previous":"FREE"}`
+	if string(decoded) != expected {
+		t.Errorf("Expected %s, got %s", expected, decoded)
+	}
+
+	if _, err := decodeAvro(schema, data[:len(data)-1]); err == nil {
+		t.Error("Expected an error for a truncated value")
+	}
+	if _, err := decodeAvro(schema, append(data, 0)); err == nil {
+		t.Error("Expected an error for trailing bytes")
+	}
+}
+
+func TestParseAvroSchemaErrors(t *testing.T) {
+	for _, schema := range []string{`"uuid"`, `{"type": "record", "fields": []}`, `{"type": "array", "items": "Unknown"}`, `{`} {
+		if _, err := parseAvroSchema([]byte(schema)); err == nil {
+			t.Errorf("Expected schema %s to be invalid", schema)
+		}
+	}
+
+	// Logical types are decoded as their underlying type
+	schema, err := parseAvroSchema([]byte(`{"type": "long", "logicalType": "timestamp-millis"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decoded, err := decodeAvro(schema, avroLong(1500000000000)); err != nil || string(decoded) != "1500000000000" {
+		t.Errorf("Expected the timestamp as a long, got %s (%v)", decoded, err)
+	}
+}
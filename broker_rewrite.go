@@ -0,0 +1,60 @@
+package kafkatools
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// ParseBrokerRewrites parses old=new broker address rewrites, the addresses are either host:port or a bare host
+func ParseBrokerRewrites(rewrites []string) (map[string]string, error) {
+	parsed := make(map[string]string)
+	for _, rewrite := range rewrites {
+		parts := strings.SplitN(rewrite, "=", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("invalid broker rewrite %q, expected <old>=<new>", rewrite)
+		}
+		parsed[parts[0]] = parts[1]
+	}
+	return parsed, nil
+}
+
+// rewritingDialer connects to the rewritten address of a broker, which is needed when the advertised listeners of
+// the brokers are not reachable (for example when port forwarding into a cluster)
+type rewritingDialer struct {
+	dialer   net.Dialer
+	rewrites map[string]string
+}
+
+func newRewritingDialer(rewrites map[string]string, timeout, keepAlive time.Duration) *rewritingDialer {
+	return &rewritingDialer{dialer: net.Dialer{Timeout: timeout, KeepAlive: keepAlive}, rewrites: rewrites}
+}
+
+// Dial connects to the rewritten address
+func (d *rewritingDialer) Dial(network, addr string) (net.Conn, error) {
+	return d.dialer.Dial(network, d.rewrite(addr))
+}
+
+// rewrite looks up the full address first and falls back to a rewrite of the host, keeping the port
+func (d *rewritingDialer) rewrite(addr string) string {
+	if rewritten, ok := d.rewrites[addr]; ok {
+		return rewritten
+	}
+
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+	if rewritten, ok := d.rewrites[host]; ok {
+		if _, _, err := net.SplitHostPort(rewritten); err == nil {
+			return rewritten
+		}
+		return net.JoinHostPort(rewritten, port)
+	}
+	return addr
+}
+
+func (d *rewritingDialer) String() string {
+	return fmt.Sprintf("broker rewrites %v", d.rewrites)
+}
@@ -0,0 +1,35 @@
+package kafkatools
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRewritingDialer(t *testing.T) {
+	rewrites, err := ParseBrokerRewrites([]string{"kafka-0.internal:9092=localhost:19092", "kafka-1.internal=127.0.0.1"})
+	if err != nil {
+		t.Fatal("Unexpected error: ", err)
+	}
+
+	dialer := newRewritingDialer(rewrites, time.Second, time.Second)
+	tests := map[string]string{
+		"kafka-0.internal:9092": "localhost:19092",
+		"kafka-0.internal:9093": "kafka-0.internal:9093",
+		"kafka-1.internal:9092": "127.0.0.1:9092",
+		"kafka-2.internal:9092": "kafka-2.internal:9092",
+	}
+
+	for addr, expected := range tests {
+		if rewritten := dialer.rewrite(addr); rewritten != expected {
+			t.Errorf("Expected %s to be rewritten to %s, got %s", addr, expected, rewritten)
+		}
+	}
+}
+
+func TestParseBrokerRewritesErrors(t *testing.T) {
+	for _, rewrite := range []string{"kafka:9092", "=localhost:9092", "kafka:9092="} {
+		if _, err := ParseBrokerRewrites([]string{rewrite}); err == nil {
+			t.Errorf("Expected an error for %q", rewrite)
+		}
+	}
+}
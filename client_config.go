@@ -0,0 +1,259 @@
+package kafkatools
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"os"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/bsm/sarama-cluster"
+)
+
+// ClientConfig contains the connection settings for a kafka client
+type ClientConfig struct {
+	SASL SASLConfig
+	// TLS encrypts the connections to the brokers when set, see NewTLSConfig
+	TLS *tls.Config
+	// Version is the kafka version of the brokers, defaults to kafka 2.1. Set an older version to downgrade for old
+	// clusters, the settings below which depend on newer versions raise it.
+	Version sarama.KafkaVersion
+	// ClientID identifies the client in the logs, metrics and quotas of the brokers, sarama's default when empty. Only
+	// ASCII letters, digits, '.', '_' and '-' are allowed.
+	ClientID string
+	// IsolationLevel controls whether records of aborted and open transactions are returned, defaults to ReadUncommitted
+	IsolationLevel sarama.IsolationLevel
+	// BrokerRewrites maps broker addresses (host:port or host) to the addresses to connect to instead
+	BrokerRewrites map[string]string
+	Producer       ProducerConfig
+	// DescribeConfigSources raises the version to kafka 1.1, the first version reporting where config values come from
+	DescribeConfigSources bool
+	// GroupInstanceID makes the consumer groups join as the static member with this id, which raises the version to
+	// kafka 2.3
+	GroupInstanceID string
+	// ConsumerGroups raises the version to kafka 0.10.2, the oldest version supported by the sarama consumer groups
+	ConsumerGroups bool
+	// ManualCommits disables the automatic commits of the consumer groups, the marked offsets are only committed by
+	// ConsumerGroupSession.Commit
+	ManualCommits bool
+	// RecordHeaders raises the version to kafka 0.11, the first version returning the headers of consumed records
+	RecordHeaders bool
+}
+
+// ProducerConfig contains the settings of the producers created from the client
+type ProducerConfig struct {
+	Compression sarama.CompressionCodec
+	// Idempotent producers write every message exactly once, which requires acks from all in-sync replicas
+	Idempotent bool
+	// TransactionalID enables transactions, only idempotent producers can be transactional
+	TransactionalID string
+	// Acks are the acknowledgements a produce request waits for: none, leader or all. It defaults to leader, or all for
+	// idempotent producers.
+	Acks string
+	// Partitioner replaces the hash partitioner of sarama when set
+	Partitioner sarama.PartitionerConstructor
+	// RecordHeaders raises the version to kafka 0.11, the first version producing record headers
+	RecordHeaders bool
+}
+
+// RequiredAcks maps the acks settings to the acknowledgements the brokers send
+var RequiredAcks = map[string]sarama.RequiredAcks{
+	"none":   sarama.NoResponse,
+	"leader": sarama.WaitForLocal,
+	"all":    sarama.WaitForAll,
+}
+
+// Validate returns an error when the producer settings are inconsistent
+func (c ProducerConfig) Validate() error {
+	if c.TransactionalID != "" && !c.Idempotent {
+		return fmt.Errorf("transactional producers have to be idempotent")
+	}
+	if _, ok := RequiredAcks[c.Acks]; c.Acks != "" && !ok {
+		return fmt.Errorf("invalid acks %q, expected none, leader or all", c.Acks)
+	}
+	if c.Idempotent && c.Acks != "" && c.Acks != "all" {
+		return fmt.Errorf("idempotent producers have to wait for all acks")
+	}
+	return nil
+}
+
+// SASLConfig contains the SASL authentication settings
+type SASLConfig struct {
+	// Mechanism is the SASL mechanism to use, SASL is disabled when empty
+	Mechanism     sarama.SASLMechanism
+	TokenProvider sarama.AccessTokenProvider
+	// User and Password are the credentials of the PLAIN and SCRAM mechanisms
+	User, Password string
+}
+
+// NewTLSConfig returns the TLS settings verifying the brokers with the CA certificates of the PEM file, or with the
+// system certificates when caCertFile is empty. The client authenticates with the certificate and key of the PEM
+// files when they are given, insecureSkipVerify accepts any broker certificate.
+func NewTLSConfig(caCertFile, certFile, keyFile string, insecureSkipVerify bool) (*tls.Config, error) {
+	config := &tls.Config{InsecureSkipVerify: insecureSkipVerify}
+
+	if caCertFile != "" {
+		caCerts, err := os.ReadFile(caCertFile)
+		if err != nil {
+			return nil, err
+		}
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(caCerts) {
+			return nil, fmt.Errorf("no certificates found in %s", caCertFile)
+		}
+	}
+
+	if (certFile == "") != (keyFile == "") {
+		return nil, fmt.Errorf("the client certificate and key have to be given together")
+	}
+	if certFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, err
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return config, nil
+}
+
+// NewSaramaConfig generates the sarama configuration for the given client config
+func NewSaramaConfig(clientConfig *ClientConfig) *sarama.Config {
+	config := sarama.NewConfig()
+	config.Version = sarama.V2_1_0_0
+	config.Consumer.Return.Errors = true
+	config.Metadata.RefreshFrequency = 1 * time.Minute
+	config.Metadata.Retry.Max = 10
+	config.Net.MaxOpenRequests = 10
+	// Required by sync producers
+	config.Producer.Return.Successes = true
+	// The connections are dialed by an abortingDialer so the Context variants can abort their requests
+	config.Net.Proxy.Enable = true
+	config.Net.Proxy.Dialer = newAbortingDialer(&net.Dialer{Timeout: config.Net.DialTimeout, KeepAlive: config.Net.KeepAlive})
+
+	if clientConfig == nil {
+		return config
+	}
+
+	if clientConfig.Version != (sarama.KafkaVersion{}) {
+		config.Version = clientConfig.Version
+	}
+	if clientConfig.ClientID != "" {
+		config.ClientID = clientConfig.ClientID
+	}
+
+	if len(clientConfig.BrokerRewrites) > 0 {
+		config.Net.Proxy.Dialer = newAbortingDialer(newRewritingDialer(clientConfig.BrokerRewrites, config.Net.DialTimeout, config.Net.KeepAlive))
+	}
+
+	if clientConfig.IsolationLevel == sarama.ReadCommitted {
+		config.Consumer.IsolationLevel = sarama.ReadCommitted
+		// Transactions were introduced in kafka 0.11
+		if !config.Version.IsAtLeast(sarama.V0_11_0_0) {
+			config.Version = sarama.V0_11_0_0
+		}
+	}
+
+	config.Producer.Compression = clientConfig.Producer.Compression
+	if acks, ok := RequiredAcks[clientConfig.Producer.Acks]; ok {
+		config.Producer.RequiredAcks = acks
+	}
+	if clientConfig.Producer.Partitioner != nil {
+		config.Producer.Partitioner = clientConfig.Producer.Partitioner
+	}
+	if clientConfig.Producer.RecordHeaders && !config.Version.IsAtLeast(sarama.V0_11_0_0) {
+		config.Version = sarama.V0_11_0_0
+	}
+	if clientConfig.Producer.Idempotent {
+		config.Producer.Idempotent = true
+		config.Producer.RequiredAcks = sarama.WaitForAll
+		// Idempotence only guarantees ordering with a single in-flight request
+		config.Net.MaxOpenRequests = 1
+		config.Producer.Transaction.ID = clientConfig.Producer.TransactionalID
+		if !config.Version.IsAtLeast(sarama.V0_11_0_0) {
+			config.Version = sarama.V0_11_0_0
+		}
+	}
+	if clientConfig.RecordHeaders && !config.Version.IsAtLeast(sarama.V0_11_0_0) {
+		config.Version = sarama.V0_11_0_0
+	}
+	if clientConfig.DescribeConfigSources && !config.Version.IsAtLeast(sarama.V1_1_0_0) {
+		config.Version = sarama.V1_1_0_0
+	}
+	if clientConfig.ConsumerGroups && !config.Version.IsAtLeast(sarama.V0_10_2_0) {
+		config.Version = sarama.V0_10_2_0
+	}
+	if clientConfig.ManualCommits {
+		config.Consumer.Offsets.AutoCommit.Enable = false
+	}
+	if clientConfig.GroupInstanceID != "" {
+		config.Consumer.Group.InstanceId = clientConfig.GroupInstanceID
+		// Static membership was introduced in kafka 2.3
+		if !config.Version.IsAtLeast(sarama.V2_3_0_0) {
+			config.Version = sarama.V2_3_0_0
+		}
+	}
+	// Zstandard compression was introduced in kafka 2.1
+	if clientConfig.Producer.Compression == sarama.CompressionZSTD && !config.Version.IsAtLeast(sarama.V2_1_0_0) {
+		config.Version = sarama.V2_1_0_0
+	}
+
+	if clientConfig.SASL.Mechanism != "" {
+		config.Net.SASL.Enable = true
+		config.Net.SASL.Mechanism = clientConfig.SASL.Mechanism
+
+		switch clientConfig.SASL.Mechanism {
+		case sarama.SASLTypeOAuth:
+			config.Net.SASL.Version = sarama.SASLHandshakeV1
+			config.Net.SASL.TokenProvider = clientConfig.SASL.TokenProvider
+		case sarama.SASLTypeSCRAMSHA256, sarama.SASLTypeSCRAMSHA512:
+			config.Net.SASL.SCRAMClientGeneratorFunc = newSCRAMClientGenerator(clientConfig.SASL.Mechanism)
+		}
+		config.Net.SASL.User = clientConfig.SASL.User
+		config.Net.SASL.Password = clientConfig.SASL.Password
+	}
+
+	if clientConfig.TLS != nil {
+		config.Net.TLS.Enable = true
+		config.Net.TLS.Config = clientConfig.TLS
+	}
+
+	return config
+}
+
+// GetSaramaClientWithConfig sets up a kafka client using the given client config
+func GetSaramaClientWithConfig(clientConfig *ClientConfig, brokers ...string) (sarama.Client, error) {
+	return sarama.NewClient(brokers, NewSaramaConfig(clientConfig))
+}
+
+// GetClusterAdmin sets up a kafka cluster admin
+func GetClusterAdmin(brokers ...string) (sarama.ClusterAdmin, error) {
+	return GetClusterAdminWithConfig(nil, brokers...)
+}
+
+// GetClusterAdminWithConfig sets up a kafka cluster admin using the given client config
+func GetClusterAdminWithConfig(clientConfig *ClientConfig, brokers ...string) (sarama.ClusterAdmin, error) {
+	return sarama.NewClusterAdmin(brokers, NewSaramaConfig(clientConfig))
+}
+
+// GetClusterAdminFromClient returns a cluster admin sharing the connections and settings of the client, closing the
+// admin closes the client as well
+func GetClusterAdminFromClient(client sarama.Client) (sarama.ClusterAdmin, error) {
+	return sarama.NewClusterAdminFromClient(client)
+}
+
+// GetSaramaConsumerWithConfig returns a high-level kafka consumer using the given client config
+func GetSaramaConsumerWithConfig(clientConfig *ClientConfig, consumerGroup string, topics []string, brokers ...string) (*cluster.Consumer, error) {
+	config := cluster.NewConfig()
+	config.Config = *NewSaramaConfig(clientConfig)
+	config.Group.Return.Notifications = true
+	config.Consumer.Offsets.Initial = sarama.OffsetNewest
+
+	consumer, err := cluster.NewConsumer(brokers, consumerGroup, topics, config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start consumer: %v", err)
+	}
+
+	return consumer, nil
+}
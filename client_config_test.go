@@ -0,0 +1,281 @@
+package kafkatools
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+)
+
+func TestNewSaramaConfigIsolationLevel(t *testing.T) {
+	config := NewSaramaConfig(&ClientConfig{IsolationLevel: sarama.ReadCommitted})
+
+	if config.Consumer.IsolationLevel != sarama.ReadCommitted {
+		t.Errorf("Expected the read committed isolation level, got %v", config.Consumer.IsolationLevel)
+	}
+	if err := config.Validate(); err != nil {
+		t.Errorf("Expected a valid config, got %v", err)
+	}
+
+	if config := NewSaramaConfig(nil); config.Consumer.IsolationLevel != sarama.ReadUncommitted {
+		t.Errorf("Expected the read uncommitted isolation level by default, got %v", config.Consumer.IsolationLevel)
+	}
+}
+
+func TestNewSaramaConfigProducer(t *testing.T) {
+	config := NewSaramaConfig(&ClientConfig{Producer: ProducerConfig{Compression: sarama.CompressionZSTD}})
+
+	if config.Producer.Compression != sarama.CompressionZSTD {
+		t.Errorf("Expected zstd compression, got %v", config.Producer.Compression)
+	}
+	if err := config.Validate(); err != nil {
+		t.Errorf("Expected a valid config, got %v", err)
+	}
+}
+
+func TestNewSaramaConfigTransactional(t *testing.T) {
+	producerConfig := ProducerConfig{Idempotent: true, TransactionalID: "kt"}
+	if err := producerConfig.Validate(); err != nil {
+		t.Fatal("Unexpected error: ", err)
+	}
+
+	config := NewSaramaConfig(&ClientConfig{Producer: producerConfig})
+	if !config.Producer.Idempotent || config.Producer.RequiredAcks != sarama.WaitForAll || config.Net.MaxOpenRequests != 1 || config.Producer.Transaction.ID != "kt" {
+		t.Errorf("Expected an idempotent transactional producer, got %+v", config.Producer)
+	}
+	if err := config.Validate(); err != nil {
+		t.Errorf("Expected a valid config, got %v", err)
+	}
+
+	if err := (ProducerConfig{TransactionalID: "kt"}).Validate(); err == nil {
+		t.Error("Expected an error for a transactional producer which is not idempotent")
+	}
+}
+
+func TestNewSaramaConfigAcks(t *testing.T) {
+	if config := NewSaramaConfig(&ClientConfig{}); config.Producer.RequiredAcks != sarama.WaitForLocal {
+		t.Errorf("Expected to wait for the leader by default, got %v", config.Producer.RequiredAcks)
+	}
+	if config := NewSaramaConfig(&ClientConfig{Producer: ProducerConfig{Acks: "none"}}); config.Producer.RequiredAcks != sarama.NoResponse {
+		t.Errorf("Expected not to wait for acks, got %v", config.Producer.RequiredAcks)
+	}
+
+	if err := (ProducerConfig{Acks: "some"}).Validate(); err == nil {
+		t.Error("Expected an error for invalid acks")
+	}
+	if err := (ProducerConfig{Idempotent: true, Acks: "leader"}).Validate(); err == nil {
+		t.Error("Expected an error for an idempotent producer which does not wait for all acks")
+	}
+	if err := (ProducerConfig{Idempotent: true, Acks: "all"}).Validate(); err != nil {
+		t.Errorf("Expected a valid idempotent producer, got %v", err)
+	}
+}
+
+func TestNewSaramaConfigDescribeConfigSources(t *testing.T) {
+	if config := NewSaramaConfig(&ClientConfig{DescribeConfigSources: true}); !config.Version.IsAtLeast(sarama.V1_1_0_0) {
+		t.Errorf("Expected at least version 1.1, got %v", config.Version)
+	}
+
+	config := NewSaramaConfig(&ClientConfig{DescribeConfigSources: true, Producer: ProducerConfig{Compression: sarama.CompressionZSTD}})
+	if config.Version != sarama.V2_1_0_0 {
+		t.Errorf("Expected version 2.1, got %v", config.Version)
+	}
+}
+
+func TestNewSaramaConfigRecordHeaders(t *testing.T) {
+	partitioner := sarama.NewManualPartitioner
+	config := NewSaramaConfig(&ClientConfig{Version: sarama.V0_10_1_0, Producer: ProducerConfig{Partitioner: partitioner, RecordHeaders: true}})
+
+	if !config.Version.IsAtLeast(sarama.V0_11_0_0) {
+		t.Errorf("Expected at least kafka 0.11 to produce headers, got %v", config.Version)
+	}
+	if config.Producer.Partitioner == nil {
+		t.Error("Expected the partitioner to be set")
+	}
+	if config := NewSaramaConfig(&ClientConfig{Version: sarama.V0_10_1_0}); config.Version != sarama.V0_10_1_0 {
+		t.Errorf("Expected version %v without headers, got %v", sarama.V0_10_1_0, config.Version)
+	}
+}
+
+func TestNewSaramaConfigConsumedRecordHeaders(t *testing.T) {
+	if config := NewSaramaConfig(&ClientConfig{RecordHeaders: true, Version: sarama.V0_10_1_0}); !config.Version.IsAtLeast(sarama.V0_11_0_0) {
+		t.Errorf("Expected at least kafka 0.11 to consume headers, got %v", config.Version)
+	}
+	config := NewSaramaConfig(&ClientConfig{RecordHeaders: true, Version: sarama.V2_0_0_0})
+	if config.Version != sarama.V2_0_0_0 {
+		t.Errorf("Expected version %v to be kept, got %v", sarama.V2_0_0_0, config.Version)
+	}
+}
+
+func TestNewSaramaConfigGroupInstanceID(t *testing.T) {
+	config := NewSaramaConfig(&ClientConfig{GroupInstanceID: "debug-1"})
+
+	if config.Consumer.Group.InstanceId != "debug-1" {
+		t.Errorf("Expected group instance id debug-1, got %q", config.Consumer.Group.InstanceId)
+	}
+	if config.Version != sarama.V2_3_0_0 {
+		t.Errorf("Expected version 2.3 for static membership, got %v", config.Version)
+	}
+	if err := config.Validate(); err != nil {
+		t.Errorf("Expected a valid config, got %v", err)
+	}
+}
+
+func TestNewSaramaConfigConsumerGroups(t *testing.T) {
+	if config := NewSaramaConfig(&ClientConfig{ConsumerGroups: true, Version: sarama.V0_10_1_0}); config.Version != sarama.V0_10_2_0 {
+		t.Errorf("Expected version 0.10.2 for consumer groups, got %v", config.Version)
+	}
+	if config := NewSaramaConfig(&ClientConfig{ConsumerGroups: true, Version: sarama.V2_8_0_0}); config.Version != sarama.V2_8_0_0 {
+		t.Errorf("Expected version %v to be kept, got %v", sarama.V2_8_0_0, config.Version)
+	}
+}
+
+func TestNewSaramaConfigManualCommits(t *testing.T) {
+	if config := NewSaramaConfig(&ClientConfig{ConsumerGroups: true}); !config.Consumer.Offsets.AutoCommit.Enable {
+		t.Error("Expected the offsets to be committed automatically by default")
+	}
+	if config := NewSaramaConfig(&ClientConfig{ConsumerGroups: true, ManualCommits: true}); config.Consumer.Offsets.AutoCommit.Enable {
+		t.Error("Expected the automatic commits to be disabled")
+	}
+}
+
+func TestNewSaramaConfigClientID(t *testing.T) {
+	if config := NewSaramaConfig(&ClientConfig{}); config.ClientID != "sarama" {
+		t.Errorf("Expected the default client id of sarama, got %s", config.ClientID)
+	}
+	config := NewSaramaConfig(&ClientConfig{ClientID: "kt-1.0"})
+	if config.ClientID != "kt-1.0" {
+		t.Errorf("Expected client id kt-1.0, got %s", config.ClientID)
+	}
+	if err := config.Validate(); err != nil {
+		t.Errorf("Expected a valid config, got %v", err)
+	}
+}
+
+func TestNewSaramaConfigVersion(t *testing.T) {
+	for _, clientConfig := range []*ClientConfig{nil, {}} {
+		if config := NewSaramaConfig(clientConfig); config.Version != sarama.V2_1_0_0 {
+			t.Errorf("Expected version %v by default, got %v", sarama.V2_1_0_0, config.Version)
+		}
+	}
+	// Older clusters can downgrade the version
+	if config := NewSaramaConfig(&ClientConfig{Version: sarama.V0_10_1_0}); config.Version != sarama.V0_10_1_0 {
+		t.Errorf("Expected version %v, got %v", sarama.V0_10_1_0, config.Version)
+	}
+	if config := NewSaramaConfig(&ClientConfig{Version: sarama.V2_8_0_0}); config.Version != sarama.V2_8_0_0 {
+		t.Errorf("Expected version %v, got %v", sarama.V2_8_0_0, config.Version)
+	}
+
+	// Settings requiring newer versions still raise it
+	config := NewSaramaConfig(&ClientConfig{Version: sarama.V0_11_0_0, DescribeConfigSources: true})
+	if config.Version != sarama.V1_1_0_0 {
+		t.Errorf("Expected version %v, got %v", sarama.V1_1_0_0, config.Version)
+	}
+}
+
+func TestGetClusterAdminWithConfig(t *testing.T) {
+	broker := sarama.NewMockBroker(t, 1)
+	defer broker.Close()
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest":    sarama.NewMockMetadataResponse(t).SetController(broker.BrokerID()).SetBroker(broker.Addr(), broker.BrokerID()),
+		"ApiVersionsRequest": sarama.NewMockApiVersionsResponse(t),
+	})
+
+	admin, err := GetClusterAdminWithConfig(&ClientConfig{Version: sarama.V1_0_0_0}, broker.Addr())
+	if err != nil {
+		t.Fatal("Unexpected error: ", err)
+	}
+	if err := admin.Close(); err != nil {
+		t.Error("Unexpected error closing the admin: ", err)
+	}
+}
+
+func TestNewSaramaConfigSASLSCRAM(t *testing.T) {
+	config := NewSaramaConfig(&ClientConfig{SASL: SASLConfig{Mechanism: sarama.SASLTypeSCRAMSHA512, User: "user", Password: "pencil"}})
+
+	if !config.Net.SASL.Enable || config.Net.SASL.User != "user" || config.Net.SASL.Password != "pencil" {
+		t.Errorf("Expected SASL with the credentials, got %+v", config.Net.SASL)
+	}
+	if config.Net.SASL.SCRAMClientGeneratorFunc == nil {
+		t.Error("Expected a SCRAM client generator")
+	}
+	if err := config.Validate(); err != nil {
+		t.Errorf("Expected a valid config, got %v", err)
+	}
+}
+
+func TestNewSaramaConfigTLS(t *testing.T) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: true}
+	config := NewSaramaConfig(&ClientConfig{TLS: tlsConfig})
+
+	if !config.Net.TLS.Enable || config.Net.TLS.Config != tlsConfig {
+		t.Errorf("Expected TLS with the given settings, got %+v", config.Net.TLS)
+	}
+	if NewSaramaConfig(nil).Net.TLS.Enable {
+		t.Error("Expected TLS to be disabled by default")
+	}
+}
+
+func TestNewTLSConfig(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	writeTestCertificate(t, certFile, keyFile)
+
+	config, err := NewTLSConfig(certFile, certFile, keyFile, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.RootCAs == nil || len(config.Certificates) != 1 || config.InsecureSkipVerify {
+		t.Errorf("Expected the CA and client certificate, got %+v", config)
+	}
+
+	if config, err := NewTLSConfig("", "", "", true); err != nil || config.RootCAs != nil || !config.InsecureSkipVerify {
+		t.Errorf("Expected the system CAs without verification, got %+v, %v", config, err)
+	}
+	for _, files := range [][3]string{{filepath.Join(dir, "missing.pem"), "", ""}, {keyFile, "", ""}, {"", certFile, ""}} {
+		if _, err := NewTLSConfig(files[0], files[1], files[2], false); err == nil {
+			t.Errorf("Expected an error for %v", files)
+		}
+	}
+}
+
+// writeTestCertificate writes a self-signed certificate and its key as PEM files
+func writeTestCertificate(t *testing.T, certFile, keyFile string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "kafka"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	cert, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyBytes, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyBytes}), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
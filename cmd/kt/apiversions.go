@@ -0,0 +1,224 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+
+	"github.com/Shopify/sarama"
+	"github.com/jurriaan/kafkatools"
+)
+
+// apiKeyNames are the names of the Kafka protocol APIs
+var apiKeyNames = []string{
+	"Produce", "Fetch", "ListOffsets", "Metadata", "LeaderAndIsr", "StopReplica", "UpdateMetadata",
+	"ControlledShutdown", "OffsetCommit", "OffsetFetch", "FindCoordinator", "JoinGroup", "Heartbeat", "LeaveGroup",
+	"SyncGroup", "DescribeGroups", "ListGroups", "SaslHandshake", "ApiVersions", "CreateTopics", "DeleteTopics",
+	"DeleteRecords", "InitProducerId", "OffsetForLeaderEpoch", "AddPartitionsToTxn", "AddOffsetsToTxn", "EndTxn",
+	"WriteTxnMarkers", "TxnOffsetCommit", "DescribeAcls", "CreateAcls", "DeleteAcls", "DescribeConfigs",
+	"AlterConfigs", "AlterReplicaLogDirs", "DescribeLogDirs", "SaslAuthenticate", "CreatePartitions",
+	"CreateDelegationToken", "RenewDelegationToken", "ExpireDelegationToken", "DescribeDelegationToken",
+	"DeleteGroups", "ElectLeaders", "IncrementalAlterConfigs", "AlterPartitionReassignments",
+	"ListPartitionReassignments", "OffsetDelete", "DescribeClientQuotas", "AlterClientQuotas",
+	"DescribeUserScramCredentials", "AlterUserScramCredentials", "Vote", "BeginQuorumEpoch", "EndQuorumEpoch",
+	"DescribeQuorum", "AlterPartition", "UpdateFeatures", "Envelope", "FetchSnapshot", "DescribeCluster",
+	"DescribeProducers", "BrokerRegistration", "BrokerHeartbeat", "UnregisterBroker", "DescribeTransactions",
+	"ListTransactions", "AllocateProducerIds",
+}
+
+// apiVersions prints the API version ranges supported by every broker, followed by the APIs whose ranges differ
+// between the brokers (e.g. during a rolling upgrade). Brokers which cannot be queried are reported and skipped.
+func apiVersions(client sarama.Client) {
+	fmt.Printf("client version: %s\n", client.Config().Version)
+
+	brokers := client.Brokers()
+	sort.Slice(brokers, func(i, j int) bool { return brokers[i].ID() < brokers[j].ID() })
+
+	versions := make(map[int32][]sarama.ApiVersionsResponseKey, len(brokers))
+	for _, broker := range brokers {
+		apiKeys, err := fetchAPIVersions(client, broker)
+		if err != nil {
+			log.Printf("Could not fetch the API versions of broker %d (%s): %v", broker.ID(), broker.Addr(), err)
+			continue
+		}
+		versions[broker.ID()] = apiKeys
+
+		fmt.Printf("broker %d (%s):\n", broker.ID(), broker.Addr())
+		for _, apiKey := range apiKeys {
+			fmt.Printf("  %s\n", formatAPIKey(apiKey))
+		}
+	}
+
+	for _, line := range formatAPIMismatches(versions) {
+		fmt.Println(line)
+	}
+}
+
+func fetchAPIVersions(client sarama.Client, broker *sarama.Broker) ([]sarama.ApiVersionsResponseKey, error) {
+	if err := broker.Open(client.Config()); err != nil && err != sarama.ErrAlreadyConnected {
+		return nil, err
+	}
+
+	response, err := broker.ApiVersions(&sarama.ApiVersionsRequest{})
+	if err != nil {
+		return nil, err
+	}
+	if kerr := sarama.KError(response.ErrorCode); kerr != sarama.ErrNoError {
+		return nil, kerr
+	}
+
+	apiKeys := response.ApiKeys
+	sort.Slice(apiKeys, func(i, j int) bool { return apiKeys[i].ApiKey < apiKeys[j].ApiKey })
+	return apiKeys, nil
+}
+
+func formatAPIKey(apiKey sarama.ApiVersionsResponseKey) string {
+	return fmt.Sprintf("%s (%d): v%d-v%d", apiKeyName(apiKey.ApiKey), apiKey.ApiKey, apiKey.MinVersion, apiKey.MaxVersion)
+}
+
+func apiKeyName(key int16) string {
+	if key >= 0 && int(key) < len(apiKeyNames) {
+		return apiKeyNames[key]
+	}
+	return "Unknown"
+}
+
+// formatAPIMismatches lists the APIs which are not supported by every broker or with different version ranges
+func formatAPIMismatches(versions map[int32][]sarama.ApiVersionsResponseKey) []string {
+	ids := make([]int32, 0, len(versions))
+	for id := range versions {
+		ids = append(ids, id)
+	}
+	ids = sortedInt32s(ids)
+
+	ranges := make(map[int16]map[int32]string)
+	for _, id := range ids {
+		for _, apiKey := range versions[id] {
+			if ranges[apiKey.ApiKey] == nil {
+				ranges[apiKey.ApiKey] = make(map[int32]string)
+			}
+			ranges[apiKey.ApiKey][id] = fmt.Sprintf("v%d-v%d", apiKey.MinVersion, apiKey.MaxVersion)
+		}
+	}
+
+	keys := make([]int, 0, len(ranges))
+	for key := range ranges {
+		keys = append(keys, int(key))
+	}
+	sort.Ints(keys)
+
+	var lines []string
+	for _, key := range keys {
+		brokerRanges := ranges[int16(key)]
+		same := len(brokerRanges) == len(ids)
+		for _, id := range ids {
+			if brokerRanges[id] != brokerRanges[ids[0]] {
+				same = false
+			}
+		}
+		if same {
+			continue
+		}
+
+		line := fmt.Sprintf("  %s (%d):", apiKeyName(int16(key)), key)
+		for _, id := range ids {
+			version, ok := brokerRanges[id]
+			if !ok {
+				version = "unsupported"
+			}
+			line += fmt.Sprintf(" broker %d %s", id, version)
+		}
+		lines = append(lines, line)
+	}
+
+	if len(lines) == 0 {
+		return nil
+	}
+	return append([]string{"mismatches:"}, lines...)
+}
+
+// fetchVersionReleases are the kafka releases which raised the highest version of the fetch API, newest first
+var fetchVersionReleases = []struct {
+	fetch   int16
+	version sarama.KafkaVersion
+}{
+	{13, sarama.V3_1_0_0},
+	{12, sarama.V2_7_0_0},
+	{11, sarama.V2_3_0_0},
+	{10, sarama.V2_1_0_0},
+	{8, sarama.V2_0_0_0},
+	{7, sarama.V1_1_0_0},
+	{6, sarama.V1_0_0_0},
+	{5, sarama.V0_11_0_0},
+	{3, sarama.V0_10_1_0},
+	{2, sarama.V0_10_0_0},
+}
+
+// detectKafkaVersion returns the newest kafka version all brokers support, derived from the highest fetch API version
+// of every broker. It returns false when no broker reported its API versions or a broker is older than kafka 0.10.
+func detectKafkaVersion(versions map[int32][]sarama.ApiVersionsResponseKey) (sarama.KafkaVersion, bool) {
+	if len(versions) == 0 {
+		return sarama.KafkaVersion{}, false
+	}
+
+	detected := sarama.MaxVersion
+	for _, apiKeys := range versions {
+		fetch := int16(-1)
+		for _, apiKey := range apiKeys {
+			// Fetch
+			if apiKey.ApiKey == 1 {
+				fetch = apiKey.MaxVersion
+			}
+		}
+
+		var brokerVersion sarama.KafkaVersion
+		for _, release := range fetchVersionReleases {
+			if fetch >= release.fetch {
+				brokerVersion = release.version
+				break
+			}
+		}
+		if brokerVersion == (sarama.KafkaVersion{}) {
+			return sarama.KafkaVersion{}, false
+		}
+		if !brokerVersion.IsAtLeast(detected) {
+			detected = brokerVersion
+		}
+	}
+	return detected, true
+}
+
+// probeKafkaVersion fetches the API versions of the brokers and returns the kafka version they all support
+func probeKafkaVersion(client sarama.Client) (sarama.KafkaVersion, bool) {
+	versions := make(map[int32][]sarama.ApiVersionsResponseKey)
+	for _, broker := range client.Brokers() {
+		apiKeys, err := fetchAPIVersions(client, broker)
+		if err != nil {
+			log.Printf("Could not fetch the API versions of broker %d (%s): %v", broker.ID(), broker.Addr(), err)
+			continue
+		}
+		versions[broker.ID()] = apiKeys
+	}
+	return detectKafkaVersion(versions)
+}
+
+// withDetectedVersion returns a client using the kafka version of the brokers, the client is reconnected when the
+// version differs from the one it started with
+func withDetectedVersion(client sarama.Client, clientConfig kafkatools.ClientConfig, brokers []string) sarama.Client {
+	version, ok := probeKafkaVersion(client)
+	if !ok {
+		kafkatools.Log.Warnf("Could not detect the kafka version, using %s", client.Config().Version)
+		return client
+	}
+
+	kafkatools.Log.Infof("Detected kafka version %s", version)
+	clientConfig.Version = version
+	if kafkatools.NewSaramaConfig(&clientConfig).Version == client.Config().Version {
+		return client
+	}
+
+	if err := client.Close(); err != nil {
+		log.Println("Error closing the client: ", err)
+	}
+	return newClient(&clientConfig, brokers...)
+}
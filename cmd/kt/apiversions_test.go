@@ -0,0 +1,60 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/Shopify/sarama"
+)
+
+func TestFormatAPIMismatches(t *testing.T) {
+	versions := map[int32][]sarama.ApiVersionsResponseKey{
+		1: {{ApiKey: 0, MinVersion: 0, MaxVersion: 9}, {ApiKey: 1, MinVersion: 0, MaxVersion: 13}, {ApiKey: 68, MinVersion: 0, MaxVersion: 0}},
+		2: {{ApiKey: 0, MinVersion: 0, MaxVersion: 9}, {ApiKey: 1, MinVersion: 0, MaxVersion: 12}},
+	}
+
+	expected := []string{
+		"mismatches:",
+		"  Fetch (1): broker 1 v0-v13 broker 2 v0-v12",
+		"  Unknown (68): broker 1 v0-v0 broker 2 unsupported",
+	}
+	if lines := formatAPIMismatches(versions); !reflect.DeepEqual(lines, expected) {
+		t.Errorf("Expected %q, got %q", expected, lines)
+	}
+
+	delete(versions, 1)
+	if lines := formatAPIMismatches(versions); lines != nil {
+		t.Errorf("Expected no mismatches for a single broker, got %q", lines)
+	}
+}
+
+func TestFormatAPIKey(t *testing.T) {
+	if formatted := formatAPIKey(sarama.ApiVersionsResponseKey{ApiKey: 18, MinVersion: 0, MaxVersion: 3}); formatted != "ApiVersions (18): v0-v3" {
+		t.Errorf("Expected the API name and versions, got %q", formatted)
+	}
+}
+
+func TestDetectKafkaVersion(t *testing.T) {
+	fetch := func(maxVersion int16) []sarama.ApiVersionsResponseKey {
+		return []sarama.ApiVersionsResponseKey{{ApiKey: 0, MaxVersion: 9}, {ApiKey: 1, MaxVersion: maxVersion}}
+	}
+
+	tests := []struct {
+		versions map[int32][]sarama.ApiVersionsResponseKey
+		expected sarama.KafkaVersion
+		ok       bool
+	}{
+		{map[int32][]sarama.ApiVersionsResponseKey{1: fetch(13)}, sarama.V3_1_0_0, true},
+		// A rolling upgrade uses the version of the oldest broker
+		{map[int32][]sarama.ApiVersionsResponseKey{1: fetch(12), 2: fetch(9)}, sarama.V2_0_0_0, true},
+		{map[int32][]sarama.ApiVersionsResponseKey{1: fetch(4)}, sarama.V0_10_1_0, true},
+		{map[int32][]sarama.ApiVersionsResponseKey{1: fetch(1)}, sarama.KafkaVersion{}, false},
+		{map[int32][]sarama.ApiVersionsResponseKey{}, sarama.KafkaVersion{}, false},
+	}
+
+	for _, test := range tests {
+		if version, ok := detectKafkaVersion(test.versions); version != test.expected || ok != test.ok {
+			t.Errorf("Expected %v (%v) for %v, got %v (%v)", test.expected, test.ok, test.versions, version, ok)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/Shopify/sarama"
+	"github.com/jurriaan/kafkatools"
+)
+
+// assertMessages exits with an error unless the bounded range contains the expected number of (matching) messages
+func assertMessages(client sarama.Client, parsedOptions options) {
+	partitionOffsets, endOffsets, _ := fetchTopicsPartitionOffsets(client, parsedOptions)
+
+	consumer, err := sarama.NewConsumerFromClient(client)
+	if err != nil {
+		log.Fatalf("Could not start consumer: %v", err)
+	}
+
+	messages, closing := consumeTopics(consumer, partitionOffsets, endOffsets, parsedOptions.consumeOpts)
+	stop := shutdownHandler(closing, parsedOptions)
+	found := countMatches(messages, parsedOptions.minCount, parsedOptions.countExact)
+	stop()
+	drainMessages(messages, parsedOptions.drainTimeout)
+
+	if err := checkMatches(found, parsedOptions.minCount, parsedOptions.countExact); err != nil {
+		log.Fatalf("Assertion failed for %v: %v", parsedOptions.topics, err)
+	}
+	kafkatools.Log.Infof("Assertion passed for %v: found %d matching messages", parsedOptions.topics, found)
+}
+
+// countMatches counts the messages until the outcome is known: at least minCount messages were found or, when exact,
+// more than minCount
+func countMatches(messages <-chan *sarama.ConsumerMessage, minCount int, exact bool) (found int) {
+	for range messages {
+		found++
+		if (!exact && found >= minCount) || (exact && found > minCount) {
+			break
+		}
+	}
+	return found
+}
+
+// checkMatches returns an error describing the mismatch unless found is at least (or, when exact, exactly) minCount
+func checkMatches(found, minCount int, exact bool) error {
+	if exact && found > minCount {
+		return fmt.Errorf("found more than %d matching messages, expected exactly %d", minCount, minCount)
+	}
+	if found < minCount {
+		expected := "at least"
+		if exact {
+			expected = "exactly"
+		}
+		return fmt.Errorf("found %d matching messages, expected %s %d", found, expected, minCount)
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/Shopify/sarama"
+)
+
+func TestCountMatches(t *testing.T) {
+	newMessages := func(n int) chan *sarama.ConsumerMessage {
+		messages := make(chan *sarama.ConsumerMessage, n)
+		for i := 0; i < n; i++ {
+			messages <- &sarama.ConsumerMessage{Offset: int64(i)}
+		}
+		close(messages)
+		return messages
+	}
+
+	if found := countMatches(newMessages(10), 3, false); found != 3 {
+		t.Errorf("Expected to stop after 3 messages, counted %d", found)
+	}
+	if found := countMatches(newMessages(10), 3, true); found != 4 {
+		t.Errorf("Expected to stop after the fourth message, counted %d", found)
+	}
+	if found := countMatches(newMessages(2), 3, false); found != 2 {
+		t.Errorf("Expected to count all 2 messages, counted %d", found)
+	}
+}
+
+func TestCheckMatches(t *testing.T) {
+	tests := []struct {
+		found, minCount int
+		exact           bool
+		expected        string
+	}{
+		{5, 5, false, ""},
+		{6, 5, false, ""},
+		{4, 5, false, "found 4 matching messages, expected at least 5"},
+		{5, 5, true, ""},
+		{4, 5, true, "found 4 matching messages, expected exactly 5"},
+		{6, 5, true, "found more than 5 matching messages, expected exactly 5"},
+	}
+
+	for _, test := range tests {
+		err := checkMatches(test.found, test.minCount, test.exact)
+		if (err == nil && test.expected != "") || (err != nil && err.Error() != test.expected) {
+			t.Errorf("Expected %q for %d of %d (exact %v), got %v", test.expected, test.found, test.minCount, test.exact, err)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Shopify/sarama"
+	cluster "github.com/bsm/sarama-cluster"
+	"github.com/jurriaan/kafkatools"
+)
+
+// assignmentTimeout bounds the time spent waiting for the group to rebalance
+const assignmentTimeout = 2 * time.Minute
+
+// printGroupAssignment joins the consumer group, prints the partitions of the topic assigned to this member by
+// the coordinator and leaves the group again without consuming or committing anything. Static members don't leave,
+// they keep their partitions until their session times out.
+func printGroupAssignment(client sarama.Client, parsedOptions options) {
+	partitions, err := client.Partitions(parsedOptions.topic)
+	if err != nil {
+		log.Fatalf("Could not fetch the partitions of topic %s: %v", parsedOptions.topic, err)
+	}
+
+	var claimed map[string][]int32
+	if instanceID := parsedOptions.clientConfig.GroupInstanceID; instanceID != "" {
+		kafkatools.Log.Infof("Joining group %s as static member %s", parsedOptions.group, instanceID)
+		claimed, err = awaitStaticAssignment(client, parsedOptions.group, parsedOptions.topic, assignmentTimeout)
+	} else {
+		kafkatools.Log.Infof("Joining group %s", parsedOptions.group)
+		consumer, err := kafkatools.GetSaramaConsumerWithConfig(&parsedOptions.clientConfig, parsedOptions.group, []string{parsedOptions.topic}, parsedOptions.brokers...)
+		if err != nil {
+			log.Fatal("Could not join the group: ", err)
+		}
+		defer func() {
+			if err := consumer.Close(); err != nil {
+				log.Println("Error closing the consumer: ", err)
+			}
+		}()
+
+		claimed, err = awaitAssignment(consumer, assignmentTimeout)
+	}
+	if err != nil {
+		log.Fatal("Could not join the group: ", err)
+	}
+	fmt.Println(formatGroupAssignment(parsedOptions.group, parsedOptions.topic, claimed[parsedOptions.topic], len(partitions)))
+}
+
+// awaitAssignment waits for the first successful rebalance and returns the partitions claimed by the consumer
+func awaitAssignment(consumer *cluster.Consumer, timeout time.Duration) (map[string][]int32, error) {
+	deadline := time.After(timeout)
+	for {
+		select {
+		case notification, ok := <-consumer.Notifications():
+			if !ok {
+				return nil, fmt.Errorf("consumer closed before the partitions were assigned")
+			}
+			kafkatools.Log.Infof("Group %s", notification.Type)
+			if notification.Type == cluster.RebalanceOK {
+				return notification.Current, nil
+			}
+		case err := <-consumer.Errors():
+			kafkatools.Log.Errorf("error: we got an error while joining the group: %v", err)
+		case <-deadline:
+			return nil, fmt.Errorf("no partitions assigned within %s", timeout)
+		}
+	}
+}
+
+// awaitStaticAssignment joins the group as the static member configured on the client and returns the partitions
+// claimed by it. The sarama-cluster consumer predates static membership, so this joins with a sarama consumer group,
+// which doesn't leave the group when it is closed: the member keeps its partitions until its session times out.
+func awaitStaticAssignment(client sarama.Client, group, topic string, timeout time.Duration) (map[string][]int32, error) {
+	consumerGroup, err := sarama.NewConsumerGroupFromClient(group, client)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := consumerGroup.Close(); err != nil {
+			log.Println("Error closing the consumer group: ", err)
+		}
+	}()
+	go func() {
+		for err := range consumerGroup.Errors() {
+			kafkatools.Log.Errorf("error: we got an error while joining the group: %v", err)
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	handler := &assignmentHandler{cancel: cancel}
+	if err := consumerGroup.Consume(ctx, []string{topic}, handler); err != nil {
+		return nil, err
+	}
+	if handler.claimed == nil {
+		return nil, fmt.Errorf("no partitions assigned within %s", timeout)
+	}
+	return handler.claimed, nil
+}
+
+// assignmentHandler records the claims of the first session of a consumer group and ends it right away
+type assignmentHandler struct {
+	cancel  context.CancelFunc
+	claimed map[string][]int32
+}
+
+func (h *assignmentHandler) Setup(session sarama.ConsumerGroupSession) error {
+	h.claimed = session.Claims()
+	h.cancel()
+	return nil
+}
+
+func (h *assignmentHandler) Cleanup(sarama.ConsumerGroupSession) error { return nil }
+
+func (h *assignmentHandler) ConsumeClaim(sarama.ConsumerGroupSession, sarama.ConsumerGroupClaim) error {
+	return nil
+}
+
+// validateGroupInstanceID checks the id against the rules of the brokers for group instance ids, which are those of
+// topic names
+func validateGroupInstanceID(id string) error {
+	if id == "." || id == ".." {
+		return fmt.Errorf("%q is not allowed", id)
+	}
+	if len(id) > 249 {
+		return fmt.Errorf("longer than 249 characters")
+	}
+	return validateIDChars(id)
+}
+
+func formatGroupAssignment(group, topic string, claimed []int32, totalPartitions int) string {
+	sorted := append([]int32(nil), claimed...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	partitions := make([]string, len(sorted))
+	for i, partition := range sorted {
+		partitions[i] = strconv.Itoa(int(partition))
+	}
+
+	if len(partitions) == 0 {
+		return fmt.Sprintf("group %s assigned no partitions of topic %s (0 of %d)", group, topic, totalPartitions)
+	}
+	return fmt.Sprintf("group %s assigned partitions %s of topic %s (%d of %d)", group, strings.Join(partitions, ", "), topic, len(sorted), totalPartitions)
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFormatGroupAssignment(t *testing.T) {
+	tests := []struct {
+		claimed  []int32
+		expected string
+	}{
+		{[]int32{6, 0, 3}, "group g assigned partitions 0, 3, 6 of topic t (3 of 12)"},
+		{nil, "group g assigned no partitions of topic t (0 of 12)"},
+	}
+
+	for _, test := range tests {
+		if formatted := formatGroupAssignment("g", "t", test.claimed, 12); formatted != test.expected {
+			t.Errorf("Expected %q, got %q", test.expected, formatted)
+		}
+	}
+}
+
+func TestValidateGroupInstanceID(t *testing.T) {
+	for _, id := range []string{"debug-1", "host_a.example"} {
+		if err := validateGroupInstanceID(id); err != nil {
+			t.Errorf("Expected %q to be valid, got %v", id, err)
+		}
+	}
+
+	for _, id := range []string{"..", "debug 1", "debug/1", strings.Repeat("a", 250)} {
+		if err := validateGroupInstanceID(id); err == nil {
+			t.Errorf("Expected %q to be invalid", id)
+		}
+	}
+}
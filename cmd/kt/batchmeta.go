@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/Shopify/sarama"
+)
+
+// batchMetadata is the producer information of the record batch a message was written in
+type batchMetadata struct {
+	BaseOffset    int64 `json:"base_offset"`
+	LastOffset    int64 `json:"last_offset"`
+	ProducerID    int64 `json:"producer_id"`
+	ProducerEpoch int16 `json:"producer_epoch"`
+	BaseSequence  int32 `json:"base_sequence"`
+	Transactional bool  `json:"transactional"`
+	Control       bool  `json:"control"`
+}
+
+// batchFetcher fetches the record batches of a partition starting with the batch containing the offset
+type batchFetcher func(topic string, partition int32, offset int64) ([]*sarama.RecordBatch, error)
+
+// batchIndex looks up the record batch of consumed messages. The sarama consumer does not expose the batches, so they
+// are fetched again from the partition leader. The batches of the last fetch are kept per partition, consuming a
+// partition in order therefore fetches every batch once. It is not safe for concurrent use.
+type batchIndex struct {
+	fetch   batchFetcher
+	batches map[string]map[int32][]batchMetadata
+}
+
+func newBatchIndex(fetch batchFetcher) *batchIndex {
+	return &batchIndex{fetch: fetch, batches: make(map[string]map[int32][]batchMetadata)}
+}
+
+// lookup returns the batch containing the message, or nil when it cannot be fetched or the message was written in a
+// pre-0.11 message set which has no batch metadata
+func (index *batchIndex) lookup(msg *sarama.ConsumerMessage) *batchMetadata {
+	if batch := findBatch(index.batches[msg.Topic][msg.Partition], msg.Offset); batch != nil {
+		return batch
+	}
+
+	recordBatches, err := index.fetch(msg.Topic, msg.Partition, msg.Offset)
+	if err != nil {
+		log.Printf("Could not fetch the record batch of the message at offset %d of %s partition %d: %v", msg.Offset, msg.Topic, msg.Partition, err)
+		return nil
+	}
+
+	batches := make([]batchMetadata, 0, len(recordBatches))
+	for _, recordBatch := range recordBatches {
+		batches = append(batches, batchMetadata{
+			BaseOffset:    recordBatch.FirstOffset,
+			LastOffset:    recordBatch.LastOffset(),
+			ProducerID:    recordBatch.ProducerID,
+			ProducerEpoch: recordBatch.ProducerEpoch,
+			BaseSequence:  recordBatch.FirstSequence,
+			Transactional: recordBatch.IsTransactional,
+			Control:       recordBatch.Control,
+		})
+	}
+	if index.batches[msg.Topic] == nil {
+		index.batches[msg.Topic] = make(map[int32][]batchMetadata)
+	}
+	index.batches[msg.Topic][msg.Partition] = batches
+
+	return findBatch(batches, msg.Offset)
+}
+
+func findBatch(batches []batchMetadata, offset int64) *batchMetadata {
+	for i := range batches {
+		if batches[i].BaseOffset <= offset && offset <= batches[i].LastOffset {
+			return &batches[i]
+		}
+	}
+	return nil
+}
+
+// fetchRecordBatches returns the batch fetcher sending fetch requests to the partition leaders
+func fetchRecordBatches(client sarama.Client) batchFetcher {
+	return func(topic string, partition int32, offset int64) ([]*sarama.RecordBatch, error) {
+		leader, err := client.Leader(topic, partition)
+		if err != nil {
+			return nil, err
+		}
+
+		// Version 4 is the first version returning record batches, read_uncommitted includes aborted transactions
+		request := &sarama.FetchRequest{Version: 4, MinBytes: 1, MaxBytes: sarama.MaxResponseSize, Isolation: sarama.ReadUncommitted}
+		request.AddBlock(topic, partition, offset, client.Config().Consumer.Fetch.Default, -1)
+		response, err := leader.Fetch(request)
+		if err != nil {
+			return nil, err
+		}
+
+		block := response.GetBlock(topic, partition)
+		if block == nil {
+			return nil, fmt.Errorf("no fetch response for %s partition %d", topic, partition)
+		}
+		if block.Err != sarama.ErrNoError {
+			return nil, block.Err
+		}
+
+		var batches []*sarama.RecordBatch
+		for _, records := range block.RecordsSet {
+			if records.RecordBatch != nil {
+				batches = append(batches, records.RecordBatch)
+			}
+		}
+		return batches, nil
+	}
+}
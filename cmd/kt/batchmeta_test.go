@@ -0,0 +1,49 @@
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/Shopify/sarama"
+)
+
+func TestBatchIndex(t *testing.T) {
+	var fetches []int64
+	index := newBatchIndex(func(topic string, partition int32, offset int64) ([]*sarama.RecordBatch, error) {
+		fetches = append(fetches, offset)
+		if offset >= 10 {
+			return nil, errors.New("fetch failed")
+		}
+		return []*sarama.RecordBatch{
+			{FirstOffset: 0, LastOffsetDelta: 2, ProducerID: 7, ProducerEpoch: 1, FirstSequence: 0, IsTransactional: true},
+			{FirstOffset: 3, LastOffsetDelta: 1, ProducerID: 7, ProducerEpoch: 1, FirstSequence: 3, IsTransactional: true},
+		}, nil
+	})
+
+	for offset, expectedBase := range map[int64]int64{1: 0, 2: 0, 4: 3} {
+		batch := index.lookup(&sarama.ConsumerMessage{Topic: "foo", Offset: offset})
+		if batch == nil || batch.BaseOffset != expectedBase || batch.ProducerID != 7 || !batch.Transactional {
+			t.Errorf("Expected offset %d to be in the batch at %d, got %+v", offset, expectedBase, batch)
+		}
+	}
+	if len(fetches) != 1 {
+		t.Errorf("Expected the batches to be fetched once, fetched at %v", fetches)
+	}
+
+	if batch := index.lookup(&sarama.ConsumerMessage{Topic: "foo", Offset: 12}); batch != nil {
+		t.Errorf("Expected no batch when the fetch fails, got %+v", batch)
+	}
+}
+
+func TestBatchMetaFormatter(t *testing.T) {
+	index := newBatchIndex(func(topic string, partition int32, offset int64) ([]*sarama.RecordBatch, error) {
+		return []*sarama.RecordBatch{{FirstOffset: 5, ProducerID: 3, ProducerEpoch: 2, FirstSequence: 9}}, nil
+	})
+	formatter := newMessageFormatter(outputOptions{format: "ndjson", printBatchMeta: true, batches: index}, newValueDecoder(""), nil)
+
+	expected := `{"topic":"foo","partition":0,"offset":5,"timestamp":"0001-01-01T00:00:00Z","key":null,"value":"value",` +
+		`"batch":{"base_offset":5,"last_offset":5,"producer_id":3,"producer_epoch":2,"base_sequence":9,"transactional":false,"control":false}}`
+	if line := string(formatter(&sarama.ConsumerMessage{Topic: "foo", Offset: 5, Value: []byte("value")})); line != expected {
+		t.Errorf("Expected %s, got %s", expected, line)
+	}
+}
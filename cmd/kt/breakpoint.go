@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/Shopify/sarama"
+)
+
+// breakpoints pause the partitions when they reach the offsets of --pause-at, until a line (Enter) is read from the
+// input. The other partitions keep being consumed, one paused partition is prompted for at a time.
+type breakpoints struct {
+	offsets map[int32]int64
+	out     io.Writer
+	// lines receives the lines read from the input, it is closed once the input ends
+	lines chan struct{}
+	mutex sync.Mutex
+	// reached are the partitions which paused already
+	reached map[int32]bool
+}
+
+// parseBreakpoints parses the <partition>:<offset> breakpoints of --pause-at
+func parseBreakpoints(specs []string) (map[int32]int64, error) {
+	offsets := make(map[int32]int64, len(specs))
+	for _, spec := range specs {
+		parts := strings.SplitN(spec, ":", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid breakpoint %q, expected <partition>:<offset>", spec)
+		}
+		partition, err := strconv.ParseInt(parts[0], 10, 32)
+		if err != nil || partition < 0 {
+			return nil, fmt.Errorf("invalid partition in breakpoint %q", spec)
+		}
+		offset, err := strconv.ParseInt(parts[1], 10, 64)
+		if err != nil || offset < 0 {
+			return nil, fmt.Errorf("invalid offset in breakpoint %q", spec)
+		}
+		if _, ok := offsets[int32(partition)]; ok {
+			return nil, fmt.Errorf("partition %d has more than one breakpoint", partition)
+		}
+		offsets[int32(partition)] = offset
+	}
+	return offsets, nil
+}
+
+// newBreakpoints returns the breakpoints at the offsets, reading the lines that resume the partitions from in
+func newBreakpoints(offsets map[int32]int64, in io.Reader, out io.Writer) *breakpoints {
+	b := &breakpoints{offsets: offsets, out: out, lines: make(chan struct{}), reached: make(map[int32]bool)}
+	go func() {
+		defer close(b.lines)
+		scanner := bufio.NewScanner(in)
+		for scanner.Scan() {
+			b.lines <- struct{}{}
+		}
+	}()
+	return b
+}
+
+// pause blocks when the message is the first one of its partition at or beyond the breakpoint of the partition (the
+// offset itself may be missing, e.g. from compacted partitions) until a line is read, the input ends or closing is
+// closed. It is a no-op on nil breakpoints.
+func (b *breakpoints) pause(msg *sarama.ConsumerMessage, closing chan struct{}) {
+	if b == nil {
+		return
+	}
+	offset, ok := b.offsets[msg.Partition]
+	if !ok || msg.Offset < offset {
+		return
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if b.reached[msg.Partition] {
+		return
+	}
+	b.reached[msg.Partition] = true
+
+	fmt.Fprintf(b.out, "Paused %s partition %d at offset %d, press Enter to continue or Ctrl-C to stop\n", msg.Topic, msg.Partition, msg.Offset)
+	select {
+	case <-b.lines:
+	case <-closing:
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+)
+
+func TestParseBreakpoints(t *testing.T) {
+	offsets, err := parseBreakpoints([]string{"0:100", "3:0"})
+	if err != nil || len(offsets) != 2 || offsets[0] != 100 || offsets[3] != 0 {
+		t.Errorf("Expected breakpoints at 0:100 and 3:0, got %v (%v)", offsets, err)
+	}
+
+	for _, spec := range []string{"100", "a:1", "0:-1", "-1:5", "0:x", "1:1,1:2"} {
+		if _, err := parseBreakpoints(strings.Split(spec, ",")); err == nil {
+			t.Errorf("Expected breakpoint %q to be invalid", spec)
+		}
+	}
+}
+
+func TestBreakpointsPause(t *testing.T) {
+	in, input := io.Pipe()
+	defer input.Close()
+	var out bytes.Buffer
+	pauses := newBreakpoints(map[int32]int64{0: 5}, in, &out)
+
+	closing := make(chan struct{})
+	paused := func(offset int64) bool {
+		done := make(chan struct{})
+		go func() {
+			pauses.pause(&sarama.ConsumerMessage{Topic: "foo", Partition: 0, Offset: offset}, closing)
+			close(done)
+		}()
+		select {
+		case <-done:
+			return false
+		case <-time.After(50 * time.Millisecond):
+			input.Write([]byte("\n"))
+			<-done
+			return true
+		}
+	}
+
+	// The breakpoint offset was compacted away, so the partition pauses at the next offset, once
+	if paused(4) || !paused(6) || paused(7) {
+		t.Error("Expected the partition to pause once, at the first offset beyond the breakpoint")
+	}
+	if !strings.Contains(out.String(), "Paused foo partition 0 at offset 6") {
+		t.Errorf("Expected a prompt for offset 6, got %q", out.String())
+	}
+
+	// Stopping resumes paused partitions
+	pauses = newBreakpoints(map[int32]int64{1: 0}, in, &out)
+	close(closing)
+	pauses.pause(&sarama.ConsumerMessage{Topic: "foo", Partition: 1, Offset: 0}, closing)
+}
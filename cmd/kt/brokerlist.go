@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// brokersEnv is the environment variable with the comma separated brokers used without --broker and --broker-file
+const brokersEnv = "KT_BROKERS"
+
+// resolveBrokers returns the brokers of --broker, or else those listed in the --broker-file, or else those of the
+// KT_BROKERS environment variable
+func resolveBrokers(flag, path interface{}, env string) ([]string, error) {
+	var brokers []string
+	switch {
+	case flag != nil:
+		brokers = splitBrokers(flag.(string))
+	case path != nil:
+		data, err := os.ReadFile(path.(string))
+		if err != nil {
+			return nil, fmt.Errorf("could not read the broker file: %v", err)
+		}
+		brokers = parseBrokerFile(string(data))
+	default:
+		brokers = splitBrokers(env)
+	}
+
+	if len(brokers) == 0 {
+		return nil, fmt.Errorf("no brokers specified, use --broker, --broker-file or the %s environment variable", brokersEnv)
+	}
+	return brokers, nil
+}
+
+// parseBrokerFile returns the brokers of a broker file: one or more comma separated brokers per line, blank lines and
+// lines starting with # are skipped
+func parseBrokerFile(data string) (brokers []string) {
+	for _, line := range strings.Split(data, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		brokers = append(brokers, splitBrokers(line)...)
+	}
+	return brokers
+}
+
+// splitBrokers splits the comma separated brokers, ignoring the whitespace around them and empty entries
+func splitBrokers(list string) (brokers []string) {
+	for _, broker := range strings.Split(list, ",") {
+		if broker = strings.TrimSpace(broker); broker != "" {
+			brokers = append(brokers, broker)
+		}
+	}
+	return brokers
+}
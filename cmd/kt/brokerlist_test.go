@@ -0,0 +1,44 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestResolveBrokers(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "brokers")
+	if err := os.WriteFile(path, []byte("# production\nkafka1:9092\n\n  kafka2:9092, kafka3:9092  \n#kafka4:9092\n"), 0o600); err != nil {
+		t.Fatal("Unexpected error: ", err)
+	}
+
+	tests := []struct {
+		flag, path interface{}
+		env        string
+		expected   []string
+	}{
+		{"a:9092, b:9092", path, "env:9092", []string{"a:9092", "b:9092"}},
+		{nil, path, "env:9092", []string{"kafka1:9092", "kafka2:9092", "kafka3:9092"}},
+		{nil, nil, " env1:9092,env2:9092 ", []string{"env1:9092", "env2:9092"}},
+	}
+	for _, test := range tests {
+		brokers, err := resolveBrokers(test.flag, test.path, test.env)
+		if err != nil || !reflect.DeepEqual(brokers, test.expected) {
+			t.Errorf("Expected brokers %q for %+v, got %q (%v)", test.expected, test, brokers, err)
+		}
+	}
+
+	empty := filepath.Join(t.TempDir(), "empty")
+	if err := os.WriteFile(empty, []byte("# nothing\n\n"), 0o600); err != nil {
+		t.Fatal("Unexpected error: ", err)
+	}
+	for _, test := range []struct {
+		flag, path interface{}
+		env        string
+	}{{nil, nil, ""}, {",", nil, "env:9092"}, {nil, empty, "env:9092"}, {nil, filepath.Join(t.TempDir(), "missing"), ""}} {
+		if brokers, err := resolveBrokers(test.flag, test.path, test.env); err == nil {
+			t.Errorf("Expected an error for %+v, got %q", test, brokers)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/Shopify/sarama"
+	"github.com/jurriaan/kafkatools"
+)
+
+// churnKeys is the number of most updated keys printed by the compaction simulation
+const churnKeys = 5
+
+// partitionCompaction tracks the latest record of every key of a partition
+type partitionCompaction struct {
+	records    int
+	tombstones int
+	// unkeyed records are never removed by compaction (compacted topics reject them)
+	unkeyed int
+	// latestTombstone is true for the keys whose latest record is a tombstone
+	latestTombstone map[string]bool
+	updates         map[string]int
+}
+
+// simulateCompaction consumes the messages and returns which records would survive compacting the partitions
+func simulateCompaction(messages <-chan *sarama.ConsumerMessage, maxMessages int) map[topicPartition]*partitionCompaction {
+	partitions := make(map[topicPartition]*partitionCompaction)
+
+	total := 0
+	for msg := range messages {
+		key := topicPartition{Topic: msg.Topic, Partition: msg.Partition}
+		partition := partitions[key]
+		if partition == nil {
+			partition = &partitionCompaction{latestTombstone: make(map[string]bool), updates: make(map[string]int)}
+			partitions[key] = partition
+		}
+		partition.add(msg)
+
+		total++
+		if maxMessages != -1 && total >= maxMessages {
+			kafkatools.Log.Infof("Quiting after %d messages", total)
+			break
+		}
+	}
+
+	return partitions
+}
+
+func (p *partitionCompaction) add(msg *sarama.ConsumerMessage) {
+	p.records++
+	if msg.Value == nil {
+		p.tombstones++
+	}
+	if msg.Key == nil {
+		p.unkeyed++
+		return
+	}
+
+	p.latestTombstone[string(msg.Key)] = msg.Value == nil
+	p.updates[string(msg.Key)]++
+}
+
+// surviving returns the number of records left after compaction and how many of them are tombstones, which are
+// removed as well once delete.retention.ms has passed
+func (p *partitionCompaction) surviving() (records, tombstones int) {
+	for _, tombstone := range p.latestTombstone {
+		if tombstone {
+			tombstones++
+		}
+	}
+	return len(p.latestTombstone) + p.unkeyed, tombstones
+}
+
+// printCompaction prints the records, tombstones and surviving records per partition and in total, followed by the
+// keys with the most records
+func printCompaction(partitions map[topicPartition]*partitionCompaction, printer func(string)) {
+	keys := make([]topicPartition, 0, len(partitions))
+	for key := range partitions {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].Topic != keys[j].Topic {
+			return keys[i].Topic < keys[j].Topic
+		}
+		return keys[i].Partition < keys[j].Partition
+	})
+
+	type churn struct {
+		key       string
+		partition topicPartition
+		updates   int
+	}
+	var churns []churn
+	var records, tombstones, survivors, survivingTombstones int
+	for _, key := range keys {
+		partition := partitions[key]
+		partitionSurvivors, partitionTombstones := partition.surviving()
+		printer(fmt.Sprintf("%s partition %d: %s", key.Topic, key.Partition, formatCompaction(partition.records, partition.tombstones, partitionSurvivors, partitionTombstones)))
+
+		records += partition.records
+		tombstones += partition.tombstones
+		survivors += partitionSurvivors
+		survivingTombstones += partitionTombstones
+		for recordKey, updates := range partition.updates {
+			if updates > 1 {
+				churns = append(churns, churn{recordKey, key, updates})
+			}
+		}
+	}
+	printer("total: " + formatCompaction(records, tombstones, survivors, survivingTombstones))
+
+	sort.Slice(churns, func(i, j int) bool {
+		if churns[i].updates != churns[j].updates {
+			return churns[i].updates > churns[j].updates
+		}
+		return churns[i].key < churns[j].key
+	})
+	if len(churns) > churnKeys {
+		churns = churns[:churnKeys]
+	}
+	for _, churn := range churns {
+		printer(fmt.Sprintf("key %q of %s partition %d: %d records", churn.key, churn.partition.Topic, churn.partition.Partition, churn.updates))
+	}
+}
+
+func formatCompaction(records, tombstones, survivors, survivingTombstones int) string {
+	var removed float64
+	if records > 0 {
+		removed = float64(records-survivors) * 100 / float64(records)
+	}
+	return fmt.Sprintf("%d records, %d tombstones, %d surviving compaction (%d tombstones), %.1f%% removed",
+		records, tombstones, survivors, survivingTombstones, removed)
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/Shopify/sarama"
+)
+
+func TestSimulateCompaction(t *testing.T) {
+	messages := make(chan *sarama.ConsumerMessage, 10)
+	for _, msg := range []*sarama.ConsumerMessage{
+		{Topic: "foo", Key: []byte("a"), Value: []byte("1")},
+		{Topic: "foo", Key: []byte("a"), Value: []byte("2")},
+		{Topic: "foo", Key: []byte("b"), Value: []byte("1")},
+		{Topic: "foo", Key: []byte("b")},
+		{Topic: "foo", Key: []byte("a"), Value: []byte("3")},
+		{Topic: "foo", Value: []byte("unkeyed")},
+		{Topic: "foo", Partition: 1, Key: []byte("c"), Value: []byte("1")},
+	} {
+		messages <- msg
+	}
+	close(messages)
+
+	var lines []string
+	printCompaction(simulateCompaction(messages, -1), func(str string) { lines = append(lines, str) })
+
+	expected := []string{
+		"foo partition 0: 6 records, 1 tombstones, 3 surviving compaction (1 tombstones), 50.0% removed",
+		"foo partition 1: 1 records, 0 tombstones, 1 surviving compaction (0 tombstones), 0.0% removed",
+		"total: 7 records, 1 tombstones, 4 surviving compaction (1 tombstones), 42.9% removed",
+		`key "a" of foo partition 0: 3 records`,
+		`key "b" of foo partition 0: 2 records`,
+	}
+	if !reflect.DeepEqual(lines, expected) {
+		t.Errorf("Expected %q, got %q", expected, lines)
+	}
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+
+	"github.com/Shopify/sarama"
+	"github.com/jurriaan/kafkatools"
+)
+
+// controlMarker is a transaction marker the transaction coordinator wrote to a partition
+type controlMarker struct {
+	Topic            string        `json:"topic"`
+	Partition        int32         `json:"partition"`
+	Offset           int64         `json:"offset"`
+	Timestamp        formattedTime `json:"timestamp"`
+	Type             string        `json:"type"`
+	ProducerID       int64         `json:"producer_id"`
+	ProducerEpoch    int16         `json:"producer_epoch"`
+	CoordinatorEpoch int32         `json:"coordinator_epoch"`
+}
+
+// controlOnly prints the transaction markers between the start and end offsets of every partition. The sarama consumer
+// drops control records, so the record batches are fetched from the partition leaders instead.
+func controlOnly(client sarama.Client, partitionOffsets, endOffsets topicOffsetMap, outputOpts outputOptions) {
+	printer := func(marker controlMarker) {
+		fmt.Println(formatControlMarker(marker, outputOpts.timestamps))
+	}
+	if outputOpts.format == "ndjson" {
+		printer = func(marker controlMarker) {
+			marker.Timestamp = outputOpts.timestamps.jsonTime(marker.Timestamp.Time)
+			line, err := json.Marshal(marker)
+			if err != nil {
+				log.Fatal("Could not encode the transaction marker: ", err)
+			}
+			fmt.Println(string(line))
+		}
+	}
+
+	found := printControlMarkers(fetchRecordBatches(client), partitionOffsets, endOffsets, printer)
+	kafkatools.Log.Infof("Found %d transaction markers", found)
+}
+
+// printControlMarkers scans the partitions in order and returns the number of markers printed
+func printControlMarkers(fetch batchFetcher, partitionOffsets, endOffsets topicOffsetMap, printer func(controlMarker)) int {
+	topics := make([]string, 0, len(partitionOffsets))
+	for topic := range partitionOffsets {
+		topics = append(topics, topic)
+	}
+	sort.Strings(topics)
+
+	found := 0
+	for _, topic := range topics {
+		partitions := make([]int32, 0, len(partitionOffsets[topic]))
+		for partition := range partitionOffsets[topic] {
+			partitions = append(partitions, partition)
+		}
+
+		for _, partition := range sortedInt32s(partitions) {
+			end, ok := endOffsets[topic][partition]
+			if !ok {
+				continue
+			}
+
+			markers, err := scanControlMarkers(fetch, topic, partition, partitionOffsets[topic][partition].Offset, end.Offset)
+			if err != nil {
+				log.Printf("Could not scan %s partition %d for transaction markers: %v", topic, partition, err)
+			}
+			for _, marker := range markers {
+				printer(marker)
+			}
+			found += len(markers)
+		}
+	}
+	return found
+}
+
+// scanControlMarkers fetches the record batches of the partition from offset up to end and returns the transaction
+// markers in them, along with the markers found before an error
+func scanControlMarkers(fetch batchFetcher, topic string, partition int32, offset, end int64) ([]controlMarker, error) {
+	var markers []controlMarker
+	for offset < end {
+		batches, err := fetch(topic, partition, offset)
+		if err != nil {
+			return markers, err
+		}
+
+		next := offset
+		for _, batch := range batches {
+			// The fetch starts with the whole batch containing the offset
+			if batch.LastOffset() < next {
+				continue
+			}
+			if batch.FirstOffset >= end {
+				return markers, nil
+			}
+
+			if batch.Control {
+				for _, record := range batch.Records {
+					marker, err := parseControlRecord(batch, record)
+					if err != nil {
+						return markers, err
+					}
+					marker.Topic, marker.Partition = topic, partition
+					markers = append(markers, marker)
+				}
+			}
+			next = batch.LastOffset() + 1
+		}
+
+		// Nothing newer could be fetched, e.g. because the partition was truncated
+		if next == offset {
+			return markers, nil
+		}
+		offset = next
+	}
+	return markers, nil
+}
+
+// parseControlRecord decodes a control record, its key is the version and the type (0 abort, 1 commit), its value the
+// version and the coordinator epoch
+func parseControlRecord(batch *sarama.RecordBatch, record *sarama.Record) (controlMarker, error) {
+	offset := batch.FirstOffset + record.OffsetDelta
+	if len(record.Key) < 4 {
+		return controlMarker{}, fmt.Errorf("invalid control record key at offset %d", offset)
+	}
+
+	marker := controlMarker{
+		Offset:        offset,
+		Timestamp:     formattedTime{Time: batch.FirstTimestamp.Add(record.TimestampDelta)},
+		ProducerID:    batch.ProducerID,
+		ProducerEpoch: batch.ProducerEpoch,
+	}
+	switch controlType := int16(binary.BigEndian.Uint16(record.Key[2:])); controlType {
+	case 0:
+		marker.Type = "ABORT"
+	case 1:
+		marker.Type = "COMMIT"
+	default:
+		marker.Type = fmt.Sprintf("UNKNOWN(%d)", controlType)
+	}
+	if len(record.Value) >= 6 {
+		marker.CoordinatorEpoch = int32(binary.BigEndian.Uint32(record.Value[2:]))
+	}
+	return marker, nil
+}
+
+func formatControlMarker(marker controlMarker, timestamps *timestampFormat) string {
+	return fmt.Sprintf("%s partition %d offset %d %s: %s producer %d epoch %d (coordinator epoch %d)",
+		marker.Topic, marker.Partition, marker.Offset, timestamps.format(marker.Timestamp.Time), marker.Type,
+		marker.ProducerID, marker.ProducerEpoch, marker.CoordinatorEpoch)
+}
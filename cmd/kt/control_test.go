@@ -0,0 +1,54 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/jurriaan/kafkatools"
+)
+
+func TestScanControlMarkers(t *testing.T) {
+	commit := &sarama.Record{OffsetDelta: 0, TimestampDelta: time.Second, Key: []byte{0, 0, 0, 1}, Value: []byte{0, 0, 0, 0, 0, 3}}
+	abort := &sarama.Record{Key: []byte{0, 0, 0, 0}, Value: []byte{0, 0, 0, 0, 0, 3}}
+	batches := []*sarama.RecordBatch{
+		{FirstOffset: 0, LastOffsetDelta: 1, ProducerID: 7, IsTransactional: true},
+		{FirstOffset: 2, ProducerID: 7, IsTransactional: true, Control: true, FirstTimestamp: time.Unix(1500000000, 0).UTC(), Records: []*sarama.Record{commit}},
+		{FirstOffset: 3, LastOffsetDelta: 2, ProducerID: 8, ProducerEpoch: 1, IsTransactional: true},
+		{FirstOffset: 6, ProducerID: 8, ProducerEpoch: 1, IsTransactional: true, Control: true, Records: []*sarama.Record{abort}},
+		{FirstOffset: 7, ProducerID: -1},
+	}
+
+	// Every fetch returns two batches, starting with the batch containing the offset
+	var fetches []int64
+	fetch := func(topic string, partition int32, offset int64) ([]*sarama.RecordBatch, error) {
+		fetches = append(fetches, offset)
+		for i, batch := range batches {
+			if batch.LastOffset() >= offset {
+				if i+2 < len(batches) {
+					return batches[i : i+2], nil
+				}
+				return batches[i:], nil
+			}
+		}
+		return nil, nil
+	}
+
+	var printed []string
+	partitionOffsets := topicOffsetMap{"foo": {0: kafkatools.TopicPartitionOffset{Topic: "foo", Partition: 0, Offset: 1}}}
+	endOffsets := topicOffsetMap{"foo": {0: kafkatools.TopicPartitionOffset{Topic: "foo", Partition: 0, Offset: 7}}}
+	found := printControlMarkers(fetch, partitionOffsets, endOffsets, func(marker controlMarker) {
+		printed = append(printed, formatControlMarker(marker, nil))
+	})
+
+	expected := []string{
+		"foo partition 0 offset 2 2017-07-14T02:40:01Z: COMMIT producer 7 epoch 0 (coordinator epoch 3)",
+		"foo partition 0 offset 6 0001-01-01T00:00:00Z: ABORT producer 8 epoch 1 (coordinator epoch 3)",
+	}
+	if found != 2 || len(printed) != 2 || printed[0] != expected[0] || printed[1] != expected[1] {
+		t.Errorf("Expected %q, got %d markers %q", expected, found, printed)
+	}
+	if len(fetches) != 2 {
+		t.Errorf("Expected two fetches, fetched at %v", fetches)
+	}
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/Shopify/sarama"
+	"github.com/jurriaan/kafkatools"
+)
+
+// countMessages counts the messages per partition without printing them
+func countMessages(messages <-chan *sarama.ConsumerMessage, maxMessages int) (counts map[int32]int, total int) {
+	counts = make(map[int32]int)
+
+	for msg := range messages {
+		counts[msg.Partition]++
+		total++
+
+		if maxMessages != -1 && total >= maxMessages {
+			kafkatools.Log.Infof("Quiting after %d messages", total)
+			break
+		}
+	}
+
+	return counts, total
+}
+
+func printCounts(counts map[int32]int, total int, printer func(string)) {
+	partitions := make([]int, 0, len(counts))
+	for partition := range counts {
+		partitions = append(partitions, int(partition))
+	}
+	sort.Ints(partitions)
+
+	for _, partition := range partitions {
+		printer(fmt.Sprintf("partition %d: %d", partition, counts[int32(partition)]))
+	}
+	printer(fmt.Sprintf("total: %d", total))
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// dateFormats describes the formats accepted by parseDate, for error messages
+const dateFormats = "RFC3339 (2006-01-02T15:04:05Z07:00), 2006-01-02 15:04:05 (local time), Unix seconds, " +
+	"Unix milliseconds or a duration relative to now such as -2h or -1h30m"
+
+// unixMillisThreshold separates Unix seconds from milliseconds, seconds only reach it in the year 5138
+const unixMillisThreshold = 100000000000
+
+// parseDate parses a timestamp in one of the dateFormats, relative durations are relative to now
+func parseDate(str string, now time.Time) (time.Time, error) {
+	str = strings.TrimSpace(str)
+
+	if date, err := time.Parse(time.RFC3339, str); err == nil {
+		return date, nil
+	}
+	if date, err := time.ParseInLocation("2006-01-02 15:04:05", str, time.Local); err == nil {
+		return date, nil
+	}
+	if number, err := strconv.ParseInt(str, 10, 64); err == nil && number >= 0 {
+		if number >= unixMillisThreshold {
+			return time.Unix(0, number*int64(time.Millisecond)), nil
+		}
+		return time.Unix(number, 0), nil
+	}
+	// Durations need a sign so they cannot be mistaken for a timestamp
+	if strings.HasPrefix(str, "-") || strings.HasPrefix(str, "+") {
+		if duration, err := time.ParseDuration(str); err == nil {
+			return now.Add(duration), nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("could not parse %q, use %s", str, dateFormats)
+}
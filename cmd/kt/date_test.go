@@ -0,0 +1,38 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseDate(t *testing.T) {
+	now := time.Date(2017, 7, 14, 2, 40, 0, 0, time.UTC)
+	local := time.Date(2017, 7, 14, 2, 40, 0, 0, time.Local)
+
+	tests := []struct {
+		str      string
+		expected time.Time
+	}{
+		{"2017-07-14T04:40:00+02:00", now},
+		{"2017-07-14 02:40:00", local},
+		{"1500000000", now},
+		{"1500000000123", now.Add(123 * time.Millisecond)},
+		{"-2h", now.Add(-2 * time.Hour)},
+		{"+1h30m", now.Add(90 * time.Minute)},
+	}
+
+	for _, test := range tests {
+		date, err := parseDate(test.str, now)
+		if err != nil {
+			t.Errorf("Could not parse %s: %v", test.str, err)
+		} else if !date.Equal(test.expected) {
+			t.Errorf("Expected %s to be %v, got %v", test.str, test.expected, date)
+		}
+	}
+
+	for _, invalid := range []string{"", "yesterday", "2h", "14-07-2017", "-5"} {
+		if _, err := parseDate(invalid, now); err == nil {
+			t.Errorf("Expected an error for %q", invalid)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/Shopify/sarama"
+)
+
+// debeziumOps are the names of the Debezium operation types
+var debeziumOps = map[string]string{
+	"c": "create",
+	"r": "read",
+	"u": "update",
+	"d": "delete",
+	"t": "truncate",
+	"m": "message",
+}
+
+// decodeDebezium summarizes a Debezium change event as the operation, the table, the key and the changed fields.
+// Values which are not a Debezium envelope (with or without the schema of the JSON converter) are returned as is.
+func decodeDebezium(msg *sarama.ConsumerMessage) ([]byte, error) {
+	if msg.Value == nil {
+		return nil, nil
+	}
+
+	envelope, ok := parseDebeziumJSON(msg.Value).(map[string]interface{})
+	if !ok {
+		return msg.Value, nil
+	}
+	op, ok := envelope["op"].(string)
+	if !ok || debeziumOps[op] == "" {
+		return msg.Value, nil
+	}
+
+	summary := debeziumOps[op]
+	if source, ok := envelope["source"].(map[string]interface{}); ok {
+		summary += " " + formatDebeziumTable(source)
+	}
+	if msg.Key != nil {
+		summary += " key=" + formatDebeziumValue(parseDebeziumJSON(msg.Key), msg.Key)
+	}
+
+	before, _ := envelope["before"].(map[string]interface{})
+	after, _ := envelope["after"].(map[string]interface{})
+	switch op {
+	case "u":
+		if changes := formatDebeziumChanges(before, after); changes != "" {
+			summary += " changed: " + changes
+		}
+	case "c", "r":
+		if after != nil {
+			summary += " set: " + formatDebeziumFields(after)
+		}
+	}
+	return []byte(summary), nil
+}
+
+// parseDebeziumJSON parses the JSON and unwraps the payload of the JSON converter, it returns nil for invalid JSON
+func parseDebeziumJSON(data []byte) interface{} {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil
+	}
+	if object, ok := value.(map[string]interface{}); ok {
+		if _, hasSchema := object["schema"]; hasSchema {
+			if payload, hasPayload := object["payload"]; hasPayload {
+				return payload
+			}
+		}
+	}
+	return value
+}
+
+// formatDebeziumTable formats the database, schema and table of the source, the schema is only set by some connectors
+func formatDebeziumTable(source map[string]interface{}) string {
+	var parts []string
+	for _, field := range []string{"db", "schema", "table"} {
+		if name, ok := source[field].(string); ok && name != "" {
+			parts = append(parts, name)
+		}
+	}
+	return strings.Join(parts, ".")
+}
+
+// formatDebeziumChanges formats the fields whose value differs between before and after as name: old -> new, all
+// fields of after are listed when before is missing (e.g. without a full replica identity)
+func formatDebeziumChanges(before, after map[string]interface{}) string {
+	if before == nil {
+		return formatDebeziumFields(after)
+	}
+
+	var changes []string
+	for _, name := range sortedFields(before, after) {
+		if !reflect.DeepEqual(before[name], after[name]) {
+			changes = append(changes, fmt.Sprintf("%s: %s -> %s", name, formatDebeziumValue(before[name], nil), formatDebeziumValue(after[name], nil)))
+		}
+	}
+	return strings.Join(changes, ", ")
+}
+
+func formatDebeziumFields(fields map[string]interface{}) string {
+	formatted := make([]string, 0, len(fields))
+	for _, name := range sortedFields(fields) {
+		formatted = append(formatted, name+"="+formatDebeziumValue(fields[name], nil))
+	}
+	return strings.Join(formatted, " ")
+}
+
+// formatDebeziumValue formats the value as compact JSON, raw is used when the value could not be parsed
+func formatDebeziumValue(value interface{}, raw []byte) string {
+	if value == nil && raw != nil {
+		return string(raw)
+	}
+	formatted, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(formatted)
+}
+
+// sortedFields returns the sorted names of the fields of all objects
+func sortedFields(objects ...map[string]interface{}) []string {
+	seen := make(map[string]bool)
+	var names []string
+	for _, object := range objects {
+		for name := range object {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/Shopify/sarama"
+)
+
+func TestDecodeDebezium(t *testing.T) {
+	for _, test := range []struct {
+		key, value, expected string
+	}{
+		{
+			`{"id": 1}`,
+			`{"before": {"id": 1, "name": "a", "age": 30}, "after": {"id": 1, "name": "b", "age": 30}, "op": "u", "source": {"db": "shop", "table": "users"}}`,
+			`update shop.users key={"id":1} changed: name: "a" -> "b"`,
+		},
+		{
+			`{"schema": {}, "payload": {"id": 2}}`,
+			`{"schema": {}, "payload": {"before": null, "after": {"id": 2, "name": "c"}, "op": "c", "source": {"db": "shop", "schema": "public", "table": "users"}}}`,
+			`create shop.public.users key={"id":2} set: id=2 name="c"`,
+		},
+		{
+			`not json`,
+			`{"before": {"id": 3}, "after": null, "op": "d", "source": {"db": "shop", "table": "users"}}`,
+			`delete shop.users key=not json`,
+		},
+		{
+			``,
+			`{"before": null, "after": {"id": 4}, "op": "u"}`,
+			`update changed: id=4`,
+		},
+		// Not a Debezium envelope
+		{``, `{"op": "x"}`, `{"op": "x"}`},
+		{``, `plain text`, `plain text`},
+	} {
+		msg := &sarama.ConsumerMessage{Value: []byte(test.value)}
+		if test.key != "" {
+			msg.Key = []byte(test.key)
+		}
+
+		decoded, err := decodeDebezium(msg)
+		if err != nil {
+			t.Errorf("Unexpected error decoding %s: %v", test.value, err)
+		} else if string(decoded) != test.expected {
+			t.Errorf("Expected %s, got %s", test.expected, decoded)
+		}
+	}
+}
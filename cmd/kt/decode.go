@@ -0,0 +1,287 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/jurriaan/kafkatools"
+)
+
+// decoder turns a message into a readable value
+type decoder func(msg *sarama.ConsumerMessage) ([]byte, error)
+
+func getDecoder(name string) decoder {
+	switch name {
+	case "":
+		return nil
+	case "offsets":
+		return decodeConsumerOffsets
+	case "msgpack":
+		return decodeMsgpack
+	case "debezium":
+		return decodeDebezium
+	default:
+		log.Fatalf("Unknown decoder %s", name)
+		return nil
+	}
+}
+
+// valueDecoder applies a decoder to messages and keeps track of the messages that could not be decoded
+type valueDecoder struct {
+	decode decoder
+	// closeDecoder releases the resources of the decoder, e.g. an external decoder command
+	closeDecoder func() error
+	// decompress decompresses the application-level compressed values before they are unwrapped and decoded
+	decompress func(msg *sarama.ConsumerMessage) ([]byte, error)
+	// unwrap extracts the value from an envelope before it is decoded
+	unwrap func(value []byte) ([]byte, error)
+	// errorSink receives the raw records that could not be decoded
+	errorSink io.Writer
+	failures  int
+	// stats counts the decoded and failed messages per partition for the --stats-interval reports
+	stats *throughputStats
+}
+
+// errorRecord is written to the error sink for every message that could not be decoded
+type errorRecord struct {
+	Topic     string    `json:"topic"`
+	Partition int32     `json:"partition"`
+	Offset    int64     `json:"offset"`
+	Timestamp time.Time `json:"timestamp"`
+	Key       []byte    `json:"key"`
+	Value     []byte    `json:"value"`
+	Error     string    `json:"error"`
+}
+
+func newValueDecoder(name string) *valueDecoder {
+	return &valueDecoder{decode: getDecoder(name)}
+}
+
+// newCommandValueDecoder returns a value decoder using the external command, see commandDecoder
+func newCommandValueDecoder(command string) *valueDecoder {
+	decoder, err := startCommandDecoder(command)
+	if err != nil {
+		log.Fatal("Could not start the decoder command: ", err)
+	}
+	return &valueDecoder{decode: decoder.decode, closeDecoder: decoder.close}
+}
+
+// close releases the resources of the decoder
+func (d *valueDecoder) close() {
+	if d.closeDecoder == nil {
+		return
+	}
+	if err := d.closeDecoder(); err != nil {
+		log.Println("Could not properly close the decoder: ", err)
+	}
+}
+
+// decodeValue decodes the message value, falling back to hex when the message can't be decoded
+func (d *valueDecoder) decodeValue(msg *sarama.ConsumerMessage) []byte {
+	if d == nil || (d.decode == nil && d.unwrap == nil && d.decompress == nil) {
+		return msg.Value
+	}
+
+	msg, err := d.prepare(msg)
+	if err != nil {
+		d.stats.addDecode(msg, err)
+		return d.fail(msg, err)
+	}
+	if d.decode == nil {
+		return msg.Value
+	}
+
+	value, err := d.decode(msg)
+	d.stats.addDecode(msg, err)
+	if err != nil {
+		return d.fail(msg, err)
+	}
+	return value
+}
+
+// decodeQuietly decodes the message value like decodeValue without counting or reporting the failures, it returns
+// false when the value can't be decoded
+func (d *valueDecoder) decodeQuietly(msg *sarama.ConsumerMessage) ([]byte, bool) {
+	if d == nil || (d.decode == nil && d.unwrap == nil && d.decompress == nil) {
+		return msg.Value, true
+	}
+
+	msg, err := d.prepare(msg)
+	if err != nil {
+		return nil, false
+	}
+	if d.decode == nil {
+		return msg.Value, true
+	}
+
+	value, err := d.decode(msg)
+	return value, err == nil
+}
+
+// prepare returns a copy of the message with the decompressed and unwrapped value to decode, or the message itself
+// when there is nothing to do
+func (d *valueDecoder) prepare(msg *sarama.ConsumerMessage) (*sarama.ConsumerMessage, error) {
+	if msg.Value == nil || (d.decompress == nil && d.unwrap == nil) {
+		return msg, nil
+	}
+
+	value := msg.Value
+	if d.decompress != nil {
+		var err error
+		if value, err = d.decompress(msg); err != nil {
+			return msg, err
+		}
+	}
+	if d.unwrap != nil {
+		var err error
+		if value, err = d.unwrap(value); err != nil {
+			return msg, err
+		}
+	}
+
+	prepared := *msg
+	prepared.Value = value
+	return &prepared, nil
+}
+
+// fail counts and reports a message that could not be decoded and returns its value as hex
+func (d *valueDecoder) fail(msg *sarama.ConsumerMessage, err error) []byte {
+	d.failures++
+	kafkatools.Log.Warnf("Could not decode message at offset %d of %s partition %d, printing it as hex: %v", msg.Offset, msg.Topic, msg.Partition, err)
+	d.writeError(msg, err)
+	return []byte(hex.EncodeToString(msg.Value))
+}
+
+func (d *valueDecoder) writeError(msg *sarama.ConsumerMessage, decodeErr error) {
+	if d.errorSink == nil {
+		return
+	}
+
+	record, err := json.Marshal(errorRecord{
+		Topic:     msg.Topic,
+		Partition: msg.Partition,
+		Offset:    msg.Offset,
+		Timestamp: msg.Timestamp,
+		Key:       msg.Key,
+		Value:     msg.Value,
+		Error:     decodeErr.Error(),
+	})
+	if err == nil {
+		_, err = fmt.Fprintln(d.errorSink, string(record))
+	}
+	if err != nil {
+		log.Printf("Could not write message at offset %d of %s partition %d to the error file: %v", msg.Offset, msg.Topic, msg.Partition, err)
+	}
+}
+
+// openErrorFile makes the decoder write the messages it fails to decode to the given file
+func (d *valueDecoder) openErrorFile(path string) (closeFile func()) {
+	if d.decode == nil && d.unwrap == nil {
+		log.Fatal("--error-file requires a decoder (--decode)")
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		log.Fatal("Could not create error file: ", err)
+	}
+	d.errorSink = file
+
+	return func() {
+		if err := file.Close(); err != nil {
+			log.Print("Could not properly close the error file: ", err)
+		}
+	}
+}
+
+// logSummary reports the number of messages that could not be decoded
+func (d *valueDecoder) logSummary(errorFile string) {
+	if d.failures == 0 {
+		return
+	}
+	if errorFile != "" {
+		kafkatools.Log.Warnf("%d messages could not be decoded, they were written to %s", d.failures, errorFile)
+	} else {
+		kafkatools.Log.Warnf("%d messages could not be decoded", d.failures)
+	}
+}
+
+func decodeConsumerOffsets(msg *sarama.ConsumerMessage) ([]byte, error) {
+	record, err := kafkatools.DecodeConsumerOffsetsRecord(msg.Key, msg.Value)
+	if err != nil {
+		return nil, err
+	}
+
+	switch record := record.(type) {
+	case *kafkatools.OffsetCommit:
+		return []byte(formatOffsetCommit(record)), nil
+	case *kafkatools.GroupMetadata:
+		return []byte(formatGroupMetadata(record)), nil
+	}
+	return nil, fmt.Errorf("unexpected record %T", record)
+}
+
+func formatOffsetCommit(commit *kafkatools.OffsetCommit) string {
+	str := fmt.Sprintf("offset-commit group=%s topic=%s partition=%d", commit.Group, commit.Topic, commit.Partition)
+	if commit.Value == nil {
+		return str + " deleted"
+	}
+
+	value := commit.Value
+	str += fmt.Sprintf(" offset=%d", value.Offset)
+	if value.LeaderEpoch >= 0 {
+		str += fmt.Sprintf(" leader_epoch=%d", value.LeaderEpoch)
+	}
+	str += fmt.Sprintf(" metadata=%q committed=%s", value.Metadata, formatMillis(value.CommitTimestamp))
+	if value.ExpireTimestamp >= 0 {
+		str += " expires=" + formatMillis(value.ExpireTimestamp)
+	}
+	return str
+}
+
+func formatGroupMetadata(metadata *kafkatools.GroupMetadata) string {
+	str := fmt.Sprintf("group-metadata group=%s", metadata.Group)
+	if metadata.Value == nil {
+		return str + " deleted"
+	}
+
+	value := metadata.Value
+	str += fmt.Sprintf(" protocol_type=%s generation=%d protocol=%s leader=%s", value.ProtocolType, value.Generation, value.Protocol, value.Leader)
+	if value.CurrentStateTimestamp >= 0 {
+		str += " state_changed=" + formatMillis(value.CurrentStateTimestamp)
+	}
+	str += fmt.Sprintf(" members=%d", len(value.Members))
+
+	for _, member := range value.Members {
+		str += fmt.Sprintf(" [member=%s", member.MemberID)
+		if member.GroupInstanceID != "" {
+			str += " instance_id=" + member.GroupInstanceID
+		}
+		str += fmt.Sprintf(" client_id=%s host=%s assignment=%s]", member.ClientID, member.ClientHost, formatAssignment(member.Assignment))
+	}
+	return str
+}
+
+// formatAssignment formats assignments like topic:0,1;other:2
+func formatAssignment(assignments []kafkatools.TopicAssignment) string {
+	topics := make([]string, len(assignments))
+	for i, assignment := range assignments {
+		partitions := make([]string, len(assignment.Partitions))
+		for j, partition := range assignment.Partitions {
+			partitions[j] = strconv.Itoa(int(partition))
+		}
+		topics[i] = assignment.Topic + ":" + strings.Join(partitions, ",")
+	}
+	return strings.Join(topics, ";")
+}
+
+func formatMillis(millis int64) string {
+	return time.Unix(0, millis*int64(time.Millisecond)).UTC().Format("2006-01-02T15:04:05.000Z07:00")
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"sync"
+
+	"github.com/Shopify/sarama"
+)
+
+// commandDecoder decodes values using a long-running external command. Every value is written to its stdin as a
+// length-prefixed frame (the framing of --output binary) and the command answers with the decoded value as a frame
+// on its stdout, a null frame (length -1) when it could not decode the value. Values are sent one at a time, the
+// command has to flush its stdout after every answer.
+type commandDecoder struct {
+	mutex  sync.Mutex
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	writer *bufio.Writer
+	reader *bufio.Reader
+}
+
+// startCommandDecoder runs the command using sh, its stderr is passed through
+func startCommandDecoder(command string) (*commandDecoder, error) {
+	cmd := exec.Command("sh", "-c", command)
+	cmd.Stderr = os.Stderr
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	return &commandDecoder{cmd: cmd, stdin: stdin, writer: bufio.NewWriter(stdin), reader: bufio.NewReader(stdout)}, nil
+}
+
+// decode sends the value to the command and waits for its answer. A command which stopped answering can't decode any
+// following messages either, so I/O errors are fatal.
+func (d *commandDecoder) decode(msg *sarama.ConsumerMessage) ([]byte, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if _, err := d.writer.Write(appendFrame(nil, msg.Value)); err != nil {
+		log.Fatal("Could not write to the decoder command: ", err)
+	}
+	if err := d.writer.Flush(); err != nil {
+		log.Fatal("Could not write to the decoder command: ", err)
+	}
+
+	value, err := readFrame(d.reader)
+	if err != nil {
+		log.Fatal("Could not read the answer of the decoder command: ", err)
+	}
+	if value == nil {
+		return nil, errors.New("the decoder command could not decode the value")
+	}
+	return value, nil
+}
+
+// close closes the stdin of the command and waits for it to exit
+func (d *commandDecoder) close() error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if err := d.stdin.Close(); err != nil {
+		return err
+	}
+	return d.cmd.Wait()
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/Shopify/sarama"
+)
+
+func TestCommandDecoder(t *testing.T) {
+	// cat answers every frame with the same frame
+	decoder, err := startCommandDecoder("cat")
+	if err != nil {
+		t.Fatal("Could not start the decoder command: ", err)
+	}
+
+	for _, value := range []string{"a", "", "line\nbreak"} {
+		decoded, err := decoder.decode(&sarama.ConsumerMessage{Value: []byte(value)})
+		if err != nil {
+			t.Errorf("Unexpected error decoding %q: %v", value, err)
+		} else if string(decoded) != value {
+			t.Errorf("Expected %q, got %q", value, decoded)
+		}
+	}
+
+	if err := decoder.close(); err != nil {
+		t.Error("Unexpected error closing the decoder command: ", err)
+	}
+}
+
+func TestCommandDecoderFailure(t *testing.T) {
+	// Answer with a null frame and discard the input
+	decoder, err := startCommandDecoder(`printf '\377\377\377\377'; cat > /dev/null`)
+	if err != nil {
+		t.Fatal("Could not start the decoder command: ", err)
+	}
+
+	if decoded, err := decoder.decode(&sarama.ConsumerMessage{Value: []byte("a")}); err == nil {
+		t.Errorf("Expected an error, got %q", decoded)
+	}
+
+	if err := decoder.close(); err != nil {
+		t.Error("Unexpected error closing the decoder command: ", err)
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/Shopify/sarama"
+	"github.com/jurriaan/kafkatools"
+)
+
+func TestFormatOffsetCommit(t *testing.T) {
+	commit := &kafkatools.OffsetCommit{
+		Group:     "group",
+		Topic:     "topic",
+		Partition: 1,
+		Value:     &kafkatools.OffsetCommitValue{Offset: 42, LeaderEpoch: -1, CommitTimestamp: 1500000000000, ExpireTimestamp: -1},
+	}
+
+	expected := `offset-commit group=group topic=topic partition=1 offset=42 metadata="" committed=2017-07-14T02:40:00.000Z`
+	if output := formatOffsetCommit(commit); output != expected {
+		t.Errorf("Expected %s, got %s", expected, output)
+	}
+
+	commit.Value = nil
+	if output := formatOffsetCommit(commit); output != "offset-commit group=group topic=topic partition=1 deleted" {
+		t.Errorf("Unexpected output for a tombstone: %s", output)
+	}
+}
+
+func TestDecodeValueFallsBackToHex(t *testing.T) {
+	var errorSink bytes.Buffer
+	decoder := &valueDecoder{decode: decodeConsumerOffsets, errorSink: &errorSink}
+	msg := &sarama.ConsumerMessage{Topic: "foo", Offset: 3, Key: []byte{0xff}, Value: []byte{0xca, 0xfe}}
+
+	if output := string(decoder.decodeValue(msg)); output != "cafe" {
+		t.Errorf("Expected the hex encoded value, got %s", output)
+	}
+
+	if decoder.failures != 1 {
+		t.Errorf("Expected 1 failure, got %d", decoder.failures)
+	}
+
+	var record errorRecord
+	if err := json.Unmarshal(errorSink.Bytes(), &record); err != nil {
+		t.Fatal("Could not parse the error record: ", err)
+	}
+	if record.Topic != "foo" || record.Offset != 3 || !bytes.Equal(record.Value, msg.Value) || record.Error == "" {
+		t.Errorf("Unexpected error record %+v", record)
+	}
+}
+
+func TestDecodeValueWithoutDecoder(t *testing.T) {
+	msg := &sarama.ConsumerMessage{Value: []byte("raw")}
+
+	if output := string(newValueDecoder("").decodeValue(msg)); output != "raw" {
+		t.Errorf("Expected the raw value, got %s", output)
+	}
+}
+
+func TestDecodeValueUnwrapsBeforeDecoding(t *testing.T) {
+	// The msgpack encoding of {"a":1}, base64 encoded in an envelope
+	msg := &sarama.ConsumerMessage{Value: []byte(`{"payload": "gaFhAQ=="}`)}
+	decoder := &valueDecoder{decode: decodeMsgpack, unwrap: newUnwrapper("payload", true)}
+
+	if output := string(decoder.decodeValue(msg)); output != `{"a":1}` {
+		t.Errorf("Expected the decoded payload, got %s", output)
+	}
+	if string(msg.Value) != `{"payload": "gaFhAQ=="}` {
+		t.Errorf("Expected the message to be left untouched, got %s", msg.Value)
+	}
+}
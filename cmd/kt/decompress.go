@@ -0,0 +1,25 @@
+package main
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/Shopify/sarama"
+	"github.com/jurriaan/kafkatools"
+)
+
+// newDecompressor returns the decompression of --decompress, the values which are not compressed by the codec are
+// kept as is with a warning for the first one
+func newDecompressor(codec string) func(msg *sarama.ConsumerMessage) ([]byte, error) {
+	var warning sync.Once
+	return func(msg *sarama.ConsumerMessage) ([]byte, error) {
+		value, err := kafkatools.DecompressValue(msg.Value, codec)
+		if errors.Is(err, kafkatools.ErrNotCompressed) {
+			warning.Do(func() {
+				kafkatools.Log.Warnf("WARNING: the message at offset %d of %s partition %d is not %s compressed, printing it and the other uncompressed values as is", msg.Offset, msg.Topic, msg.Partition, codec)
+			})
+			return msg.Value, nil
+		}
+		return value, err
+	}
+}
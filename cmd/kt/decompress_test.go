@@ -0,0 +1,37 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"testing"
+
+	"github.com/Shopify/sarama"
+)
+
+func TestDecompressValues(t *testing.T) {
+	var gzipped bytes.Buffer
+	writer := gzip.NewWriter(&gzipped)
+	writer.Write([]byte(`{"a":1}`))
+	writer.Close()
+
+	decoder := newValueDecoder("")
+	decoder.decompress = newDecompressor("gzip")
+	decoder.unwrap = newUnwrapper("a", false)
+
+	if value := string(decoder.decodeValue(&sarama.ConsumerMessage{Value: gzipped.Bytes()})); value != "1" {
+		t.Errorf("Expected the value to be decompressed before it is unwrapped, got %q", value)
+	}
+
+	decoder.unwrap = nil
+	if value := string(decoder.decodeValue(&sarama.ConsumerMessage{Value: []byte("plain")})); value != "plain" {
+		t.Errorf("Expected an uncompressed value to be kept, got %q", value)
+	}
+	if value := decoder.decodeValue(&sarama.ConsumerMessage{}); value != nil {
+		t.Errorf("Expected a tombstone to stay nil, got %q", value)
+	}
+
+	// A corrupt value fails like the values which can't be decoded
+	if value := string(decoder.decodeValue(&sarama.ConsumerMessage{Value: []byte{0x1f, 0x8b, 0x00}})); value != "1f8b00" || decoder.failures != 1 {
+		t.Errorf("Expected the corrupt value to be printed as hex, got %q with %d failures", value, decoder.failures)
+	}
+}
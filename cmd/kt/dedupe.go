@@ -0,0 +1,94 @@
+package main
+
+import (
+	"hash/fnv"
+	"sync"
+
+	"github.com/Shopify/sarama"
+	"github.com/jurriaan/kafkatools"
+)
+
+// valueDeduper suppresses messages whose value has the same hash as an earlier message: consecutive compares with
+// the previous message of the same partition, global with the last maxSeen distinct values of all partitions.
+// Tombstones are never suppressed.
+type valueDeduper struct {
+	mutex   sync.Mutex
+	global  bool
+	maxSeen int
+	// seen are the hashes of the values remembered by global, recent is the ring buffer of their insertion order used
+	// to forget the oldest
+	seen   map[uint64]struct{}
+	recent []uint64
+	next   int
+	// previous is the value hash of the last message of every partition
+	previous   map[topicPartition]uint64
+	suppressed int64
+}
+
+// newValueDeduper returns the deduper of the mode, consecutive or global, global remembers at most maxSeen values
+func newValueDeduper(mode string, maxSeen int) *valueDeduper {
+	if mode == "global" {
+		return &valueDeduper{global: true, maxSeen: maxSeen, seen: make(map[uint64]struct{}, maxSeen)}
+	}
+	return &valueDeduper{previous: make(map[topicPartition]uint64)}
+}
+
+// filter returns the filter matching the messages which are not duplicates, nil for a nil deduper
+func (d *valueDeduper) filter() messageFilter {
+	if d == nil {
+		return nil
+	}
+	return d.unique
+}
+
+func (d *valueDeduper) unique(msg *sarama.ConsumerMessage) bool {
+	if msg.Value == nil {
+		return true
+	}
+
+	hash := fnv.New64a()
+	hash.Write(msg.Value)
+	sum := hash.Sum64()
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	duplicate := d.remember(topicPartition{Topic: msg.Topic, Partition: msg.Partition}, sum)
+	if duplicate {
+		d.suppressed++
+	}
+	return !duplicate
+}
+
+// remember records the value hash and returns whether it is a duplicate
+func (d *valueDeduper) remember(partition topicPartition, sum uint64) bool {
+	if !d.global {
+		previous, ok := d.previous[partition]
+		d.previous[partition] = sum
+		return ok && previous == sum
+	}
+
+	if _, ok := d.seen[sum]; ok {
+		return true
+	}
+	if len(d.recent) < d.maxSeen {
+		d.recent = append(d.recent, sum)
+	} else {
+		delete(d.seen, d.recent[d.next])
+		d.recent[d.next] = sum
+		d.next = (d.next + 1) % d.maxSeen
+	}
+	d.seen[sum] = struct{}{}
+	return false
+}
+
+// logSummary logs the number of suppressed duplicates, it is a no-op on a nil deduper
+func (d *valueDeduper) logSummary() {
+	if d == nil {
+		return
+	}
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	kafkatools.Log.Infof("Suppressed %d duplicate values", d.suppressed)
+}
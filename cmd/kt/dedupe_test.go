@@ -0,0 +1,56 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/Shopify/sarama"
+)
+
+func TestValueDeduper(t *testing.T) {
+	messages := []*sarama.ConsumerMessage{
+		{Topic: "foo", Partition: 0, Value: []byte("a")},
+		{Topic: "foo", Partition: 0, Value: []byte("a")},
+		{Topic: "foo", Partition: 1, Value: []byte("a")},
+		{Topic: "foo", Partition: 0, Value: []byte("b")},
+		{Topic: "foo", Partition: 0, Value: []byte("a")},
+		{Topic: "foo", Partition: 0},
+		{Topic: "foo", Partition: 0},
+		{Topic: "foo", Partition: 0, Value: []byte("c")},
+		{Topic: "foo", Partition: 0, Value: []byte("a")},
+	}
+
+	tests := []struct {
+		mode     string
+		maxSeen  int
+		expected []bool
+	}{
+		// Only repeats within a partition are consecutive, tombstones are always kept
+		{"consecutive", 0, []bool{true, false, true, true, true, true, true, true, true}},
+		{"global", 10, []bool{true, false, false, true, false, true, true, true, false}},
+		// Remembering two values forgets a once c is seen
+		{"global", 2, []bool{true, false, false, true, false, true, true, true, true}},
+	}
+
+	for _, test := range tests {
+		deduper := newValueDeduper(test.mode, test.maxSeen)
+		filter := deduper.filter()
+
+		var suppressed int64
+		for i, msg := range messages {
+			if kept := filter(msg); kept != test.expected[i] {
+				t.Errorf("Expected %s (%d) to keep message %d: %v, got %v", test.mode, test.maxSeen, i, test.expected[i], kept)
+			}
+			if !test.expected[i] {
+				suppressed++
+			}
+		}
+		if deduper.suppressed != suppressed {
+			t.Errorf("Expected %s (%d) to suppress %d duplicates, got %d", test.mode, test.maxSeen, suppressed, deduper.suppressed)
+		}
+	}
+
+	var deduper *valueDeduper
+	if deduper.filter() != nil {
+		t.Error("Expected no filter without a deduper")
+	}
+}
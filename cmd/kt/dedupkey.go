@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"sort"
+
+	"github.com/Shopify/sarama"
+	"github.com/jurriaan/kafkatools"
+)
+
+// recordKey is a key of a partition, the keys of --dedup-key are deduplicated per partition like compaction does
+type recordKey struct {
+	topicPartition
+	key string
+}
+
+// dedupeKeys buffers the messages until the channel is closed and then sends the last message of every key, in the
+// order the keys were first seen or sorted by key. Messages without a key are all sent. It fails when more than
+// maxBuffer messages (one per key) have to be buffered.
+func dedupeKeys(messages <-chan *sarama.ConsumerMessage, maxBuffer int, sortKeys bool) chan *sarama.ConsumerMessage {
+	deduplicated := make(chan *sarama.ConsumerMessage)
+	go func() {
+		defer close(deduplicated)
+		latest, consumed, err := latestPerKey(messages, maxBuffer)
+		if err != nil {
+			log.Fatal("Could not deduplicate the keys: ", err)
+		}
+		if sortKeys {
+			sortByKey(latest)
+		}
+
+		kafkatools.Log.Infof("Consumed %d messages, printing the latest of %d keys", consumed, len(latest))
+		for _, msg := range latest {
+			deduplicated <- msg
+		}
+	}()
+	return deduplicated
+}
+
+// latestPerKey reads all messages of the channel and returns the last message of every key in the order the keys were
+// first seen, along with the number of messages read
+func latestPerKey(messages <-chan *sarama.ConsumerMessage, maxBuffer int) (latest []*sarama.ConsumerMessage, consumed int, err error) {
+	positions := make(map[recordKey]int)
+	for msg := range messages {
+		consumed++
+		if msg.Key != nil {
+			key := recordKey{topicPartition{Topic: msg.Topic, Partition: msg.Partition}, string(msg.Key)}
+			if position, ok := positions[key]; ok {
+				latest[position] = msg
+				continue
+			}
+			positions[key] = len(latest)
+		}
+		if len(latest) >= maxBuffer {
+			return nil, consumed, fmt.Errorf("the range holds more than %d keys, raise --max-buffer or narrow the range", maxBuffer)
+		}
+		latest = append(latest, msg)
+	}
+	return latest, consumed, nil
+}
+
+// sortByKey sorts the messages by key, then by topic and partition. Messages without a key keep their order after
+// the others.
+func sortByKey(messages []*sarama.ConsumerMessage) {
+	sort.SliceStable(messages, func(i, j int) bool {
+		a, b := messages[i], messages[j]
+		if a.Key == nil || b.Key == nil {
+			return a.Key != nil && b.Key == nil
+		}
+		if order := bytes.Compare(a.Key, b.Key); order != 0 {
+			return order < 0
+		}
+		if a.Topic != b.Topic {
+			return a.Topic < b.Topic
+		}
+		return a.Partition < b.Partition
+	})
+}
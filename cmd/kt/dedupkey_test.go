@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/Shopify/sarama"
+)
+
+func TestDedupeKeys(t *testing.T) {
+	keyed := func(key string, offset int64) *sarama.ConsumerMessage {
+		return &sarama.ConsumerMessage{Topic: "foo", Key: []byte(key), Offset: offset}
+	}
+	input := []*sarama.ConsumerMessage{
+		keyed("b", 0), keyed("a", 1), {Topic: "foo", Offset: 2}, keyed("b", 3),
+		{Topic: "foo", Key: []byte("a"), Partition: 1, Offset: 0}, {Topic: "foo", Offset: 4}, keyed("a", 5),
+	}
+	run := func(sortKeys bool) (printed []string) {
+		messages := make(chan *sarama.ConsumerMessage, len(input))
+		for _, msg := range input {
+			messages <- msg
+		}
+		close(messages)
+		for msg := range dedupeKeys(messages, 10, sortKeys) {
+			printed = append(printed, fmt.Sprintf("%s@%d:%d", msg.Key, msg.Partition, msg.Offset))
+		}
+		return printed
+	}
+
+	// The keys are deduplicated per partition, the messages without a key are all kept
+	expected := "[b@0:3 a@0:5 @0:2 a@1:0 @0:4]"
+	if printed := fmt.Sprint(run(false)); printed != expected {
+		t.Errorf("Expected %s, got %s", expected, printed)
+	}
+	expected = "[a@0:5 a@1:0 b@0:3 @0:2 @0:4]"
+	if printed := fmt.Sprint(run(true)); printed != expected {
+		t.Errorf("Expected %s sorted by key, got %s", expected, printed)
+	}
+}
+
+func TestLatestPerKeyMaxBuffer(t *testing.T) {
+	messages := make(chan *sarama.ConsumerMessage, 4)
+	for _, key := range []string{"a", "a", "b", "c"} {
+		messages <- &sarama.ConsumerMessage{Key: []byte(key)}
+	}
+	close(messages)
+
+	if _, _, err := latestPerKey(messages, 2); err == nil {
+		t.Error("Expected an error for more keys than the buffer holds")
+	}
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"log"
+
+	"github.com/Shopify/sarama"
+)
+
+// destinationClient returns the client of the cluster the messages are produced to: the --to-broker cluster, or the
+// consumed cluster itself. The returned function closes the client of the --to-broker cluster.
+func destinationClient(client sarama.Client, parsedOptions options) (destination sarama.Client, closeDestination func()) {
+	if len(parsedOptions.toBrokers) == 0 {
+		return client, func() {}
+	}
+
+	destination = newClient(&parsedOptions.toClientConfig, parsedOptions.toBrokers...)
+	if parsedOptions.detectVersion {
+		destination = withDetectedVersion(destination, parsedOptions.toClientConfig, parsedOptions.toBrokers)
+	}
+	return destination, func() {
+		if err := destination.Close(); err != nil {
+			log.Println("Error closing the client of the destination cluster: ", err)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/Shopify/sarama"
+	"github.com/jurriaan/kafkatools"
+)
+
+func TestParseDestinationConfig(t *testing.T) {
+	docOpts := map[string]interface{}{
+		"--sasl-mechanism":    "oauthbearer",
+		"--token":             "prod-token",
+		"--to-sasl-mechanism": nil,
+	}
+	source := kafkatools.ClientConfig{
+		SASL:           parseSASLConfig(docOpts, "--"),
+		Version:        sarama.V2_0_0_0,
+		BrokerRewrites: map[string]string{"kafka-1:9092": "localhost:19092"},
+	}
+
+	destination := parseDestinationConfig(docOpts, source)
+	if destination.SASL.Mechanism != "" || destination.BrokerRewrites != nil || destination.Version != sarama.V2_0_0_0 {
+		t.Errorf("Expected an open destination with the version of the source, got %+v", destination)
+	}
+	if source.SASL.Mechanism != sarama.SASLTypeOAuth {
+		t.Errorf("Expected the source to keep authenticating, got %+v", source.SASL)
+	}
+
+	docOpts["--to-sasl-mechanism"] = "OAUTHBEARER"
+	docOpts["--to-token"] = "dev-token"
+	destination = parseDestinationConfig(docOpts, kafkatools.ClientConfig{})
+	provider, ok := destination.SASL.TokenProvider.(kafkatools.StaticTokenProvider)
+	if destination.SASL.Mechanism != sarama.SASLTypeOAuth || !ok || provider.AccessToken != "dev-token" {
+		t.Errorf("Expected the destination to authenticate with its own token, got %+v", destination.SASL)
+	}
+}
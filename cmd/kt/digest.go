@@ -0,0 +1,97 @@
+package main
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"hash"
+
+	"github.com/Shopify/sarama"
+	"github.com/jurriaan/kafkatools"
+)
+
+// digestAlgorithms are the hash functions --digest supports
+var digestAlgorithms = map[string]func() hash.Hash{
+	"md5":    md5.New,
+	"sha1":   sha1.New,
+	"sha256": sha256.New,
+	"sha512": sha512.New,
+}
+
+// rangeDigest digests the consumed records of every partition in offset order: the key and value of every record are
+// hashed as the frames of --output binary. The total digest hashes the partition numbers and digests in partition
+// order, so ranges of different topics (with the same partitioning) compare equal when their records do, whatever
+// their offsets and timestamps.
+type rangeDigest struct {
+	algorithm  string
+	partitions map[int32]*partitionDigest
+}
+
+type partitionDigest struct {
+	hash     hash.Hash
+	messages int
+}
+
+// newRangeDigest returns the digest of the records of the partitions, which are listed (as empty) when no record was
+// consumed from them
+func newRangeDigest(algorithm string, partitions []int32) *rangeDigest {
+	digest := &rangeDigest{algorithm: algorithm, partitions: make(map[int32]*partitionDigest, len(partitions))}
+	for _, partition := range partitions {
+		digest.partition(partition)
+	}
+	return digest
+}
+
+func (d *rangeDigest) partition(partition int32) *partitionDigest {
+	if digest, ok := d.partitions[partition]; ok {
+		return digest
+	}
+	digest := &partitionDigest{hash: digestAlgorithms[d.algorithm]()}
+	d.partitions[partition] = digest
+	return digest
+}
+
+// add hashes the record, the records of every partition have to be added in offset order
+func (d *rangeDigest) add(msg *sarama.ConsumerMessage) {
+	digest := d.partition(msg.Partition)
+	digest.hash.Write(appendRecordFrames(nil, msg.Key, msg.Value))
+	digest.messages++
+}
+
+// digestMessages adds the messages to the digest without printing them, the messages of every partition arrive in
+// offset order
+func digestMessages(messages <-chan *sarama.ConsumerMessage, maxMessages int, digest *rangeDigest) {
+	total := 0
+	for msg := range messages {
+		digest.add(msg)
+		total++
+
+		if maxMessages != -1 && total >= maxMessages {
+			kafkatools.Log.Infof("Quiting after %d messages", total)
+			break
+		}
+	}
+}
+
+func (d *rangeDigest) print(printer func(string)) {
+	partitions := make([]int32, 0, len(d.partitions))
+	for partition := range d.partitions {
+		partitions = append(partitions, partition)
+	}
+
+	total, messages := digestAlgorithms[d.algorithm](), 0
+	for _, partition := range sortedInt32s(partitions) {
+		digest := d.partitions[partition]
+		sum := digest.hash.Sum(nil)
+		printer(fmt.Sprintf("partition %d: %d messages, %s %s", partition, digest.messages, d.algorithm, hex.EncodeToString(sum)))
+
+		total.Write(binary.BigEndian.AppendUint32(nil, uint32(partition)))
+		total.Write(sum)
+		messages += digest.messages
+	}
+	printer(fmt.Sprintf("total: %d messages, %s %s", messages, d.algorithm, hex.EncodeToString(total.Sum(nil))))
+}
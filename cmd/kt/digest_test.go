@@ -0,0 +1,62 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Shopify/sarama"
+)
+
+func TestRangeDigest(t *testing.T) {
+	digestOf := func(partitions []int32, messages ...*sarama.ConsumerMessage) []string {
+		digest := newRangeDigest("sha256", partitions)
+		for _, msg := range messages {
+			digest.add(msg)
+		}
+
+		var printed []string
+		digest.print(func(str string) { printed = append(printed, str) })
+		return printed
+	}
+
+	// The same records at other offsets of another topic have the same digest
+	original := digestOf([]int32{0, 1, 2},
+		&sarama.ConsumerMessage{Topic: "foo", Partition: 1, Offset: 3, Key: []byte("a"), Value: []byte("1")},
+		&sarama.ConsumerMessage{Topic: "foo", Partition: 0, Offset: 5, Value: []byte("2")},
+		&sarama.ConsumerMessage{Topic: "foo", Partition: 1, Offset: 4, Key: []byte("b")},
+	)
+	mirrored := digestOf([]int32{2, 1, 0},
+		&sarama.ConsumerMessage{Topic: "bar", Partition: 0, Offset: 0, Value: []byte("2")},
+		&sarama.ConsumerMessage{Topic: "bar", Partition: 1, Offset: 0, Key: []byte("a"), Value: []byte("1")},
+		&sarama.ConsumerMessage{Topic: "bar", Partition: 1, Offset: 1, Key: []byte("b")},
+	)
+	if !reflect.DeepEqual(original, mirrored) {
+		t.Errorf("Expected the same digests, got %q and %q", original, mirrored)
+	}
+
+	expected := []string{"partition 0: 1 messages, sha256 ", "partition 1: 2 messages, sha256 ", "partition 2: 0 messages, sha256 e3b0c442", "total: 3 messages, sha256 "}
+	for i, prefix := range expected {
+		if i >= len(original) || !strings.HasPrefix(original[i], prefix) {
+			t.Errorf("Expected line %d to start with %q, got %q", i, prefix, original)
+		}
+	}
+
+	// Swapping the records of a partition, or a tombstone for an empty value, changes the digests
+	swapped := digestOf([]int32{0, 1, 2},
+		&sarama.ConsumerMessage{Topic: "foo", Partition: 1, Offset: 3, Key: []byte("b")},
+		&sarama.ConsumerMessage{Topic: "foo", Partition: 0, Offset: 5, Value: []byte("2")},
+		&sarama.ConsumerMessage{Topic: "foo", Partition: 1, Offset: 4, Key: []byte("a"), Value: []byte("1")},
+	)
+	if swapped[0] != original[0] || swapped[1] == original[1] || swapped[3] == original[3] {
+		t.Errorf("Expected only partition 1 and the total to change, got %q and %q", original, swapped)
+	}
+	emptied := digestOf([]int32{0, 1, 2},
+		&sarama.ConsumerMessage{Topic: "foo", Partition: 1, Offset: 3, Key: []byte("a"), Value: []byte("1")},
+		&sarama.ConsumerMessage{Topic: "foo", Partition: 0, Offset: 5, Value: []byte("2")},
+		&sarama.ConsumerMessage{Topic: "foo", Partition: 1, Offset: 4, Key: []byte("b"), Value: []byte{}},
+	)
+	if emptied[1] == original[1] {
+		t.Errorf("Expected an empty value to change the digest of %q", original[1])
+	}
+}
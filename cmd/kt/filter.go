@@ -0,0 +1,117 @@
+package main
+
+import (
+	"encoding/binary"
+	"hash/fnv"
+	"log"
+	"regexp"
+	"strings"
+
+	"github.com/Shopify/sarama"
+)
+
+// messageFilter returns whether a message should be emitted
+type messageFilter func(msg *sarama.ConsumerMessage) bool
+
+// newMessageFilter compiles the given patterns into a filter matching messages that match all of them.
+// It returns nil when no pattern is given.
+func newMessageFilter(valuePattern, keyPattern, headerPattern string) messageFilter {
+	var filters []messageFilter
+
+	if valuePattern != "" {
+		valueRegexp := compilePattern(valuePattern)
+		filters = append(filters, func(msg *sarama.ConsumerMessage) bool {
+			return valueRegexp.Match(msg.Value)
+		})
+	}
+
+	if keyPattern != "" {
+		keyRegexp := compilePattern(keyPattern)
+		filters = append(filters, func(msg *sarama.ConsumerMessage) bool {
+			return keyRegexp.Match(msg.Key)
+		})
+	}
+
+	if headerPattern != "" {
+		parts := strings.SplitN(headerPattern, "=", 2)
+		if len(parts) != 2 {
+			log.Fatalf("Invalid header filter %s, expected <header>=<regexp>", headerPattern)
+		}
+		headerKey, headerRegexp := parts[0], compilePattern(parts[1])
+		filters = append(filters, func(msg *sarama.ConsumerMessage) bool {
+			for _, header := range msg.Headers {
+				if string(header.Key) == headerKey && headerRegexp.Match(header.Value) {
+					return true
+				}
+			}
+			return false
+		})
+	}
+
+	return allFilters(filters...)
+}
+
+// invertFilter returns a filter matching the messages the filter rejects
+func invertFilter(filter messageFilter) messageFilter {
+	return func(msg *sarama.ConsumerMessage) bool {
+		return !filter(msg)
+	}
+}
+
+// newKeyPresenceFilter returns a filter matching the messages with a key when hasKey is set or without one when noKey
+// is set, nil when neither is set. Empty keys are keys.
+func newKeyPresenceFilter(hasKey, noKey bool) messageFilter {
+	if hasKey == noKey {
+		return nil
+	}
+	return func(msg *sarama.ConsumerMessage) bool {
+		return (msg.Key != nil) == hasKey
+	}
+}
+
+// allFilters combines the filters into one matching the messages that match all of them, nil filters are skipped.
+// It returns nil when no filter is left.
+func allFilters(filters ...messageFilter) messageFilter {
+	var combined []messageFilter
+	for _, filter := range filters {
+		if filter != nil {
+			combined = append(combined, filter)
+		}
+	}
+	if len(combined) == 0 {
+		return nil
+	}
+
+	return func(msg *sarama.ConsumerMessage) bool {
+		for _, filter := range combined {
+			if !filter(msg) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// newSampleFilter returns a filter matching about ratio of the messages. The selection is derived from the seed and
+// the position of the message rather than from a shared random source, so the same seed selects the same messages
+// in every run regardless of the order in which the partitions are consumed.
+func newSampleFilter(ratio float64, seed int64) messageFilter {
+	return func(msg *sarama.ConsumerMessage) bool {
+		hash := fnv.New64a()
+		var position [20]byte
+		binary.BigEndian.PutUint64(position[0:], uint64(seed))
+		binary.BigEndian.PutUint32(position[8:], uint32(msg.Partition))
+		binary.BigEndian.PutUint64(position[12:], uint64(msg.Offset))
+		hash.Write(position[:])
+		hash.Write([]byte(msg.Topic))
+		return float64(hash.Sum64()>>11)/(1<<53) < ratio
+	}
+}
+
+func compilePattern(pattern string) *regexp.Regexp {
+	compiled, err := regexp.Compile(pattern)
+	if err != nil {
+		log.Fatalf("Invalid pattern %s: %v", pattern, err)
+	}
+	return compiled
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/Shopify/sarama"
+)
+
+func TestMessageFilter(t *testing.T) {
+	msg := &sarama.ConsumerMessage{
+		Key:     []byte("user-1"),
+		Value:   []byte(`{"status": "FAILED"}`),
+		Headers: []*sarama.RecordHeader{{Key: []byte("content-type"), Value: []byte("application/json")}},
+	}
+
+	tests := []struct {
+		value, key, header string
+		expected           bool
+	}{
+		{"FAILED", "", "", true},
+		{"OK", "", "", false},
+		{"", "^user-", "", true},
+		{"FAILED", "^order-", "", false},
+		{"", "", "content-type=json$", true},
+		{"", "", "content-type=xml", false},
+		{"", "", "trace-id=.*", false},
+	}
+
+	for _, test := range tests {
+		filter := newMessageFilter(test.value, test.key, test.header)
+		if matched := filter(msg); matched != test.expected {
+			t.Errorf("Expected filter %+v to return %v, got %v", test, test.expected, matched)
+		}
+	}
+
+	if newMessageFilter("", "", "") != nil {
+		t.Error("Expected no filter without patterns")
+	}
+}
+
+func TestInvertFilter(t *testing.T) {
+	filter := invertFilter(newMessageFilter("FAILED", "", ""))
+	if filter(&sarama.ConsumerMessage{Value: []byte(`{"status": "FAILED"}`)}) {
+		t.Error("Expected a matching message to be rejected")
+	}
+	if !filter(&sarama.ConsumerMessage{Value: []byte(`{"status": "OK"}`)}) {
+		t.Error("Expected a message which does not match to be emitted")
+	}
+}
+
+func TestKeyPresenceFilter(t *testing.T) {
+	keyed, empty, unkeyed := &sarama.ConsumerMessage{Key: []byte("user-1")}, &sarama.ConsumerMessage{Key: []byte{}}, &sarama.ConsumerMessage{}
+
+	hasKey := newKeyPresenceFilter(true, false)
+	if !hasKey(keyed) || !hasKey(empty) || hasKey(unkeyed) {
+		t.Error("Expected --has-key to match the messages with a (possibly empty) key")
+	}
+	noKey := newKeyPresenceFilter(false, true)
+	if noKey(keyed) || noKey(empty) || !noKey(unkeyed) {
+		t.Error("Expected --no-key to match only the messages without a key")
+	}
+	if newKeyPresenceFilter(false, false) != nil {
+		t.Error("Expected no filter without --has-key or --no-key")
+	}
+}
+
+func TestSampleFilter(t *testing.T) {
+	sampled := func(seed int64) []int64 {
+		filter := newSampleFilter(0.1, seed)
+		var offsets []int64
+		for offset := int64(0); offset < 10000; offset++ {
+			if filter(&sarama.ConsumerMessage{Topic: "foo", Offset: offset}) {
+				offsets = append(offsets, offset)
+			}
+		}
+		return offsets
+	}
+
+	first, again, other := sampled(42), sampled(42), sampled(43)
+	if len(first) < 800 || len(first) > 1200 {
+		t.Errorf("Expected about 1000 sampled messages, got %d", len(first))
+	}
+	if fmt.Sprint(first) != fmt.Sprint(again) {
+		t.Error("Expected the same seed to sample the same messages")
+	}
+	if fmt.Sprint(first) == fmt.Sprint(other) {
+		t.Error("Expected another seed to sample other messages")
+	}
+
+	if allFilters(nil, nil) != nil {
+		t.Error("Expected no filter when all filters are nil")
+	}
+}
+
+func TestCountMessages(t *testing.T) {
+	channel := make(chan *sarama.ConsumerMessage, 3)
+	channel <- &sarama.ConsumerMessage{Partition: 0}
+	channel <- &sarama.ConsumerMessage{Partition: 1}
+	channel <- &sarama.ConsumerMessage{Partition: 1}
+	close(channel)
+
+	counts, total := countMessages(channel, -1)
+	if total != 3 || counts[0] != 1 || counts[1] != 2 {
+		t.Errorf("Unexpected counts %+v (total %d)", counts, total)
+	}
+
+	var lines []string
+	printCounts(counts, total, func(str string) { lines = append(lines, str) })
+	if len(lines) != 3 || lines[2] != "total: 3" {
+		t.Errorf("Unexpected output %+v", lines)
+	}
+}
@@ -0,0 +1,20 @@
+package main
+
+import "github.com/jurriaan/kafkatools"
+
+// firstMessageRange limits the range of every partition to its first message, the oldest offsets have to be the start
+// offsets. Empty partitions are left out, as there is no message to wait for.
+func firstMessageRange(topic string, partitionOffsets, newest offsetMap) (starts, ends offsetMap) {
+	starts, ends = make(offsetMap), make(offsetMap)
+	for partition, start := range partitionOffsets {
+		if start.Offset >= newest[partition].Offset {
+			kafkatools.Log.Warnf("%s partition %d is empty, skipping it", topic, partition)
+			continue
+		}
+
+		end := start
+		end.Offset++
+		starts[partition], ends[partition] = start, end
+	}
+	return starts, ends
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/jurriaan/kafkatools"
+)
+
+func TestFirstMessageRange(t *testing.T) {
+	oldest := offsetMap{
+		0: kafkatools.TopicPartitionOffset{Topic: "foo", Partition: 0, Offset: 10},
+		1: kafkatools.TopicPartitionOffset{Topic: "foo", Partition: 1, Offset: 5},
+	}
+	newest := offsetMap{
+		0: kafkatools.TopicPartitionOffset{Topic: "foo", Partition: 0, Offset: 20},
+		1: kafkatools.TopicPartitionOffset{Topic: "foo", Partition: 1, Offset: 5},
+	}
+
+	starts, ends := firstMessageRange("foo", oldest, newest)
+	if expected := (offsetMap{0: oldest[0]}); !reflect.DeepEqual(starts, expected) {
+		t.Errorf("Expected the empty partition to be left out, got %v", starts)
+	}
+	if expected := (offsetMap{0: kafkatools.TopicPartitionOffset{Topic: "foo", Partition: 0, Offset: 11}}); !reflect.DeepEqual(ends, expected) {
+		t.Errorf("Expected the range to end after the first message, got %v", ends)
+	}
+}
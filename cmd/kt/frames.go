@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// nullFrameLength is the frame length of a missing key or a tombstone value
+const nullFrameLength = -1
+
+// appendFrame appends the data prefixed by its length as a 4-byte big-endian signed integer, nil is written as a
+// length of -1 so missing keys and tombstones survive a round-trip
+func appendFrame(out, data []byte) []byte {
+	length := int32(len(data))
+	if data == nil {
+		length = nullFrameLength
+	}
+
+	var prefix [4]byte
+	binary.BigEndian.PutUint32(prefix[:], uint32(length))
+	return append(append(out, prefix[:]...), data...)
+}
+
+// appendRecordFrames appends the key frame followed by the value frame of a record
+func appendRecordFrames(out, key, value []byte) []byte {
+	return appendFrame(appendFrame(out, key), value)
+}
+
+// readFrame reads a single length-prefixed frame, it returns io.EOF when the input ends before the frame starts
+func readFrame(reader *bufio.Reader) ([]byte, error) {
+	var length int32
+	if err := binary.Read(reader, binary.BigEndian, &length); err != nil {
+		return nil, err
+	}
+
+	if length == nullFrameLength {
+		return nil, nil
+	} else if length < 0 || length > maxLineSize {
+		return nil, fmt.Errorf("invalid frame length %d", length)
+	}
+
+	data := make([]byte, length)
+	if _, err := io.ReadFull(reader, data); err == io.EOF {
+		return nil, io.ErrUnexpectedEOF
+	} else if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// readRecordFrames calls emit for every key and value frame pair of the input until it ends
+func readRecordFrames(input io.Reader, emit func(record inputRecord)) error {
+	reader := bufio.NewReader(input)
+	for {
+		key, err := readFrame(reader)
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("could not read the key frame: %v", err)
+		}
+
+		value, err := readFrame(reader)
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return fmt.Errorf("could not read the value frame: %v", err)
+		}
+
+		emit(inputRecord{key: key, value: value})
+	}
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestRecordFramesRoundTrip(t *testing.T) {
+	records := [][2][]byte{
+		{[]byte("key"), []byte("value")},
+		{nil, []byte("line\nbreak\x00binary")},
+		{[]byte("tombstone"), nil},
+		{[]byte{}, []byte{}},
+	}
+
+	var input []byte
+	for _, record := range records {
+		input = appendRecordFrames(input, record[0], record[1])
+	}
+
+	var read [][2][]byte
+	err := readRecordFrames(bytes.NewReader(input), func(record inputRecord) {
+		read = append(read, [2][]byte{record.key, record.value})
+	})
+	if err != nil {
+		t.Fatal("Unexpected error: ", err)
+	}
+	if !reflect.DeepEqual(read, records) {
+		t.Errorf("Expected records %q, got %q", records, read)
+	}
+}
+
+func TestReadRecordFramesTruncated(t *testing.T) {
+	input := appendRecordFrames(nil, []byte("key"), []byte("value"))
+	for _, length := range []int{4, 7, len(input) - 1} {
+		err := readRecordFrames(bytes.NewReader(input[:length]), func(inputRecord) {})
+		if err == nil {
+			t.Errorf("Expected an error for input truncated to %d bytes", length)
+		}
+	}
+}
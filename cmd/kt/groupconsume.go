@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/Shopify/sarama"
+	"github.com/jurriaan/kafkatools"
+)
+
+// consumeGroup joins the consumer group and prints the messages of the partitions assigned to it, starting at the
+// offsets committed by the group. Every printed (or filtered out) message is committed right after it is handled, the
+// client disables the automatic commits, so a restarted kt resumes after the last printed message. The partitions
+// are split over the members of the group again whenever a member joins or leaves.
+func consumeGroup(client sarama.Client, parsedOptions options) {
+	group, err := sarama.NewConsumerGroupFromClient(parsedOptions.group, client)
+	if err != nil {
+		log.Fatal("Could not join the group: ", err)
+	}
+	go func() {
+		for err := range group.Errors() {
+			kafkatools.Log.Errorf("error: we got an error while consuming as group %s: %v", parsedOptions.group, err)
+		}
+	}()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	closing := make(chan struct{})
+	stop := shutdownHandler(closing, parsedOptions)
+	go func() {
+		<-closing
+		cancel()
+	}()
+
+	var out io.Writer = os.Stdout
+	if parsedOptions.outputFile != "" {
+		file := createOutputFile(parsedOptions)
+		defer closeOutputFile(file)
+		out = file
+	}
+	if parsedOptions.limitBytes > 0 {
+		out = &limitedWriter{out: out, max: parsedOptions.limitBytes, stop: stop}
+	}
+
+	formatter := newMessageFormatter(parsedOptions.output, parsedOptions.decoder, nil)
+	handler := &groupConsumer{
+		group:       parsedOptions.group,
+		filter:      parsedOptions.consumeOpts.filter,
+		maxMessages: parsedOptions.count,
+		stop:        stop,
+		print: func(msg *sarama.ConsumerMessage) {
+			writeMessage(out, parsedOptions.output.format, formatter(msg))
+		},
+	}
+
+	kafkatools.Log.Infof("Joining group %s", parsedOptions.group)
+	// Every rebalance ends the session, which is joined again until kt stops
+	for ctx.Err() == nil {
+		if err := group.Consume(ctx, parsedOptions.topics, handler); err != nil {
+			if errors.Is(err, sarama.ErrClosedConsumerGroup) {
+				break
+			}
+			log.Fatalf("Could not consume as group %s: %v", parsedOptions.group, err)
+		}
+	}
+	stop()
+
+	if err := group.Close(); err != nil {
+		log.Println("Error closing the consumer group: ", err)
+	}
+}
+
+// groupConsumer prints the messages of the claims of the consumer group sessions, the messages are marked as consumed
+// and committed once they are printed so the group resumes after them
+type groupConsumer struct {
+	group  string
+	filter messageFilter
+	print  func(msg *sarama.ConsumerMessage)
+	// maxMessages is the number of messages to print before stop is called, -1 prints every message
+	maxMessages int
+	stop        func()
+
+	// mutex serializes the claims, which are consumed concurrently
+	mutex   sync.Mutex
+	printed int
+}
+
+func (c *groupConsumer) Setup(session sarama.ConsumerGroupSession) error {
+	kafkatools.Log.Infof("Group %s assigned %s to this member", c.group, formatClaims(session.Claims()))
+	return nil
+}
+
+func (c *groupConsumer) Cleanup(session sarama.ConsumerGroupSession) error {
+	session.Commit()
+	return nil
+}
+
+func (c *groupConsumer) ConsumeClaim(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+	for {
+		select {
+		case msg, ok := <-claim.Messages():
+			if !ok || !c.handle(msg) {
+				return nil
+			}
+			session.MarkMessage(msg, "")
+			session.Commit()
+		case <-session.Context().Done():
+			return nil
+		}
+	}
+}
+
+// handle prints the message when it matches the filter, it returns false when the message was not handled because
+// the maximum number of messages was printed already
+func (c *groupConsumer) handle(msg *sarama.ConsumerMessage) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.maxMessages != -1 && c.printed >= c.maxMessages {
+		return false
+	}
+	if c.filter != nil && !c.filter(msg) {
+		return true
+	}
+
+	c.print(msg)
+	c.printed++
+	if c.maxMessages != -1 && c.printed >= c.maxMessages {
+		kafkatools.Log.Infof("Quiting after %d messages", c.printed)
+		c.stop()
+	}
+	return true
+}
+
+// formatClaims formats the claimed partitions per topic, e.g. bar partitions 1 and foo partitions 0, 2
+func formatClaims(claims map[string][]int32) string {
+	topics := make([]string, 0, len(claims))
+	for topic := range claims {
+		topics = append(topics, topic)
+	}
+	sort.Strings(topics)
+
+	formatted := make([]string, 0, len(topics))
+	for _, topic := range topics {
+		formatted = append(formatted, topic+" partitions "+formatPartitionList(sortedInt32s(claims[topic])))
+	}
+	if len(formatted) == 0 {
+		return "no partitions"
+	}
+	return strings.Join(formatted, " and ")
+}
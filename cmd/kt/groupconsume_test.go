@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Shopify/sarama"
+)
+
+// fakeGroupSession records the messages marked as consumed and the offsets committed
+type fakeGroupSession struct {
+	sarama.ConsumerGroupSession
+	ctx       context.Context
+	marked    []int64
+	committed []int64
+}
+
+func (s *fakeGroupSession) Context() context.Context { return s.ctx }
+
+func (s *fakeGroupSession) MarkMessage(msg *sarama.ConsumerMessage, metadata string) {
+	s.marked = append(s.marked, msg.Offset)
+}
+
+func (s *fakeGroupSession) Commit() {
+	if len(s.marked) > 0 {
+		s.committed = append(s.committed, s.marked[len(s.marked)-1])
+	}
+}
+
+type fakeGroupClaim struct {
+	sarama.ConsumerGroupClaim
+	messages chan *sarama.ConsumerMessage
+}
+
+func (c fakeGroupClaim) Messages() <-chan *sarama.ConsumerMessage { return c.messages }
+
+func TestGroupConsumerConsumeClaim(t *testing.T) {
+	claim := fakeGroupClaim{messages: make(chan *sarama.ConsumerMessage, 4)}
+	for offset, value := range []string{"a", "skip", "b", "c"} {
+		claim.messages <- &sarama.ConsumerMessage{Offset: int64(offset), Value: []byte(value)}
+	}
+	close(claim.messages)
+
+	var printed []string
+	stopped := false
+	consumer := &groupConsumer{
+		filter:      newMessageFilter("^[a-z]$", "", ""),
+		maxMessages: 2,
+		stop:        func() { stopped = true },
+		print:       func(msg *sarama.ConsumerMessage) { printed = append(printed, string(msg.Value)) },
+	}
+	session := &fakeGroupSession{ctx: context.Background()}
+
+	if err := consumer.ConsumeClaim(session, claim); err != nil {
+		t.Fatal(err)
+	}
+
+	// The filtered message is marked as well, the message after the last printed one is not
+	if len(printed) != 2 || printed[0] != "a" || printed[1] != "b" {
+		t.Errorf("Expected a and b to be printed, got %v", printed)
+	}
+	if len(session.marked) != 3 || session.marked[2] != 2 {
+		t.Errorf("Expected offsets 0 to 2 to be marked, got %v", session.marked)
+	}
+	// Every handled message is committed before the next one is read
+	if len(session.committed) != 3 || session.committed[0] != 0 || session.committed[2] != 2 {
+		t.Errorf("Expected offsets 0 to 2 to be committed one by one, got %v", session.committed)
+	}
+	if !stopped {
+		t.Error("Expected the consumer to be stopped after 2 messages")
+	}
+}
+
+func TestFormatClaims(t *testing.T) {
+	if formatted := formatClaims(map[string][]int32{"foo": {2, 0}, "bar": {1}}); formatted != "bar partitions 1 and foo partitions 0, 2" {
+		t.Errorf("Unexpected claims %q", formatted)
+	}
+	if formatted := formatClaims(nil); formatted != "no partitions" {
+		t.Errorf("Expected no partitions, got %q", formatted)
+	}
+}
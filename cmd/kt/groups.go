@@ -0,0 +1,208 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/jurriaan/kafkatools"
+)
+
+// offsetTarget is the parsed --to or --to-date of kt reset-offsets: an offset expression, the offsets of the listed
+// partitions or a timestamp in milliseconds
+type offsetTarget struct {
+	expr *offsetExpression
+	// partitions are the offsets of a partition=offset list, only the listed partitions are reset
+	partitions map[int32]int64
+	timestamp  int64
+}
+
+// parseOffsetTarget parses a comma separated partition=offset list (see parseOffsetFor), an offset expression (see
+// parseOffsetExpression) or a timestamp in the formats of --start-date. Numbers are offsets, --to-date takes Unix
+// timestamps.
+func parseOffsetTarget(target string, now time.Time) (offsetTarget, error) {
+	if strings.Contains(target, "=") {
+		partitions, err := parseOffsetFor(strings.Split(target, ","))
+		if err != nil {
+			return offsetTarget{}, err
+		}
+		return offsetTarget{partitions: partitions}, nil
+	}
+
+	expr, exprErr := parseOffsetExpression(target)
+	if exprErr == nil {
+		return offsetTarget{expr: &expr}, nil
+	}
+	if at, err := parseDate(target, now); err == nil {
+		return offsetTarget{timestamp: at.UnixNano() / int64(time.Millisecond)}, nil
+	}
+	return offsetTarget{}, fmt.Errorf("%q is neither a timestamp, a partition=offset list nor a valid offset: %v", target, exprErr)
+}
+
+// offsetReset is the new committed offset of a partition
+type offsetReset struct {
+	Partition int32
+	// Current is the committed offset, -1 when the group did not commit an offset for the partition
+	Current int64
+	New     int64
+}
+
+// deleteGroup deletes the consumer group, the brokers refuse to delete groups with active members
+func deleteGroup(client sarama.Client, parsedOptions options) {
+	if !parsedOptions.dryRun && !parsedOptions.confirmed {
+		log.Fatalf("Deleting group %s removes all its committed offsets, add --yes to confirm", parsedOptions.group)
+	}
+
+	admin, err := kafkatools.GetClusterAdminFromClient(client)
+	if err != nil {
+		log.Fatal("Could not create the cluster admin: ", err)
+	}
+	checkGroupInactive(admin, parsedOptions.group)
+
+	if parsedOptions.dryRun {
+		kafkatools.Log.Infof("Dry run: group %s is inactive and would be deleted", parsedOptions.group)
+		return
+	}
+	if err := admin.DeleteConsumerGroup(parsedOptions.group); err != nil {
+		log.Fatalf("Could not delete group %s: %v", parsedOptions.group, err)
+	}
+	kafkatools.Log.Infof("Deleted group %s", parsedOptions.group)
+}
+
+// resetOffsets commits new offsets of the topic for the group, which must not have active members
+func resetOffsets(client sarama.Client, parsedOptions options) {
+	admin, err := kafkatools.GetClusterAdminFromClient(client)
+	if err != nil {
+		log.Fatal("Could not create the cluster admin: ", err)
+	}
+	checkGroupInactive(admin, parsedOptions.group)
+
+	oldest := fetchTopicOffsets(client, sarama.OffsetOldest, parsedOptions.topic)
+	newest := fetchTopicOffsets(client, sarama.OffsetNewest, parsedOptions.topic)
+	var target offsetMap
+	if parsedOptions.resetTarget.partitions != nil {
+		if target, err = applyOffsetFor(parsedOptions.topic, offsetMap{}, oldest, newest, parsedOptions.resetTarget.partitions); err != nil {
+			log.Fatal("Invalid target specified: ", err)
+		}
+	} else if parsedOptions.resetTarget.expr != nil {
+		target = resolveOffsetExpression(*parsedOptions.resetTarget.expr, oldest, newest)
+	} else {
+		target = fetchTopicOffsets(client, parsedOptions.resetTarget.timestamp, parsedOptions.topic)
+	}
+
+	partitions := make([]int32, 0, len(target))
+	for partition := range target {
+		partitions = append(partitions, partition)
+	}
+	committed, err := admin.ListConsumerGroupOffsets(parsedOptions.group, map[string][]int32{parsedOptions.topic: partitions})
+	if err != nil {
+		log.Fatalf("Could not fetch the offsets of group %s: %v", parsedOptions.group, err)
+	}
+
+	current := make(map[int32]int64, len(partitions))
+	for _, partition := range partitions {
+		current[partition] = -1
+		if block := committed.GetBlock(parsedOptions.topic, partition); block != nil {
+			current[partition] = block.Offset
+		}
+	}
+
+	resets := planOffsetResets(current, target, newest)
+	for _, reset := range resets {
+		fmt.Printf("partition %d: %s -> %d\n", reset.Partition, formatCommittedOffset(reset.Current), reset.New)
+	}
+	if len(resets) == 0 {
+		kafkatools.Log.Infof("The offsets of group %s are already up to date", parsedOptions.group)
+		return
+	} else if parsedOptions.dryRun {
+		kafkatools.Log.Infof("Dry run: %d offsets of group %s would be reset, nothing was changed", len(resets), parsedOptions.group)
+		return
+	} else if !parsedOptions.confirmed {
+		log.Fatalf("Resetting %d offsets of group %s, add --yes to confirm", len(resets), parsedOptions.group)
+	}
+
+	commitOffsets(client, parsedOptions.group, parsedOptions.topic, resets)
+	kafkatools.Log.Infof("Reset %d offsets of group %s", len(resets), parsedOptions.group)
+}
+
+// checkGroupInactive exits when the group has members, their commits would overwrite the reset offsets
+func checkGroupInactive(admin sarama.ClusterAdmin, group string) {
+	groups, err := admin.DescribeConsumerGroups([]string{group})
+	if err != nil || len(groups) != 1 {
+		log.Fatalf("Could not describe group %s: %v", group, err)
+	}
+
+	description := groups[0]
+	if description.Err != sarama.ErrNoError {
+		log.Fatalf("Could not describe group %s: %v", group, description.Err)
+	}
+	if description.State != "Empty" && description.State != "Dead" {
+		log.Fatalf("Group %s is %s with %d members, stop its consumers first", group, description.State, len(description.Members))
+	}
+}
+
+// planOffsetResets returns the partitions whose committed offset differs from the target sorted by partition, targets
+// without a record (e.g. timestamps after the last record) resolve to the newest offset
+func planOffsetResets(current map[int32]int64, target, newest offsetMap) (resets []offsetReset) {
+	for partition, offset := range target {
+		newOffset := offset.Offset
+		if newOffset < 0 {
+			newOffset = newest[partition].Offset
+		}
+		if current[partition] != newOffset {
+			resets = append(resets, offsetReset{Partition: partition, Current: current[partition], New: newOffset})
+		}
+	}
+
+	sort.Slice(resets, func(i, j int) bool { return resets[i].Partition < resets[j].Partition })
+	return resets
+}
+
+func formatCommittedOffset(offset int64) string {
+	if offset < 0 {
+		return "none"
+	}
+	return fmt.Sprint(offset)
+}
+
+// commitOffsets commits the new offsets using an offset manager, which is only allowed for groups without members
+func commitOffsets(client sarama.Client, group, topic string, resets []offsetReset) {
+	offsetManager, err := sarama.NewOffsetManagerFromClient(group, client)
+	if err != nil {
+		log.Fatal("Could not create the offset manager: ", err)
+	}
+
+	partitionManagers := make([]sarama.PartitionOffsetManager, 0, len(resets))
+	for _, reset := range resets {
+		partitionManager, err := offsetManager.ManagePartition(topic, reset.Partition)
+		if err != nil {
+			log.Fatalf("Could not manage the offset of partition %d: %v", reset.Partition, err)
+		}
+		partitionManagers = append(partitionManagers, partitionManager)
+
+		// Marking only moves offsets forward and resetting only moves them backward
+		if reset.New < reset.Current {
+			partitionManager.ResetOffset(reset.New, "")
+		} else {
+			partitionManager.MarkOffset(reset.New, "")
+		}
+	}
+
+	offsetManager.Commit()
+	failed := false
+	for _, partitionManager := range partitionManagers {
+		if err := partitionManager.Close(); err != nil {
+			log.Println("Could not commit the offset: ", err)
+			failed = true
+		}
+	}
+	if err := offsetManager.Close(); err != nil {
+		log.Println("Error closing the offset manager: ", err)
+	}
+	if failed {
+		log.Fatalf("Could not reset all offsets of group %s", group)
+	}
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/jurriaan/kafkatools"
+)
+
+func TestParseOffsetTarget(t *testing.T) {
+	now := time.Unix(1500003600, 0)
+	target, err := parseOffsetTarget("2017-07-14T02:40:00Z", now)
+	if err != nil || target.expr != nil || target.timestamp != 1500000000000 {
+		t.Errorf("Expected a timestamp target, got %+v (%v)", target, err)
+	}
+
+	// The other --start-date formats
+	local := time.Unix(1500000000, 0).Local().Format("2006-01-02 15:04:05")
+	for _, date := range []string{local, "-1h"} {
+		target, err = parseOffsetTarget(date, now)
+		if err != nil || target.expr != nil || target.timestamp != 1500000000000 {
+			t.Errorf("Expected %q to be a timestamp target, got %+v (%v)", date, target, err)
+		}
+	}
+
+	target, err = parseOffsetTarget("newest-10", now)
+	if err != nil || target.expr == nil || *target.expr != (offsetExpression{Base: sarama.OffsetNewest, Delta: -10}) {
+		t.Errorf("Expected an offset expression target, got %+v (%v)", target, err)
+	}
+
+	// Numbers are offsets rather than Unix timestamps
+	target, err = parseOffsetTarget("1500000000", now)
+	if err != nil || target.expr == nil || *target.expr != (offsetExpression{Delta: 1500000000}) {
+		t.Errorf("Expected an absolute offset target, got %+v (%v)", target, err)
+	}
+
+	target, err = parseOffsetTarget("0=1500,3=42", now)
+	if err != nil || !reflect.DeepEqual(target.partitions, map[int32]int64{0: 1500, 3: 42}) {
+		t.Errorf("Expected a partition list target, got %+v (%v)", target, err)
+	}
+
+	for _, invalid := range []string{"yesterday", "0=1500,x=2"} {
+		if _, err := parseOffsetTarget(invalid, now); err == nil {
+			t.Errorf("Expected an error for the invalid target %q", invalid)
+		}
+	}
+}
+
+func TestPlanOffsetResets(t *testing.T) {
+	current := map[int32]int64{0: 10, 1: -1, 2: 7}
+	target := offsetMap{
+		0: kafkatools.TopicPartitionOffset{Topic: "foo", Partition: 0, Offset: 3},
+		1: kafkatools.TopicPartitionOffset{Topic: "foo", Partition: 1, Offset: -1},
+		2: kafkatools.TopicPartitionOffset{Topic: "foo", Partition: 2, Offset: 7},
+	}
+	newest := offsetMap{
+		0: kafkatools.TopicPartitionOffset{Topic: "foo", Partition: 0, Offset: 20},
+		1: kafkatools.TopicPartitionOffset{Topic: "foo", Partition: 1, Offset: 5},
+		2: kafkatools.TopicPartitionOffset{Topic: "foo", Partition: 2, Offset: 9},
+	}
+
+	expected := []offsetReset{{Partition: 0, Current: 10, New: 3}, {Partition: 1, Current: -1, New: 5}}
+	if resets := planOffsetResets(current, target, newest); !reflect.DeepEqual(resets, expected) {
+		t.Errorf("Expected resets %+v, got %+v", expected, resets)
+	}
+}
+
+func TestResetOffsets(t *testing.T) {
+	target, err := parseOffsetTarget("beginning", time.Now())
+	if err != nil {
+		t.Fatal("Unexpected error: ", err)
+	}
+	expected := map[int32]int64{0: 3, 2: 0}
+	if committed := resetMockOffsets(t, target); !reflect.DeepEqual(committed, expected) {
+		t.Errorf("Expected the offsets %v to be committed, got %v", expected, committed)
+	}
+}
+
+func TestSetOffsetsOfPartitions(t *testing.T) {
+	target, err := parseOffsetTarget("0=12,2=50", time.Now())
+	if err != nil {
+		t.Fatal("Unexpected error: ", err)
+	}
+	// Partition 1 is not listed and partition 2 is clamped to its newest offset
+	expected := map[int32]int64{0: 12, 2: 10}
+	if committed := resetMockOffsets(t, target); !reflect.DeepEqual(committed, expected) {
+		t.Errorf("Expected the offsets %v to be committed, got %v", expected, committed)
+	}
+}
+
+// resetMockOffsets resets the offsets of group on topic foo of a mock broker to the target and returns the committed
+// offsets
+func resetMockOffsets(t *testing.T, target offsetTarget) map[int32]int64 {
+	broker := sarama.NewMockBroker(t, 1)
+	defer broker.Close()
+
+	// Partition 0 moves back to its oldest offset, partition 1 is already there and partition 2 has no commit yet
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": sarama.NewMockMetadataResponse(t).
+			SetBroker(broker.Addr(), broker.BrokerID()).
+			SetController(broker.BrokerID()).
+			SetLeader("foo", 0, broker.BrokerID()).
+			SetLeader("foo", 1, broker.BrokerID()).
+			SetLeader("foo", 2, broker.BrokerID()),
+		"FindCoordinatorRequest": sarama.NewMockFindCoordinatorResponse(t).
+			SetCoordinator(sarama.CoordinatorGroup, "group", broker),
+		"DescribeGroupsRequest": sarama.NewMockDescribeGroupsResponse(t).
+			AddGroupDescription("group", &sarama.GroupDescription{GroupId: "group", State: "Empty"}),
+		"OffsetRequest": sarama.NewMockOffsetResponse(t).
+			SetOffset("foo", 0, sarama.OffsetOldest, 3).SetOffset("foo", 0, sarama.OffsetNewest, 20).
+			SetOffset("foo", 1, sarama.OffsetOldest, 4).SetOffset("foo", 1, sarama.OffsetNewest, 30).
+			SetOffset("foo", 2, sarama.OffsetOldest, 0).SetOffset("foo", 2, sarama.OffsetNewest, 10),
+		"OffsetFetchRequest": sarama.NewMockOffsetFetchResponse(t).
+			SetOffset("group", "foo", 0, 15, "", sarama.ErrNoError).
+			SetOffset("group", "foo", 1, 4, "", sarama.ErrNoError).
+			SetOffset("group", "foo", 2, -1, "", sarama.ErrNoError),
+		"OffsetCommitRequest": sarama.NewMockOffsetCommitResponse(t),
+	})
+
+	client, err := sarama.NewClient([]string{broker.Addr()}, sarama.NewConfig())
+	if err != nil {
+		t.Fatal("Unexpected error: ", err)
+	}
+	defer client.Close()
+
+	resetOffsets(client, options{group: "group", topic: "foo", resetTarget: target, confirmed: true})
+
+	committed := make(map[int32]int64)
+	for _, exchange := range broker.History() {
+		request, ok := exchange.Request.(*sarama.OffsetCommitRequest)
+		if !ok {
+			continue
+		}
+		for partition := int32(0); partition < 3; partition++ {
+			if offset, _, err := request.Offset("foo", partition); err == nil {
+				committed[partition] = offset
+			}
+		}
+	}
+	return committed
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/Shopify/sarama"
+)
+
+// sizeBuckets are the inclusive upper bounds of the histogram buckets, larger values end up in an overflow bucket
+var sizeBuckets = []int{64, 256, 1 << 10, 4 << 10, 16 << 10, 64 << 10, 256 << 10, 1 << 20, 4 << 20}
+
+// histogramWidth is the width of the largest bar of the histogram
+const histogramWidth = 40
+
+// sizeHistogram counts the value sizes of the consumed messages
+type sizeHistogram struct {
+	mutex   sync.Mutex
+	buckets []int
+	count   int
+	max     int
+	total   int64
+}
+
+func newSizeHistogram() *sizeHistogram {
+	return &sizeHistogram{buckets: make([]int, len(sizeBuckets)+1)}
+}
+
+// add counts the value size of the message, it is a no-op on a nil histogram
+func (h *sizeHistogram) add(msg *sarama.ConsumerMessage) {
+	if h == nil {
+		return
+	}
+
+	size := len(msg.Value)
+	bucket := len(sizeBuckets)
+	for i, bound := range sizeBuckets {
+		if size <= bound {
+			bucket = i
+			break
+		}
+	}
+
+	h.mutex.Lock()
+	h.buckets[bucket]++
+	h.count++
+	h.total += int64(size)
+	if size > h.max {
+		h.max = size
+	}
+	h.mutex.Unlock()
+}
+
+func (h *sizeHistogram) print(printer func(string)) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	largest := 0
+	for _, count := range h.buckets {
+		if count > largest {
+			largest = count
+		}
+	}
+
+	for i, count := range h.buckets {
+		label := "> " + formatBytes(int64(sizeBuckets[len(sizeBuckets)-1]))
+		if i < len(sizeBuckets) {
+			label = "<= " + formatBytes(int64(sizeBuckets[i]))
+		}
+
+		bar := 0
+		if largest > 0 {
+			bar = (count*histogramWidth + largest - 1) / largest
+		}
+		printer(fmt.Sprintf("%-12s %8d %s", label, count, strings.Repeat("#", bar)))
+	}
+
+	average := int64(0)
+	if h.count > 0 {
+		average = h.total / int64(h.count)
+	}
+	printer(fmt.Sprintf("messages: %d, average: %s, max: %s", h.count, formatBytes(average), formatBytes(int64(h.max))))
+}
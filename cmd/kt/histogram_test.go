@@ -0,0 +1,40 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Shopify/sarama"
+)
+
+func TestSizeHistogram(t *testing.T) {
+	histogram := newSizeHistogram()
+	for _, size := range []int{0, 64, 65, 65, 2000, 10 << 20} {
+		histogram.add(&sarama.ConsumerMessage{Value: make([]byte, size)})
+	}
+
+	expected := []int{2, 2, 0, 1, 0, 0, 0, 0, 0, 1}
+	if !reflect.DeepEqual(histogram.buckets, expected) {
+		t.Errorf("Expected buckets %v, got %v", expected, histogram.buckets)
+	}
+
+	var lines []string
+	histogram.print(func(str string) { lines = append(lines, str) })
+
+	if len(lines) != len(expected)+1 {
+		t.Fatalf("Expected %d lines, got %q", len(expected)+1, lines)
+	}
+	if !strings.HasPrefix(lines[0], "<= 64 B") || !strings.HasSuffix(lines[0], strings.Repeat("#", histogramWidth)) {
+		t.Errorf("Expected a full bar for the first bucket, got %q", lines[0])
+	}
+	if !strings.HasPrefix(lines[9], "> 4.0 MiB") {
+		t.Errorf("Expected the overflow bucket last, got %q", lines[9])
+	}
+	if lines[10] != "messages: 6, average: 1.7 MiB, max: 10.0 MiB" {
+		t.Errorf("Unexpected summary %q", lines[10])
+	}
+
+	var disabled *sizeHistogram
+	disabled.add(&sarama.ConsumerMessage{})
+}
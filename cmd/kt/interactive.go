@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// pickOffsets lists the offset range of every partition and prompts for the partitions to consume and their start offsets
+func pickOffsets(in io.Reader, out io.Writer, oldest, newest, start offsetMap) (offsetMap, error) {
+	reader := bufio.NewReader(in)
+
+	partitions := make([]int, 0, len(start))
+	for partition := range start {
+		partitions = append(partitions, int(partition))
+	}
+	sort.Ints(partitions)
+
+	fmt.Fprintln(out, "partition  oldest  newest  messages")
+	for _, partition := range partitions {
+		first, last := oldest[int32(partition)].Offset, newest[int32(partition)].Offset
+		fmt.Fprintf(out, "%9d  %6d  %6d  %8d\n", partition, first, last, last-first)
+	}
+
+	selected := partitions
+	for {
+		answer, err := prompt(reader, out, "Partitions to consume (comma separated partitions and ranges, empty for all): ")
+		if err != nil {
+			return nil, err
+		}
+		if answer == "" {
+			break
+		}
+
+		var duplicates []int32
+		selected, duplicates, err = parsePickedPartitions(answer, start)
+		if err == nil {
+			if len(duplicates) > 0 {
+				fmt.Fprintf(out, "Partitions listed more than once are picked once: %s\n", formatPartitionList(duplicates))
+			}
+			break
+		}
+		fmt.Fprintln(out, err)
+	}
+
+	picked := make(offsetMap)
+	for _, partition := range selected {
+		first, last := oldest[int32(partition)].Offset, newest[int32(partition)].Offset
+		offset := start[int32(partition)]
+
+		for {
+			answer, err := prompt(reader, out, fmt.Sprintf("Start offset for partition %d: oldest | newest | %d-%d [%d]: ", partition, first, last, offset.Offset))
+			if err != nil {
+				return nil, err
+			}
+
+			value, err := parsePickedOffset(answer, offset.Offset, first, last)
+			if err == nil {
+				offset.Offset = value
+				break
+			}
+			fmt.Fprintln(out, err)
+		}
+
+		picked[int32(partition)] = offset
+	}
+
+	return picked, nil
+}
+
+func prompt(reader *bufio.Reader, out io.Writer, question string) (string, error) {
+	fmt.Fprint(out, question)
+	answer, err := reader.ReadString('\n')
+	if err != nil && (err != io.EOF || answer == "") {
+		return "", fmt.Errorf("could not read answer: %v", err)
+	}
+	return strings.TrimSpace(answer), nil
+}
+
+// parsePickedPartitions parses the picked partitions like --partitions, along with the partitions picked more than once
+func parsePickedPartitions(answer string, start offsetMap) (partitions []int, duplicates []int32, err error) {
+	picked, duplicates, err := parsePartitionList(answer)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, partition := range picked {
+		if _, ok := start[partition]; !ok {
+			return nil, nil, fmt.Errorf("partition %d does not exist", partition)
+		}
+		partitions = append(partitions, int(partition))
+	}
+	return partitions, duplicates, nil
+}
+
+func parsePickedOffset(answer string, current, oldest, newest int64) (int64, error) {
+	switch answer {
+	case "":
+		return current, nil
+	case "oldest":
+		return oldest, nil
+	case "newest":
+		return newest, nil
+	}
+
+	offset, err := strconv.ParseInt(answer, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid offset %q", answer)
+	}
+	if offset < oldest || offset > newest {
+		return 0, fmt.Errorf("offset %d is outside of the available range %d-%d", offset, oldest, newest)
+	}
+	return offset, nil
+}
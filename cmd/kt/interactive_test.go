@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/jurriaan/kafkatools"
+)
+
+func TestPickOffsets(t *testing.T) {
+	oldest, newest, start := make(offsetMap), make(offsetMap), make(offsetMap)
+	for partition := int32(0); partition < 3; partition++ {
+		oldest[partition] = kafkatools.TopicPartitionOffset{Topic: "foo", Partition: partition, Offset: 10}
+		newest[partition] = kafkatools.TopicPartitionOffset{Topic: "foo", Partition: partition, Offset: 100}
+		start[partition] = newest[partition]
+	}
+
+	// Invalid answers are asked again
+	input := strings.NewReader("0,5\n0,2\n500\noldest\n42\n")
+	var output bytes.Buffer
+
+	picked, err := pickOffsets(input, &output, oldest, newest, start)
+	if err != nil {
+		t.Fatal("Unexpected error: ", err)
+	}
+
+	expected := offsetMap{
+		0: kafkatools.TopicPartitionOffset{Topic: "foo", Partition: 0, Offset: 10},
+		2: kafkatools.TopicPartitionOffset{Topic: "foo", Partition: 2, Offset: 42},
+	}
+	if !reflect.DeepEqual(picked, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, picked)
+	}
+
+	if !strings.Contains(output.String(), "partition 5 does not exist") {
+		t.Errorf("Expected the invalid partition to be reported, got %s", output.String())
+	}
+}
+
+func TestPickOffsetsDefaults(t *testing.T) {
+	start := offsetMap{0: kafkatools.TopicPartitionOffset{Topic: "foo", Partition: 0, Offset: 50}}
+
+	picked, err := pickOffsets(strings.NewReader("\n\n"), &bytes.Buffer{}, start, start, start)
+	if err != nil {
+		t.Fatal("Unexpected error: ", err)
+	}
+	if !reflect.DeepEqual(picked, start) {
+		t.Errorf("Expected the default start offsets %+v, got %+v", start, picked)
+	}
+
+	if _, err := pickOffsets(strings.NewReader(""), &bytes.Buffer{}, start, start, start); err == nil {
+		t.Error("Expected an error when the input ends")
+	}
+}
+
+func TestPickOffsetsDuplicates(t *testing.T) {
+	start := make(offsetMap)
+	for partition := int32(0); partition < 3; partition++ {
+		start[partition] = kafkatools.TopicPartitionOffset{Topic: "foo", Partition: partition, Offset: 50}
+	}
+
+	// Every partition is only asked for once
+	var output bytes.Buffer
+	picked, err := pickOffsets(strings.NewReader("1,0-1,1\n\n\n"), &output, start, start, start)
+	if err != nil {
+		t.Fatal("Unexpected error: ", err)
+	}
+	if len(picked) != 2 || strings.Count(output.String(), "Start offset for partition 1") != 1 {
+		t.Errorf("Expected partitions 0 and 1 to be picked once, got %+v after %s", picked, output.String())
+	}
+	if !strings.Contains(output.String(), "Partitions listed more than once are picked once: 1") {
+		t.Errorf("Expected the duplicate partition to be reported, got %s", output.String())
+	}
+}
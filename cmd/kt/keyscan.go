@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+
+	"github.com/Shopify/sarama"
+	"github.com/jurriaan/kafkatools"
+)
+
+type keyScanResult struct {
+	Partition int32
+	// Found is the first message with the key, nil when the key was not found
+	Found   *sarama.ConsumerMessage
+	Scanned int
+}
+
+// findKeyOffsets scans every partition from its start offset until the key is found, the end offset is reached
+// or maxScan messages were read (0 scans the whole range)
+func findKeyOffsets(consumer sarama.Consumer, key []byte, partitionOffsets, endOffsets offsetMap, maxScan int) []keyScanResult {
+	var wg sync.WaitGroup
+	var mutex sync.Mutex
+	var results []keyScanResult
+
+	for _, offset := range partitionOffsets {
+		endOffset := endOffsets[offset.Partition].Offset
+		if offset.Offset >= endOffset {
+			results = append(results, keyScanResult{Partition: offset.Partition})
+			continue
+		}
+
+		pc, err := consumer.ConsumePartition(offset.Topic, offset.Partition, offset.Offset)
+		if err != nil {
+			log.Fatalf("ERROR: Failed to start consumer for partition %d: %s", offset.Partition, err)
+		}
+
+		wg.Add(1)
+		go processErrors(pc)
+		go func(pc sarama.PartitionConsumer, partition int32) {
+			defer wg.Done()
+			result := scanPartitionForKey(pc, key, endOffset, maxScan)
+			result.Partition = partition
+			if err := pc.Close(); err != nil {
+				kafkatools.Log.Errorf("ERROR: Failed to close consumer for partition %d: %s", partition, err)
+			}
+
+			mutex.Lock()
+			results = append(results, result)
+			mutex.Unlock()
+		}(pc, offset.Partition)
+	}
+	wg.Wait()
+
+	sort.Slice(results, func(i, j int) bool { return results[i].Partition < results[j].Partition })
+	return results
+}
+
+func scanPartitionForKey(pc sarama.PartitionConsumer, key []byte, endOffset int64, maxScan int) (result keyScanResult) {
+	for msg := range pc.Messages() {
+		if msg.Offset >= endOffset {
+			break
+		}
+
+		result.Scanned++
+		if bytes.Equal(msg.Key, key) {
+			result.Found = msg
+			break
+		}
+
+		if msg.Offset >= endOffset-1 || (maxScan > 0 && result.Scanned >= maxScan) {
+			break
+		}
+	}
+	return result
+}
+
+func printKeyScanResults(results []keyScanResult, printer func(string)) {
+	for _, result := range results {
+		if result.Found != nil {
+			printer(fmt.Sprintf("partition %d: first offset %d (timestamp %s)", result.Partition, result.Found.Offset, result.Found.Timestamp.UTC().Format("2006-01-02T15:04:05.000Z07:00")))
+		} else {
+			printer(fmt.Sprintf("partition %d: not found (scanned %d messages)", result.Partition, result.Scanned))
+		}
+	}
+}
+
+// keyStartOffsets returns the offsets of the partitions in which the key was found
+func keyStartOffsets(results []keyScanResult, partitionOffsets offsetMap) offsetMap {
+	startOffsets := make(offsetMap)
+	for _, result := range results {
+		if result.Found != nil {
+			offset := partitionOffsets[result.Partition]
+			offset.Offset = result.Found.Offset
+			startOffsets[result.Partition] = offset
+		}
+	}
+	return startOffsets
+}
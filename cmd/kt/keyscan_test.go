@@ -0,0 +1,56 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/Shopify/sarama"
+	"github.com/Shopify/sarama/mocks"
+	"github.com/jurriaan/kafkatools"
+)
+
+func TestFindKeyOffsets(t *testing.T) {
+	config := sarama.NewConfig()
+	config.Consumer.Return.Errors = true
+
+	consumer := mocks.NewConsumer(t, config)
+
+	partitionOffsets, endOffsets := make(offsetMap), make(offsetMap)
+	for partition := int32(0); partition < 3; partition++ {
+		partitionOffsets[partition] = kafkatools.TopicPartitionOffset{Topic: "foo", Partition: partition, Offset: 0}
+		endOffsets[partition] = kafkatools.TopicPartitionOffset{Topic: "foo", Partition: partition, Offset: 4}
+	}
+
+	keys := map[int32][]string{
+		0: {"a", "b", "x", "x"},
+		1: {"a", "b", "c", "d"},
+		2: {"a", "b", "c", "x"},
+	}
+	for partition, partitionKeys := range keys {
+		partConsumer := consumer.ExpectConsumePartition("foo", partition, 0)
+		for offset, key := range partitionKeys {
+			partConsumer.YieldMessage(&sarama.ConsumerMessage{Key: []byte(key), Offset: int64(offset)})
+		}
+	}
+
+	// The key in partition 2 is beyond the max scan
+	results := findKeyOffsets(consumer, []byte("x"), partitionOffsets, endOffsets, 3)
+
+	if len(results) != 3 {
+		t.Fatalf("Expected 3 results, got %+v", results)
+	}
+	if results[0].Found == nil || results[0].Found.Offset != 2 {
+		t.Errorf("Expected the key at offset 2 of partition 0, got %+v", results[0])
+	}
+	if results[1].Found != nil || results[1].Scanned != 3 {
+		t.Errorf("Expected the key not to be found in the first 3 messages of partition 1, got %+v", results[1])
+	}
+	if results[2].Found != nil {
+		t.Errorf("Expected the key not to be found in partition 2 within the max scan, got %+v", results[2])
+	}
+
+	expected := offsetMap{0: kafkatools.TopicPartitionOffset{Topic: "foo", Partition: 0, Offset: 2}}
+	if startOffsets := keyStartOffsets(results, partitionOffsets); !reflect.DeepEqual(startOffsets, expected) {
+		t.Errorf("Expected start offsets %+v, got %+v", expected, startOffsets)
+	}
+}
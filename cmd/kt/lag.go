@@ -0,0 +1,188 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+
+	"github.com/Shopify/sarama"
+	"github.com/jurriaan/kafkatools"
+	"github.com/olekukonko/tablewriter"
+)
+
+// groupLags prints the lag of all consumer groups (kt groups) or of a single group (kt lag)
+func groupLags(client sarama.Client, parsedOptions options) {
+	var groupOffsets kafkatools.GroupOffsetSlice
+	var topicOffsets map[string]map[int32]kafkatools.TopicPartitionOffset
+	if parsedOptions.command == "lag" {
+		groupOffsets, topicOffsets = fetchGroupLagOffsets(client, parsedOptions.group)
+	} else {
+		var err error
+		groupOffsets, topicOffsets, err = kafkatools.FetchOffsets(client, sarama.OffsetNewest)
+		if err != nil {
+			log.Fatal("Could not fetch the offsets: ", err)
+		}
+	}
+
+	lags := kafkatools.ComputeLags(groupOffsets, topicOffsets)
+	if parsedOptions.command == "lag" && len(parsedOptions.topics) > 0 {
+		lags = filterLagTopics(lags, parsedOptions.topics)
+	}
+	if parsedOptions.lagFormat == "json" {
+		if err := writeLagsJSON(os.Stdout, lags); err != nil {
+			log.Fatal("Could not write the lag: ", err)
+		}
+	} else {
+		printLagTable(os.Stdout, lags)
+	}
+
+	if exceeded := parsedOptions.lagThresholds.exceeded(lags); len(exceeded) > 0 {
+		for _, line := range exceeded {
+			log.Print(line)
+		}
+		log.Fatalf("Lag of group %s exceeds the maximum", parsedOptions.group)
+	}
+}
+
+// fetchGroupLagOffsets fetches the offsets of the group from its coordinator and the newest offsets of the topics it
+// consumes, instead of the offsets of every group and topic
+func fetchGroupLagOffsets(client sarama.Client, group string) (kafkatools.GroupOffsetSlice, map[string]map[int32]kafkatools.TopicPartitionOffset) {
+	groupOffset, err := kafkatools.FetchGroupOffsets(client, group)
+	if errors.Is(err, kafkatools.ErrGroupNotFound) {
+		log.Fatalf("Group %s not found", group)
+	}
+	if err != nil {
+		log.Fatal("Could not fetch the offsets: ", err)
+	}
+
+	topicOffsets := make(map[string]map[int32]kafkatools.TopicPartitionOffset)
+	topics := make([]string, 0, len(groupOffset.GroupTopicOffsets))
+	for _, topicOffset := range groupOffset.GroupTopicOffsets {
+		topics = append(topics, topicOffset.Topic)
+	}
+	// Without topics every topic would be fetched
+	if len(topics) > 0 {
+		topicOffsets = fetchTopicsOffsets(client, sarama.OffsetNewest, topics...)
+	}
+	return kafkatools.GroupOffsetSlice{groupOffset}, topicOffsets
+}
+
+// lagThresholds are the maximum total and partition lags of kt lag, nil maximums are not checked
+type lagThresholds struct {
+	total, partition *int64
+}
+
+// parseMaxLag parses a maximum lag option, nil when it is not given
+func parseMaxLag(docOpts map[string]interface{}, option string) *int64 {
+	if docOpts[option] == nil {
+		return nil
+	}
+
+	maxLag, err := strconv.ParseInt(docOpts[option].(string), 10, 64)
+	if err != nil || maxLag < 0 {
+		log.Fatalf("Invalid maximum lag specified: %s", docOpts[option])
+	}
+	return &maxLag
+}
+
+// exceeded describes the lags above the thresholds along with the offending partitions, the partitions with lag when
+// the total is too high. Partitions without a committed offset have no lag and never exceed a threshold.
+func (t lagThresholds) exceeded(lags []kafkatools.PartitionLag) (lines []string) {
+	var total int64
+	var lagging, overPartitionMax []string
+	for _, lag := range lags {
+		if lag.Lag == nil {
+			continue
+		}
+
+		total += *lag.Lag
+		partition := fmt.Sprintf("%s partition %d: lag %d (group offset %d, end of log %d)", lag.Topic, lag.Partition, *lag.Lag, *lag.Current, lag.End)
+		if *lag.Lag > 0 {
+			lagging = append(lagging, partition)
+		}
+		if t.partition != nil && *lag.Lag > *t.partition {
+			overPartitionMax = append(overPartitionMax, partition)
+		}
+	}
+
+	if t.total != nil && total > *t.total {
+		lines = append(lines, fmt.Sprintf("Total lag %d exceeds the maximum of %d", total, *t.total))
+		lines = append(lines, lagging...)
+	}
+	if len(overPartitionMax) > 0 {
+		lines = append(lines, fmt.Sprintf("Lag of %d partitions exceeds the maximum of %d", len(overPartitionMax), *t.partition))
+		lines = append(lines, overPartitionMax...)
+	}
+	return lines
+}
+
+// filterLagTopics returns the lags of the partitions of the topics
+func filterLagTopics(lags []kafkatools.PartitionLag, topics []string) []kafkatools.PartitionLag {
+	var filtered []kafkatools.PartitionLag
+	for _, lag := range lags {
+		for _, topic := range topics {
+			if lag.Topic == topic {
+				filtered = append(filtered, lag)
+				break
+			}
+		}
+	}
+	return filtered
+}
+
+// writeLagsJSON writes the lags as a JSON array, groups without offsets result in an empty array
+func writeLagsJSON(out io.Writer, lags []kafkatools.PartitionLag) error {
+	if lags == nil {
+		lags = []kafkatools.PartitionLag{}
+	}
+	document, err := json.MarshalIndent(lags, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(out, string(document))
+	return err
+}
+
+func printLagTable(out io.Writer, lags []kafkatools.PartitionLag) {
+	table := tablewriter.NewWriter(out)
+	table.SetHeader([]string{"group", "topic", "partition", "end of log", "group offset", "lag"})
+	for _, lag := range lags {
+		current, behind := "--", "--"
+		if lag.Current != nil {
+			current, behind = strconv.FormatInt(*lag.Current, 10), strconv.FormatInt(*lag.Lag, 10)
+		}
+		table.Append([]string{lag.Group, lag.Topic, strconv.Itoa(int(lag.Partition)), strconv.FormatInt(lag.End, 10), current, behind})
+	}
+
+	table.SetAlignment(tablewriter.ALIGN_LEFT)
+	table.Render()
+
+	for _, total := range totalLags(lags) {
+		fmt.Fprintf(out, "total lag of group %s: %d\n", total.group, total.lag)
+	}
+}
+
+// groupLag is the total lag of a consumer group
+type groupLag struct {
+	group string
+	lag   int64
+}
+
+// totalLags sums the lags of the partitions per group, in the order of the groups in lags. Partitions without a
+// committed offset have no lag and are not counted.
+func totalLags(lags []kafkatools.PartitionLag) []groupLag {
+	var totals []groupLag
+	for _, lag := range lags {
+		if len(totals) == 0 || totals[len(totals)-1].group != lag.Group {
+			totals = append(totals, groupLag{group: lag.Group})
+		}
+		if lag.Lag != nil {
+			totals[len(totals)-1].lag += *lag.Lag
+		}
+	}
+	return totals
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/jurriaan/kafkatools"
+)
+
+func TestWriteLagsJSON(t *testing.T) {
+	var out bytes.Buffer
+	if err := writeLagsJSON(&out, nil); err != nil || out.String() != "[]\n" {
+		t.Errorf("Expected an empty array, got %q (%v)", out.String(), err)
+	}
+
+	current, lag := int64(40), int64(2)
+	out.Reset()
+	if err := writeLagsJSON(&out, []kafkatools.PartitionLag{{Group: "group", Topic: "foo", Current: &current, End: 42, Lag: &lag}}); err != nil {
+		t.Fatal("Unexpected error: ", err)
+	}
+	if !strings.Contains(out.String(), `"lag": 2`) {
+		t.Errorf("Expected the lag in the JSON, got %s", out.String())
+	}
+}
+
+func TestLagThresholdsExceeded(t *testing.T) {
+	lag := func(partition int32, current, end int64) kafkatools.PartitionLag {
+		behind := end - current
+		return kafkatools.PartitionLag{Group: "group", Topic: "foo", Partition: partition, Current: &current, End: end, Lag: &behind}
+	}
+	lags := []kafkatools.PartitionLag{lag(0, 10, 10), lag(1, 20, 620), lag(2, 30, 530), {Group: "group", Topic: "foo", Partition: 3, End: 5000}}
+	limit := func(n int64) *int64 { return &n }
+
+	if exceeded := (lagThresholds{}).exceeded(lags); len(exceeded) != 0 {
+		t.Errorf("Expected no thresholds to be checked, got %q", exceeded)
+	}
+	if exceeded := (lagThresholds{total: limit(1100), partition: limit(600)}).exceeded(lags); len(exceeded) != 0 {
+		t.Errorf("Expected the lags to be within the thresholds, got %q", exceeded)
+	}
+
+	expected := []string{
+		"Total lag 1100 exceeds the maximum of 1000",
+		"foo partition 1: lag 600 (group offset 20, end of log 620)",
+		"foo partition 2: lag 500 (group offset 30, end of log 530)",
+		"Lag of 1 partitions exceeds the maximum of 500",
+		"foo partition 1: lag 600 (group offset 20, end of log 620)",
+	}
+	if exceeded := (lagThresholds{total: limit(1000), partition: limit(500)}).exceeded(lags); strings.Join(exceeded, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Expected %q, got %q", expected, exceeded)
+	}
+}
+
+func TestFilterLagTopics(t *testing.T) {
+	lags := []kafkatools.PartitionLag{{Topic: "foo"}, {Topic: "bar"}, {Topic: "baz"}}
+	if filtered := filterLagTopics(lags, []string{"baz", "foo"}); len(filtered) != 2 || filtered[0].Topic != "foo" || filtered[1].Topic != "baz" {
+		t.Errorf("Expected the lags of foo and baz, got %v", filtered)
+	}
+}
+
+func TestPrintLagTableTotals(t *testing.T) {
+	current, lag, otherLag := int64(40), int64(2), int64(5)
+	lags := []kafkatools.PartitionLag{
+		{Group: "a", Topic: "foo", Partition: 0, Current: &current, End: 42, Lag: &lag},
+		{Group: "a", Topic: "foo", Partition: 1, End: 10},
+		{Group: "a", Topic: "foo", Partition: 2, Current: &current, End: 45, Lag: &otherLag},
+		{Group: "b", Topic: "bar", Partition: 0, End: 3},
+	}
+
+	var out bytes.Buffer
+	printLagTable(&out, lags)
+	if !strings.Contains(out.String(), "total lag of group a: 7\n") || !strings.HasSuffix(out.String(), "total lag of group b: 0\n") {
+		t.Errorf("Expected the total lag of both groups after the table, got %s", out.String())
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/Shopify/sarama"
+)
+
+// The latency histogram buckets grow exponentially from latencyBase by latencyGrowth, the last bucket holds everything
+// above the largest bound
+const (
+	latencyBase    = 10 * time.Microsecond
+	latencyBuckets = 128
+)
+
+var latencyGrowth = math.Pow(2, 0.25)
+
+// latencyHistogram counts durations in exponentially growing buckets, so the percentiles are accurate within the
+// growth factor of about 19% whatever the scale of the durations
+type latencyHistogram struct {
+	buckets []int64
+	count   int64
+	max     time.Duration
+}
+
+func newLatencyHistogram() *latencyHistogram {
+	return &latencyHistogram{buckets: make([]int64, latencyBuckets)}
+}
+
+// latencyBound returns the inclusive upper bound of the bucket
+func latencyBound(bucket int) time.Duration {
+	return time.Duration(float64(latencyBase) * math.Pow(latencyGrowth, float64(bucket)))
+}
+
+// add counts n messages acknowledged after the latency
+func (h *latencyHistogram) add(latency time.Duration, n int) {
+	bucket := 0
+	if latency > latencyBase {
+		bucket = int(math.Ceil(math.Log(float64(latency)/float64(latencyBase)) / math.Log(latencyGrowth)))
+		// The bound of the computed bucket may be rounded below the latency
+		for bucket < latencyBuckets-1 && latencyBound(bucket) < latency {
+			bucket++
+		}
+		if bucket > latencyBuckets-1 {
+			bucket = latencyBuckets - 1
+		}
+	}
+
+	h.buckets[bucket] += int64(n)
+	h.count += int64(n)
+	if latency > h.max {
+		h.max = latency
+	}
+}
+
+// percentile returns the upper bound of the bucket holding the percentile, at most the largest latency
+func (h *latencyHistogram) percentile(p float64) time.Duration {
+	rank := int64(math.Ceil(p / 100 * float64(h.count)))
+	var seen int64
+	for bucket, count := range h.buckets {
+		seen += count
+		if seen >= rank && count > 0 {
+			if bound := latencyBound(bucket); bound < h.max {
+				return bound
+			}
+			break
+		}
+	}
+	return h.max
+}
+
+// summary formats the percentiles of the acknowledgement latencies
+func (h *latencyHistogram) summary() string {
+	if h.count == 0 {
+		return "No messages acknowledged to report the latency of"
+	}
+	return fmt.Sprintf("Acknowledgement latency of %d messages: p50 %v, p95 %v, p99 %v, max %v", h.count,
+		h.percentile(50).Round(time.Microsecond), h.percentile(95).Round(time.Microsecond), h.percentile(99).Round(time.Microsecond), h.max.Round(time.Microsecond))
+}
+
+// timeSends returns send recording how long every batch took to be acknowledged, for each of its messages which was
+// produced
+func timeSends(send func([]*sarama.ProducerMessage) error, latencies *latencyHistogram, now func() time.Time) func([]*sarama.ProducerMessage) error {
+	return func(batch []*sarama.ProducerMessage) error {
+		start := now()
+		err := send(batch)
+		latency := now().Sub(start)
+
+		acknowledged := len(batch)
+		if producerErrors, ok := err.(sarama.ProducerErrors); ok {
+			acknowledged -= len(producerErrors)
+		} else if err != nil {
+			acknowledged = 0
+		}
+		if acknowledged > 0 {
+			latencies.add(latency, acknowledged)
+		}
+		return err
+	}
+}
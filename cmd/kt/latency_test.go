@@ -0,0 +1,60 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+)
+
+func TestLatencyHistogram(t *testing.T) {
+	histogram := newLatencyHistogram()
+	for i := 1; i <= 100; i++ {
+		histogram.add(time.Duration(i)*time.Millisecond, 1)
+	}
+
+	// The percentiles are the bounds of their buckets, within the growth factor above the exact latency
+	for p, exact := range map[float64]time.Duration{50: 50 * time.Millisecond, 95: 95 * time.Millisecond, 99: 99 * time.Millisecond} {
+		latency := histogram.percentile(p)
+		if latency < exact || float64(latency) > float64(exact)*latencyGrowth {
+			t.Errorf("Expected p%g to be within %v and %v, got %v", p, exact, time.Duration(float64(exact)*latencyGrowth), latency)
+		}
+	}
+	if latency := histogram.percentile(100); latency != 100*time.Millisecond {
+		t.Errorf("Expected p100 to be the largest latency, got %v", latency)
+	}
+
+	histogram.add(time.Microsecond, 1)
+	histogram.add(1000*time.Hour, 1)
+	if histogram.buckets[0] != 1 || histogram.buckets[latencyBuckets-1] != 1 || histogram.count != 102 {
+		t.Errorf("Expected the smallest and largest latencies in the first and last buckets, got %d and %d", histogram.buckets[0], histogram.buckets[latencyBuckets-1])
+	}
+}
+
+func TestTimeSends(t *testing.T) {
+	clock := time.Unix(1500000000, 0)
+	now := func() time.Time {
+		clock = clock.Add(5 * time.Millisecond)
+		return clock
+	}
+
+	batch := []*sarama.ProducerMessage{{Topic: "foo"}, {Topic: "foo"}, {Topic: "foo"}}
+	results := []error{nil, sarama.ProducerErrors{{Msg: batch[0], Err: errors.New("too large")}}, errors.New("broker went away")}
+	histogram := newLatencyHistogram()
+	send := timeSends(func([]*sarama.ProducerMessage) error {
+		err := results[0]
+		results = results[1:]
+		return err
+	}, histogram, now)
+
+	for i := 0; i < 3; i++ {
+		send(batch)
+	}
+	if histogram.count != 5 || histogram.max != 5*time.Millisecond {
+		t.Errorf("Expected 5 acknowledged messages after 5ms, got %d after %v", histogram.count, histogram.max)
+	}
+	if summary := histogram.summary(); summary != "Acknowledgement latency of 5 messages: p50 5ms, p95 5ms, p99 5ms, max 5ms" {
+		t.Errorf("Unexpected summary %q", summary)
+	}
+}
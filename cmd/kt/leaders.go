@@ -0,0 +1,53 @@
+package main
+
+import (
+	"log"
+	"sort"
+
+	"github.com/Shopify/sarama"
+	"github.com/jurriaan/kafkatools"
+)
+
+type partitionLeader struct {
+	ID   int32  `json:"id"`
+	Addr string `json:"addr"`
+}
+
+// fetchPartitionLeaders looks up the leader broker of every partition in the offset map using the client metadata
+func fetchPartitionLeaders(client sarama.Client, topic string, partitionOffsets offsetMap) map[int32]partitionLeader {
+	leaders := make(map[int32]partitionLeader)
+	for partition := range partitionOffsets {
+		broker, err := client.Leader(topic, partition)
+		if err != nil {
+			log.Printf("Cannot fetch leader for partition %d of topic %s: %v", partition, topic, err)
+			continue
+		}
+		leaders[partition] = partitionLeader{ID: broker.ID(), Addr: broker.Addr()}
+	}
+	return leaders
+}
+
+// filterByLeader only keeps the partitions led by the broker with the given id
+func filterByLeader(partitionOffsets offsetMap, leaders map[int32]partitionLeader, brokerID int32) offsetMap {
+	filtered := make(offsetMap)
+	for partition, offset := range partitionOffsets {
+		if leader, ok := leaders[partition]; ok && leader.ID == brokerID {
+			filtered[partition] = offset
+		}
+	}
+	return filtered
+}
+
+func logPartitionLeaders(partitionOffsets offsetMap, leaders map[int32]partitionLeader) {
+	partitions := make([]int, 0, len(partitionOffsets))
+	for partition := range partitionOffsets {
+		partitions = append(partitions, int(partition))
+	}
+	sort.Ints(partitions)
+
+	for _, partition := range partitions {
+		if leader, ok := leaders[int32(partition)]; ok {
+			kafkatools.Log.Infof("Partition %d is led by broker %d (%s)", partition, leader.ID, leader.Addr)
+		}
+	}
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/jurriaan/kafkatools"
+)
+
+func TestFilterByLeader(t *testing.T) {
+	partitionOffsets := make(offsetMap)
+	for partition := int32(0); partition < 4; partition++ {
+		partitionOffsets[partition] = kafkatools.TopicPartitionOffset{Topic: "foo", Partition: partition}
+	}
+
+	// The leader of partition 3 is unknown
+	leaders := map[int32]partitionLeader{
+		0: {ID: 1, Addr: "a:9092"},
+		1: {ID: 2, Addr: "b:9092"},
+		2: {ID: 1, Addr: "a:9092"},
+	}
+
+	filtered := filterByLeader(partitionOffsets, leaders, 1)
+	expected := offsetMap{0: partitionOffsets[0], 2: partitionOffsets[2]}
+
+	if !reflect.DeepEqual(filtered, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, filtered)
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/jurriaan/kafkatools"
+)
+
+// byteUnits are the multipliers of the size units accepted by parseByteSize
+var byteUnits = map[string]int64{
+	"":    1,
+	"B":   1,
+	"KB":  1000,
+	"MB":  1000 * 1000,
+	"GB":  1000 * 1000 * 1000,
+	"KIB": 1 << 10,
+	"MIB": 1 << 20,
+	"GIB": 1 << 30,
+}
+
+// parseByteSize parses a positive size such as 512, 100MB or 1GiB
+func parseByteSize(str string) (int64, error) {
+	number := strings.TrimRight(str, "BbKkMmGgIi")
+	multiplier, ok := byteUnits[strings.ToUpper(str[len(number):])]
+	if !ok {
+		return 0, fmt.Errorf("unknown unit in %q, use B, KB, MB, GB, KiB, MiB or GiB", str)
+	}
+
+	size, err := strconv.ParseInt(strings.TrimSpace(number), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q, use a number followed by B, KB, MB, GB, KiB, MiB or GiB", str)
+	}
+	if size <= 0 {
+		return 0, fmt.Errorf("size %q has to be positive", str)
+	}
+	return size * multiplier, nil
+}
+
+// limitedWriter writes to out until max bytes were written. Once the limit is reached stop is called so the consumers
+// shut down, the write which would exceed the limit and every write after it are dropped.
+type limitedWriter struct {
+	out     io.Writer
+	max     int64
+	written int64
+	stop    func()
+	reached bool
+}
+
+func (w *limitedWriter) Write(p []byte) (int, error) {
+	if w.reached {
+		return len(p), nil
+	}
+	if w.written+int64(len(p)) > w.max {
+		w.limitReached()
+		return len(p), nil
+	}
+
+	n, err := w.out.Write(p)
+	w.written += int64(n)
+	if w.written >= w.max {
+		w.limitReached()
+	}
+	return n, err
+}
+
+func (w *limitedWriter) limitReached() {
+	w.reached = true
+	kafkatools.Log.Infof("Quiting after %d bytes", w.written)
+	w.stop()
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestParseByteSize(t *testing.T) {
+	tests := []struct {
+		str      string
+		expected int64
+	}{
+		{"512", 512},
+		{"10B", 10},
+		{"100MB", 100 * 1000 * 1000},
+		{"2kb", 2000},
+		{"1GiB", 1 << 30},
+	}
+
+	for _, test := range tests {
+		size, err := parseByteSize(test.str)
+		if err != nil {
+			t.Errorf("Could not parse %s: %v", test.str, err)
+		} else if size != test.expected {
+			t.Errorf("Expected %s to be %d bytes, got %d", test.str, test.expected, size)
+		}
+	}
+
+	for _, invalid := range []string{"", "MB", "0", "-1KB", "10TB", "1.5MB"} {
+		if _, err := parseByteSize(invalid); err == nil {
+			t.Errorf("Expected an error for %q", invalid)
+		}
+	}
+}
+
+func TestLimitedWriter(t *testing.T) {
+	var out bytes.Buffer
+	stopped := 0
+	w := &limitedWriter{out: &out, max: 8, stop: func() { stopped++ }}
+
+	for _, line := range []string{"abc\n", "def\n", "ghi\n", "j\n"} {
+		writeMessage(w, "raw", []byte(line[:len(line)-1]))
+	}
+
+	if out.String() != "abc\ndef\n" {
+		t.Errorf("Expected the messages within the limit, got %q", out.String())
+	}
+	if stopped != 1 {
+		t.Errorf("Expected stop to be called once, got %d", stopped)
+	}
+}
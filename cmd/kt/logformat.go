@@ -0,0 +1,50 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+// logRecord is a log line as written by --log-format json
+type logRecord struct {
+	Time    string `json:"time"`
+	Message string `json:"message"`
+}
+
+// jsonLogWriter writes every log line as a JSON object, the log package calls Write once per line
+type jsonLogWriter struct {
+	out io.Writer
+	now func() time.Time
+}
+
+func (w *jsonLogWriter) Write(line []byte) (int, error) {
+	record, err := json.Marshal(logRecord{
+		Time:    w.now().UTC().Format(time.RFC3339Nano),
+		Message: strings.TrimSuffix(string(line), "\n"),
+	})
+	if err != nil {
+		return 0, err
+	}
+	if _, err := w.out.Write(append(record, '\n')); err != nil {
+		return 0, err
+	}
+	return len(line), nil
+}
+
+// setupLogging configures the log format: text (the default log output) or json
+func setupLogging(format string) error {
+	switch format {
+	case "text":
+	case "json":
+		log.SetFlags(0)
+		log.SetOutput(&jsonLogWriter{out: os.Stderr, now: time.Now})
+	default:
+		return fmt.Errorf("invalid log format %q, expected text or json", format)
+	}
+	return nil
+}
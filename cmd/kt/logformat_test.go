@@ -0,0 +1,60 @@
+package main
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/Shopify/sarama/mocks"
+	"github.com/jurriaan/kafkatools"
+)
+
+func TestJSONLogWriter(t *testing.T) {
+	var out bytes.Buffer
+	logger := log.New(&jsonLogWriter{out: &out, now: func() time.Time { return time.Unix(1500000000, 0) }}, "", 0)
+
+	logger.Printf("Fetching offsets of %s", `"foo"`)
+	logger.Println("Produced 2 messages")
+
+	expected := `{"time":"2017-07-14T02:40:00Z","message":"Fetching offsets of \"foo\""}` + "\n" +
+		`{"time":"2017-07-14T02:40:00Z","message":"Produced 2 messages"}` + "\n"
+	if out.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, out.String())
+	}
+}
+
+func TestQuietLogging(t *testing.T) {
+	var out bytes.Buffer
+	log.SetOutput(&out)
+	kafkatools.Log.SetLevel(kafkatools.LogWarning)
+	defer func() {
+		kafkatools.Log.SetLevel(kafkatools.LogInfo)
+		log.SetOutput(os.Stderr)
+	}()
+
+	config := sarama.NewConfig()
+	config.Consumer.Return.Errors = true
+	consumer := mocks.NewConsumer(t, config)
+	consumer.ExpectConsumePartition("foo", 0, 0).YieldMessage(&sarama.ConsumerMessage{Topic: "foo", Value: []byte("a"), Offset: 0})
+
+	partitionOffsets := topicOffsetMap{"foo": {0: kafkatools.TopicPartitionOffset{Topic: "foo", Partition: 0, Offset: 0}}}
+	endOffsets := topicOffsetMap{"foo": {0: kafkatools.TopicPartitionOffset{Topic: "foo", Partition: 0, Offset: 1}}}
+	// The partition summary of --no-partition-log, the time range and the statistics are informational too
+	messages, _ := consumeTopics(consumer, partitionOffsets, endOffsets, consumeOptions{noPartitionLog: true})
+	if received := receiveAll(t, messages); len(received) != 1 {
+		t.Errorf("Expected a message, received %d", len(received))
+	}
+	logTimeRange("foo", partitionOffsets["foo"], endOffsets["foo"])
+	kafkatools.Log.Infof("Connection closed. Bye.")
+	if out.Len() != 0 {
+		t.Errorf("Expected no informational logs at the warning level, got %q", out.String())
+	}
+
+	kafkatools.Log.Errorf("error: we got an error while consuming one of the partitions: %v", sarama.ErrOutOfBrokers)
+	if out.Len() == 0 {
+		t.Error("Expected the errors to be logged at the warning level")
+	}
+}
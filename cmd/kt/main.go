@@ -1,9 +1,15 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
 	"io"
 	"log"
+	"net"
+	"os"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -21,63 +27,661 @@ var (
 	usage       = `kt - kafka cli tool
 
 usage:
-  kt consume --topic <topic> --broker <broker,..> [options]
+  kt consume (--topic <topic>)... [--broker <broker,..>] [--broker-rewrite <old=new>]... [--sink <sink>]... [--offset-for <partition=offset>]... [--group <group>] [options]
+  kt replay (--topic <topic>)... [--broker <broker,..>] [--broker-rewrite <old=new>]... [--offset-for <partition=offset>]... [options]
+  kt assert (--topic <topic>)... [--broker <broker,..>] [--broker-rewrite <old=new>]... [options]
+  kt produce --topic <topic> [--broker <broker,..>] [--broker-rewrite <old=new>]... [options]
+  kt sizes [--broker <broker,..>] [--broker-rewrite <old=new>]... [options]
+  kt search [--broker <broker,..>] [--broker-rewrite <old=new>]... [options]
+  kt ping [--broker <broker,..>] [--broker-rewrite <old=new>]... [options]
+  kt api-versions [--broker <broker,..>] [--broker-rewrite <old=new>]... [options]
+  kt metadata [--broker <broker,..>] [--broker-rewrite <old=new>]... [options]
+  kt topics [--broker <broker,..>] [--broker-rewrite <old=new>]... [options]
+  kt offsets (--topic <topic>)... [--broker <broker,..>] [--broker-rewrite <old=new>]... [options]
+  kt stuck --topic <topic> [--broker <broker,..>] [--broker-rewrite <old=new>]... [options]
+  kt topic-config --topic <topic> [--broker <broker,..>] [--broker-rewrite <old=new>]... [options]
+  kt alter-topic-config --topic <topic> (--set <name=value>)... [--broker <broker,..>] [--broker-rewrite <old=new>]... [options]
+  kt reassign --topic <topic> --preview [--broker <broker,..>] [--broker-rewrite <old=new>]... [options]
+  kt delete-group --group <group> [--broker <broker,..>] [--broker-rewrite <old=new>]... [options]
+  kt groups [--broker <broker,..>] [--broker-rewrite <old=new>]... [options]
+  kt lag --group <group> [--topic <topic>]... [--broker <broker,..>] [--broker-rewrite <old=new>]... [options]
+  kt reset-offsets --group <group> --topic <topic> (--to <target> | --to-date <timestamp>) [--broker <broker,..>] [--broker-rewrite <old=new>]... [options]
+  kt set-offsets --group <group> --topic <topic> (--to <target> | --to-date <timestamp>) [--broker <broker,..>] [--broker-rewrite <old=new>]... [options]
+  kt round-trip --topic <topic> [--broker <broker,..>] [--broker-rewrite <old=new>]... [options]
 
 options:
   -h --help                  show this screen.
   -V, --version              show version.
-  -t, --topic <topic>        the topic
-  -b, --broker <broker,..>   the brokers to connect to
-  -o, --offset <offset>      offset to start consuming from: beginning | end | <value> (absolute offset) | -<value> (relative offset) TODO
-  -p, --partition <n>        consume a single partition
-	--start-date <timestamp>   start consuming from the specified timestamp
-	--end-date <timestamp>     stop consuming until the specified timestamp
+  --log-format <format>      write the logs to stderr as text or json (one object per line) [default: text]
+  -q, --quiet                only log the warnings and errors, not the progress such as the offsets being fetched and
+                             the partitions being consumed
+  -t, --topic <topic>        the topic, repeat the option or separate the topics by commas to consume several topics
+  -b, --broker <broker,..>   the brokers to connect to, the brokers of the --broker-file or the comma separated
+                             brokers of the KT_BROKERS environment variable by default
+  --broker-file <path>       connect to the brokers listed in the file without --broker: one or more comma separated
+                             brokers per line, blank lines and lines starting with # are skipped
+  --broker-rewrite <old=new>  connect to new instead of the (advertised) broker address old, both are host:port or a host,
+                             repeat the option or separate the rewrites by commas to rewrite several addresses
+  -o, --offset <offset>      offset to start consuming from: oldest | beginning | newest | end | oldest+<n> | newest-<n> |
+                             <n> (absolute offset) | -<n> (short for newest-<n>)
+  --offset-for <partition=offset>  start the partition at the absolute offset instead of the --offset or --start-date,
+                             repeat the option or separate the overrides by commas. Offsets before the oldest or after
+                             the newest offset of the partition are clamped with a warning.
+  -p, --partition <n>        consume a single partition, or a list of partitions in the format of --partitions;
+                             produce: write every message to the partition
+  --partitions <list>        consume these partitions: comma separated partitions and ranges, e.g. 0,3,5-7. Partitions
+                             listed more than once are consumed once.
+  --key <key>                consume the single partition the key is produced to by the --partitioner
+  --partitioner <name>       partitioner of the producers of the topic, to find the partition of --key: murmur2 (Java
+                             client) | fnv (sarama) | java (legacy Scala producer) [default: murmur2]
+  --interactive              pick the partitions and their start offsets interactively
+  --partition-leader-only <broker-id>  only consume the partitions led by this broker
+  --partitions-from-file <path>  consume exactly the offset ranges listed in the file instead of resolving the offsets:
+                             a "<partition> <start> <end>" line per partition of the --topic, the end offset is
+                             excluded, blank lines and lines starting with # are skipped
+  --from-file <path>         start consuming at the offsets in the file, a JSON array of {"topic", "partition",
+                             "offset"} objects as printed by kt offsets, every listed partition of the --topic topics
+                             is consumed
+	--start-date <timestamp>   start consuming from the specified timestamp: RFC3339, "2006-01-02 15:04:05" (local time),
+                             Unix seconds or milliseconds, or relative to now such as -2h
+	--end-date <timestamp>     stop consuming before the first message at or after the specified timestamp, in the
+                             formats of --start-date, from the oldest offset unless --start-date or --offset is given
   -c, --count <n>            stop consuming after n messages
+  --count-per-partition <n>  stop consuming a partition after n of its messages were printed, the other partitions
+                             keep going until they reach their own limit or end offset, --count still limits the total
+  --tail <n>                 print the last n messages and stop: every partition starts ceil(n / partitions) messages
+                             before its newest offset (or at its oldest), at most n messages are printed in total. The
+                             messages are only the n most recent ones when they are spread evenly over the partitions.
+  --limit-bytes <size>       stop consuming once the printed output reaches the size, e.g. 100MB (B, KB, MB, GB, KiB,
+                             MiB or GiB), the message which would exceed it is not printed
+  --max-rate <n>             emit at most n messages per second, over all partitions together, 0 does not limit the
+                             rate [default: 0]
+  --sink <sink>              write the messages to this sink instead of stdout: stdout | file:<path> (in the --output
+                             format) | topic:<topic> (produce them as is) | tcp://<host:port> or udp://<host:port> (in
+                             the --output format, a datagram per message over udp, reconnecting when a write fails) |
+                             syslog (the local syslog daemon, one line per message), repeat the option to write to
+                             several sinks
+  --strict-sinks             stop when a sink fails instead of only dropping that sink
   -e, --exit                 stop consuming after the last message
+  --follow                   start at the oldest offset (or at --offset or --start-date) and keep consuming the new
+                             messages until interrupted, without an end offset
+  --first-message-only       only consume the oldest message of every partition, e.g. to see the oldest data retained
+  --end-at-hwm               stop consuming every partition at the high-water mark it had when its consumer started,
+                             instead of at the end offsets fetched up front
+  --snapshot-mode <mode>     when a range ends at the newest offsets, take them before the partition consumers start
+                             (messages produced while they start are not consumed) or after, the high-water marks
+                             when every partition consumer started: before | after [default: before]
+  --grep <regexp>            only emit messages whose value matches the regexp
+  --key-filter <regexp>      only emit messages whose key matches the regexp
+  --header-filter <header=regexp>  only emit messages with a header matching the regexp
+  --invert                   only emit the messages rejected by --grep, --key-filter and --header-filter instead of the
+                             matching ones
+  --has-key                  only emit messages with a key
+  --no-key                   only emit messages without a key
+  --sample <ratio>           only emit a random sample of about this fraction of the messages, e.g. 0.01
+  --seed <n>                 seed of --sample, the same seed samples the same messages in every run (random and logged
+                             by default)
+  --where <expr>             only emit messages whose (decoded) JSON value matches the expression, evaluated by kt as
+                             Kafka can't filter: comparisons (== != < <= > >=) of dotted fields and string, number,
+                             true, false and null literals combined with && || ! and parentheses, e.g.
+                             'amount>100 && status=="FAILED"'
+  --dedupe-values <mode>     only emit messages whose value differs (by hash) from: consecutive (the previous message
+                             of the partition) | global (the values seen in any partition, up to --dedupe-window)
+  --dedupe-window <n>        number of distinct values --dedupe-values global remembers, the oldest are forgotten
+                             [default: 100000]
+  --verify-order             check that the offsets of every partition are strictly increasing and its timestamps
+                             don't decrease, log the anomalies and fail when there were any
+  --ignore-timestamp-order   with --verify-order, only check the offsets
+  --pause-at <breakpoints>   comma separated <partition>:<offset> breakpoints: pause consuming the partition when it
+                             reaches the offset, before the message is printed, until Enter is pressed (Ctrl-C stops)
+  --since-offset-of-key <key>  find the first offset of the key in every partition, scanning from the oldest offset by default
+  --max-scan <n>             stop searching a partition for the key after n messages, 0 scans everything [default: 100000]
+  --then-consume             continue consuming from the offsets at which the key was found
+  --count-only               only print the number of (matching) messages per partition, implies --exit
+  --control-only             only print the transaction markers (commit or abort) written for transactional producers,
+                             scanning from the oldest offset by default, implies --exit, requires Kafka 0.11+
+  --output <format>          print the messages as: raw (the value only) | ndjson or json (one compact JSON object per
+                             message with the topic, partition, offset, timestamp (RFC3339), key, value and headers
+                             fields, key and value are null for missing keys and tombstones, keys and values which are
+                             not valid UTF-8 are base64 encoded and marked by "key_encoding": "base64" and
+                             "value_encoding": "base64", kt produce --input ndjson reads them back, logs are written to
+                             stderr) | binary (the key and value, each prefixed by
+                             its length as a 4-byte big-endian integer, -1 for null) | parquet (an uncompressed parquet
+                             file with the topic, partition, offset, timestamp, key and value columns, values are
+                             strings when decoded and bytes otherwise, best written to an --output-file) [default: raw]
+  --output-file <path>       write the printed messages to the file instead of stdout
+  --rotate-bytes <size>      with --output-file, start a new file before the file would exceed the size (e.g. 100MB),
+                             the full files are renamed to <path>.1, <path>.2 and so on, oldest first
+  --max-value-chars <n>      print at most n characters of every value, followed by a marker with the total number of
+                             characters of longer values, 0 prints them whole [default: 0]
+  --select <field,..>        only print these fields of JSON values, as a JSON object, fields are dotted paths (e.g.
+                             user.name, numbers index arrays), other values are printed as is
+  --invalid-utf8 <mode>      how the raw output prints values which are not valid UTF-8: keep | replace (the invalid
+                             bytes with U+FFFD) | base64 (the whole value) | auto (replace when printing to a terminal,
+                             keep otherwise) [default: auto]
+  --encode <encoding>        print the keys, values and header values: raw (as is) | base64 | hex, with the ndjson
+                             output format every encoded field is marked in the key_encoding, value_encoding and
+                             header_encodings fields [default: raw]
+  --null-value <text>        print the text instead of the value of tombstones (null values) with the raw output, to
+                             tell them from empty values, an empty text prints them as empty lines [default: <null>]
+  --print-size               prefix every message with the byte length of its value
+  --headers                  print the headers of every message with headers as key=value pairs on a line before its
+                             value (raw output, ndjson always has the headers field), values which are not valid UTF-8
+                             are base64 encoded and, in ndjson, marked in the header_encodings field. Requires kafka
+                             0.11 or newer, the first version returning headers.
+  --schema-registry <url>    the Confluent schema registry of the Avro schemas of the topics, e.g. http://localhost:8081
+  --avro                     decode the Avro values with the schemas of the --schema-registry and print them as JSON,
+                             the values are in its wire format: a zero byte and the 4-byte id of the schema followed by
+                             the Avro encoding, values without the zero byte are printed as is with a warning
+  --key-deserializer-from-schema-registry  decode the Avro keys with the schemas of the --schema-registry and print them
+                             as JSON, the keys are in its wire format: a zero byte and the 4-byte id of the schema
+                             (registered under the <topic>-key subject) followed by the Avro encoding
+  --template <template>      print every message rendered by the Go text/template instead of its value (raw output),
+                             with the fields .Topic, .Partition, .Offset, .Timestamp (a time.Time), .Key, .Value (the
+                             decoded value) and .Headers (a map), e.g. '{{.Partition}}:{{.Offset}} {{.Value}}'
+  --rekey <template>         replace the key of every message, before it is printed or written to the --sink, by the
+                             template evaluated over the decoded JSON value: {path} is replaced by the field at the
+                             dotted path, e.g. {customer.id} or {region}-{customer.id}
+  --number                   prefix every message with its sequence number among the printed messages, starting at 1,
+                             a number field with the ndjson output format
+  --number-per-partition     with --number, also prefix every message with its sequence number within its partition, a
+                             partition_number field with the ndjson output format
+  --print-lag                prefix every message with how far its offset was behind the high-water mark of its
+                             partition when it was consumed, a lag field with the ndjson output format
+  --timezone <zone>          print timestamps in the zone: local | utc | an IANA name such as Europe/Amsterdam (local by
+                             default)
+  --time-format <layout>     print timestamps in the layout: rfc3339 | rfc3339milli | rfc3339nano | a Go layout such as
+                             "2006-01-02 15:04:05.000" (rfc3339nano by default for ndjson)
+  --print-batch-meta         add the producer id and epoch, base sequence and transactional and control flags of the
+                             record batch of every message, requires --output ndjson and Kafka 0.11+
+  --size-histogram           only print a histogram of the value sizes of the (matching) messages, implies --exit
+  --compact-simulate         only print how many of the (matching) records per partition would survive compaction, and
+                             the keys with the most records, implies --exit
+  --digest <algorithm>       only print a digest of the (matching) records per partition and in total, to compare
+                             ranges of topics: md5 | sha1 | sha256 | sha512, implies --exit. A partition digest hashes
+                             the key and value of its records (as --output binary frames) in offset order, the total
+                             hashes the partition numbers and digests in partition order.
+  --keys-only                print the message keys instead of the values, one per line
+  --print-broker             prefix every message with the broker leading its partition, as <id>@<address>
+  --decode <format>          decode the messages: offsets (records of the __consumer_offsets topic) | msgpack (rendered
+                             as JSON) | debezium (a summary of the change events)
+  --decoder-command <cmd>    decode the messages using a long-running command (run by sh): every value is written to its
+                             stdin as a frame of --output binary, it answers with a frame of the decoded value (or a
+                             null frame when it could not decode it) on stdout
+  --decompress <codec>       decompress the values compressed by the application (not in the record batches, sarama
+                             decompresses those) before unwrapping and decoding them: gzip | snappy | zstd | auto
+                             (detected by their magic bytes, values without any are kept), values which are not
+                             compressed are printed as is with a warning
+  --unwrap <path>            use the field at this dotted path of JSON envelopes as the value, before decoding it
+  --unwrap-base64            base64 decode the unwrapped field
+  --error-file <path>        write the messages that could not be decoded to this file (as JSON lines)
+  --group <group>            the consumer group to join; consume: print the messages of the partitions assigned to this
+                             member from the offsets committed by the group (--offset and --start-date are ignored),
+                             the printed messages are committed so the group resumes after them
+  --assignor-debug           join the group, print the partitions assigned to this member and exit without consuming
+  --group-instance-id <id>   --assignor-debug: join the group as the static member with this id (kafka 2.3), which
+                             keeps its partitions when it rejoins within the session timeout
+  --client-id <id>           the client id the brokers attribute the requests to in their logs, metrics and quotas,
+                             only ASCII letters, digits, '.', '_' and '-' (defaults to kt-<version>)
+  --kafka-version <version>  kafka version of the brokers, e.g. 0.10.1.0 for older clusters or 2.8.0, or auto to
+                             detect the newest version all brokers support [default: 2.1.0]
+  --isolation <level>        read_uncommitted also returns records of aborted and open transactions, read_committed
+                             only returns committed records (and stops at the last stable offset) [default: read_uncommitted]
+  --sasl-mechanism <name>    authenticate using SASL: oauthbearer | plain | scram-sha-256 | scram-sha-512
+  --token <token>            static OAUTHBEARER token
+  --token-command <command>  command printing an OAUTHBEARER token, re-run when the token expires
+  --sasl-user <user>         user of the plain and scram SASL mechanisms
+  --sasl-pass <password>     password of the plain and scram SASL mechanisms
+  --tls                      connect to the brokers (and the --to-broker cluster) using TLS
+  --ca-cert <path>           --tls: verify the brokers with the CA certificates of this PEM file instead of the system
+                             certificates
+  --client-cert <path>       --tls: authenticate with the certificate of this PEM file, requires --client-key
+  --client-key <path>        --tls: the private key of the --client-cert, a PEM file
+  --tls-insecure-skip-verify  --tls: accept any broker certificate, only meant for testing
+  --to-broker <broker,..>    replay --to-topic, consume --sink topic:<topic>: produce the messages to the brokers of
+                             this cluster instead of the consumed cluster
+  --to-sasl-mechanism <name>  authenticate to the --to-broker cluster using SASL: oauthbearer | plain | scram-sha-256 |
+                             scram-sha-512
+  --to-sasl-user <user>      user of the plain and scram SASL mechanisms of the --to-broker cluster
+  --to-sasl-pass <password>  password of the plain and scram SASL mechanisms of the --to-broker cluster
+  --to-token <token>         static OAUTHBEARER token of the --to-broker cluster
+  --to-token-command <command>  command printing an OAUTHBEARER token of the --to-broker cluster
+  --partition-refresh <duration>  while following the topics, look for new partitions every interval and consume them
+                             from the start offset (from the oldest offset when starting at the end), 0 disables it [default: 1m]
+  --metrics-addr <host:port>  serve the messages and bytes consumed and the lag per partition, the consumer errors and
+                             the metrics of sarama in the Prometheus text format on http://<host:port>/metrics while
+                             consuming
+  --stats-interval <duration>  log the messages/sec and bytes/sec consumed per partition and in total every interval,
+                             with a decoder also the running number of decoded and failed messages
+  --progress                 show the progress of every partition of a bounded range (--exit or --end-date) and the
+                             estimated time remaining on stderr, redrawn in place on a terminal (--stats-interval
+                             logs the rate of unbounded ranges)
+  --max-age <duration>       stop consuming after the given duration, e.g. 10m
+  --reverse                  print the messages of a bounded range newest first, in descending offset order per
+                             partition, the range is buffered in memory before it is printed
+  --max-buffer <n>           the number of messages --reverse (or keys --dedup-key) buffers at most, it fails on larger
+                             ranges
+                             [default: 100000]
+  --sorted                   print the messages of a bounded range in ascending timestamp order over all partitions, a
+                             message is printed once every partition which is not done has a message queued
+  --dedup-key                only print the last message of every key of a bounded range per partition, in the order the
+                             keys were first seen. The latest message of every key is buffered in memory until the
+                             range is consumed (up to --max-buffer keys), messages without a key are all printed and
+                             buffered too. --count limits the deduplicated messages.
+  --dedup-sort               print the keys of --dedup-key sorted by key, the messages without a key last
+  --on-out-of-range <action>  when the offset of a partition is out of range (e.g. removed by retention): fail | reset
+                             (consume the partition from the oldest offset) [default: fail]
+  --exit-on-error            exit on the first error of a partition consumer instead of logging it and retrying
+  --max-partitions-concurrent <n>  consume at most n partitions at the same time, starting the next partition when one
+                             reached its end offset, requires a bounded range
+  --no-partition-log         log a summary line per topic instead of a line for every partition started
+  --require-all-partitions   fail when a partition cannot be consumed (e.g. its leader is unavailable) instead of
+                             consuming the available partitions
+  --drain-timeout <duration>  how long to wait for in-flight messages when shutting down [default: 10s]
+  --wait-for-topic <duration>  wait up to the duration for the topics to be created instead of failing when they do
+                             not exist yet
+  --min-count <n>            assert: the number of (matching, see --grep) messages the range has to contain at least
+                             [default: 1]
+  --count-exact              assert: the range has to contain exactly --min-count messages
+  --to-topic <topic>         replay: produce the messages to this topic instead of printing them
+  --speed <factor>           replay: speed up (or slow down) the original timing by this factor [default: 1]
+  --compression <codec>      produce: compress the batches using none | gzip | snappy | lz4 | zstd [default: none]
+  --batch-size <n>           produce: send a batch after reading n lines [default: 100]
+  --input <format>           produce: read the messages as: lines (one value per line) | binary (length-prefixed key
+                             and value frames, as written by --output binary) | ndjson (one JSON object per line with
+                             the partition, key, value and headers fields as written by --output ndjson, the other
+                             fields are ignored, a missing partition hashes the key and null keys and values are
+                             missing keys and tombstones) [default: lines]
+  --key-separator <sep>      produce: split every input line at the first separator into the key and the value, lines
+                             without the separator are produced as values without a key (lines input only)
+  --print-offsets            produce: log the partition and offset of every produced message
+  --linger <duration>        produce: send a batch when no new line was read within the duration [default: 10ms]
+  --idempotent               produce: write every message exactly once (waits for all in-sync replicas)
+  --acks <acks>              produce: wait for the acknowledgement of none | leader | all (in-sync replicas), defaults
+                             to leader or to all with --idempotent
+  --transactional-id <id>    produce: send every batch in a transaction, requires --idempotent
+  --latency                  produce: log the p50, p95 and p99 acknowledgement latencies at the end, the messages of a
+                             batch all take the latency of their batch (time every message with --batch-size 1)
+  --filter <regexp>          sizes, search, topics: only include the topics matching the regexp
+  --include-internal-topics  sizes, search, metadata, topics: include the internal topics (__consumer_offsets,
+                             __transaction_state and the other topics starting with _), which are hidden by default
+  --verbose                  topics: print the leader, replicas and in-sync replicas of every partition instead of the
+                             number of partitions
+  --sample-size <n>          sizes: number of records sampled per partition to estimate the size [default: 10]
+  --max-scan-per-topic <n>   search: scan at most n messages of every topic for the --grep, --key-filter, --header-filter
+                             or --where matches, 0 scans the topics entirely [default: 10000]
+  --messages <n>             round-trip: number of generated messages to produce and consume back [default: 100]
+  --timeout <duration>       round-trip: fail when the messages were not consumed back within the duration (30s by
+                             default); consume: stop once kt ran for the duration, printing the output and summaries
+                             so far, and exit with status 124
+  --set <name=value>         alter-topic-config: override a config entry of the topic, repeat the option to set several
+  --dry-run                  alter-topic-config, delete-group, reset-offsets, set-offsets: only print (and let the
+                             brokers validate) the changes; consume: only print the brokers, partitions, offset
+                             ranges and estimated number of messages it would consume, or with --output ndjson the
+                             resolved start offsets (of --offset, --start-date and so on) as the JSON array read by
+                             kt consume --from-file
+  --to <target>              reset-offsets, set-offsets: the offset to reset to: an --offset expression (e.g. beginning,
+                             end, 42 or oldest+10), a timestamp in the formats of --start-date other than Unix
+                             timestamps (numbers are offsets), or only reset the listed partitions to comma separated
+                             partition=offset pairs, clamped to the oldest and newest offsets. The committed offset of
+                             every partition that changes is printed before and after, groups with active members are
+                             refused.
+  --to-date <timestamp>      reset-offsets, set-offsets: reset to the offsets of the first messages at or after the
+                             timestamp, in any of the formats of --start-date
+  --yes                      delete-group, reset-offsets, set-offsets: confirm the changes
+  --format <format>          groups, lag: print the committed offsets and lag per partition as a table, followed by
+                             the total lag of every group, or as json (also selected by --output json) [default: table]
+  --max-lag <n>              lag: fail when the total lag of the group (on the --topic topics) exceeds n, printing the
+                             lagging partitions
+  --max-partition-lag <n>    lag: fail when the lag of a partition of the group exceeds n, printing those partitions
+  --preview                  reassign: print the current and a balanced replica assignment without executing it
+  --interval <duration>      stuck: time between two high-water mark snapshots [default: 10s]
+  --intervals <n>            stuck: report the partitions that did not advance during n intervals [default: 3]
 `
 )
 
-func parseDateOpt(dateOpt interface{}) int64 {
-	date, err := time.Parse(time.RFC3339, dateOpt.(string))
+// stdoutIsTerminal returns whether the output is written to a terminal rather than a file or pipe
+func stdoutIsTerminal() bool {
+	info, err := os.Stdout.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// stderrIsTerminal returns whether the logs are written to a terminal
+func stderrIsTerminal() bool {
+	info, err := os.Stderr.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// parseDateOpt parses a date option into milliseconds since the epoch, see parseDate for the accepted formats
+func parseDateOpt(dateOpt interface{}) (int64, error) {
+	date, err := parseDate(dateOpt.(string), time.Now())
 	if err != nil {
-		log.Fatal("Invalid time format specified (RFC3339 required): ", err)
+		return 0, err
 	}
 
 	// Compute time in milliseconds
-	return date.UnixNano() / (int64(time.Millisecond) / int64(time.Nanosecond))
+	return date.UnixNano() / (int64(time.Millisecond) / int64(time.Nanosecond)), nil
 }
 
 type options struct {
-	brokers     []string
-	startOffset *int64
-	endOffset   *int64
-	partition   *int32
-	topic       string
-	count       int
+	command      string
+	brokers      []string
+	clientConfig kafkatools.ClientConfig
+	startOffset  *int64
+	// toBrokers and toClientConfig connect to the cluster the messages are produced to, the consumed cluster when
+	// toBrokers is empty
+	toBrokers      []string
+	toClientConfig kafkatools.ClientConfig
+	// partitionRanges are the offset ranges of --partitions-from-file, nil when the offsets are resolved
+	partitionRanges *partitionRanges
+	// startExpr overrides the start offset when it has to be resolved per partition
+	startExpr        *offsetExpression
+	endOffset        *int64
+	firstMessageOnly bool
+	partition        *int32
+	partitions       []int32
+	partitionKey     []byte
+	partitioner      string
+	leaderOnly       *int32
+	interactive      bool
+	topic            string
+	topics           []string
+	count            int
+	limitBytes       int64
+	outputFile       string
+	sinks            []string
+	strictSinks      bool
+	decoder          *valueDecoder
+	output           outputOptions
+	errorFile        string
+	countOnly        bool
+	digest           string
+	controlOnly      bool
+	compactSimulate  bool
+	sinceKey         *string
+	maxScan          int
+	thenConsume      bool
+	consumeOpts      consumeOptions
+	toTopic          string
+	speed            float64
+	topicFilter      string
+	minCount         int
+	countExact       bool
+	lagFormat        string
+	lagThresholds    lagThresholds
+	sampleSize       int
+	batchSize        int
+	linger           time.Duration
+	produceLatency   bool
+	reverse          bool
+	maxBuffer        int
+	inputFormat      string
+	// roundTripMessages is the number of messages kt round-trip produces
+	roundTripMessages int
+	timeout           time.Duration
+	configSettings    map[string]string
+	dryRun            bool
+	confirmed         bool
+	resetTarget       offsetTarget
+	interval          time.Duration
+	intervals         int
+	group             string
+	assignorDebug     bool
+	statsInterval     time.Duration
+	progress          bool
+	partitionRefresh  time.Duration
+	maxAge            time.Duration
+	drainTimeout      time.Duration
+	// deadline stops kt consume once its --timeout expired, nil without a timeout
+	deadline *consumeDeadline
+	// detectVersion detects the kafka version of the brokers once connected
+	detectVersion bool
+	// waitForTopic is how long to wait for the topics to be created, 0 does not wait
+	waitForTopic time.Duration
+	// internalTopics lists the internal topics in kt sizes, kt search and kt metadata
+	internalTopics bool
+	// maxScanPerTopic is the number of messages kt search scans per topic at most, 0 scans them entirely
+	maxScanPerTopic int
+	// keySeparator splits the input lines of kt produce into keys and values, empty produces the lines as values
+	keySeparator string
+	// printOffsets logs the partition and offset of every message produced by kt produce
+	printOffsets bool
+	// verbose prints the partition details of kt topics
+	verbose bool
+	// tail is the number of messages before the newest offsets to print, 0 when not tailing
+	tail int
+	// rotateBytes is the size at which the --output-file is rotated, 0 never rotates it
+	rotateBytes int64
+	// startOffsets are the start offsets of --from-file, nil when the offsets are resolved
+	startOffsets topicOffsetMap
+	// sorted merges the messages of the partitions in timestamp order
+	sorted bool
+	// metricsAddr is the address of the --metrics-addr endpoint, empty without it
+	metricsAddr string
+	// offsetFor are the start offsets of the partitions of --offset-for, nil without it
+	offsetFor map[int32]int64
+	// dedupKey prints the last message of every key of the range, sorted by key with dedupSort
+	dedupKey  bool
+	dedupSort bool
 }
 
 type offsetMap map[int32]kafkatools.TopicPartitionOffset
 
+// fetchTopicOffsets fetches the offsets of the partitions of the topic, kt stops when they cannot be fetched
+func fetchTopicOffsets(client sarama.Client, offset int64, topic string) offsetMap {
+	offsets, err := kafkatools.FetchTopicOffsets(client, offset, topic)
+	if err != nil {
+		log.Fatalf("Could not fetch the offsets of %s: %v", topic, err)
+	}
+	return offsets
+}
+
+// fetchTopicsOffsets fetches the offsets of the partitions of the topics, kt stops when they cannot be fetched
+func fetchTopicsOffsets(client sarama.Client, offset int64, topics ...string) map[string]map[int32]kafkatools.TopicPartitionOffset {
+	offsets, err := kafkatools.FetchTopicsOffsets(client, offset, topics...)
+	if err != nil {
+		log.Fatal("Could not fetch the offsets of the topics: ", err)
+	}
+	return offsets
+}
+
+// newClient connects to the brokers, kt stops when it cannot
+func newClient(clientConfig *kafkatools.ClientConfig, brokers ...string) sarama.Client {
+	client, err := kafkatools.GetSaramaClientWithConfig(clientConfig, brokers...)
+	if err != nil {
+		log.Fatal("Failed to start client: ", err)
+	}
+	return client
+}
+
+// topicOffsetMap contains the partition offsets per topic
+type topicOffsetMap map[string]offsetMap
+
 func parseOptions() options {
 	docOpts, err := docopt.Parse(usage, nil, true, fmt.Sprintf(versionInfo, version, gitrev), false)
 	if err != nil {
 		log.Panicf("[PANIC] We couldn't parse doc opts params: %v", err)
 	}
+	if err := setupLogging(docOpts["--log-format"].(string)); err != nil {
+		log.Fatal("Invalid log format specified: ", err)
+	}
+	if docOpts["--quiet"].(bool) {
+		kafkatools.Log.SetLevel(kafkatools.LogWarning)
+	}
+	brokers, err := resolveBrokers(docOpts["--broker"], docOpts["--broker-file"], os.Getenv(brokersEnv))
+	if err != nil {
+		log.Fatal("Could not determine the brokers: ", err)
+	}
+
+	command := "consume"
+	if docOpts["replay"].(bool) {
+		command = "replay"
+	} else if docOpts["sizes"].(bool) {
+		command = "sizes"
+	} else if docOpts["search"].(bool) {
+		command = "search"
+	} else if docOpts["stuck"].(bool) {
+		command = "stuck"
+	} else if docOpts["ping"].(bool) {
+		command = "ping"
+	} else if docOpts["api-versions"].(bool) {
+		command = "api-versions"
+	} else if docOpts["metadata"].(bool) {
+		command = "metadata"
+	} else if docOpts["topics"].(bool) {
+		command = "topics"
+	} else if docOpts["offsets"].(bool) {
+		command = "offsets"
+	} else if docOpts["assert"].(bool) {
+		command = "assert"
+	} else if docOpts["produce"].(bool) {
+		command = "produce"
+	} else if docOpts["round-trip"].(bool) {
+		command = "round-trip"
+	} else if docOpts["topic-config"].(bool) {
+		command = "topic-config"
+	} else if docOpts["alter-topic-config"].(bool) {
+		command = "alter-topic-config"
+	} else if docOpts["reassign"].(bool) {
+		command = "reassign"
+	} else if docOpts["delete-group"].(bool) {
+		command = "delete-group"
+	} else if docOpts["reset-offsets"].(bool) || docOpts["set-offsets"].(bool) {
+		command = "reset-offsets"
+	} else if docOpts["groups"].(bool) {
+		command = "groups"
+	} else if docOpts["lag"].(bool) {
+		command = "lag"
+	}
+
+	var sinceKey *string
+	if docOpts["--since-offset-of-key"] != nil {
+		sinceKey = new(string)
+		*sinceKey = docOpts["--since-offset-of-key"].(string)
+	}
+
+	maxScan, err := strconv.Atoi(docOpts["--max-scan"].(string))
+	if err != nil || maxScan < 0 {
+		log.Fatalf("Invalid max scan specified: %s", docOpts["--max-scan"])
+	}
 
 	var startOffset, endOffset = new(int64), new(int64)
-	*startOffset = sarama.OffsetNewest
+	controlOnly := docOpts["--control-only"].(bool)
+	following := docOpts["--follow"].(bool)
+	if following {
+		if command != "consume" {
+			log.Fatal("--follow can only be used with kt consume")
+		}
+		bounded := firstSetOption(map[string]bool{
+			"--exit":                 docOpts["--exit"].(bool),
+			"--end-date":             docOpts["--end-date"] != nil,
+			"--end-at-hwm":           docOpts["--end-at-hwm"].(bool),
+			"--count":                docOpts["--count"] != nil,
+			"--tail":                 docOpts["--tail"] != nil,
+			"--first-message-only":   docOpts["--first-message-only"].(bool),
+			"--partitions-from-file": docOpts["--partitions-from-file"] != nil,
+			"--count-only":           docOpts["--count-only"].(bool),
+			"--size-histogram":       docOpts["--size-histogram"].(bool),
+			"--compact-simulate":     docOpts["--compact-simulate"].(bool),
+			"--digest":               docOpts["--digest"] != nil,
+			"--control-only":         docOpts["--control-only"].(bool),
+		})
+		if bounded != "" {
+			log.Fatalf("--follow cannot be combined with %s", bounded)
+		}
+	}
+	*startOffset = defaultStartOffset(command, sinceKey != nil || controlOnly || following, docOpts["--end-date"] != nil)
 	if docOpts["--start-date"] != nil {
-		*startOffset = parseDateOpt(docOpts["--start-date"])
+		if *startOffset, err = parseDateOpt(docOpts["--start-date"]); err != nil {
+			log.Fatal("Invalid time specified: ", err)
+		}
+	}
+
+	var startExpr *offsetExpression
+	if docOpts["--offset"] != nil {
+		if docOpts["--start-date"] != nil {
+			log.Fatal("--offset cannot be combined with --start-date")
+		}
+
+		expr, err := parseOffsetExpression(docOpts["--offset"].(string))
+		if err != nil {
+			log.Fatal("Invalid offset specified: ", err)
+		}
+
+		// Partitions discovered while following start at the base of relative offsets, or at the oldest offset
+		*startOffset = sarama.OffsetOldest
+		if expr.relative() {
+			*startOffset = expr.Base
+		}
+		if expr.Delta != 0 || !expr.relative() {
+			startExpr = &expr
+		}
+	}
+
+	endAtHWM := docOpts["--end-at-hwm"].(bool)
+	if endAtHWM && docOpts["--end-date"] != nil {
+		log.Fatal("--end-at-hwm cannot be combined with --end-date")
+	}
+
+	firstMessageOnly := docOpts["--first-message-only"].(bool)
+	if firstMessageOnly {
+		if docOpts["--offset"] != nil || docOpts["--start-date"] != nil || docOpts["--end-date"] != nil || endAtHWM || sinceKey != nil || controlOnly || docOpts["--interactive"].(bool) || command != "consume" {
+			log.Fatal("--first-message-only cannot be combined with --offset, --start-date, --end-date, --end-at-hwm, --since-offset-of-key, --control-only, --interactive or kt replay")
+		}
+		*startOffset = sarama.OffsetOldest
+	}
+
+	tail := 0
+	if docOpts["--tail"] != nil {
+		if tail, err = strconv.Atoi(docOpts["--tail"].(string)); err != nil || tail < 1 {
+			log.Fatalf("Invalid tail specified: %s", docOpts["--tail"])
+		}
+		if docOpts["--offset"] != nil || docOpts["--start-date"] != nil || docOpts["--end-date"] != nil || endAtHWM || docOpts["--count"] != nil || sinceKey != nil || controlOnly || docOpts["--interactive"].(bool) || docOpts["--partitions-from-file"] != nil || command != "consume" {
+			log.Fatal("--tail cannot be combined with --offset, --start-date, --end-date, --end-at-hwm, --count, --since-offset-of-key, --control-only, --interactive, --partitions-from-file or kt replay")
+		}
 	}
 
+	// replays and counts always read a bounded range
 	if docOpts["--end-date"] != nil {
-		*endOffset = parseDateOpt(docOpts["--end-date"])
-	} else if docOpts["--exit"].(bool) {
+		if *endOffset, err = parseDateOpt(docOpts["--end-date"]); err != nil {
+			log.Fatal("Invalid time specified: ", err)
+		}
+		if docOpts["--start-date"] != nil && *endOffset < *startOffset {
+			log.Fatal("--end-date cannot be before --start-date")
+		}
+	} else if endAtHWM {
+		endOffset = nil
+	} else if docOpts["--exit"].(bool) || tail > 0 || docOpts["--partitions-from-file"] != nil || docOpts["--count-only"].(bool) || docOpts["--size-histogram"].(bool) || docOpts["--compact-simulate"].(bool) || docOpts["--digest"] != nil || controlOnly || firstMessageOnly || command == "replay" || command == "assert" {
 		*endOffset = sarama.OffsetNewest
 	} else {
 		endOffset = nil
 	}
 
+	snapshotMode := docOpts["--snapshot-mode"].(string)
+	if snapshotMode != "before" && snapshotMode != "after" {
+		log.Fatalf("Invalid snapshot mode specified: %s", snapshotMode)
+	}
+	if snapshotMode == "after" && (endOffset == nil || *endOffset != sarama.OffsetNewest || firstMessageOnly) {
+		log.Fatal("--snapshot-mode after requires a range ending at the newest offsets (--exit, --count-only, --size-histogram, --compact-simulate, kt replay or kt assert)")
+	}
+
+	// Following partitions have no end to show the progress towards
+	if docOpts["--progress"].(bool) && endOffset == nil {
+		log.Fatal("--progress requires a bounded range (--exit or --end-date), use --stats-interval to log the rate of followed partitions")
+	}
+
+	maxConcurrentPartitions := 0
+	if docOpts["--max-partitions-concurrent"] != nil {
+		maxConcurrentPartitions, err = strconv.Atoi(docOpts["--max-partitions-concurrent"].(string))
+		if err != nil || maxConcurrentPartitions < 1 {
+			log.Fatalf("Invalid number of concurrent partitions specified: %s", docOpts["--max-partitions-concurrent"])
+		}
+		// Followed partitions never finish, so the partitions beyond the limit would never be consumed
+		if endOffset == nil && !endAtHWM {
+			log.Fatal("--max-partitions-concurrent requires a bounded range (--exit, --end-date, --end-at-hwm, --count-only or kt replay)")
+		}
+	}
+
 	count := -1
 	if docOpts["--count"] != nil {
 		count, err = strconv.Atoi(docOpts["--count"].(string))
@@ -85,11 +689,52 @@ func parseOptions() options {
 			log.Fatal("Invalid count specified: ", err)
 		}
 	}
+	if tail > 0 {
+		count = tail
+	}
+
+	countPerPartition := 0
+	if docOpts["--count-per-partition"] != nil {
+		if countPerPartition, err = strconv.Atoi(docOpts["--count-per-partition"].(string)); err != nil || countPerPartition < 1 {
+			log.Fatalf("Invalid count per partition specified: %s", docOpts["--count-per-partition"])
+		}
+		if (command != "consume" && command != "replay") || tail > 0 {
+			log.Fatal("--count-per-partition can only be used with kt consume or kt replay and cannot be combined with --tail")
+		}
+	}
+
+	maxRate, err := strconv.Atoi(docOpts["--max-rate"].(string))
+	if err != nil || maxRate < 0 {
+		log.Fatalf("Invalid max rate specified: %s", docOpts["--max-rate"])
+	}
+	var rate *messageRate
+	if maxRate > 0 {
+		if command != "consume" && command != "replay" {
+			log.Fatal("--max-rate can only be used with kt consume or kt replay")
+		}
+		rate = newMessageRate(maxRate)
+	}
+
+	var limitBytes int64
+	if docOpts["--limit-bytes"] != nil {
+		if limitBytes, err = parseByteSize(docOpts["--limit-bytes"].(string)); err != nil {
+			log.Fatal("Invalid byte limit specified: ", err)
+		}
+	}
+
+	if docOpts["--partition"] != nil && docOpts["--partitions"] != nil {
+		log.Fatal("--partitions cannot be combined with --partition")
+	}
 
 	var partition = new(int32)
+	partitionList, _ := docOpts["--partitions"].(string)
 	if docOpts["--partition"] != nil {
 		if part, err := strconv.Atoi(docOpts["--partition"].(string)); err == nil {
 			*partition = int32(part)
+		} else if command != "produce" {
+			// A list of partitions is consumed like --partitions, a single partition stays a special case
+			partitionList = docOpts["--partition"].(string)
+			partition = nil
 		} else {
 			log.Fatal("Invalid partition specified: ", err)
 		}
@@ -97,136 +742,1694 @@ func parseOptions() options {
 		partition = nil
 	}
 
-	parsedOptions := options{
-		brokers:     strings.Split(docOpts["--broker"].(string), ","),
-		topic:       docOpts["--topic"].(string),
-		startOffset: startOffset,
-		endOffset:   endOffset,
-		partition:   partition,
-		count:       count,
+	var partitions []int32
+	if partitionList != "" {
+		var duplicates []int32
+		if partitions, duplicates, err = parsePartitionList(partitionList); err != nil {
+			log.Fatal("Invalid partitions specified: ", err)
+		}
+		if len(duplicates) > 0 {
+			kafkatools.Log.Infof("Partitions listed more than once are consumed once: %s", formatPartitionList(duplicates))
+		}
 	}
 
-	return parsedOptions
-}
+	var partitionKey []byte
+	if docOpts["--key"] != nil {
+		if partition != nil || partitions != nil {
+			log.Fatal("--key cannot be combined with --partition or --partitions")
+		}
+		partitionKey = []byte(docOpts["--key"].(string))
+	}
+	partitioner := docOpts["--partitioner"].(string)
+	if keyPartitioners[partitioner] == nil {
+		log.Fatalf("Invalid partitioner specified: %s", partitioner)
+	}
 
-func main() {
-	var endOffsets offsetMap
+	var leaderOnly = new(int32)
+	if docOpts["--partition-leader-only"] != nil {
+		if id, err := strconv.Atoi(docOpts["--partition-leader-only"].(string)); err == nil {
+			*leaderOnly = int32(id)
+		} else {
+			log.Fatal("Invalid broker id specified: ", err)
+		}
+	} else {
+		leaderOnly = nil
+	}
 
-	parsedOptions := parseOptions()
-	client := kafkatools.GetSaramaClient(parsedOptions.brokers...)
+	speed, err := strconv.ParseFloat(docOpts["--speed"].(string), 64)
+	if err != nil || speed <= 0 {
+		log.Fatalf("Invalid speed specified: %s", docOpts["--speed"])
+	}
 
-	log.Println("Fetching offsets")
-	partitionOffsets := kafkatools.FetchTopicOffsets(client, *parsedOptions.startOffset, parsedOptions.topic)
+	var maxAge time.Duration
+	if docOpts["--max-age"] != nil {
+		if maxAge, err = time.ParseDuration(docOpts["--max-age"].(string)); err != nil || maxAge <= 0 {
+			log.Fatalf("Invalid max age specified: %s", docOpts["--max-age"])
+		}
+	}
 
-	if parsedOptions.partition != nil {
-		val, found := partitionOffsets[*parsedOptions.partition]
-		if !found {
-			log.Fatalf("Partition %d not found for topic %s", *parsedOptions.partition, parsedOptions.topic)
+	var histogram *sizeHistogram
+	if docOpts["--size-histogram"].(bool) {
+		histogram = newSizeHistogram()
+	}
+
+	var statsInterval time.Duration
+	var stats *throughputStats
+	if docOpts["--stats-interval"] != nil {
+		if statsInterval, err = time.ParseDuration(docOpts["--stats-interval"].(string)); err != nil || statsInterval <= 0 {
+			log.Fatalf("Invalid stats interval specified: %s", docOpts["--stats-interval"])
 		}
+		stats = newThroughputStats()
+	}
 
-		partitionOffsets = make(offsetMap)
-		partitionOffsets[val.Partition] = val
+	var metricsAddr string
+	var metrics *consumeMetrics
+	if docOpts["--metrics-addr"] != nil {
+		metricsAddr = docOpts["--metrics-addr"].(string)
+		if _, _, err := net.SplitHostPort(metricsAddr); err != nil {
+			log.Fatal("Invalid metrics address specified: ", err)
+		}
+		if command != "consume" {
+			log.Fatal("--metrics-addr can only be used with kt consume")
+		}
+		metrics = newConsumeMetrics()
 	}
 
-	if parsedOptions.endOffset != nil {
-		endOffsets = kafkatools.FetchTopicOffsets(client, *parsedOptions.endOffset, parsedOptions.topic)
+	partitionRefresh, err := time.ParseDuration(docOpts["--partition-refresh"].(string))
+	if err != nil || partitionRefresh < 0 {
+		log.Fatalf("Invalid partition refresh interval specified: %s", docOpts["--partition-refresh"])
 	}
 
-	consumer, err := sarama.NewConsumerFromClient(client)
-	if err != nil {
-		log.Fatalf("Could not start consumer: %v", err)
+	onOutOfRange := docOpts["--on-out-of-range"].(string)
+	if onOutOfRange != "fail" && onOutOfRange != "reset" {
+		log.Fatalf("Invalid out of range action specified: %s", onOutOfRange)
 	}
 
-	messages, closing := consumePartitions(consumer, partitionOffsets, endOffsets)
+	drainTimeout, err := time.ParseDuration(docOpts["--drain-timeout"].(string))
+	if err != nil {
+		log.Fatal("Invalid drain timeout specified: ", err)
+	}
 
-	printMessages(messages, parsedOptions.count, func(str string) { fmt.Println(str) })
-	close(closing)
+	var waitForTopic time.Duration
+	if docOpts["--wait-for-topic"] != nil {
+		if waitForTopic, err = time.ParseDuration(docOpts["--wait-for-topic"].(string)); err != nil || waitForTopic <= 0 {
+			log.Fatalf("Invalid topic wait specified: %s", docOpts["--wait-for-topic"])
+		}
+		if command != "consume" && command != "replay" && command != "assert" {
+			log.Fatal("--wait-for-topic can only be used when consuming")
+		}
+	}
 
-	if err = client.Close(); err != nil {
-		log.Fatal("Could not properly close the client")
+	var decoderName string
+	if docOpts["--decode"] != nil {
+		decoderName = docOpts["--decode"].(string)
 	}
 
-	log.Println("Connection closed. Bye.")
-}
+	var filterPatterns [3]string
+	for i, option := range []string{"--grep", "--key-filter", "--header-filter"} {
+		if docOpts[option] != nil {
+			filterPatterns[i] = docOpts[option].(string)
+		}
+	}
+	patternFilter := newMessageFilter(filterPatterns[0], filterPatterns[1], filterPatterns[2])
+	if docOpts["--invert"].(bool) {
+		if patternFilter == nil {
+			log.Fatal("--invert requires --grep, --key-filter or --header-filter")
+		}
+		patternFilter = invertFilter(patternFilter)
+	}
 
-func printMessages(messages chan *sarama.ConsumerMessage, maxMessages int, printer func(string)) {
-	counter := 0
+	if docOpts["--has-key"].(bool) && docOpts["--no-key"].(bool) {
+		log.Fatal("--has-key cannot be combined with --no-key")
+	}
+	keyPresence := newKeyPresenceFilter(docOpts["--has-key"].(bool), docOpts["--no-key"].(bool))
 
-	for msg := range messages {
-		printer(string(msg.Value))
+	var sample messageFilter
+	if docOpts["--sample"] != nil {
+		ratio, err := strconv.ParseFloat(docOpts["--sample"].(string), 64)
+		if err != nil || ratio <= 0 || ratio > 1 {
+			log.Fatalf("Invalid sample ratio specified: %s", docOpts["--sample"])
+		}
 
-		if maxMessages != -1 {
-			counter++
-			if counter >= maxMessages {
-				log.Printf("Quiting after %d messages", counter)
-				return
+		seed := time.Now().UnixNano()
+		if docOpts["--seed"] != nil {
+			if seed, err = strconv.ParseInt(docOpts["--seed"].(string), 10, 64); err != nil {
+				log.Fatal("Invalid seed specified: ", err)
 			}
+		} else {
+			kafkatools.Log.Infof("Sampling with --seed %d", seed)
 		}
+		sample = newSampleFilter(ratio, seed)
+	} else if docOpts["--seed"] != nil {
+		log.Fatal("--seed can only be used with --sample")
 	}
-}
 
-func processErrors(pc sarama.PartitionConsumer) {
-	for err := range pc.Errors() {
-		log.Printf("error: we got an error while consuming one of the partitions: %v", err)
+	dedupeWindow, err := strconv.Atoi(docOpts["--dedupe-window"].(string))
+	if err != nil || dedupeWindow <= 0 {
+		log.Fatalf("Invalid dedupe window specified: %s", docOpts["--dedupe-window"])
+	}
+	var dedupe *valueDeduper
+	if docOpts["--dedupe-values"] != nil {
+		mode := docOpts["--dedupe-values"].(string)
+		if mode != "consecutive" && mode != "global" {
+			log.Fatalf("Invalid dedupe mode specified: %s", mode)
+		}
+		if command != "consume" {
+			log.Fatal("--dedupe-values can only be used when consuming")
+		}
+		dedupe = newValueDeduper(mode, dedupeWindow)
+	}
+
+	var order *orderVerifier
+	if docOpts["--verify-order"].(bool) {
+		if command != "consume" || controlOnly {
+			log.Fatal("--verify-order can only be used when consuming messages")
+		}
+		order = newOrderVerifier(docOpts["--ignore-timestamp-order"].(bool))
+	} else if docOpts["--ignore-timestamp-order"].(bool) {
+		log.Fatal("--ignore-timestamp-order requires --verify-order")
+	}
+
+	var pauses *breakpoints
+	if docOpts["--pause-at"] != nil {
+		if command != "consume" || controlOnly {
+			log.Fatal("--pause-at can only be used when consuming messages")
+		}
+		offsets, err := parseBreakpoints(parseList(docOpts["--pause-at"]))
+		if err != nil {
+			log.Fatal("Invalid breakpoints specified: ", err)
+		}
+		pauses = newBreakpoints(offsets, os.Stdin, os.Stderr)
+	}
+
+	output := outputOptions{
+		format:         docOpts["--output"].(string),
+		printBroker:    docOpts["--print-broker"].(bool),
+		keysOnly:       docOpts["--keys-only"].(bool),
+		printSize:      docOpts["--print-size"].(bool),
+		printHeaders:   docOpts["--headers"].(bool),
+		printBatchMeta: docOpts["--print-batch-meta"].(bool),
+		invalidUTF8:    docOpts["--invalid-utf8"].(string),
+		encode:         docOpts["--encode"].(string),
+		nullValue:      docOpts["--null-value"].(string),
+	}
+	if output.format == "json" {
+		output.format = "ndjson"
+	}
+	if docOpts["--print-lag"].(bool) {
+		output.lags = newMessageLags()
+	}
+	if docOpts["--number"].(bool) {
+		output.numbers = newMessageNumbers(docOpts["--number-per-partition"].(bool))
+	} else if docOpts["--number-per-partition"].(bool) {
+		log.Fatal("--number-per-partition requires --number")
+	}
+	if docOpts["--rekey"] != nil {
+		if output.rekey, err = parseKeyTemplate(docOpts["--rekey"].(string)); err != nil {
+			log.Fatal("Invalid key template specified: ", err)
+		}
+	}
+	var registry *kafkatools.SchemaRegistry
+	if docOpts["--schema-registry"] != nil {
+		registry = kafkatools.NewSchemaRegistry(docOpts["--schema-registry"].(string))
+	}
+	avroValues := docOpts["--avro"].(bool)
+	if docOpts["--key-deserializer-from-schema-registry"].(bool) {
+		if registry == nil {
+			log.Fatal("--key-deserializer-from-schema-registry requires --schema-registry")
+		}
+		output.keyRegistry = registry
+	} else if registry != nil && !avroValues {
+		log.Fatal("--schema-registry requires --avro or --key-deserializer-from-schema-registry")
+	}
+	if avroValues && registry == nil {
+		log.Fatal("--avro requires --schema-registry")
+	}
+	switch output.invalidUTF8 {
+	case "keep", "replace", "base64":
+	case "auto":
+		output.invalidUTF8 = "keep"
+		if stdoutIsTerminal() {
+			output.invalidUTF8 = "replace"
+		}
+	default:
+		log.Fatalf("Invalid UTF-8 mode specified: %s", output.invalidUTF8)
+	}
+	if docOpts["--timezone"] != nil || docOpts["--time-format"] != nil {
+		var zone, layout string
+		if docOpts["--timezone"] != nil {
+			zone = docOpts["--timezone"].(string)
+		}
+		if docOpts["--time-format"] != nil {
+			layout = docOpts["--time-format"].(string)
+		}
+		if output.timestamps, err = parseTimestampFormat(zone, layout); err != nil {
+			log.Fatal("Invalid timezone specified: ", err)
+		}
+		if output.format != "ndjson" && !controlOnly {
+			log.Fatal("--timezone and --time-format can only be used with the ndjson output format or --control-only")
+		}
+	}
+	if docOpts["--select"] != nil {
+		output.selectFields = parseList(docOpts["--select"])
+		if output.keysOnly {
+			log.Fatal("--select cannot be combined with --keys-only")
+		}
+	}
+	if output.maxValueChars, err = strconv.Atoi(docOpts["--max-value-chars"].(string)); err != nil || output.maxValueChars < 0 {
+		log.Fatalf("Invalid maximum value length specified: %s", docOpts["--max-value-chars"])
+	}
+	if output.format != "raw" && output.format != "ndjson" && output.format != "binary" && output.format != "parquet" {
+		log.Fatalf("Invalid output format specified: %s", output.format)
+	}
+	if output.printHeaders && output.format != "raw" && output.format != "ndjson" {
+		log.Fatal("--headers can only be used with the raw or ndjson output format")
+	}
+	if docOpts["--template"] != nil {
+		if output.format != "raw" || output.keysOnly {
+			log.Fatal("--template can only be used with the raw output format and without --keys-only")
+		}
+		if output.template, err = parseMessageTemplate(docOpts["--template"].(string)); err != nil {
+			log.Fatal("Invalid template specified: ", err)
+		}
+	}
+	if output.keysOnly && output.format != "raw" {
+		log.Fatal("--keys-only can only be used with the raw output format")
+	}
+	switch output.encode {
+	case "raw":
+	case "base64", "hex":
+		if output.format != "raw" && output.format != "ndjson" {
+			log.Fatal("--encode can only be used with the raw or ndjson output format")
+		}
+		if output.template != nil {
+			log.Fatal("--encode cannot be combined with --template")
+		}
+	default:
+		log.Fatalf("Invalid encoding specified: %s", output.encode)
+	}
+	if output.printBatchMeta && (output.format != "ndjson" || command != "consume") {
+		log.Fatal("--print-batch-meta can only be used when consuming with the ndjson output format")
+	}
+	if controlOnly && (endAtHWM || sinceKey != nil || docOpts["--count-only"].(bool) || docOpts["--size-histogram"].(bool) || docOpts["--compact-simulate"].(bool) || command != "consume") {
+		log.Fatal("--control-only cannot be combined with --end-at-hwm, --since-offset-of-key, --count-only, --size-histogram, --compact-simulate or kt replay")
+	}
+	if docOpts["--compact-simulate"].(bool) && (docOpts["--count-only"].(bool) || docOpts["--size-histogram"].(bool) || command != "consume") {
+		log.Fatal("--compact-simulate cannot be combined with --count-only, --size-histogram or kt replay")
+	}
+	if controlOnly && output.format == "binary" {
+		log.Fatal("--control-only prints the markers as raw or ndjson output")
+	}
+	if docOpts["--dry-run"].(bool) && command == "consume" && output.format != "raw" && output.format != "ndjson" {
+		log.Fatal("--dry-run prints the plan as raw or ndjson output")
+	}
+	var digest string
+	if docOpts["--digest"] != nil {
+		digest = docOpts["--digest"].(string)
+		if _, ok := digestAlgorithms[digest]; !ok {
+			log.Fatalf("Invalid digest algorithm specified: %s", digest)
+		}
+		if docOpts["--count-only"].(bool) || docOpts["--size-histogram"].(bool) || docOpts["--compact-simulate"].(bool) || controlOnly || sinceKey != nil {
+			log.Fatal("--digest cannot be combined with --count-only, --size-histogram, --compact-simulate, --control-only or --since-offset-of-key")
+		}
+		if command != "consume" {
+			log.Fatal("--digest can only be used with kt consume")
+		}
+	}
+	printsMessages := !docOpts["--count-only"].(bool) && !docOpts["--size-histogram"].(bool) && !docOpts["--compact-simulate"].(bool) && digest == "" && !docOpts["--assignor-debug"].(bool) && (sinceKey == nil || docOpts["--then-consume"].(bool))
+	if output.format != "raw" && !printsMessages {
+		log.Fatalf("--output %s can only be used when printing messages", output.format)
+	}
+	if output.lags != nil && (output.format == "binary" || output.format == "parquet" || !printsMessages || command != "consume") {
+		log.Fatal("--print-lag can only be used when printing messages with the raw or ndjson output format")
+	}
+	if output.numbers != nil && (output.format == "binary" || output.format == "parquet" || !printsMessages || controlOnly) {
+		log.Fatal("--number can only be used when printing messages with the raw or ndjson output format")
+	}
+	if output.rekey != nil && (!printsMessages || controlOnly || command != "consume") {
+		log.Fatal("--rekey can only be used when printing messages")
+	}
+	if output.keyRegistry != nil && (!printsMessages || controlOnly || command != "consume") {
+		log.Fatal("--key-deserializer-from-schema-registry can only be used when printing messages")
+	}
+	if limitBytes > 0 && (!printsMessages || command != "consume") {
+		log.Fatal("--limit-bytes can only be used when printing messages")
+	}
+	maxBuffer, err := strconv.Atoi(docOpts["--max-buffer"].(string))
+	if err != nil || maxBuffer < 1 {
+		log.Fatalf("Invalid maximum buffer specified: %s", docOpts["--max-buffer"])
+	}
+	reverse := docOpts["--reverse"].(bool)
+	if reverse && (!printsMessages || controlOnly || command != "consume") {
+		log.Fatal("--reverse can only be used when printing messages")
+	}
+	if reverse && countPerPartition > 0 {
+		log.Fatal("--reverse cannot be combined with --count-per-partition")
+	}
+	// Followed partitions never end, so the buffered messages would never be printed
+	if reverse && endOffset == nil && !endAtHWM {
+		log.Fatal("--reverse requires a bounded range (--exit, --end-date or --end-at-hwm)")
+	}
+	sorted := docOpts["--sorted"].(bool)
+	if sorted && (!printsMessages || controlOnly || command != "consume" || reverse || maxConcurrentPartitions > 0) {
+		log.Fatal("--sorted can only be used when printing messages and cannot be combined with --reverse or --max-partitions-concurrent")
+	}
+	// A followed partition may always send an older message, so no message could be printed
+	if sorted && endOffset == nil && !endAtHWM {
+		log.Fatal("--sorted requires a bounded range (--exit, --end-date or --end-at-hwm)")
+	}
+	dedupKey := docOpts["--dedup-key"].(bool)
+	if dedupKey && (!printsMessages || controlOnly || command != "consume" || reverse || sorted || countPerPartition > 0) {
+		log.Fatal("--dedup-key can only be used when printing messages and cannot be combined with --reverse, --sorted or --count-per-partition")
+	}
+	if dedupKey && endOffset == nil && !endAtHWM {
+		log.Fatal("--dedup-key requires a bounded range (--exit, --end-date or --end-at-hwm)")
+	}
+	dedupSort := docOpts["--dedup-sort"].(bool)
+	if dedupSort && !dedupKey {
+		log.Fatal("--dedup-sort requires --dedup-key")
+	}
+
+	sinks, err := parseSinks(parseSinkOpt(docOpts["--sink"]))
+	if err != nil {
+		log.Fatal("Invalid sink specified: ", err)
+	}
+	if len(sinks) > 0 && (!printsMessages || controlOnly || command != "consume") {
+		log.Fatal("--sink can only be used when printing messages")
+	}
+	if docOpts["--strict-sinks"].(bool) && len(sinks) == 0 {
+		log.Fatal("--strict-sinks can only be used with --sink")
+	}
+	if output.format == "parquet" && (controlOnly || len(sinks) > 0 || limitBytes > 0 || command != "consume") {
+		log.Fatal("--output parquet cannot be combined with --control-only, --sink, --limit-bytes or kt replay")
+	}
+	var outputFile string
+	if docOpts["--output-file"] != nil {
+		outputFile = docOpts["--output-file"].(string)
+		if !printsMessages || controlOnly || command != "consume" {
+			log.Fatal("--output-file can only be used when printing messages")
+		}
+	}
+	var rotateBytes int64
+	if docOpts["--rotate-bytes"] != nil {
+		if outputFile == "" || output.format == "parquet" {
+			log.Fatal("--rotate-bytes requires --output-file and cannot be combined with --output parquet")
+		}
+		if rotateBytes, err = parseByteSize(docOpts["--rotate-bytes"].(string)); err != nil {
+			log.Fatal("Invalid rotation size specified: ", err)
+		}
+	}
+
+	var errorFile string
+	if docOpts["--error-file"] != nil {
+		errorFile = docOpts["--error-file"].(string)
 	}
-}
 
-func processMessages(pc sarama.PartitionConsumer, partitionEndOffset *int64, partitionCloser chan struct{}, messages chan *sarama.ConsumerMessage, wg *sync.WaitGroup) {
-	defer wg.Done()
-	for message := range pc.Messages() {
-		if partitionEndOffset != nil {
-			if message.Offset >= *partitionEndOffset {
-				close(partitionCloser)
-				break
+	var toTopic string
+	if docOpts["--to-topic"] != nil {
+		toTopic = docOpts["--to-topic"].(string)
+	}
+
+	topics := parseList(docOpts["--topic"])
+	output.labelTopics = len(topics) > 1
+	var topic string
+	if len(topics) > 0 {
+		topic = topics[0]
+	}
+	if len(topics) > 1 {
+		for option, set := range map[string]bool{
+			"--interactive":         docOpts["--interactive"].(bool),
+			"--key":                 partitionKey != nil,
+			"--since-offset-of-key": sinceKey != nil,
+			"--count-only":          docOpts["--count-only"].(bool),
+			"--pause-at":            docOpts["--pause-at"] != nil,
+			"--digest":              docOpts["--digest"] != nil,
+			"--assignor-debug":      docOpts["--assignor-debug"].(bool),
+			"kt stuck":              command == "stuck",
+			"kt produce":            command == "produce",
+			"kt round-trip":         command == "round-trip",
+			"kt topic-config":       command == "topic-config",
+			"kt alter-topic-config": command == "alter-topic-config",
+			"kt reassign":           command == "reassign",
+			"kt reset-offsets":      command == "reset-offsets",
+		} {
+			if set {
+				log.Fatalf("%s can only be used with a single topic", option)
 			}
 		}
-		messages <- message
 	}
-}
 
-func consumerCloser(pc io.Closer, partition int32, closing, partitionCloser chan struct{}) {
-	select {
-	case <-closing:
-	case <-partitionCloser:
+	var ranges *partitionRanges
+	if docOpts["--partitions-from-file"] != nil {
+		if docOpts["--offset"] != nil || docOpts["--start-date"] != nil || docOpts["--end-date"] != nil || endAtHWM || firstMessageOnly || partition != nil || partitions != nil || partitionKey != nil || sinceKey != nil || docOpts["--interactive"].(bool) || docOpts["--partition-leader-only"] != nil || snapshotMode == "after" {
+			log.Fatal("--partitions-from-file cannot be combined with --offset, --start-date, --end-date, --end-at-hwm, --first-message-only, --partition, --partitions, --key, --since-offset-of-key, --interactive, --partition-leader-only or --snapshot-mode after")
+		}
+		if command != "consume" && command != "replay" && command != "assert" {
+			log.Fatal("--partitions-from-file can only be used with kt consume, kt replay or kt assert")
+		}
+		if len(topics) > 1 {
+			log.Fatal("--partitions-from-file can only be used with a single topic")
+		}
+		if ranges, err = readPartitionsFile(docOpts["--partitions-from-file"].(string), topic); err != nil {
+			log.Fatal("Could not read the partitions file: ", err)
+		}
 	}
 
-	if err := pc.Close(); err != nil {
-		log.Panicf("ERROR: Failed to close consumer for partition %d: %s", partition, err)
+	var startOffsets topicOffsetMap
+	if docOpts["--from-file"] != nil {
+		if docOpts["--offset"] != nil || docOpts["--start-date"] != nil || tail > 0 || sinceKey != nil || docOpts["--interactive"].(bool) || ranges != nil {
+			log.Fatal("--from-file cannot be combined with --offset, --start-date, --tail, --since-offset-of-key, --interactive or --partitions-from-file")
+		}
+		if command != "consume" && command != "replay" {
+			log.Fatal("--from-file can only be used with kt consume or kt replay")
+		}
+		if startOffsets, err = readOffsetsFile(docOpts["--from-file"].(string), topics); err != nil {
+			log.Fatal("Could not read the offsets file: ", err)
+		}
 	}
-}
 
-func consumePartitions(consumer sarama.Consumer, partitionOffsets, endOffsets offsetMap) (messages chan *sarama.ConsumerMessage, closing chan struct{}) {
-	var wg sync.WaitGroup
-	messages = make(chan *sarama.ConsumerMessage)
-	closing = make(chan struct{})
+	var offsetFor map[int32]int64
+	if overrides := parseList(docOpts["--offset-for"]); len(overrides) > 0 {
+		if tail > 0 || sinceKey != nil || firstMessageOnly || docOpts["--interactive"].(bool) || ranges != nil || startOffsets != nil {
+			log.Fatal("--offset-for cannot be combined with --tail, --since-offset-of-key, --first-message-only, --interactive, --partitions-from-file or --from-file")
+		}
+		if command != "consume" && command != "replay" {
+			log.Fatal("--offset-for can only be used with kt consume or kt replay")
+		}
+		if len(topics) > 1 {
+			log.Fatal("--offset-for can only be used with a single topic")
+		}
+		if offsetFor, err = parseOffsetFor(overrides); err != nil {
+			log.Fatal("Invalid offset overrides specified: ", err)
+		}
+	}
 
-	for _, offset := range partitionOffsets {
-		log.Printf("Consuming %s partition %d starting at %d (until %d)", offset.Topic, offset.Partition, offset.Offset, endOffsets[offset.Partition].Offset)
-		pc, err := consumer.ConsumePartition(offset.Topic, offset.Partition, offset.Offset)
-		if err != nil {
-			log.Panicf("ERROR: Failed to start consumer for partition %d: %s", offset.Partition, err)
+	clientConfig := parseClientConfig(docOpts)
+	var toBrokers []string
+	var toClientConfig kafkatools.ClientConfig
+	if docOpts["--to-broker"] != nil {
+		producesToTopic := toTopic != "" || (command == "consume" && hasTopicSink(sinks))
+		if !producesToTopic {
+			log.Fatal("--to-broker can only be used with kt replay --to-topic or a --sink topic:<topic>")
 		}
+		toBrokers = strings.Split(docOpts["--to-broker"].(string), ",")
+		toClientConfig = parseDestinationConfig(docOpts, clientConfig)
+	} else if docOpts["--to-sasl-mechanism"] != nil {
+		log.Fatal("--to-sasl-mechanism requires --to-broker")
+	}
+
+	lagFormat := docOpts["--format"].(string)
+	if output := docOpts["--output"].(string); (command == "groups" || command == "lag") && (output == "json" || output == "ndjson") {
+		lagFormat = "json"
+	}
+	if lagFormat != "table" && lagFormat != "json" {
+		log.Fatalf("Invalid format specified: %s", lagFormat)
+	}
+
+	thresholds := lagThresholds{total: parseMaxLag(docOpts, "--max-lag"), partition: parseMaxLag(docOpts, "--max-partition-lag")}
+	if (thresholds.total != nil || thresholds.partition != nil) && command != "lag" {
+		log.Fatal("--max-lag and --max-partition-lag can only be used with kt lag")
+	}
+
+	minCount, err := strconv.Atoi(docOpts["--min-count"].(string))
+	if err != nil || minCount < 0 {
+		log.Fatalf("Invalid minimum count specified: %s", docOpts["--min-count"])
+	}
+
+	var topicFilter string
+	if docOpts["--filter"] != nil {
+		topicFilter = docOpts["--filter"].(string)
+	}
+
+	interval, err := time.ParseDuration(docOpts["--interval"].(string))
+	if err != nil || interval <= 0 {
+		log.Fatalf("Invalid interval specified: %s", docOpts["--interval"])
+	}
+
+	intervals, err := strconv.Atoi(docOpts["--intervals"].(string))
+	if err != nil || intervals < 1 {
+		log.Fatalf("Invalid number of intervals specified: %s", docOpts["--intervals"])
+	}
+
+	batchSize, err := strconv.Atoi(docOpts["--batch-size"].(string))
+	if err != nil || batchSize < 1 {
+		log.Fatalf("Invalid batch size specified: %s", docOpts["--batch-size"])
+	}
+
+	linger, err := time.ParseDuration(docOpts["--linger"].(string))
+	if err != nil || linger < 0 {
+		log.Fatalf("Invalid linger specified: %s", docOpts["--linger"])
+	}
+
+	if docOpts["--latency"].(bool) && command != "produce" {
+		log.Fatal("--latency can only be used with kt produce")
+	}
+
+	roundTripMessages, err := strconv.Atoi(docOpts["--messages"].(string))
+	if err != nil || roundTripMessages < 1 {
+		log.Fatalf("Invalid number of messages specified: %s", docOpts["--messages"])
+	}
 
-		var partitionEndOffset *int64
-		if endOffset, ok := endOffsets[offset.Partition]; ok {
-			partitionEndOffset = new(int64)
-			*partitionEndOffset = endOffset.Offset
+	timeout := 30 * time.Second
+	if docOpts["--timeout"] != nil {
+		if timeout, err = time.ParseDuration(docOpts["--timeout"].(string)); err != nil || timeout <= 0 {
+			log.Fatalf("Invalid timeout specified: %s", docOpts["--timeout"])
+		}
+		if command != "consume" && command != "round-trip" {
+			log.Fatal("--timeout can only be used with kt consume and kt round-trip")
 		}
-		partitionCloser := make(chan struct{})
+	} else if command == "consume" {
+		timeout = 0
+	}
 
-		wg.Add(1)
-		go consumerCloser(pc, offset.Partition, closing, partitionCloser)
-		go processMessages(pc, partitionEndOffset, partitionCloser, messages, &wg)
-		go processErrors(pc)
+	var configSettings map[string]string
+	if settings, ok := docOpts["--set"].([]string); ok {
+		if configSettings, err = parseConfigSettings(settings); err != nil {
+			log.Fatal("Invalid config settings specified: ", err)
+		}
 	}
 
-	go func() {
-		wg.Wait()
-		if err := consumer.Close(); err != nil {
-			log.Fatal("Error closing the consumer: ", err)
+	var resetTarget offsetTarget
+	if docOpts["--to"] != nil {
+		if resetTarget, err = parseOffsetTarget(docOpts["--to"].(string), time.Now()); err != nil {
+			log.Fatal("Invalid target specified: ", err)
+		}
+	} else if docOpts["--to-date"] != nil {
+		if resetTarget.timestamp, err = parseDateOpt(docOpts["--to-date"]); err != nil {
+			log.Fatal("Invalid target date specified: ", err)
 		}
-		close(messages)
+	}
+
+	inputFormat := docOpts["--input"].(string)
+	if _, ok := inputReaders[inputFormat]; !ok {
+		log.Fatalf("Invalid input format specified: %s", inputFormat)
+	}
+
+	var keySeparator string
+	if docOpts["--key-separator"] != nil {
+		keySeparator = docOpts["--key-separator"].(string)
+		if keySeparator == "" {
+			log.Fatal("Invalid key separator specified: it is empty")
+		}
+		if command != "produce" || inputFormat != "lines" {
+			log.Fatal("--key-separator can only be used with kt produce --input lines")
+		}
+	}
+	if docOpts["--print-offsets"].(bool) && command != "produce" {
+		log.Fatal("--print-offsets can only be used with kt produce")
+	}
+
+	var group string
+	if docOpts["--group"] != nil {
+		group = docOpts["--group"].(string)
+	}
+	if docOpts["--assignor-debug"].(bool) && group == "" {
+		log.Fatal("--assignor-debug requires a --group to join")
+	}
+	if clientConfig.GroupInstanceID != "" && !docOpts["--assignor-debug"].(bool) {
+		log.Fatal("--group-instance-id can only be used with --assignor-debug")
+	}
+	if group != "" && command == "consume" && !docOpts["--assignor-debug"].(bool) {
+		// The group assigns the partitions and keeps consuming them, it has no range to scan, count or reverse
+		for option, set := range map[string]bool{
+			"--partition":            docOpts["--partition"] != nil,
+			"--partitions":           docOpts["--partitions"] != nil,
+			"--partitions-from-file": docOpts["--partitions-from-file"] != nil,
+			"--key":                  partitionKey != nil,
+			"--interactive":          docOpts["--interactive"].(bool),
+			"--since-offset-of-key":  sinceKey != nil,
+			"--first-message-only":   firstMessageOnly,
+			"--exit":                 docOpts["--exit"].(bool),
+			"--end-date":             docOpts["--end-date"] != nil,
+			"--end-at-hwm":           endAtHWM,
+			"--tail":                 tail > 0,
+			"--count-per-partition":  countPerPartition > 0,
+			"--from-file":            docOpts["--from-file"] != nil,
+			"--max-rate":             maxRate > 0,
+			"--count-only":           docOpts["--count-only"].(bool),
+			"--size-histogram":       docOpts["--size-histogram"].(bool),
+			"--compact-simulate":     docOpts["--compact-simulate"].(bool),
+			"--digest":               docOpts["--digest"] != nil,
+			"--control-only":         controlOnly,
+			"--pause-at":             docOpts["--pause-at"] != nil,
+			"--reverse":              reverse,
+			"--sorted":               sorted,
+			"--dedup-key":            dedupKey,
+			"--dry-run":              docOpts["--dry-run"].(bool),
+			"--metrics-addr":         metricsAddr != "",
+			"--offset-for":           offsetFor != nil,
+			"--sink":                 len(sinks) > 0,
+			"--output parquet":       output.format == "parquet",
+		} {
+			if set {
+				log.Fatalf("%s cannot be combined with consuming as a --group", option)
+			}
+		}
+		if docOpts["--offset"] != nil || docOpts["--start-date"] != nil {
+			kafkatools.Log.Warnf("WARNING: the group manages the offsets, --offset and --start-date are ignored")
+		}
+	}
+
+	sampleSize, err := strconv.Atoi(docOpts["--sample-size"].(string))
+	if err != nil || sampleSize < 0 {
+		log.Fatalf("Invalid sample size specified: %s", docOpts["--sample-size"])
+	}
+
+	maxScanPerTopic, err := strconv.Atoi(docOpts["--max-scan-per-topic"].(string))
+	if err != nil || maxScanPerTopic < 0 {
+		log.Fatalf("Invalid max scan per topic specified: %s", docOpts["--max-scan-per-topic"])
+	}
+
+	decoder := newValueDecoder(decoderName)
+	if docOpts["--decoder-command"] != nil {
+		if decoderName != "" {
+			log.Fatal("--decoder-command cannot be combined with --decode")
+		}
+		decoder = newCommandValueDecoder(docOpts["--decoder-command"].(string))
+	}
+	if avroValues {
+		if decoderName != "" || docOpts["--decoder-command"] != nil {
+			log.Fatal("--avro cannot be combined with --decode or --decoder-command")
+		}
+		decoder = &valueDecoder{decode: newAvroDecoder(registry)}
+	}
+	if docOpts["--unwrap"] != nil {
+		decoder.unwrap = newUnwrapper(docOpts["--unwrap"].(string), docOpts["--unwrap-base64"].(bool))
+	} else if docOpts["--unwrap-base64"].(bool) {
+		log.Fatal("--unwrap-base64 requires --unwrap")
+	}
+	if docOpts["--decompress"] != nil {
+		codec := docOpts["--decompress"].(string)
+		switch codec {
+		case "gzip", "snappy", "zstd", "auto":
+		default:
+			log.Fatalf("Invalid compression codec specified: %s", codec)
+		}
+		decoder.decompress = newDecompressor(codec)
+	}
+	if stats != nil && (decoder.decode != nil || decoder.unwrap != nil || decoder.decompress != nil) {
+		stats.trackDecodes()
+		decoder.stats = stats
+	}
+	var where messageFilter
+	if docOpts["--where"] != nil {
+		expr, err := parseWhere(docOpts["--where"].(string))
+		if err != nil {
+			log.Fatal("Invalid where expression specified: ", err)
+		}
+		where = newWhereFilter(expr, decoder)
+	}
+	parsedOptions := options{
+		command:          command,
+		brokers:          brokers,
+		clientConfig:     clientConfig,
+		toBrokers:        toBrokers,
+		toClientConfig:   toClientConfig,
+		topic:            topic,
+		topics:           topics,
+		startOffset:      startOffset,
+		startExpr:        startExpr,
+		endOffset:        endOffset,
+		firstMessageOnly: firstMessageOnly,
+		partition:        partition,
+		partitions:       partitions,
+		partitionKey:     partitionKey,
+		partitioner:      partitioner,
+		leaderOnly:       leaderOnly,
+		interactive:      docOpts["--interactive"].(bool),
+		partitionRanges:  ranges,
+		count:            count,
+		limitBytes:       limitBytes,
+		outputFile:       outputFile,
+		sinks:            sinks,
+		strictSinks:      docOpts["--strict-sinks"].(bool),
+		decoder:          decoder,
+		output:           output,
+		errorFile:        errorFile,
+		countOnly:        docOpts["--count-only"].(bool),
+		digest:           digest,
+		controlOnly:      controlOnly,
+		compactSimulate:  docOpts["--compact-simulate"].(bool),
+		sinceKey:         sinceKey,
+		maxScan:          maxScan,
+		thenConsume:      docOpts["--then-consume"].(bool),
+		consumeOpts: consumeOptions{
+			filter:                  allFilters(patternFilter, keyPresence, sample, where, dedupe.filter()),
+			dedupe:                  dedupe,
+			order:                   order,
+			pauses:                  pauses,
+			maxPerPartition:         countPerPartition,
+			rate:                    rate,
+			stats:                   stats,
+			endAtHWM:                endAtHWM,
+			histogram:               histogram,
+			resetOutOfRange:         onOutOfRange == "reset",
+			exitOnError:             docOpts["--exit-on-error"].(bool),
+			snapshotAfter:           snapshotMode == "after",
+			lags:                    output.lags,
+			requireAllPartitions:    docOpts["--require-all-partitions"].(bool),
+			noPartitionLog:          docOpts["--no-partition-log"].(bool),
+			maxConcurrentPartitions: maxConcurrentPartitions,
+			metrics:                 metrics,
+		},
+		toTopic:           toTopic,
+		speed:             speed,
+		topicFilter:       topicFilter,
+		minCount:          minCount,
+		countExact:        docOpts["--count-exact"].(bool),
+		lagFormat:         lagFormat,
+		lagThresholds:     thresholds,
+		sampleSize:        sampleSize,
+		batchSize:         batchSize,
+		linger:            linger,
+		produceLatency:    docOpts["--latency"].(bool),
+		reverse:           reverse,
+		sorted:            sorted,
+		metricsAddr:       metricsAddr,
+		offsetFor:         offsetFor,
+		dedupKey:          dedupKey,
+		dedupSort:         dedupSort,
+		maxBuffer:         maxBuffer,
+		inputFormat:       inputFormat,
+		roundTripMessages: roundTripMessages,
+		timeout:           timeout,
+		configSettings:    configSettings,
+		dryRun:            docOpts["--dry-run"].(bool),
+		confirmed:         docOpts["--yes"].(bool),
+		resetTarget:       resetTarget,
+		interval:          interval,
+		intervals:         intervals,
+		group:             group,
+		assignorDebug:     docOpts["--assignor-debug"].(bool),
+		statsInterval:     statsInterval,
+		progress:          docOpts["--progress"].(bool),
+		partitionRefresh:  partitionRefresh,
+		maxAge:            maxAge,
+		drainTimeout:      drainTimeout,
+		detectVersion:     docOpts["--kafka-version"].(string) == "auto",
+		waitForTopic:      waitForTopic,
+		internalTopics:    docOpts["--include-internal-topics"].(bool),
+		maxScanPerTopic:   maxScanPerTopic,
+		keySeparator:      keySeparator,
+		printOffsets:      docOpts["--print-offsets"].(bool),
+		verbose:           docOpts["--verbose"].(bool),
+		tail:              tail,
+		rotateBytes:       rotateBytes,
+		startOffsets:      startOffsets,
+	}
+	if command == "search" && parsedOptions.consumeOpts.filter == nil {
+		log.Fatal("kt search requires a filter: --grep, --key-filter, --header-filter or --where")
+	}
+
+	return parsedOptions
+}
+
+// parseSinkOpt returns the repeated --sink options, which are not split by commas as paths may contain them
+func parseSinkOpt(opt interface{}) []string {
+	sinks, _ := opt.([]string)
+	return sinks
+}
+
+// parseList splits a (repeated) option with comma separated values into a list of values
+func parseList(opt interface{}) (list []string) {
+	var values []string
+	switch opt := opt.(type) {
+	case string:
+		values = []string{opt}
+	case []string:
+		values = opt
+	}
+
+	for _, value := range values {
+		for _, item := range strings.Split(value, ",") {
+			if item != "" {
+				list = append(list, item)
+			}
+		}
+	}
+	return list
+}
+
+func parseClientConfig(docOpts map[string]interface{}) (clientConfig kafkatools.ClientConfig) {
+	if err := clientConfig.Producer.Compression.UnmarshalText([]byte(docOpts["--compression"].(string))); err != nil {
+		log.Fatalf("Invalid compression specified: %s", docOpts["--compression"])
+	}
+
+	clientConfig.DescribeConfigSources = docOpts["topic-config"].(bool) || docOpts["alter-topic-config"].(bool)
+	clientConfig.Producer.Idempotent = docOpts["--idempotent"].(bool)
+	if docOpts["--transactional-id"] != nil {
+		clientConfig.Producer.TransactionalID = docOpts["--transactional-id"].(string)
+	}
+	if docOpts["--acks"] != nil {
+		clientConfig.Producer.Acks = docOpts["--acks"].(string)
+	}
+	if docOpts["--input"].(string) == "ndjson" {
+		// The input records keep their partitions and headers
+		clientConfig.Producer.Partitioner = newInputPartitioner
+		clientConfig.Producer.RecordHeaders = true
+	}
+	// The brokers only return headers to clients of kafka 0.11 or newer
+	messageTemplate, _ := docOpts["--template"].(string)
+	clientConfig.RecordHeaders = docOpts["--headers"].(bool) || docOpts["--header-filter"] != nil || strings.Contains(messageTemplate, ".Headers")
+	if docOpts["produce"].(bool) && docOpts["--partition"] != nil {
+		// Every message is written to the --partition
+		clientConfig.Producer.Partitioner = sarama.NewManualPartitioner
+	}
+	if err := clientConfig.Producer.Validate(); err != nil {
+		log.Fatal("Invalid producer settings: ", err)
+	}
+
+	var err error
+	if clientConfig.BrokerRewrites, err = kafkatools.ParseBrokerRewrites(parseList(docOpts["--broker-rewrite"])); err != nil {
+		log.Fatal("Invalid broker rewrite specified: ", err)
+	}
+	clientConfig.TLS = parseTLSConfig(docOpts)
+
+	if kafkaVersion := docOpts["--kafka-version"].(string); kafkaVersion != "auto" {
+		if clientConfig.Version, err = sarama.ParseKafkaVersion(kafkaVersion); err != nil {
+			log.Fatalf("Invalid kafka version specified: %s", kafkaVersion)
+		}
+	}
+
+	switch docOpts["--isolation"].(string) {
+	case "read_uncommitted":
+		clientConfig.IsolationLevel = sarama.ReadUncommitted
+	case "read_committed":
+		clientConfig.IsolationLevel = sarama.ReadCommitted
+	default:
+		log.Fatalf("Invalid isolation level specified: %s", docOpts["--isolation"])
+	}
+
+	clientConfig.ConsumerGroups = docOpts["consume"].(bool) && docOpts["--group"] != nil
+	// The group consumer commits every printed message itself
+	clientConfig.ManualCommits = clientConfig.ConsumerGroups
+	if docOpts["--group-instance-id"] != nil {
+		clientConfig.GroupInstanceID = docOpts["--group-instance-id"].(string)
+		if err := validateGroupInstanceID(clientConfig.GroupInstanceID); err != nil {
+			log.Fatal("Invalid group instance id specified: ", err)
+		}
+	}
+
+	clientConfig.ClientID = defaultClientID()
+	if docOpts["--client-id"] != nil {
+		clientConfig.ClientID = docOpts["--client-id"].(string)
+	}
+	if err := validateClientID(clientConfig.ClientID); err != nil {
+		log.Fatal("Invalid client id specified: ", err)
+	}
+
+	clientConfig.SASL = parseSASLConfig(docOpts, "--")
+	return clientConfig
+}
+
+// defaultClientID returns the client id of kt without --client-id, sarama does not allow the / of kt/<version>
+func defaultClientID() string {
+	return "kt-" + version
+}
+
+// validateClientID checks the id against the client ids sarama accepts
+func validateClientID(id string) error {
+	if id == "" {
+		return fmt.Errorf("the client id is empty")
+	}
+	return validateIDChars(id)
+}
+
+// validateIDChars checks that the id only contains ASCII letters, digits, '.', '_' and '-'
+func validateIDChars(id string) error {
+	for _, char := range id {
+		if !(char >= 'a' && char <= 'z' || char >= 'A' && char <= 'Z' || char >= '0' && char <= '9' || strings.ContainsRune("._-", char)) {
+			return fmt.Errorf("%q contains %q, only ASCII letters, digits, '.', '_' and '-' are allowed", id, char)
+		}
+	}
+	return nil
+}
+
+// parseDestinationConfig returns the settings of the --to-broker cluster: the settings of the consumed cluster, but
+// with its own authentication and without the broker rewrites and the static group membership
+func parseDestinationConfig(docOpts map[string]interface{}, clientConfig kafkatools.ClientConfig) kafkatools.ClientConfig {
+	clientConfig.SASL = parseSASLConfig(docOpts, "--to-")
+	clientConfig.BrokerRewrites = nil
+	clientConfig.GroupInstanceID = ""
+	clientConfig.ConsumerGroups = false
+	clientConfig.ManualCommits = false
+	return clientConfig
+}
+
+// parseSASLConfig parses the SASL options whose names start with the prefix, -- for the consumed cluster and --to- for
+// the --to-broker cluster
+func parseSASLConfig(docOpts map[string]interface{}, prefix string) (config kafkatools.SASLConfig) {
+	if docOpts[prefix+"sasl-mechanism"] == nil {
+		return config
+	}
+
+	switch mechanism := strings.ToLower(docOpts[prefix+"sasl-mechanism"].(string)); mechanism {
+	case "oauthbearer":
+		config.Mechanism = sarama.SASLTypeOAuth
+
+		if docOpts[prefix+"token"] != nil {
+			config.TokenProvider = kafkatools.StaticTokenProvider{AccessToken: docOpts[prefix+"token"].(string)}
+		} else if docOpts[prefix+"token-command"] != nil {
+			config.TokenProvider = kafkatools.NewCommandTokenProvider(docOpts[prefix+"token-command"].(string))
+		} else {
+			log.Fatalf("The oauthbearer SASL mechanism requires %stoken or %stoken-command", prefix, prefix)
+		}
+	case "plain", "scram-sha-256", "scram-sha-512":
+		config.Mechanism = map[string]sarama.SASLMechanism{
+			"plain":         sarama.SASLTypePlaintext,
+			"scram-sha-256": sarama.SASLTypeSCRAMSHA256,
+			"scram-sha-512": sarama.SASLTypeSCRAMSHA512,
+		}[mechanism]
+
+		if docOpts[prefix+"sasl-user"] == nil || docOpts[prefix+"sasl-pass"] == nil {
+			log.Fatalf("The %s SASL mechanism requires %ssasl-user and %ssasl-pass", mechanism, prefix, prefix)
+		}
+		config.User = docOpts[prefix+"sasl-user"].(string)
+		config.Password = docOpts[prefix+"sasl-pass"].(string)
+	default:
+		log.Fatalf("Unsupported SASL mechanism %s", docOpts[prefix+"sasl-mechanism"])
+	}
+
+	return config
+}
+
+// parseTLSConfig returns the TLS settings of --tls, nil without it
+func parseTLSConfig(docOpts map[string]interface{}) *tls.Config {
+	caCert, _ := docOpts["--ca-cert"].(string)
+	clientCert, _ := docOpts["--client-cert"].(string)
+	clientKey, _ := docOpts["--client-key"].(string)
+	insecureSkipVerify := docOpts["--tls-insecure-skip-verify"].(bool)
+	if !docOpts["--tls"].(bool) {
+		if caCert != "" || clientCert != "" || clientKey != "" || insecureSkipVerify {
+			log.Fatal("--ca-cert, --client-cert, --client-key and --tls-insecure-skip-verify require --tls")
+		}
+		return nil
+	}
+
+	config, err := kafkatools.NewTLSConfig(caCert, clientCert, clientKey, insecureSkipVerify)
+	if err != nil {
+		log.Fatal("Invalid TLS settings specified: ", err)
+	}
+	return config
+}
+
+func main() {
+	parsedOptions := parseOptions()
+	if parsedOptions.command == "consume" && parsedOptions.timeout > 0 {
+		parsedOptions.deadline = startDeadline(parsedOptions.timeout)
+	}
+	client := newClient(&parsedOptions.clientConfig, parsedOptions.brokers...)
+	if parsedOptions.detectVersion {
+		client = withDetectedVersion(client, parsedOptions.clientConfig, parsedOptions.brokers)
+	}
+	if parsedOptions.waitForTopic > 0 {
+		waitForTopics(client, parsedOptions.topics, parsedOptions.waitForTopic)
+	}
+
+	switch parsedOptions.command {
+	case "replay":
+		replay(client, parsedOptions)
+	case "assert":
+		assertMessages(client, parsedOptions)
+	case "sizes":
+		sizes(client, parsedOptions)
+	case "search":
+		search(client, parsedOptions)
+	case "groups", "lag":
+		groupLags(client, parsedOptions)
+	case "stuck":
+		stuck(client, parsedOptions)
+	case "ping":
+		ping(client)
+	case "api-versions":
+		apiVersions(client)
+	case "metadata":
+		metadata(client, parsedOptions.internalTopics)
+	case "topics":
+		listTopics(client, parsedOptions)
+	case "offsets":
+		dumpOffsets(client, parsedOptions)
+	case "produce":
+		produce(client, parsedOptions)
+	case "round-trip":
+		roundTrip(client, parsedOptions)
+	case "topic-config":
+		topicConfig(client, parsedOptions)
+	case "alter-topic-config":
+		alterTopicConfig(client, parsedOptions)
+	case "reassign":
+		reassign(client, parsedOptions)
+	case "delete-group":
+		deleteGroup(client, parsedOptions)
+	case "reset-offsets":
+		resetOffsets(client, parsedOptions)
+	default:
+		consume(client, parsedOptions)
+	}
+
+	if err := client.Close(); err != nil {
+		log.Fatal("Could not properly close the client")
+	}
+
+	kafkatools.Log.Infof("Connection closed. Bye.")
+	parsedOptions.deadline.exit()
+}
+
+func consume(client sarama.Client, parsedOptions options) {
+	if parsedOptions.assignorDebug {
+		printGroupAssignment(client, parsedOptions)
+		return
+	}
+
+	if parsedOptions.errorFile != "" {
+		defer parsedOptions.decoder.openErrorFile(parsedOptions.errorFile)()
+	}
+	defer parsedOptions.decoder.close()
+	defer parsedOptions.decoder.logSummary(parsedOptions.errorFile)
+
+	if parsedOptions.group != "" {
+		consumeGroup(client, parsedOptions)
+		return
+	}
+	if parsedOptions.partitionKey != nil {
+		parsedOptions.partition = keyPartition(client, parsedOptions.topic, parsedOptions.partitionKey, parsedOptions.partitioner)
+	}
+	partitionOffsets, endOffsets, leaders := fetchTopicsPartitionOffsets(client, parsedOptions)
+	if parsedOptions.dryRun {
+		printConsumePlan(client, parsedOptions, partitionOffsets, endOffsets, leaders)
+		return
+	}
+	if parsedOptions.controlOnly {
+		controlOnly(client, partitionOffsets, endOffsets, parsedOptions.output)
+		return
+	}
+
+	consumer, err := sarama.NewConsumerFromClient(client)
+	if err != nil {
+		log.Fatalf("Could not start consumer: %v", err)
+	}
+
+	if parsedOptions.sinceKey != nil {
+		newest := fetchTopicOffsets(client, sarama.OffsetNewest, parsedOptions.topic)
+		results := findKeyOffsets(consumer, []byte(*parsedOptions.sinceKey), partitionOffsets[parsedOptions.topic], newest, parsedOptions.maxScan)
+
+		if !parsedOptions.thenConsume {
+			printKeyScanResults(results, func(str string) { fmt.Println(str) })
+			if err := consumer.Close(); err != nil {
+				log.Println("Error closing the consumer: ", err)
+			}
+			return
+		}
+
+		printKeyScanResults(results, func(str string) { kafkatools.Log.Infof("%s", str) })
+		partitionOffsets[parsedOptions.topic] = keyStartOffsets(results, partitionOffsets[parsedOptions.topic])
+	}
+
+	consumeOpts := parsedOptions.consumeOpts
+	consumeOpts.partitionRefresh = newPartitionRefresh(client, parsedOptions)
+	if parsedOptions.sorted {
+		consumeOpts.sorted = newTimestampMerger()
+	}
+	if parsedOptions.progress {
+		consumeOpts.progress = newConsumeProgress(partitionOffsets, endOffsets)
+	}
+
+	messages, closing := consumeTopics(consumer, partitionOffsets, endOffsets, consumeOpts)
+	stop := shutdownHandler(closing, parsedOptions)
+	if parsedOptions.reverse {
+		messages = reverseMessages(messages, parsedOptions.maxBuffer)
+	}
+	if parsedOptions.dedupKey {
+		messages = dedupeKeys(messages, parsedOptions.maxBuffer, parsedOptions.dedupSort)
+	}
+	if consumeOpts.sorted != nil {
+		messages = consumeOpts.sorted.merge(messages)
+	}
+	if parsedOptions.consumeOpts.stats != nil {
+		go parsedOptions.consumeOpts.stats.report(parsedOptions.statsInterval, closing)
+	}
+	if consumeOpts.progress != nil {
+		go consumeOpts.progress.report(os.Stderr, stderrIsTerminal(), closing)
+	}
+	if consumeOpts.metrics != nil {
+		consumeOpts.metrics.registry = client.Config().MetricRegistry
+		serveMetrics(parsedOptions.metricsAddr, consumeOpts.metrics, closing)
+	}
+
+	if parsedOptions.output.printBatchMeta {
+		parsedOptions.output.batches = newBatchIndex(fetchRecordBatches(client))
+	}
+	formatter := newMessageFormatter(parsedOptions.output, parsedOptions.decoder, leaders)
+	// positions are where the printed partitions stopped, to resume an interrupted consume
+	var positions resumePositions
+	if parsedOptions.countOnly {
+		counts, total := countMessages(messages, parsedOptions.count)
+		printCounts(counts, total, func(str string) { fmt.Println(str) })
+	} else if parsedOptions.compactSimulate {
+		printCompaction(simulateCompaction(messages, parsedOptions.count), func(str string) { fmt.Println(str) })
+	} else if parsedOptions.consumeOpts.histogram != nil {
+		countMessages(messages, parsedOptions.count)
+		parsedOptions.consumeOpts.histogram.print(func(str string) { fmt.Println(str) })
+	} else if parsedOptions.digest != "" {
+		partitions := make([]int32, 0, len(partitionOffsets[parsedOptions.topic]))
+		for partition := range partitionOffsets[parsedOptions.topic] {
+			partitions = append(partitions, partition)
+		}
+		digest := newRangeDigest(parsedOptions.digest, partitions)
+		digestMessages(messages, parsedOptions.count, digest)
+		digest.print(func(str string) { fmt.Println(str) })
+	} else {
+		// The partitions of a reversed or deduplicated range are not printed in offset order, they can't be resumed
+		if !parsedOptions.reverse && !parsedOptions.dedupKey {
+			positions = newResumePositions(partitionOffsets)
+		}
+		var out io.Writer = os.Stdout
+		if parsedOptions.outputFile != "" {
+			file := createOutputFile(parsedOptions)
+			defer closeOutputFile(file)
+			out = file
+		}
+		if parsedOptions.limitBytes > 0 {
+			out = &limitedWriter{out: out, max: parsedOptions.limitBytes, stop: stop}
+		}
+		if parsedOptions.output.format == "parquet" {
+			writeParquet(messages, parsedOptions, out, formatter, positions)
+		} else if len(parsedOptions.sinks) == 0 {
+			printMessages(messages, parsedOptions.count, func(msg *sarama.ConsumerMessage) {
+				writeMessage(out, parsedOptions.output.format, formatter(msg))
+				positions.printed(msg)
+			})
+		} else {
+			destination, closeDestination := destinationClient(client, parsedOptions)
+			defer closeDestination()
+			sinks, err := openSinks(parsedOptions.sinks, destination, out, parsedOptions.output.format)
+			if err != nil {
+				log.Fatal(err)
+			}
+			write := fanOut(sinks, parsedOptions.strictSinks)
+			printMessages(messages, parsedOptions.count, func(msg *sarama.ConsumerMessage) {
+				if err := write(msg, formatter(msg)); err != nil {
+					log.Fatal("Could not write the message: ", err)
+				}
+				positions.printed(msg)
+			})
+			closeSinks(sinks)
+		}
+	}
+	stop()
+	drainMessages(messages, parsedOptions.drainTimeout)
+	if consumeOpts.progress != nil {
+		consumeOpts.progress.render(os.Stderr, stderrIsTerminal())
+	}
+	consumeOpts.dedupe.logSummary()
+	if resume := positions.resumeOptions(endOffsets); len(resume) > 0 {
+		kafkatools.Log.Infof("Stopped before the end of the range, resume the partitions with:")
+		for _, option := range resume {
+			kafkatools.Log.Infof("%s", option)
+		}
+	}
+	consumeOpts.order.report()
+}
+
+// newPartitionRefresh returns the partition refresh when following all partitions of the topics, nil otherwise
+func newPartitionRefresh(client sarama.Client, parsedOptions options) *partitionRefresh {
+	following := parsedOptions.endOffset == nil && !parsedOptions.consumeOpts.endAtHWM
+	allPartitions := parsedOptions.partition == nil && parsedOptions.partitions == nil && parsedOptions.leaderOnly == nil && !parsedOptions.interactive && parsedOptions.sinceKey == nil
+	if parsedOptions.partitionRefresh == 0 || !following || !allPartitions {
+		return nil
+	}
+
+	// Everything in a partition created after starting at the end is new
+	startOffset := *parsedOptions.startOffset
+	if startOffset == sarama.OffsetNewest {
+		startOffset = sarama.OffsetOldest
+	}
+
+	return &partitionRefresh{
+		interval: parsedOptions.partitionRefresh,
+		fetchOffsets: func(topic string) offsetMap {
+			if err := client.RefreshMetadata(topic); err != nil {
+				log.Printf("Could not refresh the metadata of topic %s: %v", topic, err)
+				return nil
+			}
+			return fetchOffsetsAt(client, startOffset, topic)
+		},
+	}
+}
+
+// fetchTopicsPartitionOffsets resolves the start (and, when bounded, end) offsets of the partitions of every topic
+func fetchTopicsPartitionOffsets(client sarama.Client, parsedOptions options) (partitionOffsets, endOffsets topicOffsetMap, leaders topicLeaders) {
+	partitionOffsets, endOffsets, leaders = make(topicOffsetMap), make(topicOffsetMap), make(topicLeaders)
+	for _, topic := range parsedOptions.topics {
+		partitionOffsets[topic], endOffsets[topic], leaders[topic] = fetchPartitionOffsets(client, topic, parsedOptions)
+	}
+
+	return partitionOffsets, endOffsets, leaders
+}
+
+// firstSetOption returns the first of the options which are set in alphabetical order, empty when none is set
+func firstSetOption(options map[string]bool) string {
+	var first string
+	for option, set := range options {
+		if set && (first == "" || option < first) {
+			first = option
+		}
+	}
+	return first
+}
+
+// defaultStartOffset returns where consuming starts without --start-date or --offset: the oldest offset for replays,
+// assertions, scans from the start (--follow too) and ranges ending at an --end-date (everything before it), the
+// newest otherwise
+func defaultStartOffset(command string, scanFromOldest, endDate bool) int64 {
+	if command == "replay" || command == "assert" || scanFromOldest || endDate {
+		return sarama.OffsetOldest
+	}
+	return sarama.OffsetNewest
+}
+
+// fetchPartitionOffsets resolves the start (and, when bounded, end) offsets of the partitions of the topic to consume
+func fetchPartitionOffsets(client sarama.Client, topic string, parsedOptions options) (partitionOffsets, endOffsets offsetMap, leaders map[int32]partitionLeader) {
+	if parsedOptions.partitionRanges != nil {
+		return parsedOptions.partitionRanges.offsets(client, topic)
+	}
+
+	kafkatools.Log.Infof("Fetching offsets of %s", topic)
+	partitionOffsets = fetchOffsetsAt(client, *parsedOptions.startOffset, topic)
+	if parsedOptions.startExpr != nil {
+		oldest := fetchTopicOffsets(client, sarama.OffsetOldest, topic)
+		newest := fetchTopicOffsets(client, sarama.OffsetNewest, topic)
+		partitionOffsets = resolveOffsetExpression(*parsedOptions.startExpr, oldest, newest)
+	}
+	if parsedOptions.startOffsets != nil {
+		partitionOffsets = parsedOptions.startOffsets[topic]
+	}
+	if parsedOptions.offsetFor != nil {
+		oldest := fetchTopicOffsets(client, sarama.OffsetOldest, topic)
+		newest := fetchTopicOffsets(client, sarama.OffsetNewest, topic)
+		var err error
+		if partitionOffsets, err = applyOffsetFor(topic, partitionOffsets, oldest, newest, parsedOptions.offsetFor); err != nil {
+			log.Fatal("Could not apply the offset overrides: ", err)
+		}
+	}
+
+	if parsedOptions.partition != nil {
+		val, found := partitionOffsets[*parsedOptions.partition]
+		if !found {
+			log.Fatalf("Partition %d not found for topic %s", *parsedOptions.partition, topic)
+		}
+
+		partitionOffsets = make(offsetMap)
+		partitionOffsets[val.Partition] = val
+	} else if parsedOptions.partitions != nil {
+		selected := make(offsetMap, len(parsedOptions.partitions))
+		for _, partition := range parsedOptions.partitions {
+			val, found := partitionOffsets[partition]
+			if !found {
+				log.Fatalf("Partition %d not found for topic %s", partition, topic)
+			}
+			selected[partition] = val
+		}
+		partitionOffsets = selected
+	} else if parsedOptions.interactive {
+		oldest := fetchTopicOffsets(client, sarama.OffsetOldest, topic)
+		newest := fetchTopicOffsets(client, sarama.OffsetNewest, topic)
+
+		var err error
+		if partitionOffsets, err = pickOffsets(os.Stdin, os.Stderr, oldest, newest, partitionOffsets); err != nil {
+			log.Fatal("Could not pick offsets: ", err)
+		}
+	}
+
+	leaders = fetchPartitionLeaders(client, topic, partitionOffsets)
+	if parsedOptions.leaderOnly != nil {
+		partitionOffsets = filterByLeader(partitionOffsets, leaders, *parsedOptions.leaderOnly)
+		if len(partitionOffsets) == 0 {
+			log.Fatalf("Broker %d is not the leader of any selected partition of topic %s", *parsedOptions.leaderOnly, topic)
+		}
+	}
+	if parsedOptions.tail > 0 {
+		oldest := fetchTopicOffsets(client, sarama.OffsetOldest, topic)
+		newest := fetchTopicOffsets(client, sarama.OffsetNewest, topic)
+		partitionOffsets = tailOffsets(partitionOffsets, oldest, newest, parsedOptions.tail)
+	}
+	logPartitionLeaders(partitionOffsets, leaders)
+	if *parsedOptions.startOffset == sarama.OffsetOldest && parsedOptions.startExpr == nil && parsedOptions.startOffsets == nil && parsedOptions.offsetFor == nil && !parsedOptions.interactive {
+		logRetention(client, topic, partitionOffsets)
+	}
+
+	if parsedOptions.endOffset != nil {
+		endOffsets = fetchOffsetsAt(client, *parsedOptions.endOffset, topic)
+	}
+	if parsedOptions.firstMessageOnly {
+		partitionOffsets, endOffsets = firstMessageRange(topic, partitionOffsets, endOffsets)
+	}
+	// The offsets of a time range are resolved once, log them so the range can be checked
+	if isTimestamp(*parsedOptions.startOffset) || (parsedOptions.endOffset != nil && isTimestamp(*parsedOptions.endOffset)) {
+		logTimeRange(topic, partitionOffsets, endOffsets)
+	}
+
+	return partitionOffsets, endOffsets, leaders
+}
+
+func printMessages(messages <-chan *sarama.ConsumerMessage, maxMessages int, printer func(*sarama.ConsumerMessage)) {
+	counter := 0
+
+	for msg := range messages {
+		printer(msg)
+
+		if maxMessages != -1 {
+			counter++
+			if counter >= maxMessages {
+				kafkatools.Log.Infof("Quiting after %d messages", counter)
+				return
+			}
+		}
+	}
+}
+
+func processErrors(pc sarama.PartitionConsumer) {
+	for err := range pc.Errors() {
+		kafkatools.Log.Errorf("error: we got an error while consuming one of the partitions: %v", err)
+	}
+}
+
+// partitionErrorHandler returns the handler of the errors of the partitions consumed by consumeTopics. An out of
+// range offset shuts the partition consumer down: with resetOutOfRange the partition is consumed again from its oldest
+// offset, otherwise kt exits. Other errors are retried by the partition consumer, unless exitOnError is set.
+func partitionErrorHandler(consumeOpts consumeOptions) func(err *sarama.ConsumerError) {
+	return func(err *sarama.ConsumerError) {
+		consumeOpts.metrics.addError()
+		if !errors.Is(err, sarama.ErrOffsetOutOfRange) {
+			if consumeOpts.exitOnError {
+				log.Fatalf("Could not consume %s partition %d: %v", err.Topic, err.Partition, err.Err)
+			}
+			kafkatools.Log.Errorf("error: we got an error while consuming one of the partitions: %v", err)
+			return
+		}
+
+		if !consumeOpts.resetOutOfRange {
+			log.Fatalf("The offset of %s partition %d is out of range, use --on-out-of-range reset to continue from the oldest offset", err.Topic, err.Partition)
+		}
+		kafkatools.Log.Warnf("WARNING: the offset of %s partition %d is out of range, resetting it to the oldest offset", err.Topic, err.Partition)
+	}
+}
+
+// consumeOptions contains the settings applied while consuming the partitions
+type consumeOptions struct {
+	filter messageFilter
+	// dedupe counts the duplicates the filter suppresses when values are deduplicated
+	dedupe *valueDeduper
+	// stats counts the consumed messages when throughput stats are enabled
+	stats *throughputStats
+	// endAtHWM stops every partition at its high-water mark at the time its consumer started
+	endAtHWM bool
+	// histogram counts the value sizes of the emitted messages when a size histogram is requested
+	histogram *sizeHistogram
+	// partitionRefresh starts consuming partitions added to the topics while following them
+	partitionRefresh *partitionRefresh
+	// maxConcurrentPartitions limits the number of partitions consumed at the same time, 0 consumes all of them
+	maxConcurrentPartitions int
+	// requireAllPartitions fails when any partition cannot be consumed instead of skipping it
+	requireAllPartitions bool
+	// noPartitionLog logs a summary of the partitions of every topic instead of every partition it starts
+	noPartitionLog bool
+	// resetOutOfRange consumes partitions whose offset is out of range (e.g. removed by retention) from the oldest
+	// offset instead of exiting
+	resetOutOfRange bool
+	// exitOnError exits on the first partition consumer error instead of logging it
+	exitOnError bool
+	// snapshotAfter replaces the end offsets by the high-water marks of the partitions when their consumers started
+	snapshotAfter bool
+	// lags records the high-water mark lag of the emitted messages when it is printed
+	lags *messageLags
+	// progress tracks the consumed offsets when the progress is shown
+	progress *consumeProgress
+	// order verifies the order of the consumed messages of every partition when requested
+	order *orderVerifier
+	// pauses are the --pause-at breakpoints, nil without any
+	pauses *breakpoints
+	// maxPerPartition stops every partition after this number of emitted messages, 0 does not limit them
+	maxPerPartition int
+	// rate limits the rate at which the partitions emit their messages together, nil does not limit it
+	rate *messageRate
+	// sorted tracks the consumed partitions for the timestamp merge of --sorted, nil without it
+	sorted *timestampMerger
+	// metrics counts the consumed messages and errors for the --metrics-addr endpoint, nil without it
+	metrics *consumeMetrics
+}
+
+// emitMessage applies the settings to a consumed message of the range, it returns whether the message is emitted. It
+// waits while the partition is paused or throttled, returning false once closing is closed.
+func emitMessage(pc sarama.PartitionConsumer, message *sarama.ConsumerMessage, consumeOpts consumeOptions, closing chan struct{}) bool {
+	consumeOpts.pauses.pause(message, closing)
+	consumeOpts.stats.add(message)
+	consumeOpts.metrics.record(pc, message)
+	consumeOpts.progress.update(message)
+	consumeOpts.order.check(message)
+
+	if consumeOpts.filter != nil && !consumeOpts.filter(message) {
+		return false
+	}
+	consumeOpts.histogram.add(message)
+	consumeOpts.lags.record(pc, message)
+	return consumeOpts.rate.wait(closing)
+}
+
+// snapshotEndOffset returns the end offset of a partition: the offset of the snapshot taken before consuming, or with
+// after the high-water mark the partition consumer looked up when it started, which includes the messages produced in
+// between
+func snapshotEndOffset(snapshot int64, pc highWaterMarker, after bool) int64 {
+	if !after {
+		return snapshot
+	}
+	if hwm := pc.HighWaterMarkOffset(); hwm > snapshot {
+		return hwm
+	}
+	return snapshot
+}
+
+// skipEmptyPartition logs that the partition is not consumed because its range is empty
+func skipEmptyPartition(offset kafkatools.TopicPartitionOffset, noPartitionLog bool) {
+	if !noPartitionLog {
+		kafkatools.Log.Infof("Skipping %s partition %d starting at %d, its range is empty", offset.Topic, offset.Partition, offset.Offset)
+	}
+}
+
+// consumePartitions starts a partition consumer for every partition and merges their messages into one channel.
+// Messages of different partitions are interleaved, but the messages of a single partition are always sent in
+// offset order: each partition is read by one goroutine which hands its messages over one by one. Any buffering,
+// filtering or throttling added to the pipeline has to preserve this, downstream tooling depends on it.
+func consumePartitions(consumer sarama.Consumer, partitionOffsets, endOffsets offsetMap, consumeOpts consumeOptions) (messages <-chan *sarama.ConsumerMessage, closing chan struct{}) {
+	topicPartitionOffsets, topicEndOffsets := make(topicOffsetMap), make(topicOffsetMap)
+	for partition, offset := range partitionOffsets {
+		if _, ok := topicPartitionOffsets[offset.Topic]; !ok {
+			topicPartitionOffsets[offset.Topic] = make(offsetMap)
+		}
+		topicPartitionOffsets[offset.Topic][partition] = offset
+	}
+	for partition, offset := range endOffsets {
+		if _, ok := topicEndOffsets[offset.Topic]; !ok {
+			topicEndOffsets[offset.Topic] = make(offsetMap)
+		}
+		topicEndOffsets[offset.Topic][partition] = offset
+	}
+
+	return consumeTopics(consumer, topicPartitionOffsets, topicEndOffsets, consumeOpts)
+}
+
+// summarizePartitions formats the number of partitions of every topic to consume and the range of their start (and
+// end) offsets
+func summarizePartitions(partitionOffsets, endOffsets topicOffsetMap) (lines []string) {
+	topics := make([]string, 0, len(partitionOffsets))
+	for topic := range partitionOffsets {
+		topics = append(topics, topic)
+	}
+	sort.Strings(topics)
+
+	offsetRange := func(offsets offsetMap) string {
+		first := true
+		var min, max int64
+		for _, offset := range offsets {
+			if first || offset.Offset < min {
+				min = offset.Offset
+			}
+			if first || offset.Offset > max {
+				max = offset.Offset
+			}
+			first = false
+		}
+		return fmt.Sprintf("%d to %d", min, max)
+	}
+
+	for _, topic := range topics {
+		if len(partitionOffsets[topic]) == 0 {
+			continue
+		}
+		line := fmt.Sprintf("Consuming %d partitions of %s starting at offsets %s", len(partitionOffsets[topic]), topic, offsetRange(partitionOffsets[topic]))
+		if len(endOffsets[topic]) > 0 {
+			line += fmt.Sprintf(" (until %s)", offsetRange(endOffsets[topic]))
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// consumeTopics is the multi-topic version of consumePartitions, the same ordering guarantee applies
+func consumeTopics(consumer sarama.Consumer, partitionOffsets, endOffsets topicOffsetMap, consumeOpts consumeOptions) (messages <-chan *sarama.ConsumerMessage, closing chan struct{}) {
+	closing = make(chan struct{})
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-closing
+		cancel()
+	}()
+
+	// The partitions which could not be consumed, e.g. because their leader is unavailable
+	var unavailable []string
+	var unavailableMutex sync.Mutex
+
+	// slots limits the number of partitions consumed at the same time when set
+	var slots chan struct{}
+	if consumeOpts.maxConcurrentPartitions > 0 {
+		slots = make(chan struct{}, consumeOpts.maxConcurrentPartitions)
+	}
+	release := func() {
+		if slots != nil {
+			<-slots
+		}
+	}
+
+	var failures closeFailures
+	rangeConsumer := kafkatools.NewConsumer(consumer)
+	rangeConsumer.OnError = partitionErrorHandler(consumeOpts)
+	rangeConsumer.ResetOutOfRange = consumeOpts.resetOutOfRange
+	rangeConsumer.MaxMessagesPerPartition = consumeOpts.maxPerPartition
+	if consumeOpts.snapshotAfter || consumeOpts.endAtHWM {
+		rangeConsumer.EndOffset = func(pc sarama.PartitionConsumer, start kafkatools.TopicPartitionOffset, end *int64) *int64 {
+			if end != nil {
+				endOffset := snapshotEndOffset(*end, pc, consumeOpts.snapshotAfter)
+				return &endOffset
+			}
+			if !consumeOpts.endAtHWM {
+				return nil
+			}
+			// The partition consumer looks up the high-water mark when it starts
+			highWaterMark := pc.HighWaterMarkOffset()
+			if !consumeOpts.noPartitionLog {
+				kafkatools.Log.Infof("Consuming %s partition %d until its high-water mark %d", start.Topic, start.Partition, highWaterMark)
+			}
+			return &highWaterMark
+		}
+	}
+	rangeConsumer.OnMessage = func(_ context.Context, pc sarama.PartitionConsumer, msg *sarama.ConsumerMessage) bool {
+		return emitMessage(pc, msg, consumeOpts, closing)
+	}
+	rangeConsumer.OnDone = func(topic string, partition int32, err error) {
+		if err != nil {
+			failures.record(topic, partition, err)
+		}
+		// The merge of --sorted learns the partition is done before the messages channel is closed
+		consumeOpts.sorted.finished(topic, partition, closing)
+		release()
+	}
+	// The partition consumers are closed once all partitions are done, including those shut down by an error
+	rangeConsumer.OnFinished = func() {
+		if err := failures.err(); err != nil {
+			kafkatools.Log.Errorf("ERROR: %v", err)
+		}
+		if err := rangeConsumer.Close(); err != nil {
+			log.Println("Error closing the consumer: ", err)
+		}
+	}
+	consumption := rangeConsumer.Start(ctx)
+
+	startPartition := func(offset kafkatools.TopicPartitionOffset) {
+		if slots != nil {
+			select {
+			case slots <- struct{}{}:
+			case <-closing:
+				return
+			}
+		}
+
+		var end *int64
+		if endOffset, ok := endOffsets[offset.Topic][offset.Partition]; ok {
+			end = &endOffset.Offset
+		}
+		if !consumeOpts.noPartitionLog {
+			kafkatools.Log.Infof("Consuming %s partition %d starting at %d (until %d)", offset.Topic, offset.Partition, offset.Offset, endOffsets[offset.Topic][offset.Partition].Offset)
+		}
+		err := consumption.Add(offset, end)
+		if errors.Is(err, kafkatools.ErrEmptyRange) {
+			// The partition consumer of an empty range would wait for a message beyond the range
+			skipEmptyPartition(offset, consumeOpts.noPartitionLog)
+			release()
+			return
+		}
+		if err != nil && consumeOpts.requireAllPartitions {
+			log.Fatalf("ERROR: Failed to start consumer for %s partition %d: %s", offset.Topic, offset.Partition, err)
+		} else if err != nil {
+			kafkatools.Log.Warnf("WARNING: Failed to start consumer for %s partition %d, skipping it: %s", offset.Topic, offset.Partition, err)
+			unavailableMutex.Lock()
+			unavailable = append(unavailable, fmt.Sprintf("%s/%d", offset.Topic, offset.Partition))
+			unavailableMutex.Unlock()
+			release()
+			return
+		}
+		consumeOpts.sorted.started(offset.Topic, offset.Partition)
+	}
+
+	startPartitions := func() {
+		if consumeOpts.noPartitionLog {
+			for _, line := range summarizePartitions(partitionOffsets, endOffsets) {
+				kafkatools.Log.Infof("%s", line)
+			}
+		}
+
+		total := 0
+		for _, topicOffsets := range partitionOffsets {
+			for _, offset := range topicOffsets {
+				startPartition(offset)
+				total++
+			}
+		}
+
+		unavailableMutex.Lock()
+		if len(unavailable) > 0 {
+			sort.Strings(unavailable)
+			kafkatools.Log.Warnf("WARNING: %d of %d partitions are unavailable and not consumed: %s", len(unavailable), total, strings.Join(unavailable, ", "))
+			if len(unavailable) == total {
+				log.Fatal("None of the partitions could be consumed")
+			}
+		}
+		unavailableMutex.Unlock()
+	}
+
+	// The consumption is finished once no more partitions are started
+	var starters sync.WaitGroup
+	if slots != nil {
+		// Starting a partition waits for a free slot, so the partitions have to be started while the messages are read
+		starters.Add(1)
+		go func() {
+			defer starters.Done()
+			startPartitions()
+		}()
+	} else {
+		startPartitions()
+	}
+
+	if consumeOpts.partitionRefresh != nil {
+		starters.Add(1)
+		go func() {
+			defer starters.Done()
+			consumeOpts.partitionRefresh.watch(partitionOffsets, closing, startPartition)
+		}()
+	}
+
+	go func() {
+		starters.Wait()
+		consumption.Finish()
 	}()
 
-	return messages, closing
+	return consumption.Messages(), closing
 }
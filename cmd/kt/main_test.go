@@ -1,7 +1,11 @@
 package main
 
 import (
+	"errors"
+	"os"
+	"os/exec"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 
@@ -18,11 +22,24 @@ var messages = []*sarama.ConsumerMessage{
 }
 
 func TestParseDateOpt(t *testing.T) {
-        output := parseDateOpt("2008-09-08T22:47:31-07:00")
+	for _, test := range []struct {
+		date   string
+		millis int64
+	}{
+		{"2008-09-08T22:47:31-07:00", 1220939251000},
+		{"2008-09-09T05:47:31Z", 1220939251000},
+		{"1220939251", 1220939251000},
+		{"1220939251123", 1220939251123},
+	} {
+		output, err := parseDateOpt(test.date)
+		if err != nil || output != test.millis {
+			t.Errorf("Expected %s to be %d (ms), got %d (%v)", test.date, test.millis, output, err)
+		}
+	}
 
-        if output != 1220939251000 {
-                t.Errorf("%d does not equal 1220939251000 (ms)", output)
-        }
+	if _, err := parseDateOpt("yesterday"); err == nil {
+		t.Error("Expected an error for an invalid date")
+	}
 }
 
 func TestPrintMessagesCount(t *testing.T) {
@@ -36,7 +53,7 @@ func TestPrintMessagesCount(t *testing.T) {
 		close(channel)
 	}()
 
-	printMessages(channel, 2, func(str string) { strings = append(strings, str) })
+	printMessages(channel, 2, func(msg *sarama.ConsumerMessage) { strings = append(strings, string(msg.Value)) })
 
 	if !reflect.DeepEqual(strings, []string{"a", "b"}) {
 		t.Errorf("%+v does not equal [a b]", strings)
@@ -54,7 +71,7 @@ func TestPrintMessagesWithoutCount(t *testing.T) {
 		close(channel)
 	}()
 
-	printMessages(channel, -1, func(str string) { strings = append(strings, str) })
+	printMessages(channel, -1, func(msg *sarama.ConsumerMessage) { strings = append(strings, string(msg.Value)) })
 
 	if !reflect.DeepEqual(strings, []string{"a", "b", "c", "d"}) {
 		t.Errorf("%+v does not equal [a b c d]", strings)
@@ -74,7 +91,7 @@ func TestConsumeWithoutPartitions(t *testing.T) {
 	partitionOffsets := make(map[int32]kafkatools.TopicPartitionOffset)
 	endOffsets := make(map[int32]kafkatools.TopicPartitionOffset)
 
-	messagesChan, _ := consumePartitions(consumer, partitionOffsets, endOffsets)
+	messagesChan, _ := consumePartitions(consumer, partitionOffsets, endOffsets, consumeOptions{})
 
 	if _, ok := <-messagesChan; ok {
 		t.Error("Channel should be closed automatically")
@@ -107,7 +124,7 @@ func TestConsumeSinglePartition(t *testing.T) {
 
 	partConsumer.ExpectMessagesDrainedOnClose()
 
-	messagesChan, closing := consumePartitions(consumer, partitionOffsets, endOffsets)
+	messagesChan, closing := consumePartitions(consumer, partitionOffsets, endOffsets, consumeOptions{})
 
 	count := 0
 	for msg := range messagesChan {
@@ -155,7 +172,7 @@ func TestConsumeSinglePartitionUntilEndOffset(t *testing.T) {
 		partConsumer.YieldMessage(msg)
 	}
 
-	messagesChan, _ := consumePartitions(consumer, partitionOffsets, endOffsets)
+	messagesChan, _ := consumePartitions(consumer, partitionOffsets, endOffsets, consumeOptions{})
 
 	count := 0
 	for msg := range messagesChan {
@@ -174,3 +191,528 @@ func TestConsumeSinglePartitionUntilEndOffset(t *testing.T) {
 		t.Error("Channel should be closed automatically")
 	}
 }
+
+func TestDrainAfterEarlyStop(t *testing.T) {
+	config := sarama.NewConfig()
+	config.Version = sarama.V0_10_1_0
+	config.Consumer.Return.Errors = true
+
+	consumer := mocks.NewConsumer(t, config)
+
+	partitionOffsets := make(map[int32]kafkatools.TopicPartitionOffset)
+	partitionOffsets[0] = kafkatools.TopicPartitionOffset{
+		Topic:     "foo",
+		Partition: 0,
+		Offset:    0,
+	}
+
+	partConsumer := consumer.ExpectConsumePartition("foo", 0, 0)
+	for _, msg := range messages {
+		partConsumer.YieldMessage(msg)
+	}
+
+	messagesChan, closing := consumePartitions(consumer, partitionOffsets, nil, consumeOptions{})
+
+	// Stop reading after the first message, the remaining messages are in-flight
+	<-messagesChan
+	close(closing)
+
+	if !drainMessages(messagesChan, time.Second) {
+		t.Error("Expected the consumers to shut down within the drain timeout")
+	}
+}
+
+func TestDrainTimeout(t *testing.T) {
+	if drainMessages(make(chan *sarama.ConsumerMessage), 10*time.Millisecond) {
+		t.Error("Expected the drain to time out on a channel that is never closed")
+	}
+}
+
+func TestConsumeKeepsPartitionOrder(t *testing.T) {
+	config := sarama.NewConfig()
+	config.Version = sarama.V0_10_1_0
+	config.Consumer.Return.Errors = true
+
+	consumer := mocks.NewConsumer(t, config)
+
+	const partitions, messagesPerPartition = 4, 100
+	partitionOffsets := make(map[int32]kafkatools.TopicPartitionOffset)
+	for partition := int32(0); partition < partitions; partition++ {
+		partitionOffsets[partition] = kafkatools.TopicPartitionOffset{Topic: "foo", Partition: partition, Offset: 0}
+
+		partConsumer := consumer.ExpectConsumePartition("foo", partition, 0)
+		for offset := int64(0); offset < messagesPerPartition; offset++ {
+			partConsumer.YieldMessage(&sarama.ConsumerMessage{Value: []byte("x"), Offset: offset})
+		}
+	}
+
+	messagesChan, closing := consumePartitions(consumer, partitionOffsets, nil, consumeOptions{})
+
+	lastOffsets := make(map[int32]int64)
+	for count := 0; count < partitions*messagesPerPartition; count++ {
+		msg := <-messagesChan
+		if last, ok := lastOffsets[msg.Partition]; ok && msg.Offset <= last {
+			t.Fatalf("Partition %d: offset %d was received after offset %d", msg.Partition, msg.Offset, last)
+		}
+		lastOffsets[msg.Partition] = msg.Offset
+	}
+	close(closing)
+
+	if len(lastOffsets) != partitions {
+		t.Errorf("Expected messages from %d partitions, got %d", partitions, len(lastOffsets))
+	}
+
+	drainMessages(messagesChan, time.Second)
+}
+
+func TestParseList(t *testing.T) {
+	tests := []struct {
+		topicOpt interface{}
+		expected []string
+	}{
+		{"foo", []string{"foo"}},
+		{"foo,bar,", []string{"foo", "bar"}},
+		{[]string{"foo", "bar,baz"}, []string{"foo", "bar", "baz"}},
+		{nil, nil},
+	}
+
+	for _, test := range tests {
+		if topics := parseList(test.topicOpt); !reflect.DeepEqual(topics, test.expected) {
+			t.Errorf("Expected topics %v for %v, got %v", test.expected, test.topicOpt, topics)
+		}
+	}
+}
+
+func TestConsumeTopics(t *testing.T) {
+	config := sarama.NewConfig()
+	config.Consumer.Return.Errors = true
+
+	consumer := mocks.NewConsumer(t, config)
+
+	partitionOffsets, endOffsets := make(topicOffsetMap), make(topicOffsetMap)
+	for _, topic := range []string{"foo", "bar"} {
+		partitionOffsets[topic] = offsetMap{0: kafkatools.TopicPartitionOffset{Topic: topic, Partition: 0, Offset: 0}}
+		endOffsets[topic] = offsetMap{0: kafkatools.TopicPartitionOffset{Topic: topic, Partition: 0, Offset: 2}}
+
+		partConsumer := consumer.ExpectConsumePartition(topic, 0, 0)
+		for offset := int64(0); offset < 3; offset++ {
+			partConsumer.YieldMessage(&sarama.ConsumerMessage{Topic: topic, Value: []byte("x"), Offset: offset})
+		}
+	}
+
+	messagesChan, _ := consumeTopics(consumer, partitionOffsets, endOffsets, consumeOptions{})
+
+	received := make(map[string]int)
+	for msg := range messagesChan {
+		received[msg.Topic]++
+	}
+
+	expected := map[string]int{"foo": 2, "bar": 2}
+	if !reflect.DeepEqual(received, expected) {
+		t.Errorf("Expected messages per topic %v, got %v", expected, received)
+	}
+}
+
+func TestFetchTopicsPartitionOffsets(t *testing.T) {
+	broker := sarama.NewMockBroker(t, 1)
+	defer broker.Close()
+
+	metadata := sarama.NewMockMetadataResponse(t).SetBroker(broker.Addr(), broker.BrokerID())
+	offsets := sarama.NewMockOffsetResponse(t)
+	for topic, newest := range map[string]int64{"foo": 10, "bar": 20} {
+		for partition := int32(0); partition < 2; partition++ {
+			metadata.SetLeader(topic, partition, broker.BrokerID())
+			offsets.SetOffset(topic, partition, sarama.OffsetOldest, 0).SetOffset(topic, partition, sarama.OffsetNewest, newest+int64(partition))
+		}
+	}
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{"MetadataRequest": metadata, "OffsetRequest": offsets})
+
+	client, err := sarama.NewClient([]string{broker.Addr()}, sarama.NewConfig())
+	if err != nil {
+		t.Fatal("Unexpected error: ", err)
+	}
+	defer client.Close()
+
+	// The same partition of both topics, each with its own start and end offset
+	partition, start, end := int32(1), int64(sarama.OffsetOldest), int64(sarama.OffsetNewest)
+	startOffsets := topicOffsetMap{
+		"foo": {0: {Topic: "foo", Partition: 0, Offset: 1}, 1: {Topic: "foo", Partition: 1, Offset: 2}},
+		"bar": {0: {Topic: "bar", Partition: 0, Offset: 3}, 1: {Topic: "bar", Partition: 1, Offset: 4}},
+	}
+	partitionOffsets, endOffsets, _ := fetchTopicsPartitionOffsets(client, options{topics: []string{"foo", "bar"}, partition: &partition, startOffset: &start, endOffset: &end, startOffsets: startOffsets})
+
+	expectedStart := topicOffsetMap{
+		"foo": {1: {Topic: "foo", Partition: 1, Offset: 2}},
+		"bar": {1: {Topic: "bar", Partition: 1, Offset: 4}},
+	}
+	if !reflect.DeepEqual(partitionOffsets, expectedStart) {
+		t.Errorf("Expected start offsets %v, got %v", expectedStart, partitionOffsets)
+	}
+	if endOffsets["foo"][1].Offset != 11 || endOffsets["bar"][1].Offset != 21 {
+		t.Errorf("Expected the end offsets of each topic, got %v", endOffsets)
+	}
+}
+
+func TestConsumeCountPerPartition(t *testing.T) {
+	config := sarama.NewConfig()
+	config.Consumer.Return.Errors = true
+
+	consumer := mocks.NewConsumer(t, config)
+
+	partitionOffsets := make(offsetMap)
+	for partition, messages := range map[int32]int64{0: 5, 1: 1} {
+		partConsumer := consumer.ExpectConsumePartition("foo", partition, 0)
+		for offset := int64(0); offset < messages; offset++ {
+			partConsumer.YieldMessage(&sarama.ConsumerMessage{Value: []byte("x"), Offset: offset})
+		}
+		partitionOffsets[partition] = kafkatools.TopicPartitionOffset{Topic: "foo", Partition: partition, Offset: 0}
+	}
+	endOffsets := offsetMap{1: kafkatools.TopicPartitionOffset{Topic: "foo", Partition: 1, Offset: 1}}
+
+	messagesChan, _ := consumePartitions(consumer, partitionOffsets, endOffsets, consumeOptions{maxPerPartition: 2})
+
+	received := make(map[int32]int)
+	for msg := range messagesChan {
+		received[msg.Partition]++
+	}
+
+	if received[0] != 2 || received[1] != 1 {
+		t.Errorf("Expected 2 messages of partition 0 and 1 of partition 1, received %v", received)
+	}
+}
+
+func TestConsumeSkipsEmptyRanges(t *testing.T) {
+	config := sarama.NewConfig()
+	config.Consumer.Return.Errors = true
+
+	consumer := mocks.NewConsumer(t, config)
+
+	// Partition 1 is empty and partition 2 starts beyond its end, neither of them is consumed
+	partConsumer := consumer.ExpectConsumePartition("foo", 0, 0)
+	for offset := int64(0); offset < 2; offset++ {
+		partConsumer.YieldMessage(&sarama.ConsumerMessage{Value: []byte("x"), Offset: offset})
+	}
+	partitionOffsets := offsetMap{
+		0: kafkatools.TopicPartitionOffset{Topic: "foo", Partition: 0, Offset: 0},
+		1: kafkatools.TopicPartitionOffset{Topic: "foo", Partition: 1, Offset: 4},
+		2: kafkatools.TopicPartitionOffset{Topic: "foo", Partition: 2, Offset: 7},
+	}
+	endOffsets := offsetMap{
+		0: kafkatools.TopicPartitionOffset{Topic: "foo", Partition: 0, Offset: 2},
+		1: kafkatools.TopicPartitionOffset{Topic: "foo", Partition: 1, Offset: 4},
+		2: kafkatools.TopicPartitionOffset{Topic: "foo", Partition: 2, Offset: 5},
+	}
+
+	messagesChan, _ := consumePartitions(consumer, partitionOffsets, endOffsets, consumeOptions{})
+
+	if received := receiveAll(t, messagesChan); len(received) != 2 {
+		t.Errorf("Expected to receive 2 messages, received %d", len(received))
+	}
+}
+
+func TestConsumeTopicsCaughtUp(t *testing.T) {
+	config := sarama.NewConfig()
+	config.Consumer.Return.Errors = true
+
+	// No partition has a new message, the mock fails the test when a partition consumer is opened anyway
+	consumer := mocks.NewConsumer(t, config)
+
+	partitionOffsets, endOffsets := make(topicOffsetMap), make(topicOffsetMap)
+	for _, topic := range []string{"foo", "bar"} {
+		partitionOffsets[topic], endOffsets[topic] = make(offsetMap), make(offsetMap)
+		for partition := int32(0); partition < 3; partition++ {
+			offset := kafkatools.TopicPartitionOffset{Topic: topic, Partition: partition, Offset: int64(partition) * 10}
+			partitionOffsets[topic][partition], endOffsets[topic][partition] = offset, offset
+		}
+	}
+
+	messagesChan, _ := consumeTopics(consumer, partitionOffsets, endOffsets, consumeOptions{})
+
+	if received := receiveAll(t, messagesChan); len(received) != 0 {
+		t.Errorf("Expected no messages, received %d", len(received))
+	}
+}
+
+func TestConsumeTailWithFewMessages(t *testing.T) {
+	config := sarama.NewConfig()
+	config.Consumer.Return.Errors = true
+
+	consumer := mocks.NewConsumer(t, config)
+
+	// Partition 0 has 5 messages, partition 1 a single one and partition 2 none
+	oldest, newest := make(offsetMap), make(offsetMap)
+	for partition, messages := range map[int32]int64{0: 5, 1: 1, 2: 0} {
+		oldest[partition] = kafkatools.TopicPartitionOffset{Topic: "foo", Partition: partition, Offset: 0}
+		newest[partition] = kafkatools.TopicPartitionOffset{Topic: "foo", Partition: partition, Offset: messages}
+	}
+	partitionOffsets := tailOffsets(newest, oldest, newest, 6)
+
+	for partition, start := range map[int32]int64{0: 3, 1: 0} {
+		partConsumer := consumer.ExpectConsumePartition("foo", partition, start)
+		for offset := start; offset < newest[partition].Offset; offset++ {
+			partConsumer.YieldMessage(&sarama.ConsumerMessage{Value: []byte("x")})
+		}
+	}
+
+	messagesChan, _ := consumePartitions(consumer, partitionOffsets, newest, consumeOptions{})
+
+	received := make(map[int32][]int64)
+	for _, msg := range receiveAll(t, messagesChan) {
+		received[msg.Partition] = append(received[msg.Partition], msg.Offset)
+	}
+	expected := map[int32][]int64{0: {3, 4}, 1: {0}}
+	if !reflect.DeepEqual(received, expected) {
+		t.Errorf("Expected the offsets %v, received %v", expected, received)
+	}
+}
+
+// receiveAll returns the messages until the channel is closed, failing the test when it is not closed in time
+func receiveAll(t *testing.T, messages <-chan *sarama.ConsumerMessage) (received []*sarama.ConsumerMessage) {
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case msg, ok := <-messages:
+			if !ok {
+				return received
+			}
+			received = append(received, msg)
+		case <-timeout:
+			t.Fatalf("Expected consuming to stop at the end of the ranges, received %d messages", len(received))
+		}
+	}
+}
+
+func TestConsumeUntilHighWaterMark(t *testing.T) {
+	config := sarama.NewConfig()
+	config.Consumer.Return.Errors = true
+
+	consumer := mocks.NewConsumer(t, config)
+
+	partConsumer := consumer.ExpectConsumePartition("foo", 0, 0)
+	for offset := int64(0); offset < 3; offset++ {
+		partConsumer.YieldMessage(&sarama.ConsumerMessage{Value: []byte("x"), Offset: offset})
+	}
+	highWaterMark := partConsumer.HighWaterMarkOffset()
+
+	partitionOffsets := offsetMap{0: kafkatools.TopicPartitionOffset{Topic: "foo", Partition: 0, Offset: 0}}
+	messagesChan, _ := consumePartitions(consumer, partitionOffsets, nil, consumeOptions{endAtHWM: true})
+
+	// Messages produced after the consumer started are not consumed
+	for i := 0; i < 3; i++ {
+		partConsumer.YieldMessage(&sarama.ConsumerMessage{Value: []byte("y")})
+	}
+
+	received := 0
+	for msg := range messagesChan {
+		if msg.Offset >= highWaterMark {
+			t.Errorf("Received offset %d beyond the high-water mark %d", msg.Offset, highWaterMark)
+		}
+		received++
+	}
+
+	if int64(received) != highWaterMark {
+		t.Errorf("Expected to receive %d messages, received %d", highWaterMark, received)
+	}
+}
+
+func TestConsumeAvailablePartitions(t *testing.T) {
+	config := sarama.NewConfig()
+	config.Consumer.Return.Errors = true
+
+	consumer := mocks.NewConsumer(t, config)
+	consumer.ExpectConsumePartition("foo", 0, 0).YieldMessage(&sarama.ConsumerMessage{Value: []byte("a"), Offset: 0})
+	unavailable := consumer.ExpectConsumePartition("foo", 1, 0)
+	// A partition consumer can only be started once, so consumePartitions fails to start partition 1
+	if _, err := consumer.ConsumePartition("foo", 1, 0); err != nil {
+		t.Fatal("Unexpected error: ", err)
+	}
+	defer unavailable.AsyncClose()
+
+	partitionOffsets := offsetMap{
+		0: kafkatools.TopicPartitionOffset{Topic: "foo", Partition: 0, Offset: 0},
+		1: kafkatools.TopicPartitionOffset{Topic: "foo", Partition: 1, Offset: 0},
+	}
+	messagesChan, closing := consumePartitions(consumer, partitionOffsets, nil, consumeOptions{})
+	defer close(closing)
+
+	msg := <-messagesChan
+	if msg.Partition != 0 || string(msg.Value) != "a" {
+		t.Errorf("Expected the message of partition 0, got %v", msg)
+	}
+}
+
+func TestConsumeRequireAllPartitions(t *testing.T) {
+	// log.Fatalf exits, so the consumer runs in a subprocess
+	if os.Getenv("KT_TEST_REQUIRE_ALL_PARTITIONS") == "1" {
+		config := sarama.NewConfig()
+		config.Consumer.Return.Errors = true
+
+		consumer := mocks.NewConsumer(t, config)
+		consumer.ExpectConsumePartition("foo", 0, 0)
+		// A partition consumer can only be started once, so consumePartitions fails to start partition 0
+		if _, err := consumer.ConsumePartition("foo", 0, 0); err != nil {
+			t.Fatal("Unexpected error: ", err)
+		}
+
+		partitionOffsets := offsetMap{0: kafkatools.TopicPartitionOffset{Topic: "foo", Partition: 0, Offset: 0}}
+		consumePartitions(consumer, partitionOffsets, nil, consumeOptions{requireAllPartitions: true})
+		time.Sleep(5 * time.Second)
+		return
+	}
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestConsumeRequireAllPartitions$")
+	cmd.Env = append(os.Environ(), "KT_TEST_REQUIRE_ALL_PARTITIONS=1")
+	output, err := cmd.CombinedOutput()
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) || exitErr.ExitCode() != 1 {
+		t.Fatalf("Expected kt to exit with status 1, got %v: %s", err, output)
+	}
+	if strings.Contains(string(output), "panic") || !strings.Contains(string(output), "Failed to start consumer for foo partition 0") {
+		t.Errorf("Expected the failed partition to be logged without a panic, got %s", output)
+	}
+}
+
+func TestConsumeMaxConcurrentPartitions(t *testing.T) {
+	config := sarama.NewConfig()
+	config.Consumer.Return.Errors = true
+
+	consumer := mocks.NewConsumer(t, config)
+	partitionOffsets, endOffsets := make(offsetMap), make(offsetMap)
+	for partition := int32(0); partition < 3; partition++ {
+		partConsumer := consumer.ExpectConsumePartition("foo", partition, 0)
+		for i := 0; i < 3; i++ {
+			partConsumer.YieldMessage(&sarama.ConsumerMessage{Value: []byte("x")})
+		}
+		partitionOffsets[partition] = kafkatools.TopicPartitionOffset{Topic: "foo", Partition: partition, Offset: 0}
+		endOffsets[partition] = kafkatools.TopicPartitionOffset{Topic: "foo", Partition: partition, Offset: 2}
+	}
+
+	messagesChan, _ := consumePartitions(consumer, partitionOffsets, endOffsets, consumeOptions{maxConcurrentPartitions: 1})
+
+	// A single partition is consumed at a time, so the messages of the partitions are not interleaved
+	var partitions []int32
+	for msg := range messagesChan {
+		if len(partitions) == 0 || partitions[len(partitions)-1] != msg.Partition {
+			partitions = append(partitions, msg.Partition)
+		}
+	}
+
+	if len(partitions) != 3 {
+		t.Errorf("Expected the messages of 3 partitions one after the other, got %v", partitions)
+	}
+}
+
+func TestConsumeCountBeyondEndOffset(t *testing.T) {
+	for _, count := range []int{10, 4, 2} {
+		config := sarama.NewConfig()
+		config.Consumer.Return.Errors = true
+
+		consumer := mocks.NewConsumer(t, config)
+		partConsumer := consumer.ExpectConsumePartition("foo", 0, 0)
+		for _, value := range []string{"a", "b", "c", "d"} {
+			partConsumer.YieldMessage(&sarama.ConsumerMessage{Value: []byte(value)})
+		}
+
+		partitionOffsets := offsetMap{0: {Topic: "foo", Partition: 0, Offset: 0}}
+		endOffsets := offsetMap{0: {Topic: "foo", Partition: 0, Offset: 4}}
+		messagesChan, closing := consumePartitions(consumer, partitionOffsets, endOffsets, consumeOptions{})
+
+		// Whichever comes first, the count or the end of the range, stops printing without waiting for more messages
+		printed := 0
+		printMessages(messagesChan, count, func(msg *sarama.ConsumerMessage) { printed++ })
+		close(closing)
+
+		expected := count
+		if expected > 4 {
+			expected = 4
+		}
+		if printed != expected {
+			t.Errorf("Expected %d messages with --count %d, got %d", expected, count, printed)
+		}
+		if !drainMessages(messagesChan, time.Second) {
+			t.Errorf("Expected the consumers to shut down with --count %d", count)
+		}
+	}
+}
+
+func TestSnapshotEndOffset(t *testing.T) {
+	if end := snapshotEndOffset(10, fixedHighWaterMark(12), false); end != 10 {
+		t.Errorf("Expected the snapshot taken before consuming, got %d", end)
+	}
+	if end := snapshotEndOffset(10, fixedHighWaterMark(12), true); end != 12 {
+		t.Errorf("Expected the high-water mark when the consumer started, got %d", end)
+	}
+	if end := snapshotEndOffset(10, fixedHighWaterMark(8), true); end != 10 {
+		t.Errorf("Expected the end offset not to move back, got %d", end)
+	}
+}
+
+func TestSummarizePartitions(t *testing.T) {
+	partitionOffsets := topicOffsetMap{
+		"foo": {
+			0: kafkatools.TopicPartitionOffset{Topic: "foo", Partition: 0, Offset: 12},
+			1: kafkatools.TopicPartitionOffset{Topic: "foo", Partition: 1, Offset: 3},
+			2: kafkatools.TopicPartitionOffset{Topic: "foo", Partition: 2, Offset: 40},
+		},
+		"bar": {0: kafkatools.TopicPartitionOffset{Topic: "bar", Partition: 0, Offset: 7}},
+		"baz": {},
+	}
+	endOffsets := topicOffsetMap{
+		"foo": {
+			0: kafkatools.TopicPartitionOffset{Topic: "foo", Partition: 0, Offset: 20},
+			1: kafkatools.TopicPartitionOffset{Topic: "foo", Partition: 1, Offset: 5},
+			2: kafkatools.TopicPartitionOffset{Topic: "foo", Partition: 2, Offset: 50},
+		},
+	}
+
+	expected := []string{
+		"Consuming 1 partitions of bar starting at offsets 7 to 7",
+		"Consuming 3 partitions of foo starting at offsets 3 to 40 (until 5 to 50)",
+	}
+	if lines := summarizePartitions(partitionOffsets, endOffsets); !reflect.DeepEqual(lines, expected) {
+		t.Errorf("Expected %q, got %q", expected, lines)
+	}
+}
+
+func TestValidateClientID(t *testing.T) {
+	for _, id := range []string{defaultClientID(), "kt-1.0_ops", "A.b-C"} {
+		if err := validateClientID(id); err != nil {
+			t.Errorf("Expected client id %q to be valid, got %v", id, err)
+		}
+	}
+	for _, id := range []string{"", "kt/1.0", "kt 1"} {
+		if err := validateClientID(id); err == nil {
+			t.Errorf("Expected client id %q to be invalid", id)
+		}
+	}
+}
+
+func TestFirstSetOption(t *testing.T) {
+	if option := firstSetOption(map[string]bool{"--exit": false, "--count": false}); option != "" {
+		t.Errorf("Expected no option to be set, got %s", option)
+	}
+	if option := firstSetOption(map[string]bool{"--exit": true, "--end-date": true, "--count": false}); option != "--end-date" {
+		t.Errorf("Expected --end-date, got %s", option)
+	}
+}
+
+func TestDefaultStartOffset(t *testing.T) {
+	tests := []struct {
+		command        string
+		scanFromOldest bool
+		endDate        bool
+		expected       int64
+	}{
+		{"consume", false, false, sarama.OffsetNewest},
+		{"consume", false, true, sarama.OffsetOldest},
+		{"consume", true, false, sarama.OffsetOldest},
+		{"replay", false, false, sarama.OffsetOldest},
+		{"assert", false, true, sarama.OffsetOldest},
+	}
+
+	for _, test := range tests {
+		if offset := defaultStartOffset(test.command, test.scanFromOldest, test.endDate); offset != test.expected {
+			t.Errorf("Expected start offset %d for %+v, got %d", test.expected, test, offset)
+		}
+	}
+}
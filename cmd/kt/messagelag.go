@@ -0,0 +1,39 @@
+package main
+
+import (
+	"sync"
+
+	"github.com/Shopify/sarama"
+)
+
+// messageLags holds how far every consumed message was behind the high-water mark of its partition when it was
+// consumed, until the message is printed
+type messageLags struct {
+	lags sync.Map
+}
+
+func newMessageLags() *messageLags {
+	return &messageLags{}
+}
+
+// highWaterMarker is the part of sarama.PartitionConsumer reporting the high-water mark of the partition
+type highWaterMarker interface {
+	HighWaterMarkOffset() int64
+}
+
+// record stores the lag of the message, it is a no-op on nil lags
+func (l *messageLags) record(pc highWaterMarker, msg *sarama.ConsumerMessage) {
+	if l == nil {
+		return
+	}
+	l.lags.Store(msg, pc.HighWaterMarkOffset()-msg.Offset)
+}
+
+// take returns and forgets the lag of the message, false when it was not recorded
+func (l *messageLags) take(msg *sarama.ConsumerMessage) (int64, bool) {
+	lag, ok := l.lags.LoadAndDelete(msg)
+	if !ok {
+		return 0, false
+	}
+	return lag.(int64), true
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/Shopify/sarama"
+)
+
+type fixedHighWaterMark int64
+
+func (hwm fixedHighWaterMark) HighWaterMarkOffset() int64 {
+	return int64(hwm)
+}
+
+func TestMessageLags(t *testing.T) {
+	lags := newMessageLags()
+	msg := &sarama.ConsumerMessage{Topic: "foo", Offset: 2}
+	lags.record(fixedHighWaterMark(10), msg)
+
+	if lag, ok := lags.take(msg); !ok || lag != 8 {
+		t.Errorf("Expected a lag of 8, got %d (%v)", lag, ok)
+	}
+	if _, ok := lags.take(msg); ok {
+		t.Error("Expected the lag to be forgotten once taken")
+	}
+
+	var disabled *messageLags
+	disabled.record(fixedHighWaterMark(10), msg)
+}
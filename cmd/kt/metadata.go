@@ -0,0 +1,144 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/Shopify/sarama"
+)
+
+// clusterMetadata is the JSON document printed by kt metadata
+type clusterMetadata struct {
+	ClusterID  *string          `json:"cluster_id"`
+	Controller int32            `json:"controller"`
+	Brokers    []brokerMetadata `json:"brokers"`
+	Topics     []topicMetadata  `json:"topics"`
+}
+
+type brokerMetadata struct {
+	ID   int32   `json:"id"`
+	Addr string  `json:"addr"`
+	Rack *string `json:"rack"`
+}
+
+type topicMetadata struct {
+	Name       string              `json:"name"`
+	Internal   bool                `json:"internal"`
+	Error      string              `json:"error,omitempty"`
+	Partitions []partitionMetadata `json:"partitions"`
+}
+
+type partitionMetadata struct {
+	Partition       int32   `json:"partition"`
+	Leader          int32   `json:"leader"`
+	Replicas        []int32 `json:"replicas"`
+	ISR             []int32 `json:"isr"`
+	OfflineReplicas []int32 `json:"offline_replicas"`
+	Error           string  `json:"error,omitempty"`
+}
+
+// metadata prints the metadata of all brokers and topics of the cluster as a single JSON document, the internal topics
+// are only included when requested
+func metadata(client sarama.Client, internalTopics bool) {
+	controller, err := client.Controller()
+	if err != nil {
+		log.Fatal("Could not fetch the controller: ", err)
+	}
+
+	response, err := controller.GetMetadata(sarama.NewMetadataRequest(client.Config().Version, nil))
+	if err != nil {
+		log.Fatalf("Could not fetch metadata from broker %d: %v", controller.ID(), err)
+	}
+
+	document, err := json.MarshalIndent(newClusterMetadata(response, internalTopics), "", "  ")
+	if err != nil {
+		log.Fatal("Could not encode the metadata: ", err)
+	}
+	fmt.Println(string(document))
+}
+
+// newClusterMetadata converts a metadata response, the brokers, topics and partitions are sorted and errors are only
+// set for topics or partitions which have one. Internal topics are left out unless internalTopics is set.
+func newClusterMetadata(response *sarama.MetadataResponse, internalTopics bool) clusterMetadata {
+	cluster := clusterMetadata{
+		ClusterID:  response.ClusterID,
+		Controller: response.ControllerID,
+		Brokers:    make([]brokerMetadata, 0, len(response.Brokers)),
+		Topics:     make([]topicMetadata, 0, len(response.Topics)),
+	}
+
+	for _, broker := range response.Brokers {
+		metadata := brokerMetadata{ID: broker.ID(), Addr: broker.Addr()}
+		if rack := broker.Rack(); rack != "" {
+			metadata.Rack = &rack
+		}
+		cluster.Brokers = append(cluster.Brokers, metadata)
+	}
+	sort.Slice(cluster.Brokers, func(i, j int) bool { return cluster.Brokers[i].ID < cluster.Brokers[j].ID })
+
+	for _, topic := range response.Topics {
+		if !internalTopics && (topic.IsInternal || isInternalTopic(topic.Name)) {
+			continue
+		}
+
+		metadata := topicMetadata{
+			Name:       topic.Name,
+			Internal:   topic.IsInternal,
+			Error:      formatKError(topic.Err),
+			Partitions: make([]partitionMetadata, 0, len(topic.Partitions)),
+		}
+		for _, partition := range topic.Partitions {
+			metadata.Partitions = append(metadata.Partitions, partitionMetadata{
+				Partition:       partition.ID,
+				Leader:          partition.Leader,
+				Replicas:        nonNilInt32s(partition.Replicas),
+				ISR:             nonNilInt32s(partition.Isr),
+				OfflineReplicas: nonNilInt32s(partition.OfflineReplicas),
+				Error:           formatKError(partition.Err),
+			})
+		}
+		sort.Slice(metadata.Partitions, func(i, j int) bool {
+			return metadata.Partitions[i].Partition < metadata.Partitions[j].Partition
+		})
+		cluster.Topics = append(cluster.Topics, metadata)
+	}
+	sort.Slice(cluster.Topics, func(i, j int) bool { return cluster.Topics[i].Name < cluster.Topics[j].Name })
+
+	return cluster
+}
+
+// isInternalTopic returns whether the topic is used by kafka or the tools around it rather than by applications, like
+// __consumer_offsets, __transaction_state or _schemas
+func isInternalTopic(topic string) bool {
+	return strings.HasPrefix(topic, "_")
+}
+
+// withoutInternalTopics returns the topics which are not internal
+func withoutInternalTopics(topics []string) []string {
+	var external []string
+	for _, topic := range topics {
+		if !isInternalTopic(topic) {
+			external = append(external, topic)
+		}
+	}
+	return external
+}
+
+// formatKError returns the message of the error, or an empty string when there is none
+func formatKError(err sarama.KError) string {
+	if err == sarama.ErrNoError {
+		return ""
+	}
+	return err.Error()
+}
+
+// nonNilInt32s returns an empty slice for nil so it is encoded as [] instead of null
+func nonNilInt32s(ids []int32) []int32 {
+	if ids == nil {
+		return []int32{}
+	}
+	return ids
+}
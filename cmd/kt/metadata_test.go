@@ -0,0 +1,56 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/Shopify/sarama"
+)
+
+func TestNewClusterMetadata(t *testing.T) {
+	clusterID := "abc"
+	response := &sarama.MetadataResponse{ClusterID: &clusterID, ControllerID: 1}
+	response.AddBroker("b2:9092", 2)
+	response.AddBroker("b1:9092", 1)
+	response.AddTopicPartition("foo", 1, 2, []int32{2, 1}, []int32{2}, []int32{1}, sarama.ErrNoError)
+	response.AddTopicPartition("foo", 0, 1, []int32{1, 2}, []int32{1, 2}, nil, sarama.ErrNoError)
+	response.AddTopicPartition("bar", 0, -1, []int32{3}, nil, []int32{3}, sarama.ErrLeaderNotAvailable)
+
+	document, err := json.Marshal(newClusterMetadata(response, false))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `{"cluster_id":"abc","controller":1,` +
+		`"brokers":[{"id":1,"addr":"b1:9092","rack":null},{"id":2,"addr":"b2:9092","rack":null}],` +
+		`"topics":[{"name":"bar","internal":false,"partitions":[{"partition":0,"leader":-1,"replicas":[3],"isr":[],"offline_replicas":[3],"error":"` + sarama.ErrLeaderNotAvailable.Error() + `"}]},` +
+		`{"name":"foo","internal":false,"partitions":[{"partition":0,"leader":1,"replicas":[1,2],"isr":[1,2],"offline_replicas":[]},` +
+		`{"partition":1,"leader":2,"replicas":[2,1],"isr":[2],"offline_replicas":[1]}]}]}`
+	if string(document) != expected {
+		t.Errorf("Expected %s, got %s", expected, document)
+	}
+}
+
+func TestNewClusterMetadataInternalTopics(t *testing.T) {
+	response := &sarama.MetadataResponse{ControllerID: 1}
+	response.AddTopicPartition("__consumer_offsets", 0, 1, []int32{1}, []int32{1}, nil, sarama.ErrNoError)
+	response.AddTopicPartition("_schemas", 0, 1, []int32{1}, []int32{1}, nil, sarama.ErrNoError)
+	response.AddTopicPartition("foo", 0, 1, []int32{1}, []int32{1}, nil, sarama.ErrNoError)
+
+	names := func(cluster clusterMetadata) (names []string) {
+		for _, topic := range cluster.Topics {
+			names = append(names, topic.Name)
+		}
+		return names
+	}
+
+	if topics := names(newClusterMetadata(response, false)); len(topics) != 1 || topics[0] != "foo" {
+		t.Errorf("Expected only topic foo, got %q", topics)
+	}
+	if topics := names(newClusterMetadata(response, true)); len(topics) != 3 {
+		t.Errorf("Expected the internal topics to be included, got %q", topics)
+	}
+	if topics := withoutInternalTopics([]string{"__transaction_state", "foo", "bar_baz"}); len(topics) != 2 || topics[0] != "foo" || topics[1] != "bar_baz" {
+		t.Errorf("Expected topics foo and bar_baz, got %q", topics)
+	}
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"regexp"
+	"sort"
+	"sync"
+
+	"github.com/Shopify/sarama"
+	"github.com/jurriaan/kafkatools"
+	metrics "github.com/rcrowley/go-metrics"
+)
+
+// consumeMetrics counts the consumed messages, bytes and consumer errors and the lag of every partition for the
+// --metrics-addr endpoint
+type consumeMetrics struct {
+	mutex      sync.Mutex
+	partitions map[topicPartition]*partitionMetrics
+	errors     int64
+	// registry holds the metrics sarama collects, nil when they are not exported
+	registry metrics.Registry
+}
+
+type partitionMetrics struct {
+	messages, bytes int64
+	// lag is how far the last consumed message was behind the high-water mark of the partition
+	lag int64
+}
+
+func newConsumeMetrics() *consumeMetrics {
+	return &consumeMetrics{partitions: make(map[topicPartition]*partitionMetrics)}
+}
+
+// record counts a consumed message and the lag of its partition, it is a no-op on nil metrics
+func (m *consumeMetrics) record(pc highWaterMarker, msg *sarama.ConsumerMessage) {
+	if m == nil {
+		return
+	}
+
+	key := topicPartition{Topic: msg.Topic, Partition: msg.Partition}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	partition, ok := m.partitions[key]
+	if !ok {
+		partition = &partitionMetrics{}
+		m.partitions[key] = partition
+	}
+	partition.messages++
+	partition.bytes += int64(recordSize(msg))
+	partition.lag = pc.HighWaterMarkOffset() - msg.Offset - 1
+}
+
+// addError counts a partition consumer error, it is a no-op on nil metrics
+func (m *consumeMetrics) addError() {
+	if m == nil {
+		return
+	}
+	m.mutex.Lock()
+	m.errors++
+	m.mutex.Unlock()
+}
+
+// ServeHTTP writes the metrics in the Prometheus text format
+func (m *consumeMetrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	m.write(w)
+}
+
+func (m *consumeMetrics) write(out io.Writer) {
+	m.mutex.Lock()
+	keys := make([]topicPartition, 0, len(m.partitions))
+	partitions := make(map[topicPartition]partitionMetrics, len(m.partitions))
+	for key, partition := range m.partitions {
+		keys = append(keys, key)
+		partitions[key] = *partition
+	}
+	errors := m.errors
+	m.mutex.Unlock()
+
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].Topic != keys[j].Topic {
+			return keys[i].Topic < keys[j].Topic
+		}
+		return keys[i].Partition < keys[j].Partition
+	})
+
+	for _, metric := range []struct {
+		name, kind, help string
+		value            func(partitionMetrics) int64
+	}{
+		{"kt_messages_consumed_total", "counter", "Messages consumed per partition.", func(p partitionMetrics) int64 { return p.messages }},
+		{"kt_bytes_consumed_total", "counter", "Bytes of the keys, values and headers consumed per partition.", func(p partitionMetrics) int64 { return p.bytes }},
+		{"kt_partition_lag", "gauge", "Messages between the last consumed message and the high-water mark of the partition.", func(p partitionMetrics) int64 { return p.lag }},
+	} {
+		fmt.Fprintf(out, "# HELP %s %s\n# TYPE %s %s\n", metric.name, metric.help, metric.name, metric.kind)
+		for _, key := range keys {
+			fmt.Fprintf(out, "%s{topic=%q,partition=\"%d\"} %d\n", metric.name, key.Topic, key.Partition, metric.value(partitions[key]))
+		}
+	}
+	fmt.Fprintf(out, "# HELP kt_consumer_errors_total Errors of the partition consumers.\n# TYPE kt_consumer_errors_total counter\nkt_consumer_errors_total %d\n", errors)
+
+	if m.registry != nil {
+		writeSaramaMetrics(out, m.registry)
+	}
+}
+
+// invalidMetricChars are the characters go-metrics names may contain which Prometheus names can't
+var invalidMetricChars = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+
+// writeSaramaMetrics writes the go-metrics collected by sarama (e.g. request-rate-for-broker-1) as sarama_ metrics:
+// meters and counters as counters, gauges as gauges and histograms as summaries
+func writeSaramaMetrics(out io.Writer, registry metrics.Registry) {
+	var names []string
+	all := make(map[string]interface{})
+	registry.Each(func(name string, metric interface{}) {
+		name = "sarama_" + invalidMetricChars.ReplaceAllString(name, "_")
+		names = append(names, name)
+		all[name] = metric
+	})
+	sort.Strings(names)
+
+	for _, name := range names {
+		switch metric := all[name].(type) {
+		case metrics.Meter:
+			fmt.Fprintf(out, "# TYPE %s_total counter\n%s_total %d\n", name, name, metric.Count())
+		case metrics.Counter:
+			fmt.Fprintf(out, "# TYPE %s_total counter\n%s_total %d\n", name, name, metric.Count())
+		case metrics.Gauge:
+			fmt.Fprintf(out, "# TYPE %s gauge\n%s %d\n", name, name, metric.Value())
+		case metrics.Histogram:
+			snapshot := metric.Snapshot()
+			fmt.Fprintf(out, "# TYPE %s summary\n", name)
+			quantiles := []float64{0.5, 0.95, 0.99}
+			for i, value := range snapshot.Percentiles(quantiles) {
+				fmt.Fprintf(out, "%s{quantile=\"%g\"} %g\n", name, quantiles[i], value)
+			}
+			fmt.Fprintf(out, "%s_sum %d\n%s_count %d\n", name, snapshot.Sum(), name, snapshot.Count())
+		}
+	}
+}
+
+// serveMetrics serves the metrics on /metrics of the address until closing is closed
+func serveMetrics(addr string, m *consumeMetrics, closing chan struct{}) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		log.Fatal("Could not start the metrics server: ", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", m)
+	server := &http.Server{Handler: mux}
+	go func() {
+		if err := server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			log.Println("The metrics server failed: ", err)
+		}
+	}()
+	go func() {
+		<-closing
+		if err := server.Close(); err != nil {
+			log.Println("Error closing the metrics server: ", err)
+		}
+	}()
+	kafkatools.Log.Infof("Serving the metrics on http://%s/metrics", listener.Addr())
+}
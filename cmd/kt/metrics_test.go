@@ -0,0 +1,41 @@
+package main
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Shopify/sarama"
+	metrics "github.com/rcrowley/go-metrics"
+)
+
+func TestConsumeMetrics(t *testing.T) {
+	m := newConsumeMetrics()
+	m.record(fixedHighWaterMark(10), &sarama.ConsumerMessage{Topic: "foo", Partition: 1, Offset: 5, Key: []byte("k"), Value: []byte("value")})
+	m.record(fixedHighWaterMark(10), &sarama.ConsumerMessage{Topic: "foo", Partition: 1, Offset: 6, Value: []byte("v")})
+	m.record(fixedHighWaterMark(3), &sarama.ConsumerMessage{Topic: "bar", Partition: 0, Offset: 2})
+	m.addError()
+
+	m.registry = metrics.NewRegistry()
+	metrics.GetOrRegisterMeter("request-rate-for-broker-1", m.registry).Mark(4)
+
+	recorder := httptest.NewRecorder()
+	m.ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	body := recorder.Body.String()
+	for _, expected := range []string{
+		"# TYPE kt_messages_consumed_total counter\nkt_messages_consumed_total{topic=\"bar\",partition=\"0\"} 1\nkt_messages_consumed_total{topic=\"foo\",partition=\"1\"} 2\n",
+		"kt_bytes_consumed_total{topic=\"foo\",partition=\"1\"} 7\n",
+		"kt_partition_lag{topic=\"foo\",partition=\"1\"} 3\n",
+		"kt_partition_lag{topic=\"bar\",partition=\"0\"} 0\n",
+		"kt_consumer_errors_total 1\n",
+		"# TYPE sarama_request_rate_for_broker_1_total counter\nsarama_request_rate_for_broker_1_total 4\n",
+	} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("Expected the metrics to contain %q, got:\n%s", expected, body)
+		}
+	}
+
+	var disabled *consumeMetrics
+	disabled.record(fixedHighWaterMark(1), &sarama.ConsumerMessage{})
+	disabled.addError()
+}
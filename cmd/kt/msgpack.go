@@ -0,0 +1,202 @@
+package main
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/Shopify/sarama"
+)
+
+// msgpackTimestampType is the extension type of MessagePack timestamps
+const msgpackTimestampType = -1
+
+// decodeMsgpack renders a MessagePack value as JSON. Binary strings are base64 encoded and extensions other than
+// timestamps are rendered as {"type": <type>, "data": <base64>}.
+func decodeMsgpack(msg *sarama.ConsumerMessage) ([]byte, error) {
+	decoder := msgpackDecoder{data: msg.Value}
+	value, err := decoder.decode()
+	if err != nil {
+		return nil, err
+	}
+	if len(decoder.data) > 0 {
+		return nil, fmt.Errorf("%d unexpected bytes after the msgpack value", len(decoder.data))
+	}
+	return json.Marshal(value)
+}
+
+type msgpackDecoder struct {
+	data []byte
+}
+
+func (d *msgpackDecoder) read(n uint64) ([]byte, error) {
+	if n > uint64(len(d.data)) {
+		return nil, fmt.Errorf("msgpack value is truncated: %d bytes needed, %d left", n, len(d.data))
+	}
+	bytes := d.data[:n]
+	d.data = d.data[n:]
+	return bytes, nil
+}
+
+// readUint reads a big-endian unsigned integer of size bytes
+func (d *msgpackDecoder) readUint(size uint64) (uint64, error) {
+	bytes, err := d.read(size)
+	if err != nil {
+		return 0, err
+	}
+
+	var value uint64
+	for _, b := range bytes {
+		value = value<<8 | uint64(b)
+	}
+	return value, nil
+}
+
+func (d *msgpackDecoder) decode() (interface{}, error) {
+	typeByte, err := d.readUint(1)
+	if err != nil {
+		return nil, err
+	}
+	b := byte(typeByte)
+
+	switch {
+	case b <= 0x7f:
+		return int64(b), nil
+	case b >= 0xe0:
+		return int64(int8(b)), nil
+	case b&0xf0 == 0x80:
+		return d.decodeMap(uint64(b & 0x0f))
+	case b&0xf0 == 0x90:
+		return d.decodeArray(uint64(b & 0x0f))
+	case b&0xe0 == 0xa0:
+		return d.decodeString(uint64(b & 0x1f))
+	}
+
+	switch b {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	case 0xc4, 0xc5, 0xc6:
+		return d.decodeSized(1<<(b-0xc4), func(n uint64) (interface{}, error) { return d.read(n) })
+	case 0xc7, 0xc8, 0xc9:
+		length, err := d.readUint(1 << (b - 0xc7))
+		if err != nil {
+			return nil, err
+		}
+		return d.decodeExt(length)
+	case 0xca:
+		bits, err := d.readUint(4)
+		return float64(math.Float32frombits(uint32(bits))), err
+	case 0xcb:
+		bits, err := d.readUint(8)
+		return math.Float64frombits(bits), err
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		return d.readUint(1 << (b - 0xcc))
+	case 0xd0, 0xd1, 0xd2, 0xd3:
+		size := uint64(1) << (b - 0xd0)
+		value, err := d.readUint(size)
+		// Sign extend the value
+		shift := 64 - 8*size
+		return int64(value<<shift) >> shift, err
+	case 0xd4, 0xd5, 0xd6, 0xd7, 0xd8:
+		return d.decodeExt(1 << (b - 0xd4))
+	case 0xd9, 0xda, 0xdb:
+		return d.decodeSized(1<<(b-0xd9), d.decodeString)
+	case 0xdc, 0xdd:
+		return d.decodeSized(2<<(b-0xdc), d.decodeArray)
+	case 0xde, 0xdf:
+		return d.decodeSized(2<<(b-0xde), d.decodeMap)
+	}
+	return nil, fmt.Errorf("invalid msgpack type byte 0x%02x", b)
+}
+
+// decodeSized reads a length of size bytes and decodes the value of that length
+func (d *msgpackDecoder) decodeSized(size uint64, decode func(length uint64) (interface{}, error)) (interface{}, error) {
+	length, err := d.readUint(size)
+	if err != nil {
+		return nil, err
+	}
+	return decode(length)
+}
+
+func (d *msgpackDecoder) decodeString(length uint64) (interface{}, error) {
+	bytes, err := d.read(length)
+	return string(bytes), err
+}
+
+func (d *msgpackDecoder) decodeArray(length uint64) (interface{}, error) {
+	// Every element takes at least a byte, this prevents huge allocations for corrupt lengths
+	if length > uint64(len(d.data)) {
+		return nil, fmt.Errorf("msgpack array of %d elements is truncated", length)
+	}
+
+	array := make([]interface{}, length)
+	for i := range array {
+		var err error
+		if array[i], err = d.decode(); err != nil {
+			return nil, err
+		}
+	}
+	return array, nil
+}
+
+// decodeMap decodes a map, JSON only supports string keys so other keys are formatted
+func (d *msgpackDecoder) decodeMap(length uint64) (interface{}, error) {
+	if length > uint64(len(d.data)) {
+		return nil, fmt.Errorf("msgpack map of %d entries is truncated", length)
+	}
+
+	entries := make(map[string]interface{}, length)
+	for i := uint64(0); i < length; i++ {
+		key, err := d.decode()
+		if err != nil {
+			return nil, err
+		}
+		value, err := d.decode()
+		if err != nil {
+			return nil, err
+		}
+
+		if keyStr, ok := key.(string); ok {
+			entries[keyStr] = value
+		} else {
+			entries[fmt.Sprint(key)] = value
+		}
+	}
+	return entries, nil
+}
+
+func (d *msgpackDecoder) decodeExt(length uint64) (interface{}, error) {
+	extType, err := d.readUint(1)
+	if err != nil {
+		return nil, err
+	}
+	data, err := d.read(length)
+	if err != nil {
+		return nil, err
+	}
+
+	if int8(extType) == msgpackTimestampType {
+		return decodeMsgpackTimestamp(data)
+	}
+	return map[string]interface{}{"type": int8(extType), "data": data}, nil
+}
+
+// decodeMsgpackTimestamp decodes the 32, 64 and 96 bit timestamp extensions
+func decodeMsgpackTimestamp(data []byte) (time.Time, error) {
+	switch len(data) {
+	case 4:
+		return time.Unix(int64(binary.BigEndian.Uint32(data)), 0).UTC(), nil
+	case 8:
+		value := binary.BigEndian.Uint64(data)
+		return time.Unix(int64(value&(1<<34-1)), int64(value>>34)).UTC(), nil
+	case 12:
+		return time.Unix(int64(binary.BigEndian.Uint64(data[4:])), int64(binary.BigEndian.Uint32(data))).UTC(), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid msgpack timestamp of %d bytes", len(data))
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/Shopify/sarama"
+)
+
+func TestDecodeMsgpack(t *testing.T) {
+	value := []byte{
+		0x88,
+		0xa1, 'a', 0x01,
+		0xa1, 'b', 0x93, 0xc3, 0xc0, 0xa1, 'x',
+		0xa1, 'c', 0xff,
+		0xa1, 'd', 0xcb, 0x3f, 0xf8, 0, 0, 0, 0, 0, 0,
+		0xa1, 'e', 0xc4, 0x02, 0x01, 0x02,
+		0xa1, 'f', 0xd1, 0xff, 0x38,
+		0xa1, 'g', 0xcd, 0x01, 0x00,
+		0xa1, 'h', 0xd6, 0xff, 0, 0, 0, 0,
+	}
+
+	decoded, err := decodeMsgpack(&sarama.ConsumerMessage{Value: value})
+	if err != nil {
+		t.Fatal("Unexpected error: ", err)
+	}
+
+	expected := `{"a":1,"b":[true,null,"x"],"c":-1,"d":1.5,"e":"AQI=","f":-200,"g":256,"h":"1970-01-01T00:00:00Z"}`
+	if string(decoded) != expected {
+		t.Errorf("Expected %s, got %s", expected, decoded)
+	}
+}
+
+func TestDecodeMsgpackInvalid(t *testing.T) {
+	for _, value := range [][]byte{
+		{},
+		{0xc1},
+		{0x92, 0x01},
+		{0xdd, 0xff, 0xff, 0xff, 0xff},
+		{0xa3, 'a'},
+		{0x01, 0x02},
+	} {
+		if decoded, err := decodeMsgpack(&sarama.ConsumerMessage{Value: value}); err == nil {
+			t.Errorf("Expected an error for % x, got %s", value, decoded)
+		}
+	}
+}
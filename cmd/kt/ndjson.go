@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/Shopify/sarama"
+)
+
+// ndjsonInputRecord is a line of the ndjson input of kt produce, it accepts the records kt consume --output ndjson
+// prints. The topic, offset and timestamp (and the other fields consume can add) are ignored, a missing partition
+// leaves the partition to the hash partitioner and key and value are null for missing keys and tombstones.
+type ndjsonInputRecord struct {
+	Partition *int32            `json:"partition"`
+	Key       *string           `json:"key"`
+	Value     *string           `json:"value"`
+	Headers   map[string]string `json:"headers"`
+	// KeyEncoding is base64 or hex for the encoded keys
+	KeyEncoding string `json:"key_encoding"`
+	// ValueEncoding is base64 or hex for the encoded values
+	ValueEncoding string `json:"value_encoding"`
+	// HeaderEncodings are base64 or hex for the encoded header values
+	HeaderEncodings map[string]string `json:"header_encodings"`
+}
+
+// readNDJSON emits the record of every line of the input
+func readNDJSON(input io.Reader, emit func(record inputRecord)) error {
+	scanner := bufio.NewScanner(input)
+	scanner.Buffer(make([]byte, 64*1024), maxLineSize)
+	for line := 1; scanner.Scan(); line++ {
+		record, err := parseNDJSONRecord(scanner.Bytes())
+		if err != nil {
+			return fmt.Errorf("invalid record on line %d: %v", line, err)
+		}
+		emit(record)
+	}
+	return scanner.Err()
+}
+
+func parseNDJSONRecord(line []byte) (inputRecord, error) {
+	var parsed ndjsonInputRecord
+	if err := json.Unmarshal(line, &parsed); err != nil {
+		return inputRecord{}, err
+	}
+	if parsed.Partition != nil && *parsed.Partition < 0 {
+		return inputRecord{}, fmt.Errorf("invalid partition %d", *parsed.Partition)
+	}
+
+	record := inputRecord{partition: parsed.Partition}
+	if parsed.Key != nil {
+		record.key = []byte(*parsed.Key)
+	}
+	if parsed.KeyEncoding != "" {
+		if parsed.Key == nil {
+			return inputRecord{}, fmt.Errorf("%s key encoding without a key", parsed.KeyEncoding)
+		}
+		key, err := decodeField(*parsed.Key, parsed.KeyEncoding)
+		if err != nil {
+			return inputRecord{}, fmt.Errorf("invalid key: %v", err)
+		}
+		record.key = key
+	}
+	if parsed.Value != nil {
+		record.value = []byte(*parsed.Value)
+	}
+	if parsed.ValueEncoding != "" {
+		if parsed.Value == nil {
+			return inputRecord{}, fmt.Errorf("%s value encoding without a value", parsed.ValueEncoding)
+		}
+		value, err := decodeField(*parsed.Value, parsed.ValueEncoding)
+		if err != nil {
+			return inputRecord{}, fmt.Errorf("invalid value: %v", err)
+		}
+		record.value = value
+	}
+
+	// JSON objects are unordered, sorting the headers produces the same records for the same input
+	keys := make([]string, 0, len(parsed.Headers))
+	for key := range parsed.Headers {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		value := []byte(parsed.Headers[key])
+		if encoding := parsed.HeaderEncodings[key]; encoding != "" {
+			var err error
+			if value, err = decodeField(parsed.Headers[key], encoding); err != nil {
+				return inputRecord{}, fmt.Errorf("invalid value of header %s: %v", key, err)
+			}
+		}
+		record.headers = append(record.headers, sarama.RecordHeader{Key: []byte(key), Value: value})
+	}
+	return record, nil
+}
+
+// decodeField decodes a field in the base64 or hex encoding of kt consume --output ndjson
+func decodeField(field, encoding string) ([]byte, error) {
+	switch encoding {
+	case "base64":
+		return base64.StdEncoding.DecodeString(field)
+	case "hex":
+		return hex.DecodeString(field)
+	default:
+		return nil, fmt.Errorf("invalid encoding %q", encoding)
+	}
+}
+
+// inputPartitioner keeps the partition of the input records which have one and hashes the keys of the others like
+// the default partitioner of sarama
+type inputPartitioner struct {
+	hash sarama.Partitioner
+}
+
+func newInputPartitioner(topic string) sarama.Partitioner {
+	return inputPartitioner{hash: sarama.NewHashPartitioner(topic)}
+}
+
+func (p inputPartitioner) Partition(msg *sarama.ProducerMessage, numPartitions int32) (int32, error) {
+	if msg.Partition >= 0 {
+		return msg.Partition, nil
+	}
+	return p.hash.Partition(msg, numPartitions)
+}
+
+func (p inputPartitioner) RequiresConsistency() bool {
+	return true
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+)
+
+func TestReadNDJSON(t *testing.T) {
+	// The records as printed by --output ndjson
+	input := `{"topic":"foo","partition":2,"offset":5,"timestamp":"2017-07-14T02:40:00Z","key":"k","value":"v","headers":{"b":"2","a":"1"}}
+{"topic":"foo","partition":0,"offset":6,"timestamp":"2017-07-14T02:40:00Z","key":null,"value":null}
+{"value":"no partition"}
+{"key":"/wA=","value":"binary key","key_encoding":"base64"}
+{"value":"binary header","headers":{"h":"/wA=","t":"text"},"header_encodings":{"h":"base64"}}
+{"value":"AP/+","value_encoding":"base64"}
+{"key":"00ff","value":"6869","headers":{"h":"80"},"key_encoding":"hex","value_encoding":"hex","header_encodings":{"h":"hex"}}
+`
+
+	var records []inputRecord
+	if err := readNDJSON(strings.NewReader(input), func(record inputRecord) { records = append(records, record) }); err != nil {
+		t.Fatal("Unexpected error: ", err)
+	}
+
+	two, zero := int32(2), int32(0)
+	expected := []inputRecord{
+		{key: []byte("k"), value: []byte("v"), partition: &two, headers: []sarama.RecordHeader{{Key: []byte("a"), Value: []byte("1")}, {Key: []byte("b"), Value: []byte("2")}}},
+		{partition: &zero},
+		{value: []byte("no partition")},
+		{key: []byte{0xff, 0x00}, value: []byte("binary key")},
+		{value: []byte("binary header"), headers: []sarama.RecordHeader{{Key: []byte("h"), Value: []byte{0xff, 0x00}}, {Key: []byte("t"), Value: []byte("text")}}},
+		{value: []byte{0x00, 0xff, 0xfe}},
+		{key: []byte{0x00, 0xff}, value: []byte("hi"), headers: []sarama.RecordHeader{{Key: []byte("h"), Value: []byte{0x80}}}},
+	}
+	if !reflect.DeepEqual(records, expected) {
+		t.Errorf("Expected records %+v, got %+v", expected, records)
+	}
+
+	for _, invalid := range []string{"not json\n", `{"partition":-1}` + "\n", `{"key":"!","key_encoding":"base64"}` + "\n", `{"key_encoding":"base64"}` + "\n", `{"key":"k","key_encoding":"hex"}` + "\n", `{"headers":{"h":"!"},"header_encodings":{"h":"base64"}}` + "\n", `{"headers":{"h":"v"},"header_encodings":{"h":"hex"}}` + "\n", `{"value_encoding":"base64"}` + "\n", `{"value":"!","value_encoding":"base64"}` + "\n", `{"value":"v","value_encoding":"hex"}` + "\n"} {
+		if err := readNDJSON(strings.NewReader(invalid), func(inputRecord) {}); err == nil {
+			t.Errorf("Expected an error for %q", invalid)
+		}
+	}
+}
+
+func TestProduceInputNDJSON(t *testing.T) {
+	var batch []*sarama.ProducerMessage
+	send := func(messages []*sarama.ProducerMessage) error {
+		batch = append(batch, messages...)
+		return nil
+	}
+
+	input := `{"partition":3,"key":"k","value":"v","headers":{"h":"x"}}` + "\n" + `{"key":"k"}` + "\n"
+	if produced, failed, _ := produceInput(strings.NewReader(input), readNDJSON, "foo", 10, time.Hour, send); produced != 2 || failed != 0 {
+		t.Fatalf("Expected 2 produced and 0 failed messages, got %d and %d", produced, failed)
+	}
+
+	partitioner := newInputPartitioner("foo")
+	if partition, err := partitioner.Partition(batch[0], 4); err != nil || partition != 3 {
+		t.Errorf("Expected the record to keep partition 3, got %d (%v)", partition, err)
+	}
+	if len(batch[0].Headers) != 1 || string(batch[0].Headers[0].Key) != "h" {
+		t.Errorf("Expected the header of the record, got %v", batch[0].Headers)
+	}
+
+	hashed, _ := sarama.NewHashPartitioner("foo").Partition(batch[1], 4)
+	if partition, err := partitioner.Partition(batch[1], 4); err != nil || partition != hashed {
+		t.Errorf("Expected the record without a partition to be hashed to %d, got %d (%v)", hashed, partition, err)
+	}
+	if batch[1].Value != nil {
+		t.Errorf("Expected a tombstone, got %v", batch[1].Value)
+	}
+}
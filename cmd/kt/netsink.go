@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+// networkSinkTimeout bounds connecting to a network sink and writing a message to it, a receiver that doesn't keep up
+// for that long is reconnected. Until then the sink blocks, which stops the consumers from fetching more messages.
+const networkSinkTimeout = 10 * time.Second
+
+// networkSinkRetries is the number of times a network sink reconnects to write a message before it fails
+const networkSinkRetries = 5
+
+// syslogPaths are the sockets of the local syslog daemon on linux, macOS and the BSDs
+var syslogPaths = []string{"/dev/log", "/var/run/syslog", "/var/run/log"}
+
+// syslogPriority is the priority of the forwarded messages: facility user, severity info
+const syslogPriority = 1<<3 | 6
+
+// networkSink writes messages to a socket, it reconnects when a write fails or times out. A message whose write failed
+// halfway is written again in full on the new connection.
+type networkSink struct {
+	name    string
+	dial    func() (net.Conn, error)
+	conn    net.Conn
+	backoff time.Duration
+}
+
+// newNetworkSink connects to the address of a tcp://host:port or udp://host:port sink
+func newNetworkSink(spec string) (*networkSink, error) {
+	parts := strings.SplitN(spec, "://", 2)
+	network, address := parts[0], parts[1]
+	sink := &networkSink{name: spec, backoff: 100 * time.Millisecond, dial: func() (net.Conn, error) {
+		return net.DialTimeout(network, address, networkSinkTimeout)
+	}}
+	return sink, sink.connect()
+}
+
+// newSyslogSink connects to the local syslog daemon
+func newSyslogSink() (*networkSink, error) {
+	sink := &networkSink{name: "syslog", backoff: 100 * time.Millisecond, dial: dialSyslog}
+	return sink, sink.connect()
+}
+
+func dialSyslog() (net.Conn, error) {
+	for _, network := range []string{"unixgram", "unix"} {
+		for _, path := range syslogPaths {
+			if conn, err := net.DialTimeout(network, path, networkSinkTimeout); err == nil {
+				return conn, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("no syslog daemon found at %s", strings.Join(syslogPaths, ", "))
+}
+
+func (s *networkSink) connect() (err error) {
+	s.conn, err = s.dial()
+	return err
+}
+
+// write writes the data, reconnecting with an exponential backoff when it fails
+func (s *networkSink) write(data []byte) error {
+	var err error
+	for attempt := 0; attempt <= networkSinkRetries; attempt++ {
+		if attempt > 0 {
+			log.Printf("Could not write to sink %s, reconnecting: %v", s.name, err)
+			time.Sleep(s.backoff << (attempt - 1))
+		}
+		if s.conn == nil {
+			if err = s.connect(); err != nil {
+				continue
+			}
+		}
+
+		s.conn.SetWriteDeadline(time.Now().Add(networkSinkTimeout))
+		if _, err = s.conn.Write(data); err == nil {
+			return nil
+		}
+		s.conn.Close()
+		s.conn = nil
+	}
+	return err
+}
+
+func (s *networkSink) close() error {
+	if s.conn == nil {
+		return nil
+	}
+	return s.conn.Close()
+}
+
+// formatSyslogMessage formats the message in the format of the local syslog daemons (RFC 3164 without the hostname),
+// tagged with kt and its process id. The message is cut at its first newline, syslog messages are single lines.
+func formatSyslogMessage(message []byte, now time.Time) []byte {
+	if newline := strings.IndexByte(string(message), '\n'); newline != -1 {
+		message = message[:newline]
+	}
+	header := fmt.Sprintf("<%d>%s kt[%d]: ", syslogPriority, now.Format(time.Stamp), os.Getpid())
+	return append([]byte(header), message...)
+}
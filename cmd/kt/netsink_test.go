@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNetworkSinkReconnects(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	// The first connection is closed after reading a line, the second one reads everything
+	lines := make(chan string, 3)
+	go func() {
+		for i := 0; i < 2; i++ {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			reader := bufio.NewReader(conn)
+			for {
+				line, err := reader.ReadString('\n')
+				if err != nil {
+					break
+				}
+				lines <- line
+				if i == 0 {
+					break
+				}
+			}
+			conn.Close()
+		}
+	}()
+
+	sink, err := newNetworkSink("tcp://" + listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sink.close()
+	sink.backoff = time.Millisecond
+
+	if err := sink.write([]byte("a\n")); err != nil {
+		t.Fatal(err)
+	}
+	if line := <-lines; line != "a\n" {
+		t.Errorf("Expected line a, got %q", line)
+	}
+
+	// Writes to the closed connection only fail once the peer reset it
+	deadline := time.Now().Add(5 * time.Second)
+	for len(lines) == 0 && time.Now().Before(deadline) {
+		if err := sink.write([]byte("b\n")); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	select {
+	case line := <-lines:
+		if line != "b\n" {
+			t.Errorf("Expected line b after reconnecting, got %q", line)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("Expected the sink to reconnect")
+	}
+}
+
+func TestNetworkSinkFails(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := listener.Addr().String()
+	listener.Close()
+
+	if _, err := newNetworkSink("tcp://" + address); err == nil {
+		t.Error("Expected an error connecting to a closed port")
+	}
+}
+
+func TestFormatSyslogMessage(t *testing.T) {
+	now := time.Date(2017, 7, 4, 9, 5, 3, 0, time.UTC)
+	formatted := string(formatSyslogMessage([]byte("first\nsecond"), now))
+
+	if !strings.HasPrefix(formatted, "<14>Jul  4 09:05:03 kt[") || !strings.HasSuffix(formatted, "]: first") {
+		t.Errorf("Expected a syslog message of the first line, got %q", formatted)
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"strconv"
+
+	"github.com/Shopify/sarama"
+)
+
+// messageNumbers numbers the printed messages from 1, in the order they are printed and, when perPartition is set,
+// per partition as well
+type messageNumbers struct {
+	perPartition bool
+	total        int64
+	partitions   map[topicPartition]int64
+}
+
+func newMessageNumbers(perPartition bool) *messageNumbers {
+	return &messageNumbers{perPartition: perPartition, partitions: make(map[topicPartition]int64)}
+}
+
+// next returns the number of the message and its number within its partition, 0 when it is not numbered per partition
+func (n *messageNumbers) next(msg *sarama.ConsumerMessage) (number, partitionNumber int64) {
+	n.total++
+	if n.perPartition {
+		partition := topicPartition{Topic: msg.Topic, Partition: msg.Partition}
+		n.partitions[partition]++
+		partitionNumber = n.partitions[partition]
+	}
+	return n.total, partitionNumber
+}
+
+// prefix returns the numbers of the message as tab separated columns
+func (n *messageNumbers) prefix(msg *sarama.ConsumerMessage) string {
+	number, partitionNumber := n.next(msg)
+	prefix := strconv.FormatInt(number, 10) + "\t"
+	if n.perPartition {
+		prefix += strconv.FormatInt(partitionNumber, 10) + "\t"
+	}
+	return prefix
+}
@@ -0,0 +1,23 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/Shopify/sarama"
+)
+
+func TestMessageNumbers(t *testing.T) {
+	numbers := newMessageNumbers(false)
+	for i := int64(1); i <= 3; i++ {
+		if number, partitionNumber := numbers.next(&sarama.ConsumerMessage{Topic: "foo", Partition: int32(i % 2)}); number != i || partitionNumber != 0 {
+			t.Errorf("Expected message %d without a partition number, got %d and %d", i, number, partitionNumber)
+		}
+	}
+
+	numbers = newMessageNumbers(true)
+	numbers.next(&sarama.ConsumerMessage{Topic: "foo", Partition: 0})
+	numbers.next(&sarama.ConsumerMessage{Topic: "bar", Partition: 0})
+	if number, partitionNumber := numbers.next(&sarama.ConsumerMessage{Topic: "foo", Partition: 0}); number != 3 || partitionNumber != 2 {
+		t.Errorf("Expected the third message to be the second of foo partition 0, got %d and %d", number, partitionNumber)
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/Shopify/sarama"
+	"github.com/jurriaan/kafkatools"
+)
+
+// offsetExpression is a parsed offset: an absolute offset or an offset relative to the oldest or newest offset of
+// every partition
+type offsetExpression struct {
+	// Base is sarama.OffsetOldest or sarama.OffsetNewest for relative offsets, 0 for absolute ones
+	Base  int64
+	Delta int64
+}
+
+// parseOffsetExpression parses oldest | beginning, newest | end, oldest+<n>, newest-<n>, <n> (absolute) and -<n>
+// (short for newest-<n>)
+func parseOffsetExpression(expr string) (offsetExpression, error) {
+	for _, base := range []struct {
+		names  []string
+		offset int64
+		sign   string
+	}{
+		{[]string{"oldest", "beginning"}, sarama.OffsetOldest, "+"},
+		{[]string{"newest", "end"}, sarama.OffsetNewest, "-"},
+	} {
+		for _, name := range base.names {
+			if expr == name {
+				return offsetExpression{Base: base.offset}, nil
+			}
+			if !strings.HasPrefix(expr, name) {
+				continue
+			}
+
+			rest := strings.TrimPrefix(expr, name)
+			if !strings.HasPrefix(rest, base.sign) {
+				return offsetExpression{}, fmt.Errorf("invalid offset %q, only %s%s<n> is supported", expr, name, base.sign)
+			}
+			delta, err := parseOffsetDelta(strings.TrimPrefix(rest, base.sign))
+			if err != nil {
+				return offsetExpression{}, fmt.Errorf("invalid offset %q: %v", expr, err)
+			}
+			if base.sign == "-" {
+				delta = -delta
+			}
+			return offsetExpression{Base: base.offset, Delta: delta}, nil
+		}
+	}
+
+	if strings.HasPrefix(expr, "-") {
+		delta, err := parseOffsetDelta(strings.TrimPrefix(expr, "-"))
+		if err != nil {
+			return offsetExpression{}, fmt.Errorf("invalid offset %q: %v", expr, err)
+		}
+		return offsetExpression{Base: sarama.OffsetNewest, Delta: -delta}, nil
+	}
+
+	offset, err := parseOffsetDelta(expr)
+	if err != nil {
+		return offsetExpression{}, fmt.Errorf("invalid offset %q: %v", expr, err)
+	}
+	return offsetExpression{Delta: offset}, nil
+}
+
+func parseOffsetDelta(value string) (int64, error) {
+	// ParseUint rejects signs, so oldest+-5 is not accepted
+	delta, err := strconv.ParseUint(value, 10, 63)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a positive number", value)
+	}
+	return int64(delta), nil
+}
+
+// relative tells whether the expression depends on the oldest or newest offset of the partition
+func (e offsetExpression) relative() bool {
+	return e.Base == sarama.OffsetOldest || e.Base == sarama.OffsetNewest
+}
+
+// resolve returns the offset in a partition with the given oldest and newest offsets, relative offsets are kept
+// within the partition
+func (e offsetExpression) resolve(oldest, newest int64) int64 {
+	if !e.relative() {
+		return e.Delta
+	}
+
+	offset := newest + e.Delta
+	if e.Base == sarama.OffsetOldest {
+		offset = oldest + e.Delta
+	}
+
+	if offset < oldest {
+		return oldest
+	} else if offset > newest {
+		return newest
+	}
+	return offset
+}
+
+// tailOffsets returns the start offsets of the partitions printing the last n messages: every partition starts
+// ceil(n / partitions) messages before its newest offset, clamped at its oldest offset
+func tailOffsets(partitions, oldest, newest offsetMap, n int) offsetMap {
+	if len(partitions) == 0 {
+		return partitions
+	}
+	perPartition := (n + len(partitions) - 1) / len(partitions)
+	resolved := resolveOffsetExpression(offsetExpression{Base: sarama.OffsetNewest, Delta: -int64(perPartition)}, oldest, newest)
+
+	offsets := make(offsetMap, len(partitions))
+	for partition := range partitions {
+		if offset, ok := resolved[partition]; ok {
+			offsets[partition] = offset
+		}
+	}
+	return offsets
+}
+
+// resolveOffsetExpression resolves the expression in every partition of the oldest offsets
+func resolveOffsetExpression(expr offsetExpression, oldest, newest offsetMap) offsetMap {
+	offsets := make(offsetMap, len(oldest))
+	for partition, oldestOffset := range oldest {
+		newestOffset, ok := newest[partition]
+		if !ok {
+			continue
+		}
+		offsets[partition] = kafkatools.TopicPartitionOffset{
+			Topic:     oldestOffset.Topic,
+			Partition: partition,
+			Offset:    expr.resolve(oldestOffset.Offset, newestOffset.Offset),
+		}
+	}
+	return offsets
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/Shopify/sarama"
+	"github.com/jurriaan/kafkatools"
+)
+
+func TestParseOffsetExpression(t *testing.T) {
+	for expr, expected := range map[string]offsetExpression{
+		"oldest":      {Base: sarama.OffsetOldest},
+		"beginning":   {Base: sarama.OffsetOldest},
+		"newest":      {Base: sarama.OffsetNewest},
+		"end":         {Base: sarama.OffsetNewest},
+		"oldest+500":  {Base: sarama.OffsetOldest, Delta: 500},
+		"newest-1000": {Base: sarama.OffsetNewest, Delta: -1000},
+		"end-10":      {Base: sarama.OffsetNewest, Delta: -10},
+		"-10":         {Base: sarama.OffsetNewest, Delta: -10},
+		"42":          {Delta: 42},
+		"0":           {Delta: 0},
+	} {
+		parsed, err := parseOffsetExpression(expr)
+		if err != nil {
+			t.Errorf("Unexpected error for %s: %v", expr, err)
+		} else if parsed != expected {
+			t.Errorf("Expected %s to be parsed as %+v, got %+v", expr, expected, parsed)
+		}
+	}
+
+	for _, expr := range []string{"", "latest", "newest+5", "oldest-5", "oldest+", "oldest+-5", "newest-x", "--5", "+5", "1.5"} {
+		if _, err := parseOffsetExpression(expr); err == nil {
+			t.Errorf("Expected an error for %q", expr)
+		}
+	}
+}
+
+func TestResolveOffsetExpression(t *testing.T) {
+	oldest := offsetMap{
+		0: kafkatools.TopicPartitionOffset{Topic: "foo", Partition: 0, Offset: 100},
+		1: kafkatools.TopicPartitionOffset{Topic: "foo", Partition: 1, Offset: 0},
+	}
+	newest := offsetMap{
+		0: kafkatools.TopicPartitionOffset{Topic: "foo", Partition: 0, Offset: 150},
+		1: kafkatools.TopicPartitionOffset{Topic: "foo", Partition: 1, Offset: 2000},
+	}
+
+	for _, test := range []struct {
+		expr     offsetExpression
+		expected [2]int64
+	}{
+		{offsetExpression{Base: sarama.OffsetNewest, Delta: -1000}, [2]int64{100, 1000}},
+		{offsetExpression{Base: sarama.OffsetOldest, Delta: 500}, [2]int64{150, 500}},
+		{offsetExpression{Base: sarama.OffsetOldest}, [2]int64{100, 0}},
+		{offsetExpression{Delta: 120}, [2]int64{120, 120}},
+	} {
+		offsets := resolveOffsetExpression(test.expr, oldest, newest)
+		if offsets[0].Offset != test.expected[0] || offsets[1].Offset != test.expected[1] || offsets[1].Topic != "foo" {
+			t.Errorf("Expected %+v to resolve to %v, got %v", test.expr, test.expected, offsets)
+		}
+	}
+}
+
+func TestTailOffsets(t *testing.T) {
+	oldest := offsetMap{
+		0: kafkatools.TopicPartitionOffset{Topic: "foo", Partition: 0, Offset: 100},
+		1: kafkatools.TopicPartitionOffset{Topic: "foo", Partition: 1, Offset: 0},
+		2: kafkatools.TopicPartitionOffset{Topic: "foo", Partition: 2, Offset: 0},
+	}
+	newest := offsetMap{
+		0: kafkatools.TopicPartitionOffset{Topic: "foo", Partition: 0, Offset: 102},
+		1: kafkatools.TopicPartitionOffset{Topic: "foo", Partition: 1, Offset: 2000},
+		2: kafkatools.TopicPartitionOffset{Topic: "foo", Partition: 2, Offset: 50},
+	}
+
+	// 10 messages over 3 partitions start 4 messages before the newest offsets, partition 0 only has 2
+	offsets := tailOffsets(newest, oldest, newest, 10)
+	if len(offsets) != 3 || offsets[0].Offset != 100 || offsets[1].Offset != 1996 || offsets[2].Offset != 46 {
+		t.Errorf("Expected offsets 100, 1996 and 46, got %v", offsets)
+	}
+
+	// Only the selected partitions are tailed
+	selected := offsetMap{1: newest[1]}
+	if offsets := tailOffsets(selected, oldest, newest, 10); len(offsets) != 1 || offsets[1].Offset != 1990 {
+		t.Errorf("Expected partition 1 to start at offset 1990, got %v", offsets)
+	}
+}
+
+func TestResolveOffsetArguments(t *testing.T) {
+	oldest := offsetMap{0: kafkatools.TopicPartitionOffset{Topic: "foo", Partition: 0, Offset: 10}}
+	newest := offsetMap{0: kafkatools.TopicPartitionOffset{Topic: "foo", Partition: 0, Offset: 13}}
+
+	// -<n> reaching back before the oldest offset starts at the oldest offset
+	for expr, expected := range map[string]int64{"beginning": 10, "end": 13, "42": 42, "-2": 11, "-5": 10} {
+		parsed, err := parseOffsetExpression(expr)
+		if err != nil {
+			t.Fatalf("Unexpected error for %s: %v", expr, err)
+		}
+		if offset := resolveOffsetExpression(parsed, oldest, newest)[0].Offset; offset != expected {
+			t.Errorf("Expected --offset %s to start at %d, got %d", expr, expected, offset)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/jurriaan/kafkatools"
+)
+
+// parseOffsetFor parses the partition=offset overrides of --offset-for, the options may separate several overrides by
+// commas
+func parseOffsetFor(overrides []string) (map[int32]int64, error) {
+	parsed := make(map[int32]int64, len(overrides))
+	for _, override := range overrides {
+		parts := strings.SplitN(override, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid override %q, expected partition=offset", override)
+		}
+		partition, err := parsePartitionNumber(strings.TrimSpace(parts[0]))
+		if err != nil {
+			return nil, err
+		}
+		offset, err := strconv.ParseInt(strings.TrimSpace(parts[1]), 10, 64)
+		if err != nil || offset < 0 {
+			return nil, fmt.Errorf("invalid offset %q of partition %d", parts[1], partition)
+		}
+		if _, ok := parsed[partition]; ok {
+			return nil, fmt.Errorf("partition %d is overridden more than once", partition)
+		}
+		parsed[partition] = offset
+	}
+	return parsed, nil
+}
+
+// applyOffsetFor overrides the start offsets of the partitions of --offset-for (or the partition list of a --to), the
+// other partitions keep their start offsets. Offsets outside the oldest to newest offsets of a partition are clamped with a warning.
+func applyOffsetFor(topic string, partitionOffsets, oldest, newest offsetMap, overrides map[int32]int64) (offsetMap, error) {
+	applied := make(offsetMap, len(partitionOffsets)+len(overrides))
+	for partition, offset := range partitionOffsets {
+		applied[partition] = offset
+	}
+
+	partitions := make([]int32, 0, len(overrides))
+	for partition := range overrides {
+		partitions = append(partitions, partition)
+	}
+	for _, partition := range sortedInt32s(partitions) {
+		offset := overrides[partition]
+		first, foundOldest := oldest[partition]
+		last, foundNewest := newest[partition]
+		if !foundOldest || !foundNewest {
+			return nil, fmt.Errorf("partition %d not found for topic %s", partition, topic)
+		}
+		if offset < first.Offset {
+			kafkatools.Log.Warnf("WARNING: offset %d of %s partition %d is before its oldest offset %d, using it instead", offset, topic, partition, first.Offset)
+			offset = first.Offset
+		} else if offset > last.Offset {
+			kafkatools.Log.Warnf("WARNING: offset %d of %s partition %d is after its newest offset %d, using it instead", offset, topic, partition, last.Offset)
+			offset = last.Offset
+		}
+		applied[partition] = kafkatools.TopicPartitionOffset{Topic: topic, Partition: partition, Offset: offset}
+	}
+	return applied, nil
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/jurriaan/kafkatools"
+)
+
+func TestParseOffsetFor(t *testing.T) {
+	overrides, err := parseOffsetFor([]string{"0=1500", " 3 = 42"})
+	if err != nil {
+		t.Fatal("Unexpected error: ", err)
+	}
+	if expected := map[int32]int64{0: 1500, 3: 42}; !reflect.DeepEqual(overrides, expected) {
+		t.Errorf("Expected %v, got %v", expected, overrides)
+	}
+
+	for _, invalid := range [][]string{{"0"}, {"a=1"}, {"-1=1"}, {"0=oldest"}, {"0=-5"}, {"1=2", "1=3"}} {
+		if _, err := parseOffsetFor(invalid); err == nil {
+			t.Errorf("Expected an error for %q", invalid)
+		}
+	}
+}
+
+func TestApplyOffsetFor(t *testing.T) {
+	offsets := func(values ...int64) offsetMap {
+		result := make(offsetMap)
+		for partition, offset := range values {
+			result[int32(partition)] = kafkatools.TopicPartitionOffset{Topic: "foo", Partition: int32(partition), Offset: offset}
+		}
+		return result
+	}
+	start, oldest, newest := offsets(900, 900, 900, 900), offsets(100, 100, 100, 100), offsets(900, 900, 900, 900)
+
+	applied, err := applyOffsetFor("foo", start, oldest, newest, map[int32]int64{0: 500, 2: 50, 3: 1000})
+	if err != nil {
+		t.Fatal("Unexpected error: ", err)
+	}
+	// Partition 1 keeps its start offset, 2 and 3 are clamped to the oldest and newest offsets
+	if expected := offsets(500, 900, 100, 900); !reflect.DeepEqual(applied, expected) {
+		t.Errorf("Expected %v, got %v", expected, applied)
+	}
+	if start[0].Offset != 900 {
+		t.Error("Expected the start offsets to be left as is")
+	}
+
+	if _, err := applyOffsetFor("foo", start, oldest, newest, map[int32]int64{4: 10}); err == nil {
+		t.Error("Expected an error for a partition the topic does not have")
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/Shopify/sarama"
+	"github.com/jurriaan/kafkatools"
+)
+
+// dumpOffsets prints the newest offsets of the partitions of the topics as a JSON array, consume --from-file starts
+// at them
+func dumpOffsets(client sarama.Client, parsedOptions options) {
+	offsets := make(topicOffsetMap, len(parsedOptions.topics))
+	for _, topic := range parsedOptions.topics {
+		offsets[topic] = fetchTopicOffsets(client, sarama.OffsetNewest, topic)
+	}
+
+	if err := writeOffsetsJSON(os.Stdout, offsets); err != nil {
+		log.Fatal("Could not write the offsets: ", err)
+	}
+}
+
+// writeOffsetsJSON writes the offsets as a JSON array sorted by topic and partition
+func writeOffsetsJSON(out io.Writer, offsets topicOffsetMap) error {
+	converted := make(map[string]map[int32]kafkatools.TopicPartitionOffset, len(offsets))
+	for topic, topicOffsets := range offsets {
+		converted[topic] = topicOffsets
+	}
+
+	document, err := kafkatools.MarshalOffsets(converted)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(out, string(document))
+	return err
+}
+
+// readOffsetsFile reads the start offsets of --from-file, a JSON array written by kt offsets
+func readOffsetsFile(path string, topics []string) (topicOffsetMap, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseOffsets(data, topics)
+}
+
+// parseOffsets decodes the offsets, every topic has to be one of the topics and have an offset of at least one
+// partition
+func parseOffsets(data []byte, topics []string) (topicOffsetMap, error) {
+	decoded, err := kafkatools.UnmarshalOffsets(data)
+	if err != nil {
+		return nil, err
+	}
+
+	consumed := make(map[string]bool, len(topics))
+	for _, topic := range topics {
+		consumed[topic] = true
+	}
+	offsets := make(topicOffsetMap, len(decoded))
+	for topic, topicOffsets := range decoded {
+		if !consumed[topic] {
+			return nil, fmt.Errorf("topic %s is not consumed", topic)
+		}
+		for _, offset := range topicOffsets {
+			if offset.Offset < 0 {
+				return nil, fmt.Errorf("invalid offset %d of %s partition %d", offset.Offset, topic, offset.Partition)
+			}
+		}
+		offsets[topic] = topicOffsets
+	}
+
+	for _, topic := range topics {
+		if len(offsets[topic]) == 0 {
+			return nil, fmt.Errorf("no offsets of %s listed", topic)
+		}
+	}
+	return offsets, nil
+}
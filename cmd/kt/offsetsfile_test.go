@@ -0,0 +1,50 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/jurriaan/kafkatools"
+)
+
+func TestOffsetsRoundTrip(t *testing.T) {
+	offsets := topicOffsetMap{
+		"foo": {
+			0: kafkatools.TopicPartitionOffset{Topic: "foo", Partition: 0, Offset: 42},
+			1: kafkatools.TopicPartitionOffset{Topic: "foo", Partition: 1, Offset: 7},
+		},
+		"bar": {0: kafkatools.TopicPartitionOffset{Topic: "bar", Partition: 0, Offset: 0}},
+	}
+
+	var out bytes.Buffer
+	if err := writeOffsetsJSON(&out, offsets); err != nil {
+		t.Fatal("Unexpected error: ", err)
+	}
+	if !strings.HasPrefix(out.String(), "[\n  {\n    \"topic\": \"bar\"") {
+		t.Errorf("Expected the offsets sorted by topic, got %s", out.String())
+	}
+
+	parsed, err := parseOffsets(out.Bytes(), []string{"foo", "bar"})
+	if err != nil {
+		t.Fatal("Unexpected error: ", err)
+	}
+	if !reflect.DeepEqual(parsed, offsets) {
+		t.Errorf("Expected %v, got %v", offsets, parsed)
+	}
+}
+
+func TestParseOffsetsErrors(t *testing.T) {
+	for input, expected := range map[string]string{
+		`{}`: "could not decode offsets",
+		`[{"topic": "bar", "partition": 0, "offset": 1}]`:                                                "topic bar is not consumed",
+		`[{"topic": "foo", "partition": 0, "offset": -1}]`:                                               "invalid offset -1 of foo partition 0",
+		`[{"topic": "foo", "partition": 0, "offset": 1}, {"topic": "foo", "partition": 0, "offset": 2}]`: "duplicate offset for partition 0 of topic foo",
+		`[]`: "no offsets of foo listed",
+	} {
+		if _, err := parseOffsets([]byte(input), []string{"foo"}); err == nil || !strings.Contains(err.Error(), expected) {
+			t.Errorf("Expected an error containing %q for %s, got %v", expected, input, err)
+		}
+	}
+}
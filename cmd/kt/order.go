@@ -0,0 +1,73 @@
+package main
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/jurriaan/kafkatools"
+)
+
+// orderVerifier checks that the offsets of the consumed messages of every partition are strictly increasing and,
+// unless ignoreTimestamps is set, that their timestamps don't decrease. Messages without a timestamp (pre 0.10
+// message format) are not compared by timestamp.
+type orderVerifier struct {
+	mutex            sync.Mutex
+	ignoreTimestamps bool
+	last             map[topicPartition]orderPosition
+	verified         int64
+	anomalies        int64
+}
+
+// orderPosition is the offset and timestamp of the last message of a partition
+type orderPosition struct {
+	offset    int64
+	timestamp time.Time
+}
+
+func newOrderVerifier(ignoreTimestamps bool) *orderVerifier {
+	return &orderVerifier{ignoreTimestamps: ignoreTimestamps, last: make(map[topicPartition]orderPosition)}
+}
+
+// check compares the message with the previous message of its partition and logs the anomalies, it is a no-op on a
+// nil verifier
+func (v *orderVerifier) check(msg *sarama.ConsumerMessage) {
+	if v == nil {
+		return
+	}
+
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	v.verified++
+	partition := topicPartition{Topic: msg.Topic, Partition: msg.Partition}
+	previous, ok := v.last[partition]
+	v.last[partition] = orderPosition{offset: msg.Offset, timestamp: msg.Timestamp}
+	if !ok {
+		return
+	}
+
+	if msg.Offset <= previous.offset {
+		v.anomalies++
+		kafkatools.Log.Warnf("Order anomaly in %s partition %d: offset %d after offset %d", msg.Topic, msg.Partition, msg.Offset, previous.offset)
+	}
+	if !v.ignoreTimestamps && !msg.Timestamp.IsZero() && !previous.timestamp.IsZero() && msg.Timestamp.Before(previous.timestamp) {
+		v.anomalies++
+		kafkatools.Log.Warnf("Order anomaly in %s partition %d: timestamp %s of offset %d is before timestamp %s of offset %d", msg.Topic, msg.Partition,
+			msg.Timestamp.Format(time.RFC3339Nano), msg.Offset, previous.timestamp.Format(time.RFC3339Nano), previous.offset)
+	}
+}
+
+// report logs the number of verified messages and fails when there were anomalies, it is a no-op on a nil verifier
+func (v *orderVerifier) report() {
+	if v == nil {
+		return
+	}
+
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	if v.anomalies > 0 {
+		log.Fatalf("Found %d order anomalies in %d messages of %d partitions", v.anomalies, v.verified, len(v.last))
+	}
+	kafkatools.Log.Infof("Verified the order of %d messages of %d partitions", v.verified, len(v.last))
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+)
+
+func TestOrderVerifier(t *testing.T) {
+	at := func(seconds int64) time.Time { return time.Unix(1500000000+seconds, 0) }
+	messages := []*sarama.ConsumerMessage{
+		{Topic: "foo", Partition: 0, Offset: 1, Timestamp: at(1)},
+		{Topic: "foo", Partition: 1, Offset: 1, Timestamp: at(5)},
+		{Topic: "foo", Partition: 0, Offset: 3, Timestamp: at(1)},
+		// A timestamp going back
+		{Topic: "foo", Partition: 0, Offset: 4, Timestamp: at(0)},
+		// An offset going back, without a timestamp
+		{Topic: "foo", Partition: 0, Offset: 2},
+		{Topic: "foo", Partition: 1, Offset: 2, Timestamp: at(6)},
+	}
+
+	verifier := newOrderVerifier(false)
+	for _, msg := range messages {
+		verifier.check(msg)
+	}
+	if verifier.verified != 6 || verifier.anomalies != 2 || len(verifier.last) != 2 {
+		t.Errorf("Expected 2 anomalies in 6 messages of 2 partitions, got %d in %d of %d", verifier.anomalies, verifier.verified, len(verifier.last))
+	}
+
+	verifier = newOrderVerifier(true)
+	for _, msg := range messages {
+		verifier.check(msg)
+	}
+	if verifier.anomalies != 1 {
+		t.Errorf("Expected only the offset anomaly when ignoring timestamps, got %d anomalies", verifier.anomalies)
+	}
+
+	var nilVerifier *orderVerifier
+	nilVerifier.check(messages[0])
+	nilVerifier.report()
+}
@@ -0,0 +1,396 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+	"strings"
+	"text/template"
+	"unicode/utf8"
+
+	"github.com/Shopify/sarama"
+	"github.com/jurriaan/kafkatools"
+)
+
+// messageFormatter formats a message into a single line of output
+type messageFormatter func(msg *sarama.ConsumerMessage) []byte
+
+// messageRecord is the JSON representation of a message, key and value are null for missing keys and
+// tombstones
+type messageRecord struct {
+	Topic     string            `json:"topic"`
+	Partition int32             `json:"partition"`
+	Offset    int64             `json:"offset"`
+	Timestamp formattedTime     `json:"timestamp"`
+	Key       *string           `json:"key"`
+	Value     *string           `json:"value"`
+	Headers   map[string]string `json:"headers,omitempty"`
+	Broker    *partitionLeader  `json:"broker,omitempty"`
+	Size      *int              `json:"size,omitempty"`
+	Lag       *int64            `json:"lag,omitempty"`
+	// Number and PartitionNumber are the sequence numbers of the message among the printed messages and within its
+	// partition
+	Number          *int64         `json:"number,omitempty"`
+	PartitionNumber *int64         `json:"partition_number,omitempty"`
+	Batch           *batchMetadata `json:"batch,omitempty"`
+	// KeyEncoding is base64 for the keys which are not valid UTF-8, those are printed base64 encoded, or the --encode
+	// encoding of all keys
+	KeyEncoding *string `json:"key_encoding,omitempty"`
+	// ValueEncoding is base64 for the (decoded) values which are not valid UTF-8, those are printed base64 encoded, or
+	// the --encode encoding of all values
+	ValueEncoding *string `json:"value_encoding,omitempty"`
+	// HeaderEncodings are base64 for the headers whose values are not valid UTF-8, those are printed base64 encoded, or
+	// the --encode encoding of all headers
+	HeaderEncodings map[string]string `json:"header_encodings,omitempty"`
+}
+
+// outputOptions contains the settings of the message output
+type outputOptions struct {
+	format      string
+	labelTopics bool
+	printBroker bool
+	// keysOnly prints the message keys instead of the values (raw format only)
+	keysOnly  bool
+	printSize bool
+	// printHeaders prints the headers of every message with headers on a line before it (raw format only)
+	printHeaders bool
+	// selectFields projects JSON values onto these (dotted) fields
+	selectFields []string
+	// printBatchMeta adds the record batch of every message (ndjson format only), looked up in batches
+	printBatchMeta bool
+	batches        *batchIndex
+	// timestamps renders the message timestamps, in the default JSON encoding when nil
+	timestamps *timestampFormat
+	// invalidUTF8 is how the raw format prints values which are not valid UTF-8: keep, replace or base64
+	invalidUTF8 string
+	// encode is how the keys, values and header values are printed: raw (as is, empty is raw too), base64 or hex
+	encode string
+	// nullValue is printed instead of the (decoded) value of tombstones (raw format only)
+	nullValue string
+	// lags are the high-water mark lags of the messages to print, nil when they are not printed
+	lags *messageLags
+	// rekey replaces the keys of the messages before they are formatted, nil keeps them
+	rekey *keyTemplate
+	// keyRegistry decodes the Avro keys of the messages before they are formatted (and rekeyed), nil keeps them
+	keyRegistry *kafkatools.SchemaRegistry
+	// numbers numbers the printed messages, nil when they are not numbered
+	numbers *messageNumbers
+	// maxValueChars truncates the printed values to this number of characters, 0 prints them whole
+	maxValueChars int
+	// template renders the printed messages instead of printing their values (raw format only), nil prints the values
+	template *template.Template
+}
+
+// topicLeaders contains the partition leaders per topic
+type topicLeaders map[string]map[int32]partitionLeader
+
+// newMessageFormatter returns the formatter of the output format: raw prints the (decoded) value, or the key when
+// keysOnly is set, prefixed by the topic, the leader broker of the partition and the value size when requested. ndjson
+// prints one compact JSON object per message, binary writes the key and value as length-prefixed frames and parquet
+// returns the value of the row.
+func newMessageFormatter(outputOpts outputOptions, decoder *valueDecoder, leaders topicLeaders) messageFormatter {
+	decodedValue := decoder.decodeValue
+	if outputOpts.rekey != nil {
+		// The new key and the output are computed from the same decoded value
+		decodedValue = decodeOnce(decodedValue)
+	}
+
+	decodeValue := decodedValue
+	if len(outputOpts.selectFields) > 0 {
+		decodeValue = func(msg *sarama.ConsumerMessage) []byte {
+			value := decodedValue(msg)
+			if value == nil {
+				return nil
+			}
+
+			selected, err := selectFields(value, outputOpts.selectFields)
+			if err != nil {
+				log.Printf("Could not select the fields of the message at offset %d of %s partition %d, printing it as is: %v", msg.Offset, msg.Topic, msg.Partition, err)
+				return value
+			}
+			return selected
+		}
+	}
+
+	if outputOpts.maxValueChars > 0 {
+		untruncated := decodeValue
+		decodeValue = func(msg *sarama.ConsumerMessage) []byte {
+			return truncateValue(untruncated(msg), outputOpts.maxValueChars)
+		}
+	}
+
+	format := formatMessages(outputOpts, decodeValue, leaders)
+	if outputOpts.rekey != nil {
+		unkeyed := format
+		format = func(msg *sarama.ConsumerMessage) []byte {
+			rekeyMessage(msg, outputOpts.rekey, decodedValue(msg))
+			return unkeyed(msg)
+		}
+	}
+	if outputOpts.keyRegistry == nil {
+		return format
+	}
+	return func(msg *sarama.ConsumerMessage) []byte {
+		decodeMessageKey(msg, outputOpts.keyRegistry)
+		return format(msg)
+	}
+}
+
+// formatMessages returns the formatter of the output format for the decoded values
+func formatMessages(outputOpts outputOptions, decodeValue func(*sarama.ConsumerMessage) []byte, leaders topicLeaders) messageFormatter {
+	switch outputOpts.format {
+	case "raw":
+		printed := decodeValue
+		if outputOpts.keysOnly {
+			printed = func(msg *sarama.ConsumerMessage) []byte { return msg.Key }
+		}
+		if outputOpts.template != nil {
+			printed = renderTemplate(outputOpts.template, decodeValue)
+		}
+		if isEncoded(outputOpts.encode) {
+			unencoded := printed
+			printed = func(msg *sarama.ConsumerMessage) []byte {
+				value := unencoded(msg)
+				if value == nil {
+					return nil
+				}
+				return []byte(encodeBytes(value, outputOpts.encode))
+			}
+		} else if outputOpts.invalidUTF8 != "" && outputOpts.invalidUTF8 != "keep" {
+			unsafe := printed
+			printed = func(msg *sarama.ConsumerMessage) []byte {
+				return sanitizeUTF8(unsafe(msg), outputOpts.invalidUTF8)
+			}
+		}
+		if outputOpts.nullValue != "" && !outputOpts.keysOnly && outputOpts.template == nil {
+			nullable := printed
+			printed = func(msg *sarama.ConsumerMessage) []byte {
+				if value := nullable(msg); value != nil {
+					return value
+				}
+				return []byte(outputOpts.nullValue)
+			}
+		}
+
+		return func(msg *sarama.ConsumerMessage) []byte {
+			var prefix string
+			if outputOpts.printHeaders && len(msg.Headers) > 0 {
+				prefix = formatHeaders(msg.Headers, outputOpts.encode) + "\n"
+			}
+			if outputOpts.numbers != nil {
+				prefix += outputOpts.numbers.prefix(msg)
+			}
+			if outputOpts.labelTopics {
+				prefix += msg.Topic + "\t"
+			}
+			if outputOpts.printBroker {
+				prefix += formatLeader(leaders, msg) + "\t"
+			}
+			if outputOpts.printSize {
+				prefix += strconv.Itoa(len(msg.Value)) + "\t"
+			}
+			if outputOpts.lags != nil {
+				prefix += formatMessageLag(outputOpts.lags, msg) + "\t"
+			}
+			if prefix == "" {
+				return printed(msg)
+			}
+			return append([]byte(prefix), printed(msg)...)
+		}
+	case "binary":
+		return func(msg *sarama.ConsumerMessage) []byte {
+			return appendRecordFrames(nil, msg.Key, decodeValue(msg))
+		}
+	case "parquet":
+		// The parquet writer stores the formatted value in the value column
+		return decodeValue
+	case "ndjson":
+		return func(msg *sarama.ConsumerMessage) []byte {
+			record := newMessageRecord(msg, decodeValue, outputOpts.encode)
+			record.Timestamp = outputOpts.timestamps.jsonTime(msg.Timestamp)
+			if leader, ok := leaders[msg.Topic][msg.Partition]; ok && outputOpts.printBroker {
+				record.Broker = &leader
+			}
+			if outputOpts.printSize {
+				size := len(msg.Value)
+				record.Size = &size
+			}
+			if outputOpts.batches != nil {
+				record.Batch = outputOpts.batches.lookup(msg)
+			}
+			if outputOpts.lags != nil {
+				if lag, ok := outputOpts.lags.take(msg); ok {
+					record.Lag = &lag
+				}
+			}
+			if outputOpts.numbers != nil {
+				number, partitionNumber := outputOpts.numbers.next(msg)
+				record.Number = &number
+				if outputOpts.numbers.perPartition {
+					record.PartitionNumber = &partitionNumber
+				}
+			}
+
+			line, err := json.Marshal(record)
+			if err != nil {
+				log.Fatalf("Could not encode message at offset %d of partition %d: %v", msg.Offset, msg.Partition, err)
+			}
+			return line
+		}
+	default:
+		log.Fatalf("Invalid output format specified: %s", outputOpts.format)
+		return nil
+	}
+}
+
+// writeMessage writes a formatted message, the text formats end every message with a newline while binary frames are
+// written as is
+func writeMessage(out io.Writer, format string, formatted []byte) {
+	if err := writeFormatted(out, format, formatted); err != nil {
+		log.Fatal("Could not write the message: ", err)
+	}
+}
+
+func writeFormatted(out io.Writer, format string, formatted []byte) error {
+	if format != "binary" {
+		formatted = append(formatted, '\n')
+	}
+	_, err := out.Write(formatted)
+	return err
+}
+
+// closeOutputFile closes the file the messages were written to
+func closeOutputFile(file io.Closer) {
+	if err := file.Close(); err != nil {
+		log.Fatal("Could not close the output file: ", err)
+	}
+}
+
+// truncateValue cuts the value after max characters and appends a marker with its total number of characters, shorter
+// values and tombstones are returned as is
+func truncateValue(value []byte, max int) []byte {
+	if utf8.RuneCount(value) <= max {
+		return value
+	}
+
+	cut := 0
+	for i := 0; i < max; i++ {
+		_, size := utf8.DecodeRune(value[cut:])
+		cut += size
+	}
+	truncated := append([]byte{}, value[:cut]...)
+	return append(truncated, fmt.Sprintf("…(truncated, total %d)", utf8.RuneCount(value))...)
+}
+
+// sanitizeUTF8 replaces the invalid UTF-8 sequences of the text with U+FFFD, or base64 encodes the whole text when the
+// mode is base64. Valid text is returned as is.
+func sanitizeUTF8(text []byte, mode string) []byte {
+	if utf8.Valid(text) {
+		return text
+	}
+	if mode == "base64" {
+		return []byte(base64.StdEncoding.EncodeToString(text))
+	}
+	return bytes.ToValidUTF8(text, []byte(string(utf8.RuneError)))
+}
+
+// formatHeaders formats the headers as space separated key=value pairs, the values are encoded like encodeField
+// encodes them
+func formatHeaders(headers []*sarama.RecordHeader, encoding string) string {
+	formatted := make([]string, len(headers))
+	for i, header := range headers {
+		value, _ := encodeField(header.Value, encoding)
+		formatted[i] = string(header.Key) + "=" + value
+	}
+	return strings.Join(formatted, " ")
+}
+
+// isEncoded reports whether the --encode encoding prints the data encoded rather than as is
+func isEncoded(encoding string) bool {
+	return encoding != "" && encoding != "raw"
+}
+
+// encodeBytes returns the data in the --encode encoding: base64 (standard, padded), hex (lowercase) or raw
+func encodeBytes(data []byte, encoding string) string {
+	switch encoding {
+	case "base64":
+		return base64.StdEncoding.EncodeToString(data)
+	case "hex":
+		return hex.EncodeToString(data)
+	default:
+		return string(data)
+	}
+}
+
+// encodeField returns the data as text and the encoding of the text for the ndjson encoding fields, nil for data
+// printed as is. Raw data is printed as is unless it is not valid UTF-8, then it is base64 encoded.
+func encodeField(data []byte, encoding string) (string, *string) {
+	if !isEncoded(encoding) {
+		if utf8.Valid(data) {
+			return string(data), nil
+		}
+		encoding = "base64"
+	}
+	return encodeBytes(data, encoding), &encoding
+}
+
+// formatLeader formats the leader of the partition of the message as <id>@<address>, unknown leaders are printed as -
+func formatLeader(leaders topicLeaders, msg *sarama.ConsumerMessage) string {
+	leader, ok := leaders[msg.Topic][msg.Partition]
+	if !ok {
+		return "-"
+	}
+	return fmt.Sprintf("%d@%s", leader.ID, leader.Addr)
+}
+
+// formatMessageLag formats the high-water mark lag of the message, unknown lags are printed as -
+func formatMessageLag(lags *messageLags, msg *sarama.ConsumerMessage) string {
+	lag, ok := lags.take(msg)
+	if !ok {
+		return "-"
+	}
+	return strconv.FormatInt(lag, 10)
+}
+
+func newMessageRecord(msg *sarama.ConsumerMessage, decodeValue func(*sarama.ConsumerMessage) []byte, encoding string) messageRecord {
+	record := messageRecord{
+		Topic:     msg.Topic,
+		Partition: msg.Partition,
+		Offset:    msg.Offset,
+		Timestamp: formattedTime{Time: msg.Timestamp},
+	}
+
+	if msg.Key != nil {
+		key, keyEncoding := encodeField(msg.Key, encoding)
+		record.Key = &key
+		record.KeyEncoding = keyEncoding
+	}
+
+	// Decoders may turn tombstones into meaningful values
+	if value := decodeValue(msg); value != nil {
+		valueStr, valueEncoding := encodeField(value, encoding)
+		record.Value = &valueStr
+		record.ValueEncoding = valueEncoding
+	}
+
+	if len(msg.Headers) > 0 {
+		record.Headers = make(map[string]string, len(msg.Headers))
+		for _, header := range msg.Headers {
+			value, headerEncoding := encodeField(header.Value, encoding)
+			record.Headers[string(header.Key)] = value
+			if headerEncoding != nil {
+				if record.HeaderEncodings == nil {
+					record.HeaderEncodings = make(map[string]string)
+				}
+				record.HeaderEncodings[string(header.Key)] = *headerEncoding
+			}
+		}
+	}
+
+	return record
+}
@@ -0,0 +1,254 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+)
+
+func TestNdjsonFormatter(t *testing.T) {
+	formatter := newMessageFormatter(outputOptions{format: "ndjson"}, newValueDecoder(""), nil)
+
+	tests := []struct {
+		msg      *sarama.ConsumerMessage
+		expected string
+	}{
+		{
+			&sarama.ConsumerMessage{Topic: "foo", Partition: 1, Offset: 2, Timestamp: time.Unix(1500000000, 0).UTC(), Key: []byte("key"), Value: []byte("value\n"),
+				Headers: []*sarama.RecordHeader{{Key: []byte("h"), Value: []byte("v")}}},
+			`{"topic":"foo","partition":1,"offset":2,"timestamp":"2017-07-14T02:40:00Z","key":"key","value":"value\n","headers":{"h":"v"}}`,
+		},
+		{
+			&sarama.ConsumerMessage{Topic: "foo", Offset: 3, Timestamp: time.Unix(1500000000, 0).UTC()},
+			`{"topic":"foo","partition":0,"offset":3,"timestamp":"2017-07-14T02:40:00Z","key":null,"value":null}`,
+		},
+		{
+			&sarama.ConsumerMessage{Topic: "foo", Offset: 4, Timestamp: time.Unix(1500000000, 0).UTC(), Key: []byte{0xff, 0x00}, Value: []byte("v")},
+			`{"topic":"foo","partition":0,"offset":4,"timestamp":"2017-07-14T02:40:00Z","key":"/wA=","value":"v","key_encoding":"base64"}`,
+		},
+		{
+			&sarama.ConsumerMessage{Topic: "foo", Offset: 5, Timestamp: time.Unix(1500000000, 0).UTC(), Value: []byte("v"),
+				Headers: []*sarama.RecordHeader{{Key: []byte("h"), Value: []byte{0xff, 0x00}}, {Key: []byte("t"), Value: []byte("text")}}},
+			`{"topic":"foo","partition":0,"offset":5,"timestamp":"2017-07-14T02:40:00Z","key":null,"value":"v","headers":{"h":"/wA=","t":"text"},"header_encodings":{"h":"base64"}}`,
+		},
+		{
+			&sarama.ConsumerMessage{Topic: "foo", Offset: 6, Timestamp: time.Unix(1500000000, 0).UTC(), Value: []byte{0x00, 0xff, 0xfe}},
+			`{"topic":"foo","partition":0,"offset":6,"timestamp":"2017-07-14T02:40:00Z","key":null,"value":"AP/+","value_encoding":"base64"}`,
+		},
+	}
+
+	for _, test := range tests {
+		if line := string(formatter(test.msg)); line != test.expected {
+			t.Errorf("Expected %s, got %s", test.expected, line)
+		}
+	}
+}
+
+func TestRawFormatter(t *testing.T) {
+	msg := &sarama.ConsumerMessage{Topic: "foo", Value: []byte("value")}
+
+	if value := string(newMessageFormatter(outputOptions{format: "raw"}, newValueDecoder(""), nil)(msg)); value != "value" {
+		t.Errorf("Expected the raw value, got %q", value)
+	}
+	if value := string(newMessageFormatter(outputOptions{format: "raw", labelTopics: true}, newValueDecoder(""), nil)(msg)); value != "foo\tvalue" {
+		t.Errorf("Expected the value labelled with its topic, got %q", value)
+	}
+
+	if value := string(newMessageFormatter(outputOptions{format: "raw", printSize: true}, newValueDecoder(""), nil)(msg)); value != "5\tvalue" {
+		t.Errorf("Expected the value prefixed by its size, got %q", value)
+	}
+
+	msg.Key = []byte("key")
+	if key := string(newMessageFormatter(outputOptions{format: "raw", keysOnly: true}, newValueDecoder(""), nil)(msg)); key != "key" {
+		t.Errorf("Expected the key, got %q", key)
+	}
+
+	if value := string(newMessageFormatter(outputOptions{format: "raw", printHeaders: true}, newValueDecoder(""), nil)(msg)); value != "value" {
+		t.Errorf("Expected only the value of a message without headers, got %q", value)
+	}
+	msg.Headers = []*sarama.RecordHeader{{Key: []byte("content-type"), Value: []byte("text/plain")}, {Key: []byte("trace-id"), Value: []byte{0xff, 0x00}}}
+	if value := string(newMessageFormatter(outputOptions{format: "raw", printHeaders: true, printSize: true}, newValueDecoder(""), nil)(msg)); value != "content-type=text/plain trace-id=/wA=\n5\tvalue" {
+		t.Errorf("Expected the headers on a line before the value, got %q", value)
+	}
+}
+
+func TestPrintBroker(t *testing.T) {
+	leaders := topicLeaders{"foo": {0: {ID: 2, Addr: "b:9092"}}}
+	outputOpts := outputOptions{printBroker: true}
+
+	tests := []struct {
+		format   string
+		msg      *sarama.ConsumerMessage
+		expected string
+	}{
+		{"raw", &sarama.ConsumerMessage{Topic: "foo", Value: []byte("value")}, "2@b:9092\tvalue"},
+		{"raw", &sarama.ConsumerMessage{Topic: "foo", Partition: 1, Value: []byte("value")}, "-\tvalue"},
+		{"ndjson", &sarama.ConsumerMessage{Topic: "foo", Timestamp: time.Unix(1500000000, 0).UTC(), Value: []byte("value")},
+			`{"topic":"foo","partition":0,"offset":0,"timestamp":"2017-07-14T02:40:00Z","key":null,"value":"value","broker":{"id":2,"addr":"b:9092"}}`},
+	}
+
+	for _, test := range tests {
+		outputOpts.format = test.format
+		if line := string(newMessageFormatter(outputOpts, newValueDecoder(""), leaders)(test.msg)); line != test.expected {
+			t.Errorf("Expected %s, got %s", test.expected, line)
+		}
+	}
+}
+
+func TestSelectFieldsFormatter(t *testing.T) {
+	formatter := newMessageFormatter(outputOptions{format: "raw", selectFields: []string{"a"}}, newValueDecoder(""), nil)
+
+	if value := string(formatter(&sarama.ConsumerMessage{Value: []byte(`{"a":1,"b":2}`)})); value != `{"a":1}` {
+		t.Errorf("Expected the selected fields, got %q", value)
+	}
+	if value := string(formatter(&sarama.ConsumerMessage{Value: []byte("plain")})); value != "plain" {
+		t.Errorf("Expected a non-JSON value to be printed as is, got %q", value)
+	}
+}
+
+func TestInvalidUTF8(t *testing.T) {
+	msg := &sarama.ConsumerMessage{Value: []byte("a\xffb")}
+
+	tests := map[string]string{
+		"keep":    "a\xffb",
+		"replace": "a�b",
+		"base64":  "Yf9i",
+	}
+	for mode, expected := range tests {
+		formatter := newMessageFormatter(outputOptions{format: "raw", invalidUTF8: mode}, newValueDecoder(""), nil)
+		if value := string(formatter(msg)); value != expected {
+			t.Errorf("Expected %q with %s, got %q", expected, mode, value)
+		}
+	}
+
+	valid := &sarama.ConsumerMessage{Value: []byte("héllo")}
+	if value := string(newMessageFormatter(outputOptions{format: "raw", invalidUTF8: "base64"}, newValueDecoder(""), nil)(valid)); value != "héllo" {
+		t.Errorf("Expected a valid value to be printed as is, got %q", value)
+	}
+}
+
+func TestEncode(t *testing.T) {
+	msg := &sarama.ConsumerMessage{Topic: "foo", Partition: 1, Offset: 2, Timestamp: time.Unix(1500000000, 0).UTC(), Key: []byte{0x00, 0xff},
+		Value: []byte{0x1b, 0x5b, 0xff, 0xfe, 'a'}, Headers: []*sarama.RecordHeader{{Key: []byte("h"), Value: []byte{0x80}}}}
+
+	tests := []struct {
+		outputOpts outputOptions
+		expected   string
+	}{
+		{outputOptions{format: "raw", encode: "raw"}, "\x1b[\xff\xfea"},
+		{outputOptions{format: "raw", encode: "base64"}, "G1v//mE="},
+		{outputOptions{format: "raw", encode: "hex"}, "1b5bfffe61"},
+		{outputOptions{format: "raw", encode: "hex", keysOnly: true}, "00ff"},
+		{outputOptions{format: "raw", encode: "hex", printHeaders: true}, "h=80\n1b5bfffe61"},
+		{outputOptions{format: "raw", encode: "base64", invalidUTF8: "replace"}, "G1v//mE="},
+		{outputOptions{format: "ndjson", encode: "hex"}, `{"topic":"foo","partition":1,"offset":2,"timestamp":"2017-07-14T02:40:00Z","key":"00ff","value":"1b5bfffe61","headers":{"h":"80"},"key_encoding":"hex","value_encoding":"hex","header_encodings":{"h":"hex"}}`},
+		{outputOptions{format: "ndjson", encode: "base64"}, `{"topic":"foo","partition":1,"offset":2,"timestamp":"2017-07-14T02:40:00Z","key":"AP8=","value":"G1v//mE=","headers":{"h":"gA=="},"key_encoding":"base64","value_encoding":"base64","header_encodings":{"h":"base64"}}`},
+	}
+	for _, test := range tests {
+		formatter := newMessageFormatter(test.outputOpts, newValueDecoder(""), nil)
+		// The same bytes are always encoded the same way
+		for i := 0; i < 2; i++ {
+			if value := string(formatter(msg)); value != test.expected {
+				t.Errorf("Expected %q with %+v, got %q", test.expected, test.outputOpts, value)
+			}
+		}
+	}
+
+	// Encoded text values are marked as encoded too, so they can be read back
+	text := &sarama.ConsumerMessage{Topic: "foo", Timestamp: time.Unix(1500000000, 0).UTC(), Value: []byte("hi")}
+	formatter := newMessageFormatter(outputOptions{format: "ndjson", encode: "hex"}, newValueDecoder(""), nil)
+	record, err := parseNDJSONRecord(formatter(text))
+	if err != nil {
+		t.Fatal("Unexpected error: ", err)
+	}
+	if string(record.value) != "hi" || record.key != nil {
+		t.Errorf("Expected the hex encoded value to be read back, got %+v", record)
+	}
+}
+
+func TestNullValue(t *testing.T) {
+	tombstone := &sarama.ConsumerMessage{Topic: "foo", Timestamp: time.Unix(1500000000, 0).UTC()}
+	empty := &sarama.ConsumerMessage{Topic: "foo", Timestamp: time.Unix(1500000000, 0).UTC(), Value: []byte{}}
+
+	raw := newMessageFormatter(outputOptions{format: "raw", nullValue: "<null>"}, newValueDecoder(""), nil)
+	if value := string(raw(tombstone)); value != "<null>" {
+		t.Errorf("Expected the sentinel for a tombstone, got %q", value)
+	}
+	if value := string(raw(empty)); value != "" {
+		t.Errorf("Expected an empty value to be printed empty, got %q", value)
+	}
+
+	encoded := newMessageFormatter(outputOptions{format: "raw", nullValue: "<null>", encode: "hex"}, newValueDecoder(""), nil)
+	if value := string(encoded(tombstone)); value != "<null>" {
+		t.Errorf("Expected the sentinel for an encoded tombstone, got %q", value)
+	}
+
+	ndjson := newMessageFormatter(outputOptions{format: "ndjson", nullValue: "<null>"}, newValueDecoder(""), nil)
+	if value := string(ndjson(tombstone)); value != `{"topic":"foo","partition":0,"offset":0,"timestamp":"2017-07-14T02:40:00Z","key":null,"value":null}` {
+		t.Errorf("Expected a JSON null for a tombstone, got %s", value)
+	}
+	if value := string(ndjson(empty)); value != `{"topic":"foo","partition":0,"offset":0,"timestamp":"2017-07-14T02:40:00Z","key":null,"value":""}` {
+		t.Errorf("Expected an empty string for an empty value, got %s", value)
+	}
+}
+
+func TestPrintLag(t *testing.T) {
+	lags := newMessageLags()
+	msg := &sarama.ConsumerMessage{Topic: "foo", Offset: 7, Timestamp: time.Unix(1500000000, 0).UTC(), Value: []byte("value")}
+
+	lags.record(fixedHighWaterMark(10), msg)
+	if line := string(newMessageFormatter(outputOptions{format: "raw", lags: lags}, newValueDecoder(""), nil)(msg)); line != "3\tvalue" {
+		t.Errorf("Expected the lag prefix, got %q", line)
+	}
+
+	lags.record(fixedHighWaterMark(8), msg)
+	expected := `{"topic":"foo","partition":0,"offset":7,"timestamp":"2017-07-14T02:40:00Z","key":null,"value":"value","lag":1}`
+	if line := string(newMessageFormatter(outputOptions{format: "ndjson", lags: lags}, newValueDecoder(""), nil)(msg)); line != expected {
+		t.Errorf("Expected %s, got %s", expected, line)
+	}
+}
+
+func TestMaxValueChars(t *testing.T) {
+	msg := &sarama.ConsumerMessage{Topic: "foo", Offset: 7, Timestamp: time.Unix(1500000000, 0).UTC(), Value: []byte("héllo world")}
+
+	if line := string(newMessageFormatter(outputOptions{format: "raw", maxValueChars: 5}, newValueDecoder(""), nil)(msg)); line != "héllo…(truncated, total 11)" {
+		t.Errorf("Expected the value truncated after 5 characters, got %q", line)
+	}
+	expected := `{"topic":"foo","partition":0,"offset":7,"timestamp":"2017-07-14T02:40:00Z","key":null,"value":"hé…(truncated, total 11)"}`
+	if line := string(newMessageFormatter(outputOptions{format: "ndjson", maxValueChars: 2}, newValueDecoder(""), nil)(msg)); line != expected {
+		t.Errorf("Expected %s, got %s", expected, line)
+	}
+
+	if value := truncateValue([]byte("short"), 5); string(value) != "short" {
+		t.Errorf("Expected a short value as is, got %q", value)
+	}
+	if value := truncateValue(nil, 5); value != nil {
+		t.Errorf("Expected a tombstone to stay nil, got %q", value)
+	}
+}
+
+func TestNumberMessages(t *testing.T) {
+	messages := []*sarama.ConsumerMessage{
+		{Topic: "foo", Partition: 0, Value: []byte("a")},
+		{Topic: "foo", Partition: 1, Value: []byte("b")},
+		{Topic: "foo", Partition: 0, Value: []byte("c")},
+	}
+
+	format := newMessageFormatter(outputOptions{format: "raw", numbers: newMessageNumbers(true)}, newValueDecoder(""), nil)
+	var lines []string
+	for _, msg := range messages {
+		lines = append(lines, string(format(msg)))
+	}
+	if expected := []string{"1\t1\ta", "2\t1\tb", "3\t2\tc"}; !reflect.DeepEqual(lines, expected) {
+		t.Errorf("Expected %q, got %q", expected, lines)
+	}
+
+	format = newMessageFormatter(outputOptions{format: "ndjson", numbers: newMessageNumbers(false)}, newValueDecoder(""), nil)
+	format(messages[0])
+	expected := `{"topic":"foo","partition":1,"offset":0,"timestamp":"0001-01-01T00:00:00Z","key":null,"value":"b","number":2}`
+	if line := string(format(messages[1])); line != expected {
+		t.Errorf("Expected %s, got %s", expected, line)
+	}
+}
@@ -0,0 +1,372 @@
+package main
+
+import (
+	"encoding/binary"
+	"io"
+	"log"
+
+	"github.com/Shopify/sarama"
+	"github.com/jurriaan/kafkatools"
+)
+
+// parquetRowGroupSize is the number of messages buffered before they are written as a row group
+const parquetRowGroupSize = 10000
+
+// The physical types, repetitions, converted types, encodings and page types of the parquet format
+const (
+	parquetInt32     = 1
+	parquetInt64     = 2
+	parquetByteArray = 6
+
+	parquetRequired = 0
+	parquetOptional = 1
+
+	parquetNoConversion    = -1
+	parquetUTF8            = 0
+	parquetTimestampMillis = 9
+
+	parquetPlain = 0
+	parquetRLE   = 3
+
+	parquetDataPage = 0
+)
+
+// parquetMagic starts and ends every parquet file
+var parquetMagic = []byte("PAR1")
+
+// parquetColumn buffers the values of a column for the next row group
+type parquetColumn struct {
+	name          string
+	physicalType  int32
+	optional      bool
+	convertedType int32
+	// values are the plain encoded non-null values, defined tells which rows of an optional column are not null
+	values  []byte
+	defined []bool
+}
+
+// parquetColumnChunk is the location of a column in a row group
+type parquetColumnChunk struct {
+	offset, size, values int64
+}
+
+type parquetRowGroup struct {
+	chunks []parquetColumnChunk
+	rows   int64
+}
+
+// parquetWriter writes messages as the rows of an uncompressed parquet file with the topic, partition, offset,
+// timestamp, key and value columns. Timestamps are null for messages without one (pre 0.10 message format), keys and
+// values for missing keys and tombstones. The values are UTF8 strings when they are decoded, raw bytes otherwise.
+type parquetWriter struct {
+	out     io.Writer
+	written int64
+	columns []*parquetColumn
+	// rows is the number of rows written, buffered the number of them not written as a row group yet
+	rows, buffered int64
+	rowGroups      []parquetRowGroup
+}
+
+func newParquetWriter(out io.Writer, decodedValues bool) (*parquetWriter, error) {
+	valueConversion := int32(parquetNoConversion)
+	if decodedValues {
+		valueConversion = parquetUTF8
+	}
+
+	w := &parquetWriter{out: out, columns: []*parquetColumn{
+		{name: "topic", physicalType: parquetByteArray, convertedType: parquetUTF8},
+		{name: "partition", physicalType: parquetInt32, convertedType: parquetNoConversion},
+		{name: "offset", physicalType: parquetInt64, convertedType: parquetNoConversion},
+		{name: "timestamp", physicalType: parquetInt64, optional: true, convertedType: parquetTimestampMillis},
+		{name: "key", physicalType: parquetByteArray, optional: true, convertedType: parquetNoConversion},
+		{name: "value", physicalType: parquetByteArray, optional: true, convertedType: valueConversion},
+	}}
+	return w, w.write(parquetMagic)
+}
+
+// writeParquet writes the messages to the output as a parquet file, the formatter returns the values to store
+func writeParquet(messages <-chan *sarama.ConsumerMessage, parsedOptions options, out io.Writer, formatter messageFormatter, positions resumePositions) {
+	decoded := parsedOptions.decoder != nil && (parsedOptions.decoder.decode != nil || parsedOptions.decoder.unwrap != nil)
+	writer, err := newParquetWriter(out, decoded)
+	if err != nil {
+		log.Fatal("Could not write the parquet file: ", err)
+	}
+
+	printMessages(messages, parsedOptions.count, func(msg *sarama.ConsumerMessage) {
+		if err := writer.writeMessage(msg, formatter(msg)); err != nil {
+			log.Fatal("Could not write the parquet file: ", err)
+		}
+		positions.printed(msg)
+	})
+	if err := writer.close(); err != nil {
+		log.Fatal("Could not write the parquet file: ", err)
+	}
+	kafkatools.Log.Infof("Wrote %d messages to the parquet file", writer.rows)
+}
+
+// writeMessage adds the message as a row, value is the (decoded) value to store
+func (w *parquetWriter) writeMessage(msg *sarama.ConsumerMessage, value []byte) error {
+	topic, partition, offset, timestamp, key, valueColumn := w.columns[0], w.columns[1], w.columns[2], w.columns[3], w.columns[4], w.columns[5]
+	topic.appendBytes([]byte(msg.Topic))
+	partition.values = binary.LittleEndian.AppendUint32(partition.values, uint32(msg.Partition))
+	offset.values = binary.LittleEndian.AppendUint64(offset.values, uint64(msg.Offset))
+
+	timestamp.defined = append(timestamp.defined, !msg.Timestamp.IsZero())
+	if !msg.Timestamp.IsZero() {
+		timestamp.values = binary.LittleEndian.AppendUint64(timestamp.values, uint64(msg.Timestamp.UnixMilli()))
+	}
+	key.appendOptionalBytes(msg.Key)
+	valueColumn.appendOptionalBytes(value)
+
+	w.rows++
+	w.buffered++
+	if w.buffered >= parquetRowGroupSize {
+		return w.flushRowGroup()
+	}
+	return nil
+}
+
+// close writes the buffered rows and the footer, it does not close the output
+func (w *parquetWriter) close() error {
+	if err := w.flushRowGroup(); err != nil {
+		return err
+	}
+
+	footer := w.fileMetaData()
+	length := binary.LittleEndian.AppendUint32(nil, uint32(len(footer)))
+	for _, data := range [][]byte{footer, length, parquetMagic} {
+		if err := w.write(data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *parquetWriter) write(data []byte) error {
+	n, err := w.out.Write(data)
+	w.written += int64(n)
+	return err
+}
+
+// flushRowGroup writes the buffered rows as a row group with a single data page per column
+func (w *parquetWriter) flushRowGroup() error {
+	rows := w.buffered
+	if rows == 0 {
+		return nil
+	}
+
+	rowGroup := parquetRowGroup{rows: rows}
+	for _, column := range w.columns {
+		page := column.values
+		if column.optional {
+			levels := encodeParquetLevels(column.defined)
+			page = append(binary.LittleEndian.AppendUint32(nil, uint32(len(levels))), levels...)
+			page = append(page, column.values...)
+		}
+
+		var header thriftWriter
+		header.i32(1, parquetDataPage)
+		header.i32(2, int32(len(page)))
+		header.i32(3, int32(len(page)))
+		header.beginStruct(5)
+		header.i32(1, int32(rows))
+		header.i32(2, parquetPlain)
+		header.i32(3, parquetRLE)
+		header.i32(4, parquetRLE)
+		header.endStruct()
+		header.endStruct()
+
+		chunk := parquetColumnChunk{offset: w.written, size: int64(len(header.buf) + len(page)), values: rows}
+		if err := w.write(header.buf); err != nil {
+			return err
+		}
+		if err := w.write(page); err != nil {
+			return err
+		}
+		rowGroup.chunks = append(rowGroup.chunks, chunk)
+
+		column.values, column.defined = column.values[:0], column.defined[:0]
+	}
+	w.rowGroups = append(w.rowGroups, rowGroup)
+	w.buffered = 0
+	return nil
+}
+
+// fileMetaData encodes the footer with the schema and the locations of the row groups
+func (w *parquetWriter) fileMetaData() []byte {
+	var meta thriftWriter
+	meta.i32(1, 1)
+
+	meta.beginList(2, thriftStruct, len(w.columns)+1)
+	meta.beginElement()
+	meta.binary(4, []byte("schema"))
+	meta.i32(5, int32(len(w.columns)))
+	meta.endStruct()
+	for _, column := range w.columns {
+		repetition := int32(parquetRequired)
+		if column.optional {
+			repetition = parquetOptional
+		}
+
+		meta.beginElement()
+		meta.i32(1, column.physicalType)
+		meta.i32(3, repetition)
+		meta.binary(4, []byte(column.name))
+		if column.convertedType != parquetNoConversion {
+			meta.i32(6, column.convertedType)
+		}
+		meta.endStruct()
+	}
+
+	meta.i64(3, w.rows)
+	meta.beginList(4, thriftStruct, len(w.rowGroups))
+	for _, rowGroup := range w.rowGroups {
+		var size int64
+		meta.beginElement()
+		meta.beginList(1, thriftStruct, len(rowGroup.chunks))
+		for i, chunk := range rowGroup.chunks {
+			column := w.columns[i]
+			size += chunk.size
+
+			meta.beginElement()
+			meta.i64(2, chunk.offset)
+			meta.beginStruct(3)
+			meta.i32(1, column.physicalType)
+			meta.beginList(2, thriftI32, 2)
+			meta.listI32(parquetPlain)
+			meta.listI32(parquetRLE)
+			meta.beginList(3, thriftBinary, 1)
+			meta.listBinary([]byte(column.name))
+			meta.i32(4, 0) // uncompressed
+			meta.i64(5, chunk.values)
+			meta.i64(6, chunk.size)
+			meta.i64(7, chunk.size)
+			meta.i64(9, chunk.offset)
+			meta.endStruct()
+			meta.endStruct()
+		}
+		meta.i64(2, size)
+		meta.i64(3, rowGroup.rows)
+		meta.endStruct()
+	}
+
+	meta.binary(6, []byte("kt version "+version))
+	meta.endStruct()
+	return meta.buf
+}
+
+func (c *parquetColumn) appendBytes(value []byte) {
+	c.values = binary.LittleEndian.AppendUint32(c.values, uint32(len(value)))
+	c.values = append(c.values, value...)
+}
+
+func (c *parquetColumn) appendOptionalBytes(value []byte) {
+	c.defined = append(c.defined, value != nil)
+	if value != nil {
+		c.appendBytes(value)
+	}
+}
+
+// encodeParquetLevels encodes the definition levels (0 for null, 1 for a value) as runs of the RLE/bit-packing hybrid
+// encoding with a bit width of 1
+func encodeParquetLevels(defined []bool) []byte {
+	var encoded []byte
+	for start := 0; start < len(defined); {
+		end := start + 1
+		for end < len(defined) && defined[end] == defined[start] {
+			end++
+		}
+
+		encoded = binary.AppendUvarint(encoded, uint64(end-start)<<1)
+		if defined[start] {
+			encoded = append(encoded, 1)
+		} else {
+			encoded = append(encoded, 0)
+		}
+		start = end
+	}
+	return encoded
+}
+
+// The types of the thrift compact protocol
+const (
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+// thriftWriter encodes a struct in the thrift compact protocol, which parquet uses for its metadata. The fields of
+// every struct have to be written in increasing order.
+type thriftWriter struct {
+	buf []byte
+	// lastField is the id of the previous field of the current struct, parents are the ids of the enclosing structs
+	lastField int16
+	parents   []int16
+}
+
+func (w *thriftWriter) fieldHeader(id int16, fieldType byte) {
+	if delta := id - w.lastField; delta > 0 && delta <= 15 {
+		w.buf = append(w.buf, byte(delta)<<4|fieldType)
+	} else {
+		w.buf = append(w.buf, fieldType)
+		w.buf = binary.AppendVarint(w.buf, int64(id))
+	}
+	w.lastField = id
+}
+
+func (w *thriftWriter) i32(id int16, value int32) {
+	w.fieldHeader(id, thriftI32)
+	w.buf = binary.AppendVarint(w.buf, int64(value))
+}
+
+func (w *thriftWriter) i64(id int16, value int64) {
+	w.fieldHeader(id, thriftI64)
+	w.buf = binary.AppendVarint(w.buf, value)
+}
+
+func (w *thriftWriter) binary(id int16, value []byte) {
+	w.fieldHeader(id, thriftBinary)
+	w.listBinary(value)
+}
+
+// beginStruct starts a struct field, which is ended by endStruct
+func (w *thriftWriter) beginStruct(id int16) {
+	w.fieldHeader(id, thriftStruct)
+	w.beginElement()
+}
+
+// beginList starts a list field of size elements, struct elements are written between beginElement and endStruct
+func (w *thriftWriter) beginList(id int16, elementType byte, size int) {
+	w.fieldHeader(id, thriftList)
+	if size < 15 {
+		w.buf = append(w.buf, byte(size)<<4|elementType)
+	} else {
+		w.buf = append(w.buf, 0xf0|elementType)
+		w.buf = binary.AppendUvarint(w.buf, uint64(size))
+	}
+}
+
+func (w *thriftWriter) beginElement() {
+	w.parents = append(w.parents, w.lastField)
+	w.lastField = 0
+}
+
+// endStruct ends the current struct, or the encoded struct itself when no struct was begun
+func (w *thriftWriter) endStruct() {
+	w.buf = append(w.buf, 0)
+	if len(w.parents) > 0 {
+		w.lastField = w.parents[len(w.parents)-1]
+		w.parents = w.parents[:len(w.parents)-1]
+	}
+}
+
+func (w *thriftWriter) listI32(value int32) {
+	w.buf = binary.AppendVarint(w.buf, int64(value))
+}
+
+func (w *thriftWriter) listBinary(value []byte) {
+	w.buf = binary.AppendUvarint(w.buf, uint64(len(value)))
+	w.buf = append(w.buf, value...)
+}
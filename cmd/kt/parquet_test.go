@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+)
+
+func TestParquetWriter(t *testing.T) {
+	var out bytes.Buffer
+	writer, err := newParquetWriter(&out, true)
+	if err != nil {
+		t.Fatal("Unexpected error: ", err)
+	}
+
+	messages := []*sarama.ConsumerMessage{
+		{Topic: "foo", Partition: 1, Offset: 5, Timestamp: time.Unix(1500000000, 0), Key: []byte("k"), Value: []byte("v")},
+		{Topic: "foo", Partition: 1, Offset: 6},
+	}
+	for _, msg := range messages {
+		if err := writer.writeMessage(msg, msg.Value); err != nil {
+			t.Fatal("Unexpected error: ", err)
+		}
+	}
+	if err := writer.close(); err != nil {
+		t.Fatal("Unexpected error: ", err)
+	}
+
+	file := out.Bytes()
+	if !bytes.HasPrefix(file, parquetMagic) || !bytes.HasSuffix(file, parquetMagic) {
+		t.Fatalf("Expected the file to start and end with the magic, got %q", file)
+	}
+	footerLength := int(binary.LittleEndian.Uint32(file[len(file)-8:]))
+	footer := file[len(file)-8-footerLength : len(file)-8]
+	if !bytes.Equal(footer, writer.fileMetaData()) {
+		t.Errorf("Expected the footer before its length")
+	}
+	if len(writer.rowGroups) != 1 || writer.rowGroups[0].rows != 2 || len(writer.rowGroups[0].chunks) != 6 {
+		t.Fatalf("Expected a row group of 2 rows in 6 columns, got %+v", writer.rowGroups)
+	}
+	// The first column chunk follows the magic, the others follow each other
+	offset := int64(len(parquetMagic))
+	for _, chunk := range writer.rowGroups[0].chunks {
+		if chunk.offset != offset {
+			t.Errorf("Expected a column chunk at %d, got %+v", offset, chunk)
+		}
+		offset = chunk.offset + chunk.size
+	}
+	if offset != int64(len(file)-8-footerLength) {
+		t.Errorf("Expected the footer after the column chunks at %d, got %d", offset, len(file)-8-footerLength)
+	}
+}
+
+func TestEncodeParquetLevels(t *testing.T) {
+	// Runs of 2 values, 1 null and 1 value
+	expected := []byte{4, 1, 2, 0, 2, 1}
+	if encoded := encodeParquetLevels([]bool{true, true, false, true}); !reflect.DeepEqual(encoded, expected) {
+		t.Errorf("Expected %v, got %v", expected, encoded)
+	}
+}
+
+func TestThriftWriter(t *testing.T) {
+	var w thriftWriter
+	w.i32(1, -1)
+	w.beginStruct(2)
+	w.binary(1, []byte("ab"))
+	w.endStruct()
+	// Field 20 is too far from field 2 for the short header
+	w.i64(20, 300)
+	w.beginList(21, thriftI32, 1)
+	w.listI32(3)
+	w.endStruct()
+
+	expected := []byte{0x15, 0x01, 0x1c, 0x18, 0x02, 'a', 'b', 0x00, 0x06, 0x28, 0xd8, 0x04, 0x19, 0x15, 0x06, 0x00}
+	if !reflect.DeepEqual(w.buf, expected) {
+		t.Errorf("Expected % x, got % x", expected, w.buf)
+	}
+}
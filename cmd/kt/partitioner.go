@@ -0,0 +1,88 @@
+package main
+
+import (
+	"encoding/binary"
+	"log"
+	"unicode/utf16"
+
+	"github.com/Shopify/sarama"
+	"github.com/jurriaan/kafkatools"
+)
+
+// keyPartitioners map a key onto one of numPartitions partitions the way the producers of an ecosystem do
+var keyPartitioners = map[string]func(key []byte, numPartitions int32) int32{
+	// murmur2 is the default partitioner of the Java client (and librdkafka's murmur2_random)
+	"murmur2": func(key []byte, numPartitions int32) int32 {
+		return int32(murmur2(key)&0x7fffffff) % numPartitions
+	},
+	// fnv is the default hash partitioner of sarama
+	"fnv": func(key []byte, numPartitions int32) int32 {
+		partition, err := sarama.NewHashPartitioner("").Partition(&sarama.ProducerMessage{Key: sarama.ByteEncoder(key)}, numPartitions)
+		if err != nil {
+			log.Fatal("Could not hash the key: ", err)
+		}
+		return partition
+	},
+	// java is the legacy (pre-0.9) Scala producer, which hashed the key as a Java string
+	"java": func(key []byte, numPartitions int32) int32 {
+		return int32(javaStringHash(key)&0x7fffffff) % numPartitions
+	},
+}
+
+// keyPartition returns the partition of the topic the partitioner assigns the key to
+func keyPartition(client sarama.Client, topic string, key []byte, partitioner string) *int32 {
+	partitions, err := client.Partitions(topic)
+	if err != nil {
+		log.Fatalf("Could not fetch the partitions of %s: %v", topic, err)
+	}
+
+	partition := keyPartitioners[partitioner](key, int32(len(partitions)))
+	kafkatools.Log.Infof("Key %s is in partition %d of %s (%s partitioner, %d partitions)", key, partition, topic, partitioner, len(partitions))
+	return &partition
+}
+
+// murmur2 is the 32-bit MurmurHash2 variant used by the Java client
+func murmur2(data []byte) uint32 {
+	const (
+		seed uint32 = 0x9747b28c
+		m    uint32 = 0x5bd1e995
+		r           = 24
+	)
+
+	h := seed ^ uint32(len(data))
+	for len(data) >= 4 {
+		k := binary.LittleEndian.Uint32(data)
+		k *= m
+		k ^= k >> r
+		k *= m
+		h *= m
+		h ^= k
+		data = data[4:]
+	}
+
+	switch len(data) {
+	case 3:
+		h ^= uint32(data[2]) << 16
+		fallthrough
+	case 2:
+		h ^= uint32(data[1]) << 8
+		fallthrough
+	case 1:
+		h ^= uint32(data[0])
+		h *= m
+	}
+
+	h ^= h >> 13
+	h *= m
+	h ^= h >> 15
+	return h
+}
+
+// javaStringHash is String.hashCode of the key decoded as UTF-8
+func javaStringHash(key []byte) uint32 {
+	var h uint32
+	for _, unit := range utf16.Encode([]rune(string(key))) {
+		h = 31*h + uint32(unit)
+	}
+	return h
+}
@@ -0,0 +1,46 @@
+package main
+
+import "testing"
+
+func TestMurmur2(t *testing.T) {
+	// The test vectors of the Java client
+	tests := map[string]int32{
+		"21":                         -973932308,
+		"foobar":                     -790332482,
+		"a-little-bit-long-string":   -985981536,
+		"a-little-bit-longer-string": -1486304829,
+		"lkjh234lh9fiuh90y23oiuhsafujhadof229phr9h19h89h8": -58897971,
+		"abc": 479470107,
+	}
+
+	for key, expected := range tests {
+		if hash := int32(murmur2([]byte(key))); hash != expected {
+			t.Errorf("Expected murmur2 of %q to be %d, got %d", key, expected, hash)
+		}
+	}
+}
+
+func TestKeyPartitioners(t *testing.T) {
+	tests := []struct {
+		partitioner string
+		key         string
+		expected    int32
+	}{
+		// 479470107 % 10
+		{"murmur2", "abc", 7},
+		// fnv-1a of abc is 440920331, as int32 % 10
+		{"fnv", "abc", 1},
+		// "abc".hashCode() is 96354
+		{"java", "abc", 4},
+	}
+
+	for _, test := range tests {
+		if partition := keyPartitioners[test.partitioner]([]byte(test.key), 10); partition != test.expected {
+			t.Errorf("Expected the %s partitioner to assign %q to partition %d, got %d", test.partitioner, test.key, test.expected, partition)
+		}
+	}
+
+	if hash := javaStringHash([]byte("héllo")); int32(hash) != 103094734 {
+		t.Errorf("Expected the Java string hash of héllo, got %d", int32(hash))
+	}
+}
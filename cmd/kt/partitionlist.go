@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// maxPartitionRange is the largest number of partitions a range of --partitions may span
+const maxPartitionRange = 100000
+
+// parsePartitionList parses the partition selection of --partitions: comma separated partitions and inclusive ranges,
+// e.g. 0,3,5-7. The partitions are returned sorted and once, along with the partitions listed (or covered by ranges)
+// more than once.
+func parsePartitionList(list string) (partitions, duplicates []int32, err error) {
+	seen := make(map[int32]bool)
+	reported := make(map[int32]bool)
+	add := func(partition int32) {
+		if !seen[partition] {
+			seen[partition] = true
+			partitions = append(partitions, partition)
+		} else if !reported[partition] {
+			reported[partition] = true
+			duplicates = append(duplicates, partition)
+		}
+	}
+
+	for _, item := range strings.Split(list, ",") {
+		bounds := strings.SplitN(strings.TrimSpace(item), "-", 2)
+		start, err := parsePartitionNumber(bounds[0])
+		if err != nil {
+			return nil, nil, err
+		}
+		end := start
+		if len(bounds) == 2 {
+			if end, err = parsePartitionNumber(bounds[1]); err != nil {
+				return nil, nil, err
+			}
+			if end < start {
+				return nil, nil, fmt.Errorf("invalid range %s, it ends before it starts", item)
+			}
+			if end-start >= maxPartitionRange {
+				return nil, nil, fmt.Errorf("invalid range %s, it spans more than %d partitions", item, maxPartitionRange)
+			}
+		}
+
+		for partition := start; partition <= end; partition++ {
+			add(partition)
+		}
+	}
+	return sortedInt32s(partitions), sortedInt32s(duplicates), nil
+}
+
+func parsePartitionNumber(number string) (int32, error) {
+	partition, err := strconv.ParseInt(number, 10, 32)
+	if err != nil || partition < 0 {
+		return 0, fmt.Errorf("invalid partition %q", number)
+	}
+	return int32(partition), nil
+}
+
+// formatPartitionList formats partitions as a comma separated list
+func formatPartitionList(partitions []int32) string {
+	formatted := make([]string, len(partitions))
+	for i, partition := range partitions {
+		formatted[i] = strconv.Itoa(int(partition))
+	}
+	return strings.Join(formatted, ", ")
+}
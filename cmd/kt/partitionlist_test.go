@@ -0,0 +1,35 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParsePartitionList(t *testing.T) {
+	tests := []struct {
+		list                   string
+		partitions, duplicates []int32
+	}{
+		{"3", []int32{3}, nil},
+		{"5-7,0,3", []int32{0, 3, 5, 6, 7}, nil},
+		{"0,0,1", []int32{0, 1}, []int32{0}},
+		{"2-4,3,1-3,3", []int32{1, 2, 3, 4}, []int32{2, 3}},
+	}
+
+	for _, test := range tests {
+		partitions, duplicates, err := parsePartitionList(test.list)
+		if err != nil {
+			t.Errorf("Unexpected error for %q: %v", test.list, err)
+			continue
+		}
+		if !reflect.DeepEqual(partitions, test.partitions) || !reflect.DeepEqual(duplicates, test.duplicates) {
+			t.Errorf("Expected partitions %v with duplicates %v for %q, got %v and %v", test.partitions, test.duplicates, test.list, partitions, duplicates)
+		}
+	}
+
+	for _, list := range []string{"", "a", "1,", "-1", "3-1", "1-", "0-200000", "4294967296"} {
+		if _, _, err := parsePartitionList(list); err == nil {
+			t.Errorf("Expected %q to be invalid", list)
+		}
+	}
+}
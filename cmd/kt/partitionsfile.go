@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/Shopify/sarama"
+	"github.com/jurriaan/kafkatools"
+)
+
+// partitionRanges are the exact offset ranges of --partitions-from-file, they replace the offset resolution
+type partitionRanges struct {
+	start, end offsetMap
+}
+
+// readPartitionsFile reads the offset ranges of the partitions of the topic from the file
+func readPartitionsFile(path, topic string) (*partitionRanges, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return parsePartitionRanges(file, topic)
+}
+
+// parsePartitionRanges parses "<partition> <start> <end>" lines, the end offset is excluded from the range. Blank
+// lines and lines starting with # are skipped.
+func parsePartitionRanges(input io.Reader, topic string) (*partitionRanges, error) {
+	ranges := &partitionRanges{start: make(offsetMap), end: make(offsetMap)}
+	scanner := bufio.NewScanner(input)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		fields := strings.Fields(text)
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("invalid range on line %d, expected <partition> <start> <end>: %s", line, text)
+		}
+		partition, err := strconv.ParseInt(fields[0], 10, 32)
+		if err != nil || partition < 0 {
+			return nil, fmt.Errorf("invalid partition on line %d: %s", line, fields[0])
+		}
+		start, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil || start < 0 {
+			return nil, fmt.Errorf("invalid start offset on line %d: %s", line, fields[1])
+		}
+		end, err := strconv.ParseInt(fields[2], 10, 64)
+		if err != nil || end < start {
+			return nil, fmt.Errorf("invalid end offset on line %d: %s", line, fields[2])
+		}
+		if _, ok := ranges.start[int32(partition)]; ok {
+			return nil, fmt.Errorf("partition %d is listed twice, again on line %d", partition, line)
+		}
+
+		ranges.start[int32(partition)] = kafkatools.TopicPartitionOffset{Topic: topic, Partition: int32(partition), Offset: start}
+		ranges.end[int32(partition)] = kafkatools.TopicPartitionOffset{Topic: topic, Partition: int32(partition), Offset: end}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(ranges.start) == 0 {
+		return nil, fmt.Errorf("no partitions listed")
+	}
+	return ranges, nil
+}
+
+// offsets returns the ranges as the start and end offsets to consume, only the leaders of the partitions are looked up
+func (r *partitionRanges) offsets(client sarama.Client, topic string) (partitionOffsets, endOffsets offsetMap, leaders map[int32]partitionLeader) {
+	leaders = fetchPartitionLeaders(client, topic, r.start)
+	logPartitionLeaders(r.start, leaders)
+	logTimeRange(topic, r.start, r.end)
+	return r.start, r.end, leaders
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/jurriaan/kafkatools"
+)
+
+func TestParsePartitionRanges(t *testing.T) {
+	input := "# pinned dump\n0 12 20\n\n  2\t5 5\n"
+	ranges, err := parsePartitionRanges(strings.NewReader(input), "foo")
+	if err != nil {
+		t.Fatal("Unexpected error: ", err)
+	}
+
+	start := offsetMap{
+		0: kafkatools.TopicPartitionOffset{Topic: "foo", Partition: 0, Offset: 12},
+		2: kafkatools.TopicPartitionOffset{Topic: "foo", Partition: 2, Offset: 5},
+	}
+	end := offsetMap{
+		0: kafkatools.TopicPartitionOffset{Topic: "foo", Partition: 0, Offset: 20},
+		2: kafkatools.TopicPartitionOffset{Topic: "foo", Partition: 2, Offset: 5},
+	}
+	if !reflect.DeepEqual(ranges.start, start) || !reflect.DeepEqual(ranges.end, end) {
+		t.Errorf("Expected %v to %v, got %v to %v", start, end, ranges.start, ranges.end)
+	}
+}
+
+func TestParsePartitionRangesErrors(t *testing.T) {
+	for input, expected := range map[string]string{
+		"0 12":                "invalid range on line 1, expected <partition> <start> <end>: 0 12",
+		"-1 0 3":              "invalid partition on line 1: -1",
+		"0 x 3":               "invalid start offset on line 1: x",
+		"0 12 3":              "invalid end offset on line 1: 3",
+		"0 1 3\n1 0 0\n0 3 4": "partition 0 is listed twice, again on line 3",
+		"# nothing\n":         "no partitions listed",
+	} {
+		if _, err := parsePartitionRanges(strings.NewReader(input), "foo"); err == nil || err.Error() != expected {
+			t.Errorf("Expected %q for %q, got %v", expected, input, err)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/Shopify/sarama"
+)
+
+// pingAPIs are the APIs whose supported version range is printed by kt ping
+var pingAPIs = []struct {
+	key  int16
+	name string
+}{
+	{0, "produce"},
+	{1, "fetch"},
+	{3, "metadata"},
+}
+
+// ping prints the brokers, the controller and the API versions supported by the controller. Failures are fatal so
+// the exit code can be used as a health check.
+func ping(client sarama.Client) {
+	if err := client.RefreshMetadata(); err != nil {
+		log.Fatal("Could not fetch metadata: ", err)
+	}
+
+	controller, err := client.Controller()
+	if err != nil {
+		log.Fatal("Could not fetch the controller: ", err)
+	}
+
+	response, err := controller.ApiVersions(&sarama.ApiVersionsRequest{})
+	if err != nil {
+		log.Fatalf("Could not fetch the API versions of broker %d: %v", controller.ID(), err)
+	}
+	if kerr := sarama.KError(response.ErrorCode); kerr != sarama.ErrNoError {
+		log.Fatalf("Could not fetch the API versions of broker %d: %v", controller.ID(), kerr)
+	}
+
+	brokers := client.Brokers()
+	ids := make([]int, len(brokers))
+	for i, broker := range brokers {
+		ids[i] = int(broker.ID())
+	}
+	sort.Ints(ids)
+
+	fmt.Printf("brokers: %d %v\n", len(brokers), ids)
+	fmt.Printf("controller: %d (%s)\n", controller.ID(), controller.Addr())
+	fmt.Printf("api versions: %s\n", formatAPIVersions(response.ApiKeys))
+}
+
+func formatAPIVersions(apiKeys []sarama.ApiVersionsResponseKey) string {
+	versions := make([]string, 0, len(pingAPIs))
+	for _, api := range pingAPIs {
+		version := api.name + " unsupported"
+		for _, apiKey := range apiKeys {
+			if apiKey.ApiKey == api.key {
+				version = fmt.Sprintf("%s v%d-v%d", api.name, apiKey.MinVersion, apiKey.MaxVersion)
+			}
+		}
+		versions = append(versions, version)
+	}
+	return fmt.Sprintf("%s (%d APIs)", strings.Join(versions, ", "), len(apiKeys))
+}
@@ -0,0 +1,20 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/Shopify/sarama"
+)
+
+func TestFormatAPIVersions(t *testing.T) {
+	apiKeys := []sarama.ApiVersionsResponseKey{
+		{ApiKey: 0, MinVersion: 0, MaxVersion: 9},
+		{ApiKey: 1, MinVersion: 0, MaxVersion: 13},
+		{ApiKey: 18, MinVersion: 0, MaxVersion: 3},
+	}
+
+	expected := "produce v0-v9, fetch v0-v13, metadata unsupported (3 APIs)"
+	if formatted := formatAPIVersions(apiKeys); formatted != expected {
+		t.Errorf("Expected %q, got %q", expected, formatted)
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/Shopify/sarama"
+)
+
+// printConsumePlan prints the brokers, partitions and offset ranges a consume would read without consuming anything,
+// with the ndjson output format only the resolved start offsets as the JSON array of kt offsets
+func printConsumePlan(client sarama.Client, parsedOptions options, partitionOffsets, endOffsets topicOffsetMap, leaders topicLeaders) {
+	if parsedOptions.output.format == "ndjson" {
+		if err := writeOffsetsJSON(os.Stdout, partitionOffsets); err != nil {
+			log.Fatal("Could not write the offsets: ", err)
+		}
+		return
+	}
+
+	var brokers []string
+	for _, broker := range client.Brokers() {
+		brokers = append(brokers, fmt.Sprintf("%d@%s", broker.ID(), broker.Addr()))
+	}
+	sort.Strings(brokers)
+
+	// The ranges which are not bounded are estimated up to the current newest offsets
+	newest := make(topicOffsetMap)
+	for topic := range partitionOffsets {
+		if len(endOffsets[topic]) == 0 {
+			newest[topic] = fetchTopicOffsets(client, sarama.OffsetNewest, topic)
+		}
+	}
+
+	following := !parsedOptions.consumeOpts.endAtHWM
+	for _, line := range formatConsumePlan(brokers, partitionOffsets, endOffsets, newest, leaders, following, parsedOptions.count) {
+		fmt.Println(line)
+	}
+}
+
+// formatConsumePlan formats the plan of a consume, the message estimates are upper bounds as compaction and
+// transaction markers leave gaps in the offsets
+func formatConsumePlan(brokers []string, partitionOffsets, endOffsets, newest topicOffsetMap, leaders topicLeaders, following bool, count int) []string {
+	lines := []string{"brokers: " + strings.Join(brokers, ", ")}
+
+	topics := make([]string, 0, len(partitionOffsets))
+	for topic := range partitionOffsets {
+		topics = append(topics, topic)
+	}
+	sort.Strings(topics)
+
+	var total int64
+	for _, topic := range topics {
+		partitions := make([]int32, 0, len(partitionOffsets[topic]))
+		for partition := range partitionOffsets[topic] {
+			partitions = append(partitions, partition)
+		}
+		lines = append(lines, fmt.Sprintf("topic %s: %d partitions", topic, len(partitions)))
+
+		for _, partition := range sortedInt32s(partitions) {
+			start := partitionOffsets[topic][partition].Offset
+			leader := "-"
+			if partitionLeader, ok := leaders[topic][partition]; ok {
+				leader = fmt.Sprintf("%d@%s", partitionLeader.ID, partitionLeader.Addr)
+			}
+
+			var end int64
+			var until string
+			if offset, ok := endOffsets[topic][partition]; ok {
+				end = offset.Offset
+				until = fmt.Sprintf("to %d", end)
+			} else {
+				end = newest[topic][partition].Offset
+				until = fmt.Sprintf("to the high-water mark, currently %d", end)
+				if following {
+					until = fmt.Sprintf("following, currently at %d", end)
+				}
+			}
+
+			messages := end - start
+			if messages < 0 {
+				messages = 0
+			}
+			total += messages
+			lines = append(lines, fmt.Sprintf("  partition %d (leader %s): from %d %s, up to %d messages", partition, leader, start, until, messages))
+		}
+	}
+
+	estimate := fmt.Sprintf("estimated: up to %d messages", total)
+	if count != -1 && int64(count) < total {
+		estimate = fmt.Sprintf("estimated: %d messages (--count), of up to %d", count, total)
+	}
+	return append(lines, estimate)
+}
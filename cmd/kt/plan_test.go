@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/Shopify/sarama"
+	"github.com/jurriaan/kafkatools"
+)
+
+func TestFormatConsumePlan(t *testing.T) {
+	partitionOffsets := topicOffsetMap{
+		"foo": {
+			0: kafkatools.TopicPartitionOffset{Topic: "foo", Partition: 0, Offset: 10},
+			1: kafkatools.TopicPartitionOffset{Topic: "foo", Partition: 1, Offset: 30},
+		},
+		"bar": {0: kafkatools.TopicPartitionOffset{Topic: "bar", Partition: 0, Offset: 5}},
+	}
+	endOffsets := topicOffsetMap{"foo": {
+		0: kafkatools.TopicPartitionOffset{Topic: "foo", Partition: 0, Offset: 20},
+		1: kafkatools.TopicPartitionOffset{Topic: "foo", Partition: 1, Offset: 25},
+	}}
+	newest := topicOffsetMap{"bar": {0: kafkatools.TopicPartitionOffset{Topic: "bar", Partition: 0, Offset: 8}}}
+	leaders := topicLeaders{"foo": {0: {ID: 1, Addr: "kafka1:9092"}}}
+
+	expected := []string{
+		"brokers: 1@kafka1:9092",
+		"topic bar: 1 partitions",
+		"  partition 0 (leader -): from 5 following, currently at 8, up to 3 messages",
+		"topic foo: 2 partitions",
+		"  partition 0 (leader 1@kafka1:9092): from 10 to 20, up to 10 messages",
+		"  partition 1 (leader -): from 30 to 25, up to 0 messages",
+		"estimated: up to 13 messages",
+	}
+	if lines := formatConsumePlan([]string{"1@kafka1:9092"}, partitionOffsets, endOffsets, newest, leaders, true, -1); !reflect.DeepEqual(lines, expected) {
+		t.Errorf("Expected %q, got %q", expected, lines)
+	}
+
+	lines := formatConsumePlan(nil, partitionOffsets, endOffsets, newest, leaders, false, 4)
+	if lines[2] != "  partition 0 (leader -): from 5 to the high-water mark, currently 8, up to 3 messages" {
+		t.Errorf("Expected the range to end at the high-water mark, got %q", lines[2])
+	}
+	if lines[len(lines)-1] != "estimated: 4 messages (--count), of up to 13" {
+		t.Errorf("Expected the estimate to be limited by the count, got %q", lines[len(lines)-1])
+	}
+}
+
+func TestDryRunStartOffsetsAtTime(t *testing.T) {
+	broker := sarama.NewMockBroker(t, 1)
+	defer broker.Close()
+
+	// Partition 1 has no message at or after the start date, it starts at its newest offset
+	const startDate = 1500000000000
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": sarama.NewMockMetadataResponse(t).
+			SetBroker(broker.Addr(), broker.BrokerID()).
+			SetLeader("foo", 0, broker.BrokerID()).
+			SetLeader("foo", 1, broker.BrokerID()),
+		"OffsetRequest": sarama.NewMockOffsetResponse(t).
+			SetOffset("foo", 0, startDate, 12).
+			SetOffset("foo", 0, sarama.OffsetNewest, 20).
+			SetOffset("foo", 1, startDate, -1).
+			SetOffset("foo", 1, sarama.OffsetNewest, 30),
+	})
+
+	client, err := sarama.NewClient([]string{broker.Addr()}, sarama.NewConfig())
+	if err != nil {
+		t.Fatal("Unexpected error: ", err)
+	}
+	defer client.Close()
+
+	start := int64(startDate)
+	partitionOffsets, _, _ := fetchTopicsPartitionOffsets(client, options{topics: []string{"foo"}, startOffset: &start})
+
+	// The --dry-run --output ndjson output, which --from-file reads back
+	var out bytes.Buffer
+	if err := writeOffsetsJSON(&out, partitionOffsets); err != nil {
+		t.Fatal("Unexpected error: ", err)
+	}
+	parsed, err := parseOffsets(out.Bytes(), []string{"foo"})
+	if err != nil {
+		t.Fatal("Unexpected error: ", err)
+	}
+	expected := topicOffsetMap{"foo": {
+		0: kafkatools.TopicPartitionOffset{Topic: "foo", Partition: 0, Offset: 12},
+		1: kafkatools.TopicPartitionOffset{Topic: "foo", Partition: 1, Offset: 30},
+	}}
+	if !reflect.DeepEqual(parsed, expected) {
+		t.Errorf("Expected %v, got %v", expected, parsed)
+	}
+}
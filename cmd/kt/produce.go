@@ -0,0 +1,251 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/jurriaan/kafkatools"
+)
+
+// maxLineSize is the largest input line which can be produced
+const maxLineSize = 10 * 1024 * 1024
+
+// inputRecord is a record read from the input, a nil partition leaves the partition to the partitioner
+type inputRecord struct {
+	key, value []byte
+	partition  *int32
+	headers    []sarama.RecordHeader
+}
+
+// inputReader calls emit for every record read from the input until it ends
+type inputReader func(input io.Reader, emit func(record inputRecord)) error
+
+// inputReaders are the supported input formats of kt produce
+var inputReaders = map[string]inputReader{
+	"lines":  readLines,
+	"binary": readRecordFrames,
+	"ndjson": readNDJSON,
+}
+
+// produce publishes every record read from stdin as a message to the topic
+func produce(client sarama.Client, parsedOptions options) {
+	producer, err := sarama.NewSyncProducerFromClient(client)
+	if err != nil {
+		log.Fatalf("Could not start producer: %v", err)
+	}
+
+	send := producer.SendMessages
+	if producer.IsTransactional() {
+		send = func(batch []*sarama.ProducerMessage) error {
+			return sendTransaction(producer, batch)
+		}
+	}
+	var latencies *latencyHistogram
+	if parsedOptions.produceLatency {
+		latencies = newLatencyHistogram()
+		send = timeSends(send, latencies, time.Now)
+	}
+
+	if parsedOptions.printOffsets {
+		send = logOffsets(send)
+	}
+
+	read := inputReaders[parsedOptions.inputFormat]
+	if parsedOptions.keySeparator != "" {
+		read = readKeyedLines(parsedOptions.keySeparator)
+	}
+	if parsedOptions.partition != nil {
+		read = withPartition(read, *parsedOptions.partition)
+	}
+
+	produced, failed, err := produceInput(os.Stdin, read, parsedOptions.topic, parsedOptions.batchSize, parsedOptions.linger, send)
+	if err := producer.Close(); err != nil {
+		log.Println("Error closing the producer: ", err)
+	}
+
+	kafkatools.Log.Infof("Produced %d messages to %s", produced, parsedOptions.topic)
+	if latencies != nil {
+		kafkatools.Log.Infof("%s", latencies.summary())
+	}
+	if err != nil {
+		log.Fatal("Could not read the input: ", err)
+	}
+	if failed > 0 {
+		log.Fatalf("Failed to produce %d messages", failed)
+	}
+}
+
+// readLines emits every line of the input as a value without a key
+func readLines(input io.Reader, emit func(record inputRecord)) error {
+	scanner := bufio.NewScanner(input)
+	scanner.Buffer(make([]byte, 64*1024), maxLineSize)
+	for scanner.Scan() {
+		emit(inputRecord{value: []byte(scanner.Text())})
+	}
+	return scanner.Err()
+}
+
+// readKeyedLines returns a reader splitting every line at the first separator into the key and the value, lines
+// without the separator are emitted as values without a key
+func readKeyedLines(separator string) inputReader {
+	return func(input io.Reader, emit func(record inputRecord)) error {
+		return readLines(input, func(record inputRecord) {
+			if key, value, found := bytes.Cut(record.value, []byte(separator)); found {
+				record.key, record.value = key, value
+			}
+			emit(record)
+		})
+	}
+}
+
+// withPartition returns a reader emitting the records of read with the partition
+func withPartition(read inputReader, partition int32) inputReader {
+	return func(input io.Reader, emit func(record inputRecord)) error {
+		return read(input, func(record inputRecord) {
+			record.partition = &partition
+			emit(record)
+		})
+	}
+}
+
+// logOffsets logs the partition and offset of every message of the batches which was produced
+func logOffsets(send func([]*sarama.ProducerMessage) error) func([]*sarama.ProducerMessage) error {
+	return func(batch []*sarama.ProducerMessage) error {
+		err := send(batch)
+		producerErrors, ok := err.(sarama.ProducerErrors)
+		if err != nil && !ok {
+			return err
+		}
+
+		failed := make(map[*sarama.ProducerMessage]bool, len(producerErrors))
+		for _, producerError := range producerErrors {
+			failed[producerError.Msg] = true
+		}
+		for _, msg := range batch {
+			if failed[msg] {
+				continue
+			}
+			if record, ok := msg.Metadata.(int); ok {
+				kafkatools.Log.Infof("Produced input record %d to partition %d at offset %d", record, msg.Partition, msg.Offset)
+			} else {
+				kafkatools.Log.Infof("Produced message to partition %d at offset %d", msg.Partition, msg.Offset)
+			}
+		}
+		return err
+	}
+}
+
+// produceInput sends the records read from the input in batches of at most batchSize messages, a batch is sent early
+// when no new record was read within linger. It returns the number of produced and failed messages, and the error which
+// stopped reading the input early (the records read before it are produced).
+func produceInput(input io.Reader, read inputReader, topic string, batchSize int, linger time.Duration, send func([]*sarama.ProducerMessage) error) (produced, failed int, err error) {
+	records := make(chan *sarama.ProducerMessage)
+	// readErr is set before records is closed
+	var readErr error
+	go func() {
+		defer close(records)
+		record := 0
+		readErr = read(input, func(entry inputRecord) {
+			// The position of the record in the input (its line number for lines input) identifies it in the failures
+			record++
+			msg := &sarama.ProducerMessage{Topic: topic, Partition: -1, Headers: entry.headers, Metadata: record}
+			if entry.partition != nil {
+				msg.Partition = *entry.partition
+			}
+			// Leave missing keys and tombstones nil instead of encoding them as empty byte slices
+			if entry.key != nil {
+				msg.Key = sarama.ByteEncoder(entry.key)
+			}
+			if entry.value != nil {
+				msg.Value = sarama.ByteEncoder(entry.value)
+			}
+			records <- msg
+		})
+	}()
+
+	var batch []*sarama.ProducerMessage
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+
+		batchFailures := countFailures(send(batch), batch)
+		failed += batchFailures
+		produced += len(batch) - batchFailures
+		batch = nil
+	}
+
+	var lingerTimer <-chan time.Time
+	for {
+		select {
+		case msg, ok := <-records:
+			if !ok {
+				flush()
+				return produced, failed, readErr
+			}
+
+			batch = append(batch, msg)
+			if len(batch) >= batchSize {
+				flush()
+				lingerTimer = nil
+			} else if lingerTimer == nil {
+				lingerTimer = time.After(linger)
+			}
+		case <-lingerTimer:
+			flush()
+			lingerTimer = nil
+		}
+	}
+}
+
+// sendTransaction sends the batch in a transaction, the transaction is aborted when any message fails so either the
+// whole batch or nothing is visible to read_committed consumers
+func sendTransaction(producer sarama.SyncProducer, batch []*sarama.ProducerMessage) error {
+	if err := producer.BeginTxn(); err != nil {
+		return err
+	}
+
+	if err := producer.SendMessages(batch); err != nil {
+		if abortErr := producer.AbortTxn(); abortErr != nil {
+			log.Println("Could not abort the transaction: ", abortErr)
+		}
+		// All messages of an aborted transaction are lost
+		return fmt.Errorf("transaction aborted: %v", err)
+	}
+
+	return producer.CommitTxn()
+}
+
+// countFailures logs the errors of a batch and returns the number of messages which failed. The failed messages read
+// from the input are logged with their position in the input, so only those can be retried.
+func countFailures(err error, batch []*sarama.ProducerMessage) int {
+	if err == nil {
+		return 0
+	}
+
+	if producerErrors, ok := err.(sarama.ProducerErrors); ok {
+		for _, producerError := range producerErrors {
+			if record, ok := producerError.Msg.Metadata.(int); ok {
+				log.Printf("Failed to produce input record %d: %v", record, producerError.Err)
+			} else {
+				log.Printf("Failed to produce message: %v", producerError.Err)
+			}
+		}
+		return len(producerErrors)
+	}
+
+	first, firstOK := batch[0].Metadata.(int)
+	last, lastOK := batch[len(batch)-1].Metadata.(int)
+	if firstOK && lastOK {
+		log.Printf("Failed to produce input records %d to %d: %v", first, last, err)
+	} else {
+		log.Printf("Failed to produce %d messages: %v", len(batch), err)
+	}
+	return len(batch)
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+)
+
+func TestProduceInputBatches(t *testing.T) {
+	var batches [][]string
+	send := func(batch []*sarama.ProducerMessage) error {
+		var values []string
+		for _, msg := range batch {
+			if msg.Topic != "foo" {
+				t.Errorf("Expected topic foo, got %s", msg.Topic)
+			}
+			value, _ := msg.Value.Encode()
+			values = append(values, string(value))
+		}
+		batches = append(batches, values)
+		return nil
+	}
+
+	produced, failed, _ := produceInput(strings.NewReader("a\nb\nc\nd\ne\n"), readLines, "foo", 2, time.Hour, send)
+
+	expected := [][]string{{"a", "b"}, {"c", "d"}, {"e"}}
+	if !reflect.DeepEqual(batches, expected) {
+		t.Errorf("Expected batches %v, got %v", expected, batches)
+	}
+	if produced != 5 || failed != 0 {
+		t.Errorf("Expected 5 produced and 0 failed messages, got %d and %d", produced, failed)
+	}
+}
+
+func TestProduceInputFailures(t *testing.T) {
+	send := func(batch []*sarama.ProducerMessage) error {
+		if len(batch) == 2 {
+			return sarama.ProducerErrors{{Msg: batch[1], Err: errors.New("boom")}}
+		}
+		return errors.New("connection lost")
+	}
+
+	produced, failed, _ := produceInput(strings.NewReader("a\nb\nc\n"), readLines, "foo", 2, time.Hour, send)
+	if produced != 1 || failed != 2 {
+		t.Errorf("Expected 1 produced and 2 failed messages, got %d and %d", produced, failed)
+	}
+}
+
+func TestProduceInputReadError(t *testing.T) {
+	var values []string
+	send := func(batch []*sarama.ProducerMessage) error {
+		for _, msg := range batch {
+			value, _ := msg.Value.Encode()
+			values = append(values, string(value))
+		}
+		return nil
+	}
+
+	input := "a\n" + strings.Repeat("x", maxLineSize+1) + "\nc\n"
+	produced, failed, err := produceInput(strings.NewReader(input), readLines, "foo", 2, time.Hour, send)
+	if err == nil {
+		t.Fatal("Expected an error reading the oversized line")
+	}
+	// The records before the error are still produced
+	if produced != 1 || failed != 0 || !reflect.DeepEqual(values, []string{"a"}) {
+		t.Errorf("Expected only a to be produced, got %v (%d produced, %d failed)", values, produced, failed)
+	}
+}
+
+func TestCountFailures(t *testing.T) {
+	batch := []*sarama.ProducerMessage{{Metadata: 3}, {Metadata: 4}, {Metadata: 5}}
+
+	var logged strings.Builder
+	log.SetOutput(&logged)
+	defer log.SetOutput(os.Stderr)
+
+	if failed := countFailures(sarama.ProducerErrors{{Msg: batch[1], Err: errors.New("boom")}}, batch); failed != 1 {
+		t.Errorf("Expected 1 failed message, got %d", failed)
+	}
+	if failed := countFailures(errors.New("connection lost"), batch); failed != 3 {
+		t.Errorf("Expected 3 failed messages, got %d", failed)
+	}
+
+	if !strings.Contains(logged.String(), "input record 4: boom") || !strings.Contains(logged.String(), "input records 3 to 5: connection lost") {
+		t.Errorf("Expected the failures to be logged with their input records, got %q", logged.String())
+	}
+}
+
+func TestReadKeyedLines(t *testing.T) {
+	var records []inputRecord
+	read := withPartition(readKeyedLines("::"), 3)
+	if err := read(strings.NewReader("a::1\nb::2::3\nnokey\n::empty\n"), func(record inputRecord) { records = append(records, record) }); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := [][2]string{{"a", "1"}, {"b", "2::3"}, {"", "nokey"}, {"", "empty"}}
+	if len(records) != len(expected) {
+		t.Fatalf("Expected %d records, got %d", len(expected), len(records))
+	}
+	for i, record := range records {
+		if string(record.key) != expected[i][0] || string(record.value) != expected[i][1] {
+			t.Errorf("Expected key %q and value %q, got %q and %q", expected[i][0], expected[i][1], record.key, record.value)
+		}
+		if record.partition == nil || *record.partition != 3 {
+			t.Errorf("Expected record %d to be written to partition 3, got %v", i, record.partition)
+		}
+	}
+	if records[2].key != nil || records[3].key == nil {
+		t.Errorf("Expected only lines without the separator to lack a key, got %q and %q", records[2].key, records[3].key)
+	}
+}
+
+func TestLogOffsets(t *testing.T) {
+	batch := []*sarama.ProducerMessage{{Metadata: 1}, {Metadata: 2}}
+	send := logOffsets(func(batch []*sarama.ProducerMessage) error {
+		for i, msg := range batch {
+			msg.Partition, msg.Offset = 1, int64(40+i)
+		}
+		return sarama.ProducerErrors{{Msg: batch[1], Err: errors.New("boom")}}
+	})
+
+	var logged strings.Builder
+	log.SetOutput(&logged)
+	defer log.SetOutput(os.Stderr)
+
+	if err := send(batch); err == nil {
+		t.Error("Expected the failures to be returned")
+	}
+	if !strings.Contains(logged.String(), "input record 1 to partition 1 at offset 40") || strings.Contains(logged.String(), "input record 2") {
+		t.Errorf("Expected only the produced message to be logged, got %q", logged.String())
+	}
+}
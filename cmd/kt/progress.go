@@ -0,0 +1,160 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Shopify/sarama"
+)
+
+// progressWidth is the width of the progress bars
+const progressWidth = 30
+
+// The progress is redrawn often on a terminal, but written sparingly to files to keep the logs readable
+const (
+	terminalProgressInterval = 200 * time.Millisecond
+	logProgressInterval      = 10 * time.Second
+)
+
+// consumeProgress tracks how far the partitions of a bounded range have been consumed
+type consumeProgress struct {
+	mutex      sync.Mutex
+	partitions []topicPartition
+	start      map[topicPartition]int64
+	end        map[topicPartition]int64
+	next       map[topicPartition]int64
+	// started is when consuming started, the estimated time remaining extrapolates the rate since
+	started time.Time
+	// rendered is the number of lines rendered in place on the terminal by the last render
+	rendered int
+}
+
+// newConsumeProgress returns the progress of the partitions from their start to their end offsets, partitions
+// without an end offset are not tracked
+func newConsumeProgress(partitionOffsets, endOffsets topicOffsetMap) *consumeProgress {
+	p := &consumeProgress{
+		start: make(map[topicPartition]int64),
+		end:   make(map[topicPartition]int64),
+		next:  make(map[topicPartition]int64),
+		// The partitions start consuming right after their offsets are fetched
+		started: time.Now(),
+	}
+	for topic, offsets := range partitionOffsets {
+		for partition, offset := range offsets {
+			end, ok := endOffsets[topic][partition]
+			if !ok {
+				continue
+			}
+
+			key := topicPartition{Topic: topic, Partition: partition}
+			p.partitions = append(p.partitions, key)
+			p.start[key], p.end[key], p.next[key] = offset.Offset, end.Offset, offset.Offset
+		}
+	}
+	sort.Slice(p.partitions, func(i, j int) bool {
+		if p.partitions[i].Topic != p.partitions[j].Topic {
+			return p.partitions[i].Topic < p.partitions[j].Topic
+		}
+		return p.partitions[i].Partition < p.partitions[j].Partition
+	})
+	return p
+}
+
+// update records the consumed message, it is a no-op on a nil progress
+func (p *consumeProgress) update(msg *sarama.ConsumerMessage) {
+	if p == nil {
+		return
+	}
+
+	key := topicPartition{Topic: msg.Topic, Partition: msg.Partition}
+	p.mutex.Lock()
+	if msg.Offset+1 > p.next[key] {
+		p.next[key] = msg.Offset + 1
+	}
+	p.mutex.Unlock()
+}
+
+// lines formats a progress bar per partition followed by the total and the estimated time remaining
+func (p *consumeProgress) lines() []string {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	lines := make([]string, 0, len(p.partitions)+1)
+	var consumed, total int64
+	for _, key := range p.partitions {
+		partitionTotal := p.end[key] - p.start[key]
+		if partitionTotal < 0 {
+			partitionTotal = 0
+		}
+		partitionConsumed := p.next[key] - p.start[key]
+		if partitionConsumed > partitionTotal {
+			partitionConsumed = partitionTotal
+		}
+
+		consumed += partitionConsumed
+		total += partitionTotal
+		lines = append(lines, fmt.Sprintf("%s partition %d: %s", key.Topic, key.Partition, formatProgress(partitionConsumed, partitionTotal)))
+	}
+	totalLine := "total: " + formatProgress(consumed, total)
+	if consumed > 0 && consumed < total {
+		perMessage := time.Since(p.started) / time.Duration(consumed)
+		totalLine += " ETA " + (perMessage * time.Duration(total-consumed)).Round(time.Second).String()
+	}
+	return append(lines, totalLine)
+}
+
+func formatProgress(consumed, total int64) string {
+	fraction := 1.0
+	if total > 0 {
+		fraction = float64(consumed) / float64(total)
+	}
+	filled := int(fraction * progressWidth)
+	return fmt.Sprintf("[%s%s] %5.1f%% %d/%d", strings.Repeat("#", filled), strings.Repeat("-", progressWidth-filled), fraction*100, consumed, total)
+}
+
+// render writes the progress to out: on a terminal all bars are redrawn in place, otherwise only the total is
+// written on a new line
+func (p *consumeProgress) render(out io.Writer, terminal bool) {
+	lines := p.lines()
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if !terminal {
+		fmt.Fprintln(out, "progress "+lines[len(lines)-1])
+		return
+	}
+
+	var screen strings.Builder
+	if p.rendered > 0 {
+		// Move the cursor back up to the first bar
+		fmt.Fprintf(&screen, "\x1b[%dA", p.rendered)
+	}
+	for _, line := range lines {
+		screen.WriteString("\x1b[2K" + line + "\n")
+	}
+	p.rendered = len(lines)
+	io.WriteString(out, screen.String())
+}
+
+// report renders the progress until closing is closed
+func (p *consumeProgress) report(out io.Writer, terminal bool, closing chan struct{}) {
+	interval := logProgressInterval
+	if terminal {
+		interval = terminalProgressInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			p.render(out, terminal)
+		case <-closing:
+			return
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/jurriaan/kafkatools"
+)
+
+func TestConsumeProgress(t *testing.T) {
+	partitionOffsets := topicOffsetMap{"foo": {
+		0: kafkatools.TopicPartitionOffset{Topic: "foo", Partition: 0, Offset: 10},
+		1: kafkatools.TopicPartitionOffset{Topic: "foo", Partition: 1, Offset: 5},
+	}}
+	endOffsets := topicOffsetMap{"foo": {
+		0: kafkatools.TopicPartitionOffset{Topic: "foo", Partition: 0, Offset: 20},
+		1: kafkatools.TopicPartitionOffset{Topic: "foo", Partition: 1, Offset: 5},
+	}}
+	progress := newConsumeProgress(partitionOffsets, endOffsets)
+	// Half of the messages in 10 seconds leaves another 10
+	progress.started = time.Now().Add(-10 * time.Second)
+
+	progress.update(&sarama.ConsumerMessage{Topic: "foo", Partition: 0, Offset: 14})
+	expected := []string{
+		"foo partition 0: [###############---------------]  50.0% 5/10",
+		"foo partition 1: [##############################] 100.0% 0/0",
+		"total: [###############---------------]  50.0% 5/10 ETA 10s",
+	}
+	if lines := progress.lines(); !reflect.DeepEqual(lines, expected) {
+		t.Errorf("Expected %q, got %q", expected, lines)
+	}
+
+	var out bytes.Buffer
+	progress.render(&out, true)
+	progress.render(&out, true)
+	if rendered := out.String(); strings.Count(rendered, "\x1b[3A") != 1 || strings.Count(rendered, "\n") != 6 {
+		t.Errorf("Expected the bars to be redrawn in place, got %q", rendered)
+	}
+
+	out.Reset()
+	progress.render(&out, false)
+	if rendered := out.String(); rendered != "progress total: [###############---------------]  50.0% 5/10 ETA 10s\n" {
+		t.Errorf("Expected only the total without a terminal, got %q", rendered)
+	}
+
+	progress.update(&sarama.ConsumerMessage{Topic: "foo", Partition: 0, Offset: 19})
+	if lines := progress.lines(); lines[2] != "total: [##############################] 100.0% 10/10" {
+		t.Errorf("Expected no estimate once done, got %q", lines[2])
+	}
+
+	var disabled *consumeProgress
+	disabled.update(&sarama.ConsumerMessage{})
+}
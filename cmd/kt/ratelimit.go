@@ -0,0 +1,55 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// messageRate limits the rate at which the partitions emit their messages to --max-rate messages per second. It is
+// shared by the partitions, so the rate applies to all of them together: every message is scheduled one interval
+// after the previous one.
+type messageRate struct {
+	interval time.Duration
+	mutex    sync.Mutex
+	// next is the time at which the next message may be emitted
+	next time.Time
+}
+
+// newMessageRate returns the limit of perSecond messages per second
+func newMessageRate(perSecond int) *messageRate {
+	interval := time.Second / time.Duration(perSecond)
+	if interval <= 0 {
+		interval = time.Nanosecond
+	}
+	return &messageRate{interval: interval}
+}
+
+// wait blocks until the next message may be emitted, it returns false when closing is closed first. A nil rate never
+// waits.
+func (r *messageRate) wait(closing chan struct{}) bool {
+	if r == nil {
+		return true
+	}
+
+	r.mutex.Lock()
+	now := time.Now()
+	if r.next.Before(now) {
+		r.next = now
+	}
+	at := r.next
+	r.next = r.next.Add(r.interval)
+	r.mutex.Unlock()
+
+	delay := time.Until(at)
+	if delay <= 0 {
+		return true
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-closing:
+		return false
+	}
+}
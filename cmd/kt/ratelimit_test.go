@@ -0,0 +1,48 @@
+package main
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMessageRateIsShared(t *testing.T) {
+	rate := newMessageRate(100)
+	closing := make(chan struct{})
+
+	start := time.Now()
+	var wg sync.WaitGroup
+	for partition := 0; partition < 4; partition++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 5; i++ {
+				rate.wait(closing)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// 20 messages at 100 per second take 190ms, the first one is emitted right away
+	if elapsed := time.Since(start); elapsed < 190*time.Millisecond {
+		t.Errorf("Expected the partitions to share the rate, 20 messages took %v", elapsed)
+	}
+}
+
+func TestMessageRateWaitClosing(t *testing.T) {
+	rate := newMessageRate(1)
+	closing := make(chan struct{})
+	if !rate.wait(closing) {
+		t.Error("Expected the first message to be emitted right away")
+	}
+
+	close(closing)
+	if rate.wait(closing) {
+		t.Error("Expected the wait to end once closing")
+	}
+
+	var unlimited *messageRate
+	if !unlimited.wait(closing) {
+		t.Error("Expected a nil rate not to wait")
+	}
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+
+	"github.com/Shopify/sarama"
+	"github.com/jurriaan/kafkatools"
+	"github.com/olekukonko/tablewriter"
+)
+
+// replicaAssignment contains the replicas per partition, the first replica is the preferred leader
+type replicaAssignment map[int32][]int32
+
+// reassign previews a balanced replica assignment of the topic next to the current one, nothing is executed
+func reassign(client sarama.Client, parsedOptions options) {
+	if err := client.RefreshMetadata(parsedOptions.topic); err != nil {
+		log.Fatal("Could not fetch metadata: ", err)
+	}
+
+	partitions, err := client.Partitions(parsedOptions.topic)
+	if err != nil {
+		log.Fatalf("Could not fetch the partitions of topic %s: %v", parsedOptions.topic, err)
+	}
+
+	current := make(replicaAssignment, len(partitions))
+	for _, partition := range partitions {
+		if current[partition], err = client.Replicas(parsedOptions.topic, partition); err != nil {
+			log.Fatalf("Could not fetch the replicas of %s partition %d: %v", parsedOptions.topic, partition, err)
+		}
+	}
+
+	var brokers []int32
+	for _, broker := range client.Brokers() {
+		brokers = append(brokers, broker.ID())
+	}
+
+	proposed := proposeAssignment(current, brokers)
+	printAssignments(current, proposed)
+	for _, line := range formatBrokerLoad(current, proposed, brokers) {
+		fmt.Println(line)
+	}
+	kafkatools.Log.Infof("Preview only: %d of %d partitions would move, nothing was changed", countMovedPartitions(current, proposed), len(current))
+}
+
+// proposeAssignment spreads the replicas of every partition round-robin over the brokers, keeping the replication
+// factor of the partition (limited to the number of brokers). Consecutive partitions start at consecutive brokers so
+// the preferred leaders are balanced as well.
+func proposeAssignment(current replicaAssignment, brokers []int32) replicaAssignment {
+	brokers = sortedInt32s(brokers)
+	proposed := make(replicaAssignment, len(current))
+	if len(brokers) == 0 {
+		return proposed
+	}
+
+	for i, partition := range sortedPartitions(current) {
+		replicationFactor := len(current[partition])
+		if replicationFactor > len(brokers) {
+			replicationFactor = len(brokers)
+		}
+
+		replicas := make([]int32, replicationFactor)
+		for j := range replicas {
+			replicas[j] = brokers[(i+j)%len(brokers)]
+		}
+		proposed[partition] = replicas
+	}
+	return proposed
+}
+
+func printAssignments(current, proposed replicaAssignment) {
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"partition", "current replicas", "proposed replicas", "moved"})
+	for _, partition := range sortedPartitions(current) {
+		moved := ""
+		if !sameReplicas(current[partition], proposed[partition]) {
+			moved = "yes"
+		}
+		table.Append([]string{strconv.Itoa(int(partition)), fmt.Sprint(current[partition]), fmt.Sprint(proposed[partition]), moved})
+	}
+
+	table.SetAlignment(tablewriter.ALIGN_LEFT)
+	table.Render()
+}
+
+// formatBrokerLoad describes the number of replicas and preferred leaderships per broker before and after
+func formatBrokerLoad(current, proposed replicaAssignment, brokers []int32) (lines []string) {
+	currentReplicas, currentLeaders := brokerLoad(current)
+	proposedReplicas, proposedLeaders := brokerLoad(proposed)
+	for _, broker := range sortedInt32s(brokers) {
+		lines = append(lines, fmt.Sprintf("broker %d: %d -> %d replicas, %d -> %d leaders", broker,
+			currentReplicas[broker], proposedReplicas[broker], currentLeaders[broker], proposedLeaders[broker]))
+	}
+	return lines
+}
+
+func brokerLoad(assignment replicaAssignment) (replicas, leaders map[int32]int) {
+	replicas, leaders = make(map[int32]int), make(map[int32]int)
+	for _, partitionReplicas := range assignment {
+		for i, broker := range partitionReplicas {
+			replicas[broker]++
+			if i == 0 {
+				leaders[broker]++
+			}
+		}
+	}
+	return replicas, leaders
+}
+
+func countMovedPartitions(current, proposed replicaAssignment) (moved int) {
+	for partition, replicas := range current {
+		if !sameReplicas(replicas, proposed[partition]) {
+			moved++
+		}
+	}
+	return moved
+}
+
+// sameReplicas compares the replicas including their order, a different preferred leader also needs a reassignment
+func sameReplicas(a, b []int32) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func sortedPartitions(assignment replicaAssignment) []int32 {
+	partitions := make([]int32, 0, len(assignment))
+	for partition := range assignment {
+		partitions = append(partitions, partition)
+	}
+	return sortedInt32s(partitions)
+}
+
+func sortedInt32s(values []int32) []int32 {
+	sorted := append([]int32(nil), values...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return sorted
+}
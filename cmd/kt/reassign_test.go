@@ -0,0 +1,40 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestProposeAssignment(t *testing.T) {
+	// All replicas on brokers 1 and 2 while broker 3 is empty
+	current := replicaAssignment{
+		0: {1, 2},
+		1: {1, 2},
+		2: {2, 1},
+		3: {1, 2, 3, 4},
+	}
+
+	proposed := proposeAssignment(current, []int32{3, 1, 2})
+	expected := replicaAssignment{
+		0: {1, 2},
+		1: {2, 3},
+		2: {3, 1},
+		3: {1, 2, 3},
+	}
+	if !reflect.DeepEqual(proposed, expected) {
+		t.Errorf("Expected assignment %v, got %v", expected, proposed)
+	}
+
+	if moved := countMovedPartitions(current, proposed); moved != 3 {
+		t.Errorf("Expected 3 moved partitions, got %d", moved)
+	}
+
+	expectedLoad := []string{
+		"broker 1: 4 -> 3 replicas, 3 -> 2 leaders",
+		"broker 2: 4 -> 3 replicas, 1 -> 1 leaders",
+		"broker 3: 1 -> 3 replicas, 0 -> 1 leaders",
+	}
+	if load := formatBrokerLoad(current, proposed, []int32{1, 2, 3}); !reflect.DeepEqual(load, expectedLoad) {
+		t.Errorf("Expected broker load %v, got %v", expectedLoad, load)
+	}
+}
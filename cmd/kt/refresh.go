@@ -0,0 +1,64 @@
+package main
+
+import (
+	"sort"
+	"time"
+
+	"github.com/jurriaan/kafkatools"
+)
+
+// partitionRefresh periodically looks for partitions added to the consumed topics
+type partitionRefresh struct {
+	interval time.Duration
+	// fetchOffsets refreshes the metadata of the topic and returns the start offsets of all its partitions
+	fetchOffsets func(topic string) offsetMap
+}
+
+// watch calls startPartition for every partition missing from the known partition offsets until closing is closed
+func (r *partitionRefresh) watch(known topicOffsetMap, closing chan struct{}, startPartition func(kafkatools.TopicPartitionOffset)) {
+	consumed := make(map[string]map[int32]bool)
+	for topic, partitionOffsets := range known {
+		consumed[topic] = make(map[int32]bool)
+		for partition := range partitionOffsets {
+			consumed[topic][partition] = true
+		}
+	}
+
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-closing:
+			return
+		case <-ticker.C:
+		}
+
+		for topic := range consumed {
+			for _, offset := range newPartitions(r.fetchOffsets(topic), consumed[topic]) {
+				// Don't start consumers after shutting down
+				select {
+				case <-closing:
+					return
+				default:
+				}
+
+				kafkatools.Log.Infof("Discovered new partition %d of topic %s", offset.Partition, topic)
+				consumed[topic][offset.Partition] = true
+				startPartition(offset)
+			}
+		}
+	}
+}
+
+// newPartitions returns the partition offsets of the partitions which are not consumed yet, sorted by partition
+func newPartitions(partitionOffsets offsetMap, consumed map[int32]bool) (offsets []kafkatools.TopicPartitionOffset) {
+	for partition, offset := range partitionOffsets {
+		if !consumed[partition] {
+			offsets = append(offsets, offset)
+		}
+	}
+
+	sort.Slice(offsets, func(i, j int) bool { return offsets[i].Partition < offsets[j].Partition })
+	return offsets
+}
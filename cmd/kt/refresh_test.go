@@ -0,0 +1,46 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/Shopify/sarama/mocks"
+	"github.com/jurriaan/kafkatools"
+)
+
+func TestPartitionRefresh(t *testing.T) {
+	config := sarama.NewConfig()
+	config.Consumer.Return.Errors = true
+
+	consumer := mocks.NewConsumer(t, config)
+	consumer.ExpectConsumePartition("foo", 0, 0)
+	consumer.ExpectConsumePartition("foo", 1, 0).YieldMessage(&sarama.ConsumerMessage{Value: []byte("new"), Offset: 0})
+
+	partitionOffsets := topicOffsetMap{"foo": {0: kafkatools.TopicPartitionOffset{Topic: "foo", Partition: 0, Offset: 0}}}
+	refresh := &partitionRefresh{
+		interval: 10 * time.Millisecond,
+		fetchOffsets: func(topic string) offsetMap {
+			return offsetMap{
+				0: kafkatools.TopicPartitionOffset{Topic: topic, Partition: 0, Offset: 0},
+				1: kafkatools.TopicPartitionOffset{Topic: topic, Partition: 1, Offset: 0},
+			}
+		},
+	}
+
+	messagesChan, closing := consumeTopics(consumer, partitionOffsets, nil, consumeOptions{partitionRefresh: refresh})
+
+	select {
+	case msg := <-messagesChan:
+		if msg.Partition != 1 || string(msg.Value) != "new" {
+			t.Errorf("Expected the message of the new partition, got %+v", msg)
+		}
+	case <-time.After(time.Second):
+		t.Error("Expected the new partition to be consumed")
+	}
+
+	close(closing)
+	if !drainMessages(messagesChan, time.Second) {
+		t.Error("Expected the consumers to stop")
+	}
+}
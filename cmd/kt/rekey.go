@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/Shopify/sarama"
+)
+
+// keyTemplate computes a new message key from a JSON value: the text between the fields is copied, {path} is replaced
+// by the field at the dotted path (see lookupField)
+type keyTemplate struct {
+	// literals surround the fields, there is one literal more than there are fields
+	literals []string
+	fields   [][]string
+	paths    []string
+}
+
+func parseKeyTemplate(template string) (*keyTemplate, error) {
+	t := &keyTemplate{}
+	rest := template
+	for {
+		open := strings.IndexAny(rest, "{}")
+		if open < 0 {
+			t.literals = append(t.literals, rest)
+			break
+		}
+		if rest[open] == '}' {
+			return nil, fmt.Errorf("unexpected } in key template %q", template)
+		}
+
+		length := strings.IndexAny(rest[open+1:], "{}")
+		if length < 0 || rest[open+1+length] != '}' {
+			return nil, fmt.Errorf("unclosed { in key template %q", template)
+		}
+		path := rest[open+1 : open+1+length]
+		if path == "" {
+			return nil, fmt.Errorf("empty field in key template %q", template)
+		}
+
+		t.literals = append(t.literals, rest[:open])
+		t.fields = append(t.fields, strings.Split(path, "."))
+		t.paths = append(t.paths, path)
+		rest = rest[open+length+2:]
+	}
+
+	if len(t.fields) == 0 {
+		return nil, fmt.Errorf("key template %q contains no {field}", template)
+	}
+	return t, nil
+}
+
+// evaluate returns the key for the JSON value, string fields are inserted as is and other fields as JSON
+func (t *keyTemplate) evaluate(value []byte) ([]byte, error) {
+	decoder := json.NewDecoder(bytes.NewReader(value))
+	decoder.UseNumber()
+
+	var object interface{}
+	if err := decoder.Decode(&object); err != nil {
+		return nil, fmt.Errorf("value is not JSON: %v", err)
+	}
+
+	var key bytes.Buffer
+	for i, field := range t.fields {
+		key.WriteString(t.literals[i])
+		switch selected := lookupField(object, field).(type) {
+		case nil:
+			return nil, fmt.Errorf("the value has no %s field", t.paths[i])
+		case string:
+			key.WriteString(selected)
+		default:
+			encoded, err := json.Marshal(selected)
+			if err != nil {
+				return nil, err
+			}
+			key.Write(encoded)
+		}
+	}
+	key.WriteString(t.literals[len(t.fields)])
+	return key.Bytes(), nil
+}
+
+// rekeyMessage replaces the key of the message by the template evaluated over its decoded value, messages which can't
+// be rekeyed (e.g. tombstones) keep their key
+func rekeyMessage(msg *sarama.ConsumerMessage, template *keyTemplate, value []byte) {
+	if value == nil {
+		return
+	}
+
+	key, err := template.evaluate(value)
+	if err != nil {
+		log.Printf("Could not rekey the message at offset %d of %s partition %d, keeping its key: %v", msg.Offset, msg.Topic, msg.Partition, err)
+		return
+	}
+	msg.Key = key
+}
+
+// decodeOnce returns decodeValue remembering the value of the last message, so formatting a message decodes it only
+// once
+func decodeOnce(decodeValue func(*sarama.ConsumerMessage) []byte) func(*sarama.ConsumerMessage) []byte {
+	var last *sarama.ConsumerMessage
+	var lastValue []byte
+	return func(msg *sarama.ConsumerMessage) []byte {
+		if msg != last {
+			last, lastValue = msg, decodeValue(msg)
+		}
+		return lastValue
+	}
+}
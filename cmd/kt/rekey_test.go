@@ -0,0 +1,60 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/Shopify/sarama"
+)
+
+func TestKeyTemplate(t *testing.T) {
+	template, err := parseKeyTemplate("{region}-{customer.id}")
+	if err != nil {
+		t.Fatal("Unexpected error: ", err)
+	}
+
+	tests := map[string]string{
+		`{"region":"eu","customer":{"id":42}}`:  "eu-42",
+		`{"region":"us","customer":{"id":"a"}}`: "us-a",
+	}
+	for value, expected := range tests {
+		if key, err := template.evaluate([]byte(value)); err != nil || string(key) != expected {
+			t.Errorf("Expected key %q for %s, got %q (%v)", expected, value, key, err)
+		}
+	}
+
+	if _, err := template.evaluate([]byte(`{"region":"eu"}`)); err == nil {
+		t.Error("Expected an error for a missing field")
+	}
+	if _, err := template.evaluate([]byte(`not json`)); err == nil {
+		t.Error("Expected an error for a value which is not JSON")
+	}
+
+	for _, invalid := range []string{"no fields", "{unclosed", "{}", "a}", "{a{b}}"} {
+		if _, err := parseKeyTemplate(invalid); err == nil {
+			t.Errorf("Expected key template %q to be invalid", invalid)
+		}
+	}
+}
+
+func TestRekeyFormatter(t *testing.T) {
+	template, _ := parseKeyTemplate("{id}")
+	decodes := 0
+	decoder := &valueDecoder{decode: func(msg *sarama.ConsumerMessage) ([]byte, error) {
+		decodes++
+		return msg.Value, nil
+	}}
+	formatter := newMessageFormatter(outputOptions{format: "raw", keysOnly: true, rekey: template}, decoder, nil)
+
+	msg := &sarama.ConsumerMessage{Key: []byte("old"), Value: []byte(`{"id":"new"}`)}
+	if line := string(formatter(msg)); line != "new" || string(msg.Key) != "new" {
+		t.Errorf("Expected the new key, printed %q with key %q", line, msg.Key)
+	}
+
+	tombstone := &sarama.ConsumerMessage{Key: []byte("old")}
+	if line := string(formatter(tombstone)); line != "old" {
+		t.Errorf("Expected tombstones to keep their key, got %q", line)
+	}
+	if decodes != 2 {
+		t.Errorf("Expected every value to be decoded once, decoded %d times", decodes)
+	}
+}
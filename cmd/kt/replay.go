@@ -0,0 +1,112 @@
+package main
+
+import (
+	"log"
+	"os"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/jurriaan/kafkatools"
+)
+
+// replay re-emits a bounded range of messages while reproducing the time gaps
+// between their record timestamps, scaled by the configured speed
+func replay(client sarama.Client, parsedOptions options) {
+	if parsedOptions.errorFile != "" {
+		defer parsedOptions.decoder.openErrorFile(parsedOptions.errorFile)()
+	}
+	defer parsedOptions.decoder.close()
+	defer parsedOptions.decoder.logSummary(parsedOptions.errorFile)
+
+	partitionOffsets, endOffsets, leaders := fetchTopicsPartitionOffsets(client, parsedOptions)
+
+	formatter := newMessageFormatter(parsedOptions.output, parsedOptions.decoder, leaders)
+	emit := func(msg *sarama.ConsumerMessage) error {
+		writeMessage(os.Stdout, parsedOptions.output.format, formatter(msg))
+		return nil
+	}
+
+	if parsedOptions.toTopic != "" {
+		destination, closeDestination := destinationClient(client, parsedOptions)
+		defer closeDestination()
+		producer, err := sarama.NewSyncProducerFromClient(destination)
+		if err != nil {
+			log.Fatalf("Could not start producer: %v", err)
+		}
+		defer func() {
+			if err := producer.Close(); err != nil {
+				log.Fatal("Could not properly close the producer: ", err)
+			}
+		}()
+
+		emit = func(msg *sarama.ConsumerMessage) error {
+			_, _, err := producer.SendMessage(replayProducerMessage(parsedOptions.toTopic, msg))
+			return err
+		}
+	}
+
+	consumer, err := sarama.NewConsumerFromClient(client)
+	if err != nil {
+		log.Fatalf("Could not start consumer: %v", err)
+	}
+
+	kafkatools.Log.Infof("Replaying at %gx speed", parsedOptions.speed)
+	messages, closing := consumeTopics(consumer, partitionOffsets, endOffsets, parsedOptions.consumeOpts)
+	stop := shutdownHandler(closing, parsedOptions)
+
+	replayMessages(messages, parsedOptions.count, parsedOptions.speed, emit, time.Sleep)
+	stop()
+	drainMessages(messages, parsedOptions.drainTimeout)
+}
+
+func replayMessages(messages <-chan *sarama.ConsumerMessage, maxMessages int, speed float64, emit func(*sarama.ConsumerMessage) error, sleep func(time.Duration)) {
+	var firstTimestamp, started time.Time
+	counter := 0
+
+	for msg := range messages {
+		// Messages without a timestamp (pre 0.10 message format) are emitted right away
+		if !msg.Timestamp.IsZero() {
+			if firstTimestamp.IsZero() {
+				firstTimestamp = msg.Timestamp
+				started = time.Now()
+			} else if delay := replayDelay(firstTimestamp, msg.Timestamp, speed) - time.Since(started); delay > 0 {
+				sleep(delay)
+			}
+		}
+
+		if err := emit(msg); err != nil {
+			log.Fatalf("Could not replay message %s:%d at offset %d: %v", msg.Topic, msg.Partition, msg.Offset, err)
+		}
+
+		if maxMessages != -1 {
+			counter++
+			if counter >= maxMessages {
+				kafkatools.Log.Infof("Quiting after %d messages", counter)
+				return
+			}
+		}
+	}
+}
+
+// replayDelay returns when a message should be emitted relative to the first replayed message
+func replayDelay(firstTimestamp, timestamp time.Time, speed float64) time.Duration {
+	return time.Duration(float64(timestamp.Sub(firstTimestamp)) / speed)
+}
+
+func replayProducerMessage(topic string, msg *sarama.ConsumerMessage) *sarama.ProducerMessage {
+	producerMessage := &sarama.ProducerMessage{Topic: topic}
+
+	// Keep nil keys and values (tombstones) nil
+	if msg.Key != nil {
+		producerMessage.Key = sarama.ByteEncoder(msg.Key)
+	}
+	if msg.Value != nil {
+		producerMessage.Value = sarama.ByteEncoder(msg.Value)
+	}
+
+	for _, header := range msg.Headers {
+		producerMessage.Headers = append(producerMessage.Headers, *header)
+	}
+
+	return producerMessage
+}
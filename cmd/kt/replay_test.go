@@ -0,0 +1,62 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+)
+
+func TestReplayDelay(t *testing.T) {
+	first := time.Unix(1500000000, 0)
+
+	tests := []struct {
+		timestamp time.Time
+		speed     float64
+		expected  time.Duration
+	}{
+		{first, 1, 0},
+		{first.Add(2 * time.Second), 1, 2 * time.Second},
+		{first.Add(2 * time.Second), 2, time.Second},
+		{first.Add(time.Second), 0.5, 2 * time.Second},
+		{first.Add(-time.Second), 1, -time.Second},
+	}
+
+	for _, test := range tests {
+		if delay := replayDelay(first, test.timestamp, test.speed); delay != test.expected {
+			t.Errorf("Expected a delay of %v at %gx speed, got %v", test.expected, test.speed, delay)
+		}
+	}
+}
+
+func TestReplayMessagesCount(t *testing.T) {
+	var replayed []string
+
+	channel := make(chan *sarama.ConsumerMessage, len(messages))
+	for _, message := range messages {
+		channel <- message
+	}
+	close(channel)
+
+	emit := func(msg *sarama.ConsumerMessage) error {
+		replayed = append(replayed, string(msg.Value))
+		return nil
+	}
+	replayMessages(channel, 3, 1, emit, func(time.Duration) {})
+
+	if len(replayed) != 3 {
+		t.Errorf("Expected 3 replayed messages, got %+v", replayed)
+	}
+}
+
+func TestReplayProducerMessageKeepsTombstones(t *testing.T) {
+	msg := replayProducerMessage("bar", &sarama.ConsumerMessage{Key: []byte("k")})
+
+	if msg.Topic != "bar" || msg.Value != nil {
+		t.Errorf("Expected a tombstone for topic bar, got %+v", msg)
+	}
+
+	if key, _ := msg.Key.Encode(); string(key) != "k" {
+		t.Errorf("Expected key k, got %s", key)
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/Shopify/sarama"
+)
+
+// resumePositions tracks where every partition stopped: the offset after its last printed message, or its start
+// offset when nothing was printed
+type resumePositions map[topicPartition]int64
+
+func newResumePositions(partitionOffsets topicOffsetMap) resumePositions {
+	positions := make(resumePositions)
+	for topic, offsets := range partitionOffsets {
+		for partition, offset := range offsets {
+			positions[topicPartition{Topic: topic, Partition: partition}] = offset.Offset
+		}
+	}
+	return positions
+}
+
+// printed moves the partition of the message past it
+func (p resumePositions) printed(msg *sarama.ConsumerMessage) {
+	p[topicPartition{Topic: msg.Topic, Partition: msg.Partition}] = msg.Offset + 1
+}
+
+// resumeOptions returns the options consuming every partition which did not reach its end offset from where it
+// stopped, one partition per line. The end offsets of a bounded range are added as a shell comment, as the range
+// cannot be ended at an offset.
+func (p resumePositions) resumeOptions(endOffsets topicOffsetMap) []string {
+	partitions := make([]topicPartition, 0, len(p))
+	for partition, next := range p {
+		if end, ok := endOffsets[partition.Topic][partition.Partition]; ok && next >= end.Offset {
+			continue
+		}
+		partitions = append(partitions, partition)
+	}
+	sort.Slice(partitions, func(i, j int) bool {
+		if partitions[i].Topic != partitions[j].Topic {
+			return partitions[i].Topic < partitions[j].Topic
+		}
+		return partitions[i].Partition < partitions[j].Partition
+	})
+
+	lines := make([]string, 0, len(partitions))
+	for _, partition := range partitions {
+		option := fmt.Sprintf("--topic %s --partition %d --offset %d", partition.Topic, partition.Partition, p[partition])
+		if end, ok := endOffsets[partition.Topic][partition.Partition]; ok {
+			option += fmt.Sprintf(" # until offset %d", end.Offset)
+		}
+		lines = append(lines, option)
+	}
+	return lines
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/Shopify/sarama"
+	"github.com/jurriaan/kafkatools"
+)
+
+func TestResumeOptions(t *testing.T) {
+	partitionOffsets := topicOffsetMap{
+		"foo": {
+			0: kafkatools.TopicPartitionOffset{Topic: "foo", Partition: 0, Offset: 10},
+			1: kafkatools.TopicPartitionOffset{Topic: "foo", Partition: 1, Offset: 20},
+			2: kafkatools.TopicPartitionOffset{Topic: "foo", Partition: 2, Offset: 30},
+		},
+		"bar": {0: kafkatools.TopicPartitionOffset{Topic: "bar", Partition: 0, Offset: 5}},
+	}
+	endOffsets := topicOffsetMap{
+		"foo": {
+			0: kafkatools.TopicPartitionOffset{Topic: "foo", Partition: 0, Offset: 12},
+			1: kafkatools.TopicPartitionOffset{Topic: "foo", Partition: 1, Offset: 25},
+			2: kafkatools.TopicPartitionOffset{Topic: "foo", Partition: 2, Offset: 40},
+		},
+	}
+
+	positions := newResumePositions(partitionOffsets)
+	for _, msg := range []*sarama.ConsumerMessage{
+		{Topic: "foo", Partition: 0, Offset: 10},
+		{Topic: "foo", Partition: 0, Offset: 11},
+		{Topic: "foo", Partition: 1, Offset: 22},
+		{Topic: "bar", Partition: 0, Offset: 7},
+	} {
+		positions.printed(msg)
+	}
+
+	// foo partition 0 reached its end, bar is not bounded
+	expected := []string{
+		"--topic bar --partition 0 --offset 8",
+		"--topic foo --partition 1 --offset 23 # until offset 25",
+		"--topic foo --partition 2 --offset 30 # until offset 40",
+	}
+	if resume := positions.resumeOptions(endOffsets); !reflect.DeepEqual(resume, expected) {
+		t.Errorf("Expected %q, got %q", expected, resume)
+	}
+
+	var none resumePositions
+	if resume := none.resumeOptions(endOffsets); len(resume) != 0 {
+		t.Errorf("Expected nothing to resume without printed partitions, got %q", resume)
+	}
+}
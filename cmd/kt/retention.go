@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/jurriaan/kafkatools"
+)
+
+// logRetention logs the oldest offset of every partition and when its record was written, so it is clear how far back
+// consuming from the beginning goes
+func logRetention(client sarama.Client, topic string, oldest offsetMap) {
+	consumer, err := sarama.NewConsumerFromClient(client)
+	if err != nil {
+		log.Println("Could not start consumer to look up the oldest records: ", err)
+		return
+	}
+	defer func() {
+		if err := consumer.Close(); err != nil {
+			log.Println("Error closing the consumer: ", err)
+		}
+	}()
+
+	newest := fetchTopicOffsets(client, sarama.OffsetNewest, topic)
+	for _, line := range formatRetention(oldest, newest, oldestTimestamps(consumer, oldest, newest), time.Now()) {
+		kafkatools.Log.Infof("%s", line)
+	}
+}
+
+// oldestTimestamps reads the record at the oldest offset of every non-empty partition and returns its timestamp
+func oldestTimestamps(consumer sarama.Consumer, oldest, newest offsetMap) map[int32]time.Time {
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+	timestamps := make(map[int32]time.Time)
+
+	for partition, offset := range oldest {
+		if offset.Offset >= newest[partition].Offset {
+			continue
+		}
+
+		wg.Add(1)
+		go func(offset kafkatools.TopicPartitionOffset) {
+			defer wg.Done()
+			pc, err := consumer.ConsumePartition(offset.Topic, offset.Partition, offset.Offset)
+			if err != nil {
+				kafkatools.Log.Errorf("ERROR: Failed to start consumer for %s partition %d: %s", offset.Topic, offset.Partition, err)
+				return
+			}
+			defer func() {
+				if err := pc.Close(); err != nil {
+					kafkatools.Log.Errorf("ERROR: Failed to close consumer for %s partition %d: %s", offset.Topic, offset.Partition, err)
+				}
+			}()
+			go processErrors(pc)
+
+			select {
+			case msg, ok := <-pc.Messages():
+				if ok {
+					mutex.Lock()
+					timestamps[offset.Partition] = msg.Timestamp
+					mutex.Unlock()
+				}
+			case <-time.After(sampleTimeout):
+			}
+		}(offset)
+	}
+
+	wg.Wait()
+	return timestamps
+}
+
+// formatRetention describes the oldest record of every partition sorted by partition
+func formatRetention(oldest, newest offsetMap, timestamps map[int32]time.Time, now time.Time) (lines []string) {
+	partitions := make([]int32, 0, len(oldest))
+	for partition := range oldest {
+		partitions = append(partitions, partition)
+	}
+	sort.Slice(partitions, func(i, j int) bool { return partitions[i] < partitions[j] })
+
+	for _, partition := range partitions {
+		offset := oldest[partition].Offset
+		timestamp, ok := timestamps[partition]
+		switch {
+		case offset >= newest[partition].Offset:
+			lines = append(lines, fmt.Sprintf("Partition %d is empty, its oldest offset is %d", partition, offset))
+		case !ok || timestamp.IsZero():
+			lines = append(lines, fmt.Sprintf("Partition %d starts at offset %d, its timestamp is unknown", partition, offset))
+		default:
+			lines = append(lines, fmt.Sprintf("Partition %d starts at offset %d, written at %s (%s ago)", partition, offset,
+				timestamp.UTC().Format(time.RFC3339), now.Sub(timestamp).Truncate(time.Second)))
+		}
+	}
+	return lines
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/Shopify/sarama/mocks"
+	"github.com/jurriaan/kafkatools"
+)
+
+func TestOldestTimestamps(t *testing.T) {
+	written := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	consumer := mocks.NewConsumer(t, nil)
+	consumer.ExpectConsumePartition("foo", 0, 5).YieldMessage(&sarama.ConsumerMessage{Timestamp: written})
+
+	oldest := offsetMap{
+		0: kafkatools.TopicPartitionOffset{Topic: "foo", Partition: 0, Offset: 5},
+		1: kafkatools.TopicPartitionOffset{Topic: "foo", Partition: 1, Offset: 7},
+	}
+	newest := offsetMap{
+		0: kafkatools.TopicPartitionOffset{Topic: "foo", Partition: 0, Offset: 10},
+		1: kafkatools.TopicPartitionOffset{Topic: "foo", Partition: 1, Offset: 7},
+	}
+
+	// The empty partition 1 is not consumed
+	timestamps := oldestTimestamps(consumer, oldest, newest)
+	if !reflect.DeepEqual(timestamps, map[int32]time.Time{0: written}) {
+		t.Errorf("Expected the timestamp of partition 0, got %v", timestamps)
+	}
+
+	oldest[2] = kafkatools.TopicPartitionOffset{Topic: "foo", Partition: 2, Offset: 0}
+	newest[2] = kafkatools.TopicPartitionOffset{Topic: "foo", Partition: 2, Offset: 3}
+	expected := []string{
+		"Partition 0 starts at offset 5, written at 2020-01-01T12:00:00Z (3h30m0s ago)",
+		"Partition 1 is empty, its oldest offset is 7",
+		"Partition 2 starts at offset 0, its timestamp is unknown",
+	}
+	if lines := formatRetention(oldest, newest, timestamps, written.Add(210*time.Minute)); !reflect.DeepEqual(lines, expected) {
+		t.Errorf("Expected %v, got %v", expected, lines)
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/Shopify/sarama"
+	"github.com/jurriaan/kafkatools"
+)
+
+// reverseMessages buffers the messages until the channel is closed and then sends them newest first, which reverses
+// the offset order of every partition. It fails when more than maxBuffer messages have to be buffered.
+func reverseMessages(messages <-chan *sarama.ConsumerMessage, maxBuffer int) chan *sarama.ConsumerMessage {
+	reversed := make(chan *sarama.ConsumerMessage)
+	go func() {
+		defer close(reversed)
+		buffered, err := bufferMessages(messages, maxBuffer)
+		if err != nil {
+			log.Fatal("Could not reverse the messages: ", err)
+		}
+
+		kafkatools.Log.Infof("Consumed %d messages, printing them newest first", len(buffered))
+		for i := len(buffered) - 1; i >= 0; i-- {
+			reversed <- buffered[i]
+		}
+	}()
+	return reversed
+}
+
+// bufferMessages reads all messages of the channel, at most maxBuffer
+func bufferMessages(messages <-chan *sarama.ConsumerMessage, maxBuffer int) ([]*sarama.ConsumerMessage, error) {
+	var buffered []*sarama.ConsumerMessage
+	for msg := range messages {
+		if len(buffered) >= maxBuffer {
+			return nil, fmt.Errorf("the range holds more than %d messages, raise --max-buffer or narrow the range", maxBuffer)
+		}
+		buffered = append(buffered, msg)
+	}
+	return buffered, nil
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/Shopify/sarama"
+)
+
+func TestReverseMessages(t *testing.T) {
+	messages := make(chan *sarama.ConsumerMessage, 4)
+	messages <- &sarama.ConsumerMessage{Partition: 0, Offset: 1}
+	messages <- &sarama.ConsumerMessage{Partition: 1, Offset: 7}
+	messages <- &sarama.ConsumerMessage{Partition: 0, Offset: 2}
+	messages <- &sarama.ConsumerMessage{Partition: 1, Offset: 8}
+	close(messages)
+
+	var offsets []int64
+	for msg := range reverseMessages(messages, 4) {
+		offsets = append(offsets, msg.Offset)
+	}
+	if len(offsets) != 4 || offsets[0] != 8 || offsets[1] != 2 || offsets[2] != 7 || offsets[3] != 1 {
+		t.Errorf("Expected the offsets newest first, got %v", offsets)
+	}
+}
+
+func TestBufferMessagesLimit(t *testing.T) {
+	messages := make(chan *sarama.ConsumerMessage, 3)
+	for i := 0; i < 3; i++ {
+		messages <- &sarama.ConsumerMessage{Offset: int64(i)}
+	}
+	close(messages)
+
+	if _, err := bufferMessages(messages, 2); err == nil || err.Error() != "the range holds more than 2 messages, raise --max-buffer or narrow the range" {
+		t.Errorf("Expected the buffer to overflow, got %v", err)
+	}
+}
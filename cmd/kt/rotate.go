@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+)
+
+// rotatingFile writes to the file at path until a write would make it exceed max bytes, the file is then renamed to
+// <path>.1 (then <path>.2, …) and a new file is started at path. Every write ends up whole in a single file, so a file
+// only exceeds max bytes when a single write does.
+type rotatingFile struct {
+	path string
+	max  int64
+
+	file *os.File
+	size int64
+	// rotations is the number of files renamed so far
+	rotations int
+}
+
+func createRotatingFile(path string, max int64) (*rotatingFile, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	return &rotatingFile{path: path, max: max, file: file}, nil
+}
+
+func (f *rotatingFile) Write(p []byte) (int, error) {
+	if f.size > 0 && f.size+int64(len(p)) > f.max {
+		if err := f.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+// rotate renames the full file to the next numbered path and starts a new file at the path
+func (f *rotatingFile) rotate() error {
+	if err := f.Close(); err != nil {
+		return err
+	}
+	f.rotations++
+	if err := os.Rename(f.path, fmt.Sprintf("%s.%d", f.path, f.rotations)); err != nil {
+		return err
+	}
+
+	file, err := os.Create(f.path)
+	if err != nil {
+		return err
+	}
+	f.file, f.size = file, 0
+	return nil
+}
+
+// Close flushes the current file to disk and closes it
+func (f *rotatingFile) Close() error {
+	if err := f.file.Sync(); err != nil {
+		f.file.Close()
+		return err
+	}
+	return f.file.Close()
+}
+
+// createOutputFile creates the --output-file, rotated once it reaches --rotate-bytes when that is set
+func createOutputFile(parsedOptions options) io.WriteCloser {
+	if parsedOptions.rotateBytes > 0 {
+		file, err := createRotatingFile(parsedOptions.outputFile, parsedOptions.rotateBytes)
+		if err != nil {
+			log.Fatal("Could not create the output file: ", err)
+		}
+		return file
+	}
+
+	file, err := os.Create(parsedOptions.outputFile)
+	if err != nil {
+		log.Fatal("Could not create the output file: ", err)
+	}
+	return file
+}
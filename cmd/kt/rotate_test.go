@@ -0,0 +1,43 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRotatingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out")
+	file, err := createRotatingFile(path, 8)
+	if err != nil {
+		t.Fatal("Unexpected error: ", err)
+	}
+
+	// A write larger than the size gets a file of its own
+	for _, line := range []string{"abc\n", "def\n", "ghi\n", "a longer line\n", "j\n"} {
+		if _, err := file.Write([]byte(line)); err != nil {
+			t.Fatal("Unexpected error: ", err)
+		}
+	}
+	if err := file.Close(); err != nil {
+		t.Fatal("Unexpected error: ", err)
+	}
+
+	for name, expected := range map[string]string{
+		"out.1": "abc\ndef\n",
+		"out.2": "ghi\n",
+		"out.3": "a longer line\n",
+		"out":   "j\n",
+	} {
+		content, err := os.ReadFile(filepath.Join(filepath.Dir(path), name))
+		if err != nil {
+			t.Fatal("Unexpected error: ", err)
+		}
+		if string(content) != expected {
+			t.Errorf("Expected %s to contain %q, got %q", name, expected, content)
+		}
+	}
+	if _, err := os.Stat(path + ".4"); !os.IsNotExist(err) {
+		t.Errorf("Expected only 3 rotated files, got %v", err)
+	}
+}
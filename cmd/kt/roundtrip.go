@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/jurriaan/kafkatools"
+)
+
+// roundTrip produces generated messages to the topic, consumes them back and verifies that every message arrived
+// exactly once with its key and value intact, it exits non-zero on any mismatch
+func roundTrip(client sarama.Client, parsedOptions options) {
+	runID := fmt.Sprintf("kt-round-trip-%d", time.Now().UnixNano())
+	sent := roundTripMessages(parsedOptions.topic, runID, parsedOptions.roundTripMessages)
+
+	producer, err := sarama.NewSyncProducerFromClient(client)
+	if err != nil {
+		log.Fatalf("Could not start producer: %v", err)
+	}
+
+	send := producer.SendMessages
+	if producer.IsTransactional() {
+		send = func(batch []*sarama.ProducerMessage) error {
+			return sendTransaction(producer, batch)
+		}
+	}
+
+	for start := 0; start < len(sent); start += parsedOptions.batchSize {
+		end := start + parsedOptions.batchSize
+		if end > len(sent) {
+			end = len(sent)
+		}
+		if countFailures(send(sent[start:end]), sent[start:end]) > 0 {
+			log.Fatalf("Failed to produce the messages of round-trip %s", runID)
+		}
+	}
+	if err := producer.Close(); err != nil {
+		log.Println("Error closing the producer: ", err)
+	}
+	kafkatools.Log.Infof("Produced %d messages to %s, consuming them back", len(sent), parsedOptions.topic)
+
+	consumer, err := sarama.NewConsumerFromClient(client)
+	if err != nil {
+		log.Fatal("Could not start consumer: ", err)
+	}
+
+	startOffsets := topicOffsetMap{parsedOptions.topic: roundTripStartOffsets(sent)}
+	messages, closing := consumeTopics(consumer, startOffsets, nil, consumeOptions{})
+	received := collectRoundTrip(messages, runID, len(sent), parsedOptions.timeout)
+	close(closing)
+	drainMessages(messages, parsedOptions.drainTimeout)
+
+	problems := verifyRoundTrip(sent, received)
+	for _, problem := range problems {
+		fmt.Println(problem)
+	}
+	if len(problems) > 0 {
+		log.Fatalf("Round-trip %s failed: %d problems", runID, len(problems))
+	}
+	kafkatools.Log.Infof("Round-trip %s succeeded: consumed all %d messages back", runID, len(sent))
+}
+
+// roundTripMessages generates n messages, the keys start with the run ID to tell them apart from other messages
+func roundTripMessages(topic, runID string, n int) []*sarama.ProducerMessage {
+	messages := make([]*sarama.ProducerMessage, n)
+	for i := range messages {
+		messages[i] = &sarama.ProducerMessage{
+			Topic: topic,
+			Key:   sarama.StringEncoder(fmt.Sprintf("%s-%d", runID, i)),
+			Value: sarama.StringEncoder(fmt.Sprintf("message %d of %s", i, runID)),
+		}
+	}
+	return messages
+}
+
+// roundTripStartOffsets returns the lowest offset the producer reported per partition
+func roundTripStartOffsets(sent []*sarama.ProducerMessage) offsetMap {
+	offsets := make(offsetMap)
+	for _, msg := range sent {
+		if offset, ok := offsets[msg.Partition]; !ok || msg.Offset < offset.Offset {
+			offsets[msg.Partition] = kafkatools.TopicPartitionOffset{Topic: msg.Topic, Partition: msg.Partition, Offset: msg.Offset}
+		}
+	}
+	return offsets
+}
+
+// collectRoundTrip returns the consumed messages of the run until n of them arrived or the timeout expired
+func collectRoundTrip(messages <-chan *sarama.ConsumerMessage, runID string, n int, timeout time.Duration) (received []*sarama.ConsumerMessage) {
+	deadline := time.After(timeout)
+	for len(received) < n {
+		select {
+		case msg, ok := <-messages:
+			if !ok {
+				return received
+			}
+			if strings.HasPrefix(string(msg.Key), runID+"-") {
+				received = append(received, msg)
+			}
+		case <-deadline:
+			log.Printf("Timed out after %v waiting for the messages", timeout)
+			return received
+		}
+	}
+	return received
+}
+
+// verifyRoundTrip describes every sent message that was not received exactly once with the same value
+func verifyRoundTrip(sent []*sarama.ProducerMessage, received []*sarama.ConsumerMessage) (problems []string) {
+	counts := make(map[string]int, len(received))
+	values := make(map[string][]byte, len(received))
+	for _, msg := range received {
+		counts[string(msg.Key)]++
+		values[string(msg.Key)] = msg.Value
+	}
+
+	for _, msg := range sent {
+		key, _ := msg.Key.Encode()
+		value, _ := msg.Value.Encode()
+		switch count := counts[string(key)]; {
+		case count == 0:
+			problems = append(problems, fmt.Sprintf("%s: missing", key))
+		case count > 1:
+			problems = append(problems, fmt.Sprintf("%s: received %d times", key, count))
+		case string(values[string(key)]) != string(value):
+			problems = append(problems, fmt.Sprintf("%s: expected value %q, got %q", key, value, values[string(key)]))
+		}
+	}
+	return problems
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/Shopify/sarama"
+)
+
+func TestVerifyRoundTrip(t *testing.T) {
+	sent := roundTripMessages("foo", "run", 4)
+
+	received := make([]*sarama.ConsumerMessage, 0, len(sent))
+	for _, msg := range sent {
+		key, _ := msg.Key.Encode()
+		value, _ := msg.Value.Encode()
+		received = append(received, &sarama.ConsumerMessage{Key: key, Value: value})
+	}
+	if problems := verifyRoundTrip(sent, received); len(problems) != 0 {
+		t.Errorf("Expected no problems, got %v", problems)
+	}
+
+	// Lose the first message, duplicate the second and corrupt the third
+	received = []*sarama.ConsumerMessage{received[1], received[1], {Key: received[2].Key, Value: []byte("corrupt")}, received[3]}
+	expected := []string{
+		"run-0: missing",
+		"run-1: received 2 times",
+		`run-2: expected value "message 2 of run", got "corrupt"`,
+	}
+	if problems := verifyRoundTrip(sent, received); !reflect.DeepEqual(problems, expected) {
+		t.Errorf("Expected problems %v, got %v", expected, problems)
+	}
+}
+
+func TestRoundTripStartOffsets(t *testing.T) {
+	sent := roundTripMessages("foo", "run", 3)
+	sent[0].Partition, sent[0].Offset = 0, 12
+	sent[1].Partition, sent[1].Offset = 1, 4
+	sent[2].Partition, sent[2].Offset = 0, 10
+
+	offsets := roundTripStartOffsets(sent)
+	if len(offsets) != 2 || offsets[0].Offset != 10 || offsets[1].Offset != 4 || offsets[1].Topic != "foo" {
+		t.Errorf("Expected start offsets 10 and 4, got %v", offsets)
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"errors"
+	"log"
+
+	"github.com/Shopify/sarama"
+	"github.com/jurriaan/kafkatools"
+)
+
+// decodeMessageKey replaces the key of the message by its decoded JSON, keys which can't be decoded are kept
+func decodeMessageKey(msg *sarama.ConsumerMessage, registry *kafkatools.SchemaRegistry) {
+	if msg.Key == nil {
+		return
+	}
+
+	key, err := registry.Decode(msg.Key)
+	if err != nil {
+		log.Printf("Could not decode the key at offset %d of %s partition %d, printing it as is: %v", msg.Offset, msg.Topic, msg.Partition, err)
+		return
+	}
+	msg.Key = key
+}
+
+// newAvroDecoder returns the decoder of --avro, it decodes the values in the wire format of the registry as JSON.
+// Values without the magic byte of the wire format are printed as is with a warning.
+func newAvroDecoder(registry *kafkatools.SchemaRegistry) decoder {
+	return func(msg *sarama.ConsumerMessage) ([]byte, error) {
+		if msg.Value == nil {
+			return nil, nil
+		}
+
+		value, err := registry.Decode(msg.Value)
+		if errors.Is(err, kafkatools.ErrNotSchemaRegistryFormat) {
+			kafkatools.Log.Warnf("WARNING: the message at offset %d of %s partition %d is not in the schema registry wire format, printing it as is", msg.Offset, msg.Topic, msg.Partition)
+			return msg.Value, nil
+		}
+		return value, err
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Shopify/sarama"
+	"github.com/jurriaan/kafkatools"
+)
+
+// newTestSchemaRegistry serves schema 3, a record with a string id field
+func newTestSchemaRegistry(t *testing.T) *kafkatools.SchemaRegistry {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/schemas/ids/3" {
+			http.Error(w, `{"error_code": 40403, "message": "Schema not found"}`, http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(`{"schema": "{\"type\": \"record\", \"name\": \"Key\", \"fields\": [{\"name\": \"id\", \"type\": \"string\"}]}"}`))
+	}))
+	t.Cleanup(server.Close)
+	return kafkatools.NewSchemaRegistry(server.URL)
+}
+
+// framedRecord is the record of schema 3 with the id, in the wire format of the registry
+func framedRecord(schemaID byte, id string) []byte {
+	return append([]byte{0, 0, 0, 0, schemaID, byte(2 * len(id))}, id...)
+}
+
+func TestDecodeMessageKey(t *testing.T) {
+	registry := newTestSchemaRegistry(t)
+
+	tests := []struct {
+		key, expected []byte
+	}{
+		{framedRecord(3, "c-1"), []byte(`{"id":"c-1"}`)},
+		// Keys which can't be decoded are kept
+		{framedRecord(5, "c-1"), framedRecord(5, "c-1")},
+		{[]byte("plain"), []byte("plain")},
+		{nil, nil},
+	}
+
+	for _, test := range tests {
+		msg := &sarama.ConsumerMessage{Topic: "foo", Key: test.key}
+		decodeMessageKey(msg, registry)
+		if string(msg.Key) != string(test.expected) || (msg.Key == nil) != (test.expected == nil) {
+			t.Errorf("Expected key %q, got %q", test.expected, msg.Key)
+		}
+	}
+}
+
+func TestAvroDecoder(t *testing.T) {
+	decode := newAvroDecoder(newTestSchemaRegistry(t))
+
+	tests := []struct {
+		value, expected []byte
+		fails           bool
+	}{
+		{framedRecord(3, "c-1"), []byte(`{"id":"c-1"}`), false},
+		// Values without the magic byte are printed as is
+		{[]byte("plain"), []byte("plain"), false},
+		{nil, nil, false},
+		{framedRecord(5, "c-1"), nil, true},
+	}
+
+	for _, test := range tests {
+		value, err := decode(&sarama.ConsumerMessage{Topic: "foo", Value: test.value})
+		if (err != nil) != test.fails || string(value) != string(test.expected) {
+			t.Errorf("Expected %q (failing: %v) for %q, got %q (%v)", test.expected, test.fails, test.value, value, err)
+		}
+	}
+}
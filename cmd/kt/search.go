@@ -0,0 +1,168 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/Shopify/sarama"
+	"github.com/jurriaan/kafkatools"
+	"github.com/olekukonko/tablewriter"
+)
+
+// topicSearchResult contains the number of messages of a topic which were scanned and matched the filter
+type topicSearchResult struct {
+	Topic    string
+	Messages int64
+	Scanned  int
+	Matches  int
+	// First are the first matching messages of the partitions with matches, by partition
+	First []*sarama.ConsumerMessage
+}
+
+func search(client sarama.Client, parsedOptions options) {
+	topics := matchingTopics(client, parsedOptions)
+	if len(topics) == 0 {
+		kafkatools.Log.Infof("No matching topics found")
+		return
+	}
+	sort.Strings(topics)
+
+	kafkatools.Log.Infof("Fetching offsets of %d topics", len(topics))
+	oldest := fetchTopicsOffsets(client, sarama.OffsetOldest, topics...)
+	newest := fetchTopicsOffsets(client, sarama.OffsetNewest, topics...)
+
+	consumer, err := sarama.NewConsumerFromClient(client)
+	if err != nil {
+		log.Fatalf("Could not start consumer: %v", err)
+	}
+
+	var results []topicSearchResult
+	var scanned int
+	for _, topic := range topics {
+		result := searchTopic(consumer, topic, oldest[topic], newest[topic], parsedOptions.consumeOpts.filter, parsedOptions.maxScanPerTopic)
+		scanned += result.Scanned
+		if result.Matches > 0 {
+			results = append(results, result)
+		}
+	}
+	if err := consumer.Close(); err != nil {
+		log.Println("Error closing the consumer: ", err)
+	}
+
+	kafkatools.Log.Infof("Scanned %d messages of %d topics, %d topics contain matching messages", scanned, len(topics), len(results))
+	if len(results) > 0 {
+		printSearchResults(results)
+	}
+}
+
+// searchTopic scans the partitions of the topic from their oldest to their newest offset for the messages matching
+// the filter. The topic is scanned concurrently, maxScan (0 scans everything) is split evenly over its partitions.
+func searchTopic(consumer sarama.Consumer, topic string, oldest, newest map[int32]kafkatools.TopicPartitionOffset, filter messageFilter, maxScan int) topicSearchResult {
+	result := topicSearchResult{Topic: topic}
+
+	var partitions []int32
+	for partition, newestOffset := range newest {
+		if messages := newestOffset.Offset - oldest[partition].Offset; messages > 0 {
+			result.Messages += messages
+			partitions = append(partitions, partition)
+		}
+	}
+	sort.Slice(partitions, func(i, j int) bool { return partitions[i] < partitions[j] })
+
+	var wg sync.WaitGroup
+	var mutex sync.Mutex
+	first := make(map[int32]*sarama.ConsumerMessage)
+	for i, partition := range partitions {
+		// The lowest partitions scan the remainder of the split
+		partitionScan := 0
+		if maxScan > 0 {
+			partitionScan = maxScan / len(partitions)
+			if i < maxScan%len(partitions) {
+				partitionScan++
+			}
+			if partitionScan == 0 {
+				continue
+			}
+		}
+
+		pc, err := consumer.ConsumePartition(topic, partition, oldest[partition].Offset)
+		if err != nil {
+			kafkatools.Log.Errorf("ERROR: Failed to start consumer for %s partition %d, skipping it: %s", topic, partition, err)
+			continue
+		}
+
+		wg.Add(1)
+		go processErrors(pc)
+		go func(pc sarama.PartitionConsumer, partition int32, endOffset int64) {
+			defer wg.Done()
+			scanned, matches, firstMatch := scanPartition(pc, filter, endOffset, partitionScan)
+			if err := pc.Close(); err != nil {
+				kafkatools.Log.Errorf("ERROR: Failed to close consumer for %s partition %d: %s", topic, partition, err)
+			}
+
+			mutex.Lock()
+			result.Scanned += scanned
+			result.Matches += matches
+			if firstMatch != nil {
+				first[partition] = firstMatch
+			}
+			mutex.Unlock()
+		}(pc, partition, newest[partition].Offset)
+	}
+	wg.Wait()
+
+	for _, partition := range partitions {
+		if msg, ok := first[partition]; ok {
+			result.First = append(result.First, msg)
+		}
+	}
+	return result
+}
+
+// scanPartition reads the partition until the end offset is reached or maxScan messages were read (0 reads the whole
+// range) and counts the messages matching the filter
+func scanPartition(pc sarama.PartitionConsumer, filter messageFilter, endOffset int64, maxScan int) (scanned, matches int, first *sarama.ConsumerMessage) {
+	for msg := range pc.Messages() {
+		if msg.Offset >= endOffset {
+			break
+		}
+
+		scanned++
+		if filter(msg) {
+			matches++
+			if first == nil {
+				first = msg
+			}
+		}
+
+		if msg.Offset >= endOffset-1 || (maxScan > 0 && scanned >= maxScan) {
+			break
+		}
+	}
+	return scanned, matches, first
+}
+
+// formatFirstMatches formats the first matches of the partitions as <partition>@<offset>
+func formatFirstMatches(first []*sarama.ConsumerMessage) string {
+	formatted := make([]string, 0, len(first))
+	for _, msg := range first {
+		formatted = append(formatted, fmt.Sprintf("%d@%d", msg.Partition, msg.Offset))
+	}
+	return strings.Join(formatted, " ")
+}
+
+func printSearchResults(results []topicSearchResult) {
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"topic", "matches", "scanned", "messages", "first matches (partition@offset)"})
+	for _, result := range results {
+		table.Append([]string{result.Topic, strconv.Itoa(result.Matches), strconv.Itoa(result.Scanned), strconv.FormatInt(result.Messages, 10), formatFirstMatches(result.First)})
+	}
+
+	table.SetAlignment(tablewriter.ALIGN_LEFT)
+	table.Render()
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/Shopify/sarama"
+	"github.com/Shopify/sarama/mocks"
+	"github.com/jurriaan/kafkatools"
+)
+
+func TestSearchTopic(t *testing.T) {
+	config := sarama.NewConfig()
+	config.Consumer.Return.Errors = true
+
+	consumer := mocks.NewConsumer(t, config)
+
+	oldest := map[int32]kafkatools.TopicPartitionOffset{0: {Offset: 0}, 1: {Offset: 0}, 2: {Offset: 5}}
+	newest := map[int32]kafkatools.TopicPartitionOffset{0: {Offset: 3}, 1: {Offset: 3}, 2: {Offset: 5}}
+
+	// The scan of 5 messages is split over the partitions with messages, partition 0 scans the remainder
+	first := consumer.ExpectConsumePartition("events", 0, 0)
+	first.YieldMessage(&sarama.ConsumerMessage{Value: []byte("a")})
+	first.YieldMessage(&sarama.ConsumerMessage{Value: []byte("needle")})
+	first.YieldMessage(&sarama.ConsumerMessage{Value: []byte("needle")})
+	second := consumer.ExpectConsumePartition("events", 1, 0)
+	second.YieldMessage(&sarama.ConsumerMessage{Value: []byte("needle")})
+	second.YieldMessage(&sarama.ConsumerMessage{Value: []byte("b")})
+	second.YieldMessage(&sarama.ConsumerMessage{Value: []byte("needle")})
+
+	result := searchTopic(consumer, "events", oldest, newest, newMessageFilter("needle", "", ""), 5)
+
+	if result.Messages != 6 || result.Scanned != 5 || result.Matches != 3 {
+		t.Errorf("Expected 6 messages, 5 scanned and 3 matches, got %+v", result)
+	}
+	if formatted := formatFirstMatches(result.First); formatted != "0@1 1@0" {
+		t.Errorf("Expected the first matches 0@1 1@0, got %s", formatted)
+	}
+}
+
+func TestSearchTopicEntirely(t *testing.T) {
+	config := sarama.NewConfig()
+	config.Consumer.Return.Errors = true
+
+	consumer := mocks.NewConsumer(t, config)
+
+	oldest := map[int32]kafkatools.TopicPartitionOffset{0: {Offset: 0}}
+	newest := map[int32]kafkatools.TopicPartitionOffset{0: {Offset: 2}}
+
+	pc := consumer.ExpectConsumePartition("events", 0, 0)
+	pc.YieldMessage(&sarama.ConsumerMessage{Key: []byte("k1")})
+	pc.YieldMessage(&sarama.ConsumerMessage{Key: []byte("k2")})
+
+	result := searchTopic(consumer, "events", oldest, newest, newMessageFilter("", "^k3$", ""), 0)
+
+	if result.Scanned != 2 || result.Matches != 0 || len(result.First) != 0 {
+		t.Errorf("Expected 2 messages scanned without matches, got %+v", result)
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// selectFields projects a JSON object onto the fields, a field is a dotted path where numeric elements index into
+// arrays. The fields are written in the given order, missing fields are null.
+func selectFields(value []byte, fields []string) ([]byte, error) {
+	decoder := json.NewDecoder(bytes.NewReader(value))
+	// Keep numbers as they were written instead of converting them to floats
+	decoder.UseNumber()
+
+	var object interface{}
+	if err := decoder.Decode(&object); err != nil {
+		return nil, fmt.Errorf("value is not JSON: %v", err)
+	}
+	if _, ok := object.(map[string]interface{}); !ok {
+		return nil, fmt.Errorf("value is not a JSON object")
+	}
+
+	var out bytes.Buffer
+	out.WriteByte('{')
+	for i, field := range fields {
+		if i > 0 {
+			out.WriteByte(',')
+		}
+
+		name, err := json.Marshal(field)
+		if err != nil {
+			return nil, err
+		}
+		selected, err := json.Marshal(lookupField(object, strings.Split(field, ".")))
+		if err != nil {
+			return nil, err
+		}
+		out.Write(name)
+		out.WriteByte(':')
+		out.Write(selected)
+	}
+	out.WriteByte('}')
+	return out.Bytes(), nil
+}
+
+// lookupField returns the value at the path or nil when it does not exist
+func lookupField(value interface{}, path []string) interface{} {
+	for _, element := range path {
+		switch container := value.(type) {
+		case map[string]interface{}:
+			value = container[element]
+		case []interface{}:
+			index, err := strconv.Atoi(element)
+			if err != nil || index < 0 || index >= len(container) {
+				return nil
+			}
+			value = container[index]
+		default:
+			return nil
+		}
+	}
+	return value
+}
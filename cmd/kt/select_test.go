@@ -0,0 +1,23 @@
+package main
+
+import "testing"
+
+func TestSelectFields(t *testing.T) {
+	value := []byte(`{"id": 12345678901234567890, "user": {"name": "jane", "roles": ["admin", "dev"]}, "wide": "ignored"}`)
+
+	selected, err := selectFields(value, []string{"user.name", "id", "user.roles.1", "missing", "user.roles.5", "id.nested"})
+	if err != nil {
+		t.Fatal("Unexpected error: ", err)
+	}
+
+	expected := `{"user.name":"jane","id":12345678901234567890,"user.roles.1":"dev","missing":null,"user.roles.5":null,"id.nested":null}`
+	if string(selected) != expected {
+		t.Errorf("Expected %s, got %s", expected, selected)
+	}
+
+	for _, invalid := range []string{"not json", "[1, 2]", "42", ""} {
+		if selected, err := selectFields([]byte(invalid), []string{"id"}); err == nil {
+			t.Errorf("Expected an error for %q, got %s", invalid, selected)
+		}
+	}
+}
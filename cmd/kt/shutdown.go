@@ -0,0 +1,157 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/jurriaan/kafkatools"
+)
+
+// shutdownHandler returns the function stopping the partition consumers. It is also
+// called when a shutdown signal is received and once the --max-age has passed.
+func shutdownHandler(closing chan struct{}, parsedOptions options) (stop func()) {
+	stop = closeOnce(closing)
+	stopOnSignal(stop)
+	if parsedOptions.maxAge > 0 {
+		stopAfter(parsedOptions.maxAge, stop)
+	}
+	parsedOptions.deadline.consuming(stop)
+	return stop
+}
+
+// timedOutExitCode is the exit status of kt consume when its --timeout expired, the status of timeout(1)
+const timedOutExitCode = 124
+
+// consumeDeadline bounds the total runtime of kt consume. When it expires before the messages are consumed kt exits
+// right away, otherwise the partition consumers are stopped so the output and summaries are completed before exiting.
+type consumeDeadline struct {
+	mutex   sync.Mutex
+	timeout time.Duration
+	expired bool
+	// stop stops the partition consumers, nil until consuming started
+	stop func()
+	// exitProcess is os.Exit, replaced in tests
+	exitProcess func(code int)
+}
+
+// startDeadline returns the deadline expiring after the timeout
+func startDeadline(timeout time.Duration) *consumeDeadline {
+	d := &consumeDeadline{timeout: timeout, exitProcess: os.Exit}
+	time.AfterFunc(timeout, d.expire)
+	return d
+}
+
+func (d *consumeDeadline) expire() {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	kafkatools.Log.Warnf("Timed out after %v", d.timeout)
+	d.expired = true
+	if d.stop == nil {
+		d.exitProcess(timedOutExitCode)
+		return
+	}
+	d.stop()
+}
+
+// consuming registers the function stopping the partition consumers, it is a no-op on a nil deadline
+func (d *consumeDeadline) consuming(stop func()) {
+	if d == nil {
+		return
+	}
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.stop = stop
+}
+
+// exit exits with the timed out status when the deadline expired, it is a no-op on a nil deadline
+func (d *consumeDeadline) exit() {
+	if d == nil {
+		return
+	}
+	d.mutex.Lock()
+	expired := d.expired
+	d.mutex.Unlock()
+	if expired {
+		d.exitProcess(timedOutExitCode)
+	}
+}
+
+// closeOnce returns a function closing the channel, which is safe to call multiple times
+func closeOnce(closing chan struct{}) func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() { close(closing) })
+	}
+}
+
+// stopOnSignal calls stop when an interrupt or termination signal is received.
+// A second signal terminates the process immediately.
+func stopOnSignal(stop func()) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		sig := <-signals
+		signal.Stop(signals)
+		kafkatools.Log.Infof("Received %v, shutting down", sig)
+		stop()
+	}()
+}
+
+// stopAfter calls stop once the duration has passed
+func stopAfter(duration time.Duration, stop func()) *time.Timer {
+	return time.AfterFunc(duration, func() {
+		kafkatools.Log.Infof("Stopping after %v", duration)
+		stop()
+	})
+}
+
+// drainMessages discards in-flight messages until all partition consumers have
+// shut down (and the messages channel is closed) or the timeout expires
+func drainMessages(messages <-chan *sarama.ConsumerMessage, timeout time.Duration) bool {
+	deadline := time.After(timeout)
+	for {
+		select {
+		case _, ok := <-messages:
+			if !ok {
+				return true
+			}
+		case <-deadline:
+			kafkatools.Log.Warnf("Consumers did not shut down within %v, force closing", timeout)
+			return false
+		}
+	}
+}
+
+// closeFailures collects the partitions whose consumer could not be closed
+type closeFailures struct {
+	mutex      sync.Mutex
+	partitions []string
+}
+
+// record logs the failure to close the consumer of the partition
+func (f *closeFailures) record(topic string, partition int32, err error) {
+	kafkatools.Log.Errorf("ERROR: Failed to close consumer for %s partition %d: %s", topic, partition, err)
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.partitions = append(f.partitions, fmt.Sprintf("%s/%d", topic, partition))
+}
+
+// err returns the failures as a single error, nil when all partition consumers were closed
+func (f *closeFailures) err() error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if len(f.partitions) == 0 {
+		return nil
+	}
+
+	sort.Strings(f.partitions)
+	return fmt.Errorf("could not close the consumers of %d partitions: %s", len(f.partitions), strings.Join(f.partitions, ", "))
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestStopAfter(t *testing.T) {
+	closing := make(chan struct{})
+	stopAfter(10*time.Millisecond, closeOnce(closing))
+
+	select {
+	case <-closing:
+	case <-time.After(time.Second):
+		t.Error("Expected the closing channel to be closed after the max age")
+	}
+}
+
+func TestCloseOnce(t *testing.T) {
+	closing := make(chan struct{})
+	stop := closeOnce(closing)
+	stop()
+	stop()
+
+	if _, ok := <-closing; ok {
+		t.Error("Expected the closing channel to be closed")
+	}
+}
+
+func TestCloseFailures(t *testing.T) {
+	var failures closeFailures
+	failures.record("foo", 1, errors.New("broker went away"))
+	failures.record("foo", 0, errors.New("broker went away"))
+
+	if err := failures.err(); err == nil || err.Error() != "could not close the consumers of 2 partitions: foo/0, foo/1" {
+		t.Errorf("Expected the failures of both partitions, got %v", err)
+	}
+	if err := (&closeFailures{}).err(); err != nil {
+		t.Errorf("Expected no error without failures, got %v", err)
+	}
+}
+
+func TestConsumeDeadline(t *testing.T) {
+	var exited []int
+	exit := func(code int) { exited = append(exited, code) }
+
+	// Expiring before consuming started exits right away
+	deadline := &consumeDeadline{timeout: time.Second, exitProcess: exit}
+	deadline.expire()
+	if len(exited) != 1 || exited[0] != timedOutExitCode {
+		t.Errorf("Expected to exit with %d, exited with %v", timedOutExitCode, exited)
+	}
+
+	// Expiring while consuming stops the consumers, kt exits once the output is complete
+	exited = nil
+	closing := make(chan struct{})
+	deadline = &consumeDeadline{timeout: time.Second, exitProcess: exit}
+	deadline.consuming(closeOnce(closing))
+	deadline.expire()
+	select {
+	case <-closing:
+	default:
+		t.Error("Expected the consumers to be stopped")
+	}
+	if len(exited) != 0 {
+		t.Errorf("Expected not to exit before the output is complete, exited with %v", exited)
+	}
+	deadline.exit()
+	if len(exited) != 1 || exited[0] != timedOutExitCode {
+		t.Errorf("Expected to exit with %d, exited with %v", timedOutExitCode, exited)
+	}
+
+	// Without a timeout or before expiring kt exits normally
+	exited = nil
+	(*consumeDeadline)(nil).consuming(func() {})
+	(*consumeDeadline)(nil).exit()
+	(&consumeDeadline{exitProcess: exit}).exit()
+	if len(exited) != 0 {
+		t.Errorf("Expected not to exit, exited with %v", exited)
+	}
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Shopify/sarama"
+)
+
+// messageSink is a destination of the consumed messages, write receives the message along with its formatted output
+type messageSink struct {
+	name  string
+	write func(msg *sarama.ConsumerMessage, formatted []byte) error
+	close func() error
+}
+
+// parseSinks validates the sink specifications: stdout, file:<path>, topic:<topic>, tcp://<host:port>,
+// udp://<host:port> or syslog
+func parseSinks(specs []string) ([]string, error) {
+	for _, spec := range specs {
+		parts := strings.SplitN(spec, ":", 2)
+		switch {
+		case spec == "stdout" || spec == "syslog":
+		case len(parts) == 2 && (parts[0] == "tcp" || parts[0] == "udp") && strings.HasPrefix(parts[1], "//"):
+			if _, _, err := net.SplitHostPort(parts[1][2:]); err != nil {
+				return nil, fmt.Errorf("invalid sink %q: %v", spec, err)
+			}
+		case len(parts) == 2 && (parts[0] == "file" || parts[0] == "topic") && parts[1] != "":
+		default:
+			return nil, fmt.Errorf("invalid sink %q, expected stdout, file:<path>, topic:<topic>, tcp://<host:port>, udp://<host:port> or syslog", spec)
+		}
+	}
+	return specs, nil
+}
+
+// openSinks opens the sinks, stdout writes to out and the topic sinks produce the original messages through the
+// client. The sinks opened before an error are closed.
+func openSinks(specs []string, client sarama.Client, out io.Writer, format string) ([]messageSink, error) {
+	sinks := make([]messageSink, 0, len(specs))
+	for _, spec := range specs {
+		sink, err := openSink(spec, client, out, format)
+		if err != nil {
+			closeSinks(sinks)
+			return nil, fmt.Errorf("could not open sink %s: %v", spec, err)
+		}
+		sinks = append(sinks, sink)
+	}
+	return sinks, nil
+}
+
+func openSink(spec string, client sarama.Client, out io.Writer, format string) (messageSink, error) {
+	parts := strings.SplitN(spec, ":", 2)
+	switch parts[0] {
+	case "tcp", "udp":
+		sink, err := newNetworkSink(spec)
+		if err != nil {
+			return messageSink{}, err
+		}
+		return messageSink{
+			name: spec,
+			write: func(_ *sarama.ConsumerMessage, formatted []byte) error {
+				if format != "binary" {
+					formatted = append(formatted, '\n')
+				}
+				return sink.write(formatted)
+			},
+			close: sink.close,
+		}, nil
+	case "syslog":
+		if format == "binary" {
+			return messageSink{}, fmt.Errorf("syslog messages are text, use the raw or ndjson output format")
+		}
+		sink, err := newSyslogSink()
+		if err != nil {
+			return messageSink{}, err
+		}
+		return messageSink{
+			name: spec,
+			write: func(_ *sarama.ConsumerMessage, formatted []byte) error {
+				return sink.write(formatSyslogMessage(formatted, time.Now()))
+			},
+			close: sink.close,
+		}, nil
+	case "file":
+		file, err := os.Create(parts[1])
+		if err != nil {
+			return messageSink{}, err
+		}
+		buffered := bufio.NewWriter(file)
+		return messageSink{
+			name: spec,
+			write: func(_ *sarama.ConsumerMessage, formatted []byte) error {
+				return writeFormatted(buffered, format, formatted)
+			},
+			close: func() error {
+				if err := buffered.Flush(); err != nil {
+					file.Close()
+					return err
+				}
+				return file.Close()
+			},
+		}, nil
+	case "topic":
+		producer, err := sarama.NewSyncProducerFromClient(client)
+		if err != nil {
+			return messageSink{}, err
+		}
+		return messageSink{
+			name: spec,
+			write: func(msg *sarama.ConsumerMessage, _ []byte) error {
+				_, _, err := producer.SendMessage(replayProducerMessage(parts[1], msg))
+				return err
+			},
+			close: producer.Close,
+		}, nil
+	default:
+		return messageSink{
+			name:  spec,
+			write: func(_ *sarama.ConsumerMessage, formatted []byte) error { return writeFormatted(out, format, formatted) },
+			close: func() error { return nil },
+		}, nil
+	}
+}
+
+// fanOut returns a writer of every message to all sinks. A failing sink is dropped while the others keep receiving the
+// messages, unless strict is set; an error is returned when strict or when no sink is left.
+func fanOut(sinks []messageSink, strict bool) func(msg *sarama.ConsumerMessage, formatted []byte) error {
+	failed := make([]bool, len(sinks))
+	left := len(sinks)
+	return func(msg *sarama.ConsumerMessage, formatted []byte) error {
+		for i, sink := range sinks {
+			if failed[i] {
+				continue
+			}
+			err := sink.write(msg, formatted)
+			if err == nil {
+				continue
+			}
+			if strict {
+				return fmt.Errorf("sink %s: %v", sink.name, err)
+			}
+
+			failed[i] = true
+			left--
+			if left == 0 {
+				return fmt.Errorf("sink %s: %v, no sinks left", sink.name, err)
+			}
+			log.Printf("Could not write the message at offset %d of %s partition %d to sink %s, no longer writing to it: %v", msg.Offset, msg.Topic, msg.Partition, sink.name, err)
+		}
+		return nil
+	}
+}
+
+// closeSinks closes all sinks, logging the sinks that could not be closed
+func closeSinks(sinks []messageSink) {
+	for _, sink := range sinks {
+		if err := sink.close(); err != nil {
+			log.Printf("Could not properly close sink %s: %v", sink.name, err)
+		}
+	}
+}
+
+// hasTopicSink returns whether one of the sinks produces to a topic
+func hasTopicSink(specs []string) bool {
+	for _, spec := range specs {
+		if strings.HasPrefix(spec, "topic:") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Shopify/sarama"
+)
+
+func TestParseSinks(t *testing.T) {
+	if _, err := parseSinks([]string{"stdout", "file:/tmp/a,b.json", "topic:copy", "tcp://localhost:5170", "udp://[::1]:514", "syslog"}); err != nil {
+		t.Errorf("Expected valid sinks, got %v", err)
+	}
+	for _, spec := range []string{"stderr", "file:", "topic", "kafka:copy", "tcp:localhost:5170", "udp://localhost"} {
+		if _, err := parseSinks([]string{spec}); err == nil {
+			t.Errorf("Expected sink %q to be invalid", spec)
+		}
+	}
+
+	if !hasTopicSink([]string{"stdout", "topic:copy"}) || hasTopicSink([]string{"stdout", "file:topic:copy"}) {
+		t.Error("Expected only the topic sink to produce to a topic")
+	}
+}
+
+func TestFanOut(t *testing.T) {
+	var first, second bytes.Buffer
+	failing := 0
+	newSinks := func() []messageSink {
+		return []messageSink{
+			{name: "first", write: func(_ *sarama.ConsumerMessage, formatted []byte) error {
+				return writeFormatted(&first, "raw", formatted)
+			}},
+			{name: "failing", write: func(*sarama.ConsumerMessage, []byte) error { failing++; return errors.New("broken") }},
+			{name: "second", write: func(_ *sarama.ConsumerMessage, formatted []byte) error {
+				return writeFormatted(&second, "raw", formatted)
+			}},
+		}
+	}
+
+	write := fanOut(newSinks(), false)
+	for _, value := range []string{"a", "b"} {
+		if err := write(&sarama.ConsumerMessage{}, []byte(value)); err != nil {
+			t.Errorf("Expected the failing sink to be dropped, got %v", err)
+		}
+	}
+	if first.String() != "a\nb\n" || second.String() != "a\nb\n" || failing != 1 {
+		t.Errorf("Expected the other sinks to get every message, got %q, %q and %d failures", first.String(), second.String(), failing)
+	}
+
+	first.Reset()
+	second.Reset()
+	if err := fanOut(newSinks(), true)(&sarama.ConsumerMessage{}, []byte("a")); err == nil || second.Len() != 0 {
+		t.Errorf("Expected strict sinks to stop at the failing sink, got %v", err)
+	}
+
+	if err := fanOut([]messageSink{newSinks()[1]}, false)(&sarama.ConsumerMessage{}, []byte("a")); err == nil {
+		t.Error("Expected an error when no sinks are left")
+	}
+}
+
+func TestFileSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "messages")
+
+	sinks, err := openSinks([]string{"file:" + path}, nil, nil, "raw")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := fanOut(sinks, true)(&sarama.ConsumerMessage{}, []byte("a")); err != nil {
+		t.Fatal(err)
+	}
+	closeSinks(sinks)
+
+	if written, err := os.ReadFile(path); err != nil || string(written) != "a\n" {
+		t.Errorf("Expected the message to be written to the file, got %q (%v)", written, err)
+	}
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/jurriaan/kafkatools"
+	"github.com/olekukonko/tablewriter"
+)
+
+// sampleTimeout bounds the time spent sampling a single partition, compacted or transactional partitions may not
+// contain a message at every offset
+const sampleTimeout = 5 * time.Second
+
+// topicSize contains the message count and the estimated size in bytes of a topic
+type topicSize struct {
+	Topic      string
+	Partitions int
+	Messages   int64
+	Bytes      int64
+}
+
+func sizes(client sarama.Client, parsedOptions options) {
+	topics := matchingTopics(client, parsedOptions)
+	if len(topics) == 0 {
+		kafkatools.Log.Infof("No matching topics found")
+		return
+	}
+
+	kafkatools.Log.Infof("Fetching offsets of %d topics", len(topics))
+	oldest := fetchTopicsOffsets(client, sarama.OffsetOldest, topics...)
+	newest := fetchTopicsOffsets(client, sarama.OffsetNewest, topics...)
+
+	consumer, err := sarama.NewConsumerFromClient(client)
+	if err != nil {
+		log.Fatalf("Could not start consumer: %v", err)
+	}
+
+	results := measureTopicSizes(consumer, topics, oldest, newest, parsedOptions.sampleSize)
+	if err := consumer.Close(); err != nil {
+		log.Println("Error closing the consumer: ", err)
+	}
+
+	printTopicSizes(results)
+}
+
+// matchingTopics returns the topics of the cluster matching --filter, without the internal topics unless they are
+// included
+func matchingTopics(client sarama.Client, parsedOptions options) []string {
+	topics, err := client.Topics()
+	if err != nil {
+		log.Fatal("Could not fetch topics: ", err)
+	}
+
+	if !parsedOptions.internalTopics {
+		topics = withoutInternalTopics(topics)
+	}
+	if parsedOptions.topicFilter != "" {
+		topicRegexp := compilePattern(parsedOptions.topicFilter)
+		matching := topics[:0]
+		for _, topic := range topics {
+			if topicRegexp.MatchString(topic) {
+				matching = append(matching, topic)
+			}
+		}
+		topics = matching
+	}
+	return topics
+}
+
+// measureTopicSizes sums the message counts of the partitions and estimates their size by sampling the sizes of
+// the last sampleSize records of every partition. The results are sorted by size, largest first.
+func measureTopicSizes(consumer sarama.Consumer, topics []string, oldest, newest map[string]map[int32]kafkatools.TopicPartitionOffset, sampleSize int) []topicSize {
+	var wg sync.WaitGroup
+	var mutex sync.Mutex
+	results := make(map[string]*topicSize)
+
+	for _, topic := range topics {
+		result := &topicSize{Topic: topic}
+		results[topic] = result
+
+		for partition, newestOffset := range newest[topic] {
+			oldestOffset := oldest[topic][partition].Offset
+			messages := newestOffset.Offset - oldestOffset
+
+			result.Partitions++
+			result.Messages += messages
+			if messages <= 0 || sampleSize == 0 {
+				continue
+			}
+
+			wg.Add(1)
+			go func(result *topicSize, partition int32, oldestOffset, newestOffset int64) {
+				defer wg.Done()
+				averageSize := sampleRecordSize(consumer, result.Topic, partition, oldestOffset, newestOffset, sampleSize)
+
+				mutex.Lock()
+				result.Bytes += int64(averageSize * float64(newestOffset-oldestOffset))
+				mutex.Unlock()
+			}(result, partition, oldestOffset, newestOffset.Offset)
+		}
+	}
+	wg.Wait()
+
+	sorted := make([]topicSize, 0, len(results))
+	for _, result := range results {
+		sorted = append(sorted, *result)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Bytes != sorted[j].Bytes {
+			return sorted[i].Bytes > sorted[j].Bytes
+		}
+		return sorted[i].Topic < sorted[j].Topic
+	})
+
+	return sorted
+}
+
+// sampleRecordSize reads up to sampleSize records from the end of the partition and returns their average size
+func sampleRecordSize(consumer sarama.Consumer, topic string, partition int32, oldestOffset, newestOffset int64, sampleSize int) float64 {
+	startOffset := newestOffset - int64(sampleSize)
+	if startOffset < oldestOffset {
+		startOffset = oldestOffset
+	}
+
+	pc, err := consumer.ConsumePartition(topic, partition, startOffset)
+	if err != nil {
+		kafkatools.Log.Errorf("ERROR: Failed to start consumer for %s partition %d: %s", topic, partition, err)
+		return 0
+	}
+	defer func() {
+		if err := pc.Close(); err != nil {
+			kafkatools.Log.Errorf("ERROR: Failed to close consumer for %s partition %d: %s", topic, partition, err)
+		}
+	}()
+	go processErrors(pc)
+
+	var sampled, totalSize int
+	timeout := time.After(sampleTimeout)
+sample:
+	for sampled < sampleSize {
+		select {
+		case msg, ok := <-pc.Messages():
+			if !ok || msg.Offset >= newestOffset {
+				break sample
+			}
+			sampled++
+			totalSize += recordSize(msg)
+			if msg.Offset >= newestOffset-1 {
+				break sample
+			}
+		case <-timeout:
+			break sample
+		}
+	}
+
+	if sampled == 0 {
+		return 0
+	}
+	return float64(totalSize) / float64(sampled)
+}
+
+// recordSize returns the size of the key, value and headers of the message
+func recordSize(msg *sarama.ConsumerMessage) (size int) {
+	size = len(msg.Key) + len(msg.Value)
+	for _, header := range msg.Headers {
+		size += len(header.Key) + len(header.Value)
+	}
+	return size
+}
+
+func printTopicSizes(results []topicSize) {
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"topic", "partitions", "messages", "estimated size"})
+	for _, result := range results {
+		table.Append([]string{result.Topic, strconv.Itoa(result.Partitions), strconv.FormatInt(result.Messages, 10), formatBytes(result.Bytes)})
+	}
+
+	table.SetAlignment(tablewriter.ALIGN_LEFT)
+	table.Render()
+}
+
+// formatBytes formats a size in bytes using binary prefixes
+func formatBytes(bytes int64) string {
+	const unit = 1024
+	if bytes < unit {
+		return fmt.Sprintf("%d B", bytes)
+	}
+
+	div, exp := int64(unit), 0
+	for n := bytes / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(bytes)/float64(div), "KMGTPE"[exp])
+}
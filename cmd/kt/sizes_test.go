@@ -0,0 +1,64 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/Shopify/sarama"
+	"github.com/Shopify/sarama/mocks"
+	"github.com/jurriaan/kafkatools"
+)
+
+func TestMeasureTopicSizes(t *testing.T) {
+	config := sarama.NewConfig()
+	config.Consumer.Return.Errors = true
+
+	consumer := mocks.NewConsumer(t, config)
+
+	oldest := map[string]map[int32]kafkatools.TopicPartitionOffset{
+		"small": {0: {Topic: "small", Partition: 0, Offset: 0}},
+		"large": {0: {Topic: "large", Partition: 0, Offset: 0}, 1: {Topic: "large", Partition: 1, Offset: 0}},
+	}
+	newest := map[string]map[int32]kafkatools.TopicPartitionOffset{
+		"small": {0: {Topic: "small", Partition: 0, Offset: 2}},
+		"large": {0: {Topic: "large", Partition: 0, Offset: 100}, 1: {Topic: "large", Partition: 1, Offset: 0}},
+	}
+
+	// Only the last two records of every partition are sampled
+	small := consumer.ExpectConsumePartition("small", 0, 0)
+	small.YieldMessage(&sarama.ConsumerMessage{Value: []byte("ab"), Offset: 0})
+	small.YieldMessage(&sarama.ConsumerMessage{Key: []byte("k"), Value: []byte("abc"), Offset: 1})
+	large := consumer.ExpectConsumePartition("large", 0, 98)
+	large.YieldMessage(&sarama.ConsumerMessage{Value: []byte("0123456789"), Offset: 0})
+	large.YieldMessage(&sarama.ConsumerMessage{Value: []byte("0123456789"), Offset: 1})
+
+	results := measureTopicSizes(consumer, []string{"small", "large"}, oldest, newest, 2)
+
+	expected := []topicSize{
+		{Topic: "large", Partitions: 2, Messages: 100, Bytes: 1000},
+		{Topic: "small", Partitions: 1, Messages: 2, Bytes: 6},
+	}
+	if len(results) != len(expected) {
+		t.Fatalf("Expected %+v, got %+v", expected, results)
+	}
+	for i := range expected {
+		if results[i] != expected[i] {
+			t.Errorf("Expected %+v, got %+v", expected[i], results[i])
+		}
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	tests := map[int64]string{
+		0:               "0 B",
+		1023:            "1023 B",
+		1536:            "1.5 KiB",
+		5 * 1024 * 1024: "5.0 MiB",
+		3 << 40:         "3.0 TiB",
+	}
+
+	for bytes, expected := range tests {
+		if formatted := formatBytes(bytes); formatted != expected {
+			t.Errorf("Expected %d to be formatted as %q, got %q", bytes, expected, formatted)
+		}
+	}
+}
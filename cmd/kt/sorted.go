@@ -0,0 +1,132 @@
+package main
+
+import (
+	"container/heap"
+	"sync"
+
+	"github.com/Shopify/sarama"
+)
+
+// timestampMerger merges the messages of the partitions of --sorted in ascending timestamp order. The consumed
+// partitions are registered when they start and report when they are done, a message is only sent once every
+// partition which is not done has a message queued, so no partition can still send an older one. Messages with the
+// same timestamp are sent in topic and partition order, the messages of a partition always in offset order.
+type timestampMerger struct {
+	mutex sync.Mutex
+	// open are the partitions which started and are not done
+	open map[topicPartition]bool
+	done chan topicPartition
+}
+
+func newTimestampMerger() *timestampMerger {
+	return &timestampMerger{open: make(map[topicPartition]bool), done: make(chan topicPartition)}
+}
+
+// started registers a partition which is consumed
+func (m *timestampMerger) started(topic string, partition int32) {
+	if m == nil {
+		return
+	}
+	m.mutex.Lock()
+	m.open[topicPartition{Topic: topic, Partition: partition}] = true
+	m.mutex.Unlock()
+}
+
+// finished reports that a partition sent its last message, or gave up because consuming is closing
+func (m *timestampMerger) finished(topic string, partition int32, closing chan struct{}) {
+	if m == nil {
+		return
+	}
+	select {
+	case m.done <- topicPartition{Topic: topic, Partition: partition}:
+	case <-closing:
+	}
+}
+
+// merge sends the messages in timestamp order, the partitions have to be started before it is called
+func (m *timestampMerger) merge(messages <-chan *sarama.ConsumerMessage) chan *sarama.ConsumerMessage {
+	m.mutex.Lock()
+	open := make(map[topicPartition]bool, len(m.open))
+	for partition := range m.open {
+		open[partition] = true
+	}
+	m.mutex.Unlock()
+
+	sorted := make(chan *sarama.ConsumerMessage)
+	go func() {
+		defer close(sorted)
+
+		queues := make(map[topicPartition][]*sarama.ConsumerMessage)
+		heads := &messageHeap{}
+		// missing is the number of open partitions without a queued message
+		missing := len(open)
+		for {
+			for missing > 0 && messages != nil {
+				select {
+				case msg, ok := <-messages:
+					if !ok {
+						messages = nil
+						break
+					}
+					partition := topicPartition{Topic: msg.Topic, Partition: msg.Partition}
+					if len(queues[partition]) == 0 {
+						heap.Push(heads, msg)
+						if open[partition] {
+							missing--
+						}
+					}
+					queues[partition] = append(queues[partition], msg)
+				case partition := <-m.done:
+					if open[partition] {
+						delete(open, partition)
+						if len(queues[partition]) == 0 {
+							missing--
+						}
+					}
+				}
+			}
+			if heads.Len() == 0 {
+				if messages == nil {
+					return
+				}
+				// Every partition is done, the channel is closed next
+				for range messages {
+				}
+				return
+			}
+
+			msg := heap.Pop(heads).(*sarama.ConsumerMessage)
+			partition := topicPartition{Topic: msg.Topic, Partition: msg.Partition}
+			queues[partition] = queues[partition][1:]
+			if len(queues[partition]) > 0 {
+				heap.Push(heads, queues[partition][0])
+			} else if open[partition] {
+				missing++
+			}
+			sorted <- msg
+		}
+	}()
+	return sorted
+}
+
+// messageHeap is a min-heap of the first queued messages of the partitions, by timestamp
+type messageHeap []*sarama.ConsumerMessage
+
+func (h messageHeap) Len() int { return len(h) }
+func (h messageHeap) Less(i, j int) bool {
+	if !h[i].Timestamp.Equal(h[j].Timestamp) {
+		return h[i].Timestamp.Before(h[j].Timestamp)
+	}
+	if h[i].Topic != h[j].Topic {
+		return h[i].Topic < h[j].Topic
+	}
+	return h[i].Partition < h[j].Partition
+}
+func (h messageHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *messageHeap) Push(x interface{}) { *h = append(*h, x.(*sarama.ConsumerMessage)) }
+func (h *messageHeap) Pop() interface{} {
+	old := *h
+	msg := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return msg
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/Shopify/sarama/mocks"
+	"github.com/jurriaan/kafkatools"
+)
+
+func TestConsumeSorted(t *testing.T) {
+	config := sarama.NewConfig()
+	config.Consumer.Return.Errors = true
+
+	consumer := mocks.NewConsumer(t, config)
+
+	start := time.Unix(1600000000, 0)
+	partitionOffsets, endOffsets := make(offsetMap), make(offsetMap)
+	// Partition 2 is empty, it is skipped and does not hold up the merge
+	for partition, seconds := range map[int32][]int{0: {1, 4, 5}, 1: {2, 3, 6}, 2: {}} {
+		if len(seconds) > 0 {
+			partConsumer := consumer.ExpectConsumePartition("foo", partition, 0)
+			for offset, second := range seconds {
+				partConsumer.YieldMessage(&sarama.ConsumerMessage{Value: []byte("x"), Offset: int64(offset), Timestamp: start.Add(time.Duration(second) * time.Second)})
+			}
+		}
+		partitionOffsets[partition] = kafkatools.TopicPartitionOffset{Topic: "foo", Partition: partition, Offset: 0}
+		endOffsets[partition] = kafkatools.TopicPartitionOffset{Topic: "foo", Partition: partition, Offset: int64(len(seconds))}
+	}
+
+	merger := newTimestampMerger()
+	messagesChan, _ := consumePartitions(consumer, partitionOffsets, endOffsets, consumeOptions{sorted: merger})
+
+	var seconds []int64
+	for msg := range merger.merge(messagesChan) {
+		seconds = append(seconds, msg.Timestamp.Unix()-start.Unix())
+	}
+
+	if len(seconds) != 6 {
+		t.Fatalf("Expected 6 messages, received %v", seconds)
+	}
+	for i, second := range seconds {
+		if second != int64(i+1) {
+			t.Errorf("Expected the messages in timestamp order, received %v", seconds)
+			break
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/jurriaan/kafkatools"
+)
+
+type topicPartition struct {
+	Topic     string
+	Partition int32
+}
+
+// throughputStats counts the messages and bytes consumed per partition since the last report
+type throughputStats struct {
+	mutex    sync.Mutex
+	messages map[topicPartition]int64
+	bytes    map[topicPartition]int64
+	// decodes counts the decoded messages per partition since the start, they are only reported once trackDecodes
+	// was called
+	decodes map[topicPartition]decodeCounts
+}
+
+type decodeCounts struct {
+	decoded, failed int64
+}
+
+func newThroughputStats() *throughputStats {
+	return &throughputStats{messages: make(map[topicPartition]int64), bytes: make(map[topicPartition]int64)}
+}
+
+// add counts a consumed message, it is a no-op on nil stats
+func (s *throughputStats) add(msg *sarama.ConsumerMessage) {
+	if s == nil {
+		return
+	}
+
+	key := topicPartition{Topic: msg.Topic, Partition: msg.Partition}
+	s.mutex.Lock()
+	s.messages[key]++
+	s.bytes[key] += int64(recordSize(msg))
+	s.mutex.Unlock()
+}
+
+// trackDecodes adds the running number of decoded and failed messages to the reports
+func (s *throughputStats) trackDecodes() {
+	s.decodes = make(map[topicPartition]decodeCounts)
+}
+
+// addDecode counts a decoded message, it is a no-op on nil stats or when decodes are not tracked
+func (s *throughputStats) addDecode(msg *sarama.ConsumerMessage, err error) {
+	if s == nil || s.decodes == nil {
+		return
+	}
+
+	key := topicPartition{Topic: msg.Topic, Partition: msg.Partition}
+	s.mutex.Lock()
+	counts := s.decodes[key]
+	if err != nil {
+		counts.failed++
+	} else {
+		counts.decoded++
+	}
+	s.decodes[key] = counts
+	s.mutex.Unlock()
+}
+
+// report logs the throughput every interval until closing is closed
+func (s *throughputStats) report(interval time.Duration, closing chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	last := time.Now()
+	for {
+		select {
+		case now := <-ticker.C:
+			for _, line := range s.flush(now.Sub(last)) {
+				kafkatools.Log.Infof("%s", line)
+			}
+			last = now
+		case <-closing:
+			return
+		}
+	}
+}
+
+// flush formats the throughput per partition and in total over the elapsed time and resets the counters
+func (s *throughputStats) flush(elapsed time.Duration) (lines []string) {
+	s.mutex.Lock()
+	messages, bytes := s.messages, s.bytes
+	s.messages, s.bytes = make(map[topicPartition]int64), make(map[topicPartition]int64)
+	decodes := make(map[topicPartition]decodeCounts, len(s.decodes))
+	var totalDecodes decodeCounts
+	for partition, counts := range s.decodes {
+		decodes[partition] = counts
+		totalDecodes.decoded += counts.decoded
+		totalDecodes.failed += counts.failed
+	}
+	trackDecodes := s.decodes != nil
+	s.mutex.Unlock()
+
+	formatDecodes := func(counts decodeCounts) string {
+		if !trackDecodes {
+			return ""
+		}
+		return counts.format()
+	}
+
+	partitions := make([]topicPartition, 0, len(messages))
+	for partition := range messages {
+		partitions = append(partitions, partition)
+	}
+	sort.Slice(partitions, func(i, j int) bool {
+		if partitions[i].Topic != partitions[j].Topic {
+			return partitions[i].Topic < partitions[j].Topic
+		}
+		return partitions[i].Partition < partitions[j].Partition
+	})
+
+	var totalMessages, totalBytes int64
+	for _, partition := range partitions {
+		totalMessages += messages[partition]
+		totalBytes += bytes[partition]
+		lines = append(lines, fmt.Sprintf("%s partition %d: %s%s", partition.Topic, partition.Partition, formatThroughput(messages[partition], bytes[partition], elapsed), formatDecodes(decodes[partition])))
+	}
+	return append(lines, "total: "+formatThroughput(totalMessages, totalBytes, elapsed)+formatDecodes(totalDecodes))
+}
+
+func (c decodeCounts) format() string {
+	var failedPercentage float64
+	if c.decoded+c.failed > 0 {
+		failedPercentage = float64(c.failed) * 100 / float64(c.decoded+c.failed)
+	}
+	return fmt.Sprintf(", decoded %d, failed %d (%.1f%%)", c.decoded, c.failed, failedPercentage)
+}
+
+func formatThroughput(messages, bytes int64, elapsed time.Duration) string {
+	seconds := elapsed.Seconds()
+	if seconds <= 0 {
+		seconds = 1
+	}
+	return fmt.Sprintf("%.1f msg/s, %s/s", float64(messages)/seconds, formatBytes(int64(float64(bytes)/seconds)))
+}
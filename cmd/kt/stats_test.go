@@ -0,0 +1,61 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+)
+
+func TestThroughputStats(t *testing.T) {
+	stats := newThroughputStats()
+	for i := 0; i < 10; i++ {
+		stats.add(&sarama.ConsumerMessage{Topic: "foo", Partition: 1, Value: make([]byte, 1024)})
+	}
+	stats.add(&sarama.ConsumerMessage{Topic: "foo", Partition: 0, Key: []byte("k"), Value: []byte("v")})
+
+	expected := []string{
+		"foo partition 0: 0.5 msg/s, 1 B/s",
+		"foo partition 1: 5.0 msg/s, 5.0 KiB/s",
+		"total: 5.5 msg/s, 5.0 KiB/s",
+	}
+	if lines := stats.flush(2 * time.Second); !reflect.DeepEqual(lines, expected) {
+		t.Errorf("Expected %q, got %q", expected, lines)
+	}
+
+	// The counters are reset after every report
+	if lines := stats.flush(time.Second); !reflect.DeepEqual(lines, []string{"total: 0.0 msg/s, 0 B/s"}) {
+		t.Errorf("Expected an empty report, got %q", lines)
+	}
+
+	var disabled *throughputStats
+	disabled.add(&sarama.ConsumerMessage{})
+}
+
+func TestThroughputStatsDecodes(t *testing.T) {
+	stats := newThroughputStats()
+	stats.trackDecodes()
+	for i := 0; i < 4; i++ {
+		msg := &sarama.ConsumerMessage{Topic: "foo", Value: []byte("v")}
+		stats.add(msg)
+		stats.addDecode(msg, nil)
+	}
+	failed := &sarama.ConsumerMessage{Topic: "foo", Value: []byte("v")}
+	stats.add(failed)
+	stats.addDecode(failed, errors.New("invalid"))
+
+	expected := []string{
+		"foo partition 0: 5.0 msg/s, 5 B/s, decoded 4, failed 1 (20.0%)",
+		"total: 5.0 msg/s, 5 B/s, decoded 4, failed 1 (20.0%)",
+	}
+	if lines := stats.flush(time.Second); !reflect.DeepEqual(lines, expected) {
+		t.Errorf("Expected %q, got %q", expected, lines)
+	}
+
+	// The decode counts are kept across reports
+	if lines := stats.flush(time.Second); !reflect.DeepEqual(lines, []string{"total: 0.0 msg/s, 0 B/s, decoded 4, failed 1 (20.0%)"}) {
+		t.Errorf("Expected the running decode counts, got %q", lines)
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/jurriaan/kafkatools"
+)
+
+// stuck polls the high-water marks of the topic and reports the partitions that did not advance during any of the
+// intervals, which usually means their producers stopped
+func stuck(client sarama.Client, parsedOptions options) {
+	kafkatools.Log.Infof("Watching %s for %d intervals of %s", parsedOptions.topic, parsedOptions.intervals, parsedOptions.interval)
+
+	snapshots := []offsetMap{fetchTopicOffsets(client, sarama.OffsetNewest, parsedOptions.topic)}
+	for i := 0; i < parsedOptions.intervals; i++ {
+		time.Sleep(parsedOptions.interval)
+		snapshots = append(snapshots, fetchTopicOffsets(client, sarama.OffsetNewest, parsedOptions.topic))
+	}
+
+	stuckPartitions := findStuckPartitions(snapshots)
+	duration := parsedOptions.interval * time.Duration(parsedOptions.intervals)
+	for _, partition := range stuckPartitions {
+		fmt.Printf("partition %d: stuck at offset %d for %s\n", partition, snapshots[0][partition].Offset, duration)
+	}
+	kafkatools.Log.Infof("%d of %d partitions did not advance", len(stuckPartitions), len(snapshots[len(snapshots)-1]))
+}
+
+// findStuckPartitions returns the sorted partitions whose newest offset is the same in all snapshots
+func findStuckPartitions(snapshots []offsetMap) (partitions []int32) {
+	if len(snapshots) < 2 {
+		return nil
+	}
+
+	for partition, first := range snapshots[0] {
+		advanced := false
+		for _, snapshot := range snapshots[1:] {
+			if offset, ok := snapshot[partition]; !ok || offset.Offset != first.Offset {
+				advanced = true
+				break
+			}
+		}
+
+		if !advanced {
+			partitions = append(partitions, partition)
+		}
+	}
+
+	sort.Slice(partitions, func(i, j int) bool { return partitions[i] < partitions[j] })
+	return partitions
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/jurriaan/kafkatools"
+)
+
+func TestFindStuckPartitions(t *testing.T) {
+	snapshot := func(offsets ...int64) offsetMap {
+		snapshot := make(offsetMap)
+		for partition, offset := range offsets {
+			snapshot[int32(partition)] = kafkatools.TopicPartitionOffset{Topic: "foo", Partition: int32(partition), Offset: offset}
+		}
+		return snapshot
+	}
+
+	// Partition 1 only advanced during the first interval
+	snapshots := []offsetMap{snapshot(10, 5, 7, 0), snapshot(11, 6, 7, 0), snapshot(12, 6, 7, 0)}
+
+	expected := []int32{2, 3}
+	if partitions := findStuckPartitions(snapshots); !reflect.DeepEqual(partitions, expected) {
+		t.Errorf("Expected stuck partitions %v, got %v", expected, partitions)
+	}
+
+	if partitions := findStuckPartitions(snapshots[:1]); partitions != nil {
+		t.Errorf("Expected no stuck partitions for a single snapshot, got %v", partitions)
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"text/template"
+	"time"
+
+	"github.com/Shopify/sarama"
+)
+
+// templateMessage is the message a --template is rendered with, key and value are empty for missing keys and
+// tombstones
+type templateMessage struct {
+	Topic     string
+	Partition int32
+	Offset    int64
+	Timestamp time.Time
+	Key       string
+	Value     string
+	Headers   map[string]string
+}
+
+// parseMessageTemplate parses the Go template and renders it once with an empty message, so templates referring to
+// fields a message does not have fail before anything is consumed
+func parseMessageTemplate(text string) (*template.Template, error) {
+	parsed, err := template.New("message").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	if err := parsed.Execute(io.Discard, templateMessage{}); err != nil {
+		return nil, err
+	}
+	return parsed, nil
+}
+
+// renderTemplate returns the printer of the raw format rendering the template with the message and its decoded value
+func renderTemplate(messageTemplate *template.Template, decodeValue func(*sarama.ConsumerMessage) []byte) func(*sarama.ConsumerMessage) []byte {
+	return func(msg *sarama.ConsumerMessage) []byte {
+		message := templateMessage{
+			Topic:     msg.Topic,
+			Partition: msg.Partition,
+			Offset:    msg.Offset,
+			Timestamp: msg.Timestamp,
+			Key:       string(msg.Key),
+			Value:     string(decodeValue(msg)),
+			Headers:   make(map[string]string, len(msg.Headers)),
+		}
+		for _, header := range msg.Headers {
+			message.Headers[string(header.Key)] = string(header.Value)
+		}
+
+		var rendered bytes.Buffer
+		if err := messageTemplate.Execute(&rendered, message); err != nil {
+			log.Fatalf("Could not render the message at offset %d of %s partition %d: %v", msg.Offset, msg.Topic, msg.Partition, err)
+		}
+		return rendered.Bytes()
+	}
+}
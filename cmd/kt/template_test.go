@@ -0,0 +1,40 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+)
+
+func TestParseMessageTemplate(t *testing.T) {
+	if _, err := parseMessageTemplate("{{.Partition}}:{{.Offset}} {{.Value}}"); err != nil {
+		t.Error("Unexpected error: ", err)
+	}
+	for _, invalid := range []string{"{{.Partition", "{{.Size}}", "{{.Value.Missing}}"} {
+		if _, err := parseMessageTemplate(invalid); err == nil {
+			t.Errorf("Expected an error for %q", invalid)
+		}
+	}
+}
+
+func TestTemplateFormatter(t *testing.T) {
+	messageTemplate, err := parseMessageTemplate(`{{.Topic}}/{{.Partition}}:{{.Offset}} {{.Timestamp.Unix}} {{.Key}}={{.Value}} {{index .Headers "h"}}`)
+	if err != nil {
+		t.Fatal("Unexpected error: ", err)
+	}
+	msg := &sarama.ConsumerMessage{
+		Topic: "foo", Partition: 1, Offset: 2, Timestamp: time.Unix(1500000000, 0), Key: []byte("key"), Value: []byte("value"),
+		Headers: []*sarama.RecordHeader{{Key: []byte("h"), Value: []byte("v")}},
+	}
+
+	formatter := newMessageFormatter(outputOptions{format: "raw", template: messageTemplate}, newValueDecoder(""), nil)
+	if line := string(formatter(msg)); line != "foo/1:2 1500000000 key=value v" {
+		t.Errorf("Expected the rendered template, got %q", line)
+	}
+
+	formatter = newMessageFormatter(outputOptions{format: "raw", template: messageTemplate, printSize: true}, newValueDecoder(""), nil)
+	if line := string(formatter(&sarama.ConsumerMessage{Topic: "foo", Offset: 3, Timestamp: time.Unix(0, 0)})); line != "0\tfoo/0:3 0 = " {
+		t.Errorf("Expected the rendered tombstone prefixed by its size, got %q", line)
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// timestampLayouts are the named layouts accepted by --time-format besides Go layouts
+var timestampLayouts = map[string]string{
+	"rfc3339":      time.RFC3339,
+	"rfc3339milli": "2006-01-02T15:04:05.000Z07:00",
+	"rfc3339nano":  time.RFC3339Nano,
+}
+
+// timestampFormat renders timestamps in a time zone and layout, as set by --timezone and --time-format
+type timestampFormat struct {
+	location *time.Location
+	layout   string
+}
+
+// parseTimestampFormat parses the zone (local, utc or an IANA name) and the layout (a named or Go layout), empty
+// values select the local zone and RFC 3339 with nanoseconds
+func parseTimestampFormat(zone, layout string) (*timestampFormat, error) {
+	format := &timestampFormat{location: time.Local, layout: time.RFC3339Nano}
+
+	switch strings.ToLower(zone) {
+	case "", "local":
+	case "utc":
+		format.location = time.UTC
+	default:
+		location, err := time.LoadLocation(zone)
+		if err != nil {
+			return nil, err
+		}
+		format.location = location
+	}
+
+	if named, ok := timestampLayouts[strings.ToLower(layout)]; ok {
+		format.layout = named
+	} else if layout != "" {
+		format.layout = layout
+	}
+	return format, nil
+}
+
+// format renders the timestamp, a nil format renders it as RFC 3339 in its own zone
+func (f *timestampFormat) format(t time.Time) string {
+	if f == nil {
+		return t.Format(time.RFC3339)
+	}
+	return t.In(f.location).Format(f.layout)
+}
+
+// jsonTime returns the timestamp to encode in JSON output, a nil format keeps the default encoding of time.Time
+func (f *timestampFormat) jsonTime(t time.Time) formattedTime {
+	if f == nil {
+		return formattedTime{Time: t}
+	}
+	return formattedTime{Time: t.In(f.location), layout: f.layout}
+}
+
+// formattedTime is a timestamp encoded in JSON as a string in its layout, or as RFC 3339 when no layout is set
+type formattedTime struct {
+	time.Time
+	layout string
+}
+
+func (t formattedTime) MarshalJSON() ([]byte, error) {
+	if t.layout == "" {
+		return t.Time.MarshalJSON()
+	}
+	return json.Marshal(t.Format(t.layout))
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+)
+
+func TestParseTimestampFormat(t *testing.T) {
+	timestamp := time.Date(2017, 7, 14, 2, 40, 0, 123000000, time.UTC)
+
+	tests := []struct {
+		zone, layout string
+		expected     string
+	}{
+		{"utc", "", "2017-07-14T02:40:00.123Z"},
+		{"UTC", "rfc3339", "2017-07-14T02:40:00Z"},
+		{"Asia/Tokyo", "rfc3339milli", "2017-07-14T11:40:00.123+09:00"},
+		{"America/New_York", "2006-01-02 15:04:05 MST", "2017-07-13 22:40:00 EDT"},
+	}
+
+	for _, test := range tests {
+		format, err := parseTimestampFormat(test.zone, test.layout)
+		if err != nil {
+			t.Errorf("Could not parse %s %s: %v", test.zone, test.layout, err)
+		} else if formatted := format.format(timestamp); formatted != test.expected {
+			t.Errorf("Expected %s, got %s", test.expected, formatted)
+		}
+	}
+
+	if _, err := parseTimestampFormat("Mars/Olympus", ""); err == nil {
+		t.Error("Expected an error for an unknown zone")
+	}
+}
+
+func TestNdjsonTimestampFormat(t *testing.T) {
+	timestamps, err := parseTimestampFormat("utc", "2006-01-02 15:04:05")
+	if err != nil {
+		t.Fatal(err)
+	}
+	formatter := newMessageFormatter(outputOptions{format: "ndjson", timestamps: timestamps}, newValueDecoder(""), nil)
+
+	expected := `{"topic":"foo","partition":0,"offset":0,"timestamp":"2017-07-14 02:40:00","key":null,"value":"v"}`
+	if line := string(formatter(&sarama.ConsumerMessage{Topic: "foo", Timestamp: time.Unix(1500000000, 0), Value: []byte("v")})); line != expected {
+		t.Errorf("Expected %s, got %s", expected, line)
+	}
+}
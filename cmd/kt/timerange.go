@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/Shopify/sarama"
+	"github.com/jurriaan/kafkatools"
+)
+
+// isTimestamp returns whether a start or end offset is a timestamp in milliseconds (--start-date or --end-date)
+// rather than sarama.OffsetOldest or sarama.OffsetNewest
+func isTimestamp(offset int64) bool {
+	return offset >= 0
+}
+
+// fetchOffsetsAt resolves sarama.OffsetOldest, sarama.OffsetNewest or a timestamp in milliseconds to the offsets of the
+// partitions of the topic, see kafkatools.FetchTopicOffsetsForTime for timestamps
+func fetchOffsetsAt(client sarama.Client, offset int64, topic string) offsetMap {
+	if !isTimestamp(offset) {
+		return fetchTopicOffsets(client, offset, topic)
+	}
+
+	offsets, err := kafkatools.FetchTopicOffsetsForTime(client, topic, offset)
+	if err != nil {
+		log.Fatalf("Could not fetch the offsets of %s at %d: %v", topic, offset, err)
+	}
+	return offsets
+}
+
+// logTimeRange logs the offsets between which the partitions of the topic are consumed
+func logTimeRange(topic string, partitionOffsets, endOffsets offsetMap) {
+	for _, line := range formatTimeRange(topic, partitionOffsets, endOffsets) {
+		kafkatools.Log.Infof("%s", line)
+	}
+}
+
+func formatTimeRange(topic string, partitionOffsets, endOffsets offsetMap) (lines []string) {
+	partitions := make([]int32, 0, len(partitionOffsets))
+	for partition := range partitionOffsets {
+		partitions = append(partitions, partition)
+	}
+
+	for _, partition := range sortedInt32s(partitions) {
+		start := partitionOffsets[partition].Offset
+		end, ok := endOffsets[partition]
+		if !ok {
+			lines = append(lines, fmt.Sprintf("%s partition %d: offsets %d to the end", topic, partition, start))
+			continue
+		}
+
+		messages := end.Offset - start
+		if messages < 0 {
+			messages = 0
+		}
+		lines = append(lines, fmt.Sprintf("%s partition %d: offsets %d to %d (%d messages)", topic, partition, start, end.Offset, messages))
+	}
+	return lines
+}
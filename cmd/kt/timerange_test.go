@@ -0,0 +1,68 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/Shopify/sarama"
+	"github.com/jurriaan/kafkatools"
+)
+
+func TestFormatTimeRange(t *testing.T) {
+	partitionOffsets := offsetMap{
+		1: kafkatools.TopicPartitionOffset{Topic: "foo", Partition: 1, Offset: 30},
+		0: kafkatools.TopicPartitionOffset{Topic: "foo", Partition: 0, Offset: 12},
+		2: kafkatools.TopicPartitionOffset{Topic: "foo", Partition: 2, Offset: 5},
+	}
+	endOffsets := offsetMap{
+		0: kafkatools.TopicPartitionOffset{Topic: "foo", Partition: 0, Offset: 20},
+		1: kafkatools.TopicPartitionOffset{Topic: "foo", Partition: 1, Offset: 30},
+	}
+
+	expected := []string{
+		"foo partition 0: offsets 12 to 20 (8 messages)",
+		"foo partition 1: offsets 30 to 30 (0 messages)",
+		"foo partition 2: offsets 5 to the end",
+	}
+	if lines := formatTimeRange("foo", partitionOffsets, endOffsets); !reflect.DeepEqual(lines, expected) {
+		t.Errorf("Expected %q, got %q", expected, lines)
+	}
+}
+
+func TestFetchOffsetsBeforeDate(t *testing.T) {
+	broker := sarama.NewMockBroker(t, 1)
+	defer broker.Close()
+
+	// Partition 1 has no message at or after the end date
+	const endDate = 1500000000000
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": sarama.NewMockMetadataResponse(t).
+			SetBroker(broker.Addr(), broker.BrokerID()).
+			SetLeader("foo", 0, broker.BrokerID()).
+			SetLeader("foo", 1, broker.BrokerID()),
+		"OffsetRequest": sarama.NewMockOffsetResponse(t).
+			SetOffset("foo", 0, sarama.OffsetOldest, 3).
+			SetOffset("foo", 0, endDate, 12).
+			SetOffset("foo", 0, sarama.OffsetNewest, 20).
+			SetOffset("foo", 1, sarama.OffsetOldest, 0).
+			SetOffset("foo", 1, endDate, -1).
+			SetOffset("foo", 1, sarama.OffsetNewest, 30),
+	})
+
+	client, err := sarama.NewClient([]string{broker.Addr()}, sarama.NewConfig())
+	if err != nil {
+		t.Fatal("Unexpected error: ", err)
+	}
+	defer client.Close()
+
+	partitionOffsets := fetchOffsetsAt(client, defaultStartOffset("consume", false, true), "foo")
+	endOffsets := fetchOffsetsAt(client, endDate, "foo")
+
+	expected := []string{
+		"foo partition 0: offsets 3 to 12 (9 messages)",
+		"foo partition 1: offsets 0 to 30 (30 messages)",
+	}
+	if lines := formatTimeRange("foo", partitionOffsets, endOffsets); !reflect.DeepEqual(lines, expected) {
+		t.Errorf("Expected %q, got %q", expected, lines)
+	}
+}
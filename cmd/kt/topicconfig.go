@@ -0,0 +1,155 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/Shopify/sarama"
+	"github.com/jurriaan/kafkatools"
+	"github.com/olekukonko/tablewriter"
+)
+
+// topicConfig prints the effective configuration of the topic and whether every entry is the default or overridden
+func topicConfig(client sarama.Client, parsedOptions options) {
+	admin, err := kafkatools.GetClusterAdminFromClient(client)
+	if err != nil {
+		log.Fatal("Could not create the cluster admin: ", err)
+	}
+
+	entries, err := admin.DescribeConfig(sarama.ConfigResource{Type: sarama.TopicResource, Name: parsedOptions.topic})
+	if err != nil {
+		log.Fatalf("Could not describe the config of topic %s: %v", parsedOptions.topic, err)
+	}
+
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"name", "value", "source"})
+	table.AppendBulk(formatConfigEntries(entries))
+	table.SetAlignment(tablewriter.ALIGN_LEFT)
+	table.Render()
+}
+
+// formatConfigEntries returns a row with the name, value and source of every entry sorted by name
+func formatConfigEntries(entries []sarama.ConfigEntry) (rows [][]string) {
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+	for _, entry := range entries {
+		value := entry.Value
+		if entry.Sensitive {
+			value = "(sensitive)"
+		}
+		rows = append(rows, []string{entry.Name, value, formatConfigSource(entry)})
+	}
+	return rows
+}
+
+// formatConfigSource tells whether the entry is the default, a topic override or set on the brokers. Brokers only
+// report the source to clients using kafka 1.1 or newer, older versions only tell whether the value is the default.
+func formatConfigSource(entry sarama.ConfigEntry) string {
+	switch {
+	case entry.Default:
+		return "default"
+	case entry.Source == sarama.SourceDynamicBroker:
+		return "broker (dynamic)"
+	case entry.Source == sarama.SourceDynamicDefaultBroker:
+		return "cluster (dynamic)"
+	case entry.Source == sarama.SourceStaticBroker:
+		return "broker (static)"
+	default:
+		return "override"
+	}
+}
+
+// configChange is a config entry changed by kt alter-topic-config
+type configChange struct {
+	Name     string
+	Old, New string
+	// Default is set when the old value is not overridden for the topic
+	Default bool
+}
+
+// alterTopicConfig sets config entries of the topic while keeping its other overrides, with dryRun the changes are
+// only validated by the brokers and printed
+func alterTopicConfig(client sarama.Client, parsedOptions options) {
+	admin, err := kafkatools.GetClusterAdminFromClient(client)
+	if err != nil {
+		log.Fatal("Could not create the cluster admin: ", err)
+	}
+
+	entries, err := admin.DescribeConfig(sarama.ConfigResource{Type: sarama.TopicResource, Name: parsedOptions.topic})
+	if err != nil {
+		log.Fatalf("Could not describe the config of topic %s: %v", parsedOptions.topic, err)
+	}
+
+	config, changes := alteredConfig(entries, parsedOptions.configSettings)
+	for _, change := range changes {
+		fmt.Println(formatConfigChange(change))
+	}
+	if len(changes) == 0 {
+		kafkatools.Log.Infof("The config of topic %s is already up to date", parsedOptions.topic)
+		return
+	}
+
+	if err := admin.AlterConfig(sarama.TopicResource, parsedOptions.topic, config, parsedOptions.dryRun); err != nil {
+		log.Fatalf("Could not alter the config of topic %s: %v", parsedOptions.topic, err)
+	}
+
+	if parsedOptions.dryRun {
+		kafkatools.Log.Infof("Dry run: the brokers accepted the %d changes to topic %s, nothing was applied", len(changes), parsedOptions.topic)
+	} else {
+		kafkatools.Log.Infof("Applied %d changes to topic %s", len(changes), parsedOptions.topic)
+	}
+}
+
+// alteredConfig returns the complete set of topic overrides after applying the settings, AlterConfig replaces all
+// overrides of the topic so the current ones have to be included. The changes are sorted by name.
+func alteredConfig(entries []sarama.ConfigEntry, settings map[string]string) (config map[string]*string, changes []configChange) {
+	config = make(map[string]*string)
+	current := make(map[string]sarama.ConfigEntry, len(entries))
+	for _, entry := range entries {
+		current[entry.Name] = entry
+		if entry.Source == sarama.SourceTopic {
+			value := entry.Value
+			config[entry.Name] = &value
+		}
+	}
+
+	for name, value := range settings {
+		value := value
+		config[name] = &value
+
+		entry, ok := current[name]
+		if ok && entry.Source == sarama.SourceTopic && entry.Value == value {
+			continue
+		}
+		changes = append(changes, configChange{Name: name, Old: entry.Value, New: value, Default: entry.Source != sarama.SourceTopic})
+	}
+
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Name < changes[j].Name })
+	return config, changes
+}
+
+func formatConfigChange(change configChange) string {
+	old := change.Old
+	if change.Default {
+		old += " (not overridden)"
+	}
+	return fmt.Sprintf("%s: %s -> %s", change.Name, old, change.New)
+}
+
+// parseConfigSettings parses name=value settings, values may contain commas (e.g. cleanup.policy=compact,delete)
+func parseConfigSettings(settings []string) (map[string]string, error) {
+	parsed := make(map[string]string, len(settings))
+	for _, setting := range settings {
+		parts := strings.SplitN(setting, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, fmt.Errorf("invalid setting %q, expected name=value", setting)
+		}
+		if _, ok := parsed[parts[0]]; ok {
+			return nil, fmt.Errorf("%s is set more than once", parts[0])
+		}
+		parsed[parts[0]] = parts[1]
+	}
+	return parsed, nil
+}
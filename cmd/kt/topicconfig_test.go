@@ -0,0 +1,83 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/Shopify/sarama"
+)
+
+func TestFormatConfigEntries(t *testing.T) {
+	entries := []sarama.ConfigEntry{
+		{Name: "segment.bytes", Value: "1073741824", Default: true, Source: sarama.SourceDefault},
+		{Name: "retention.ms", Value: "3600000", Source: sarama.SourceTopic},
+		{Name: "cleanup.policy", Value: "compact", Source: sarama.SourceStaticBroker},
+		{Name: "sasl.jaas.config", Value: "secret", Sensitive: true, Source: sarama.SourceDynamicDefaultBroker},
+		{Name: "max.message.bytes", Value: "2097152", Source: sarama.SourceUnknown},
+	}
+
+	expected := [][]string{
+		{"cleanup.policy", "compact", "broker (static)"},
+		{"max.message.bytes", "2097152", "override"},
+		{"retention.ms", "3600000", "override"},
+		{"sasl.jaas.config", "(sensitive)", "cluster (dynamic)"},
+		{"segment.bytes", "1073741824", "default"},
+	}
+	if rows := formatConfigEntries(entries); !reflect.DeepEqual(rows, expected) {
+		t.Errorf("Expected rows %v, got %v", expected, rows)
+	}
+}
+
+func TestAlteredConfig(t *testing.T) {
+	entries := []sarama.ConfigEntry{
+		{Name: "cleanup.policy", Value: "delete", Default: true, Source: sarama.SourceDefault},
+		{Name: "retention.ms", Value: "3600000", Source: sarama.SourceTopic},
+		{Name: "segment.ms", Value: "60000", Source: sarama.SourceTopic},
+		{Name: "max.message.bytes", Value: "2097152", Source: sarama.SourceStaticBroker},
+	}
+
+	config, changes := alteredConfig(entries, map[string]string{
+		"cleanup.policy": "compact,delete",
+		"retention.ms":   "60000",
+		"segment.ms":     "60000",
+	})
+
+	// The overrides which are not set are kept, broker values are not turned into overrides
+	expectedConfig := map[string]string{"cleanup.policy": "compact,delete", "retention.ms": "60000", "segment.ms": "60000"}
+	if len(config) != len(expectedConfig) {
+		t.Errorf("Expected config %v, got %d entries", expectedConfig, len(config))
+	}
+	for name, value := range expectedConfig {
+		if config[name] == nil || *config[name] != value {
+			t.Errorf("Expected %s to be %s, got %v", name, value, config[name])
+		}
+	}
+
+	expectedChanges := []configChange{
+		{Name: "cleanup.policy", Old: "delete", New: "compact,delete", Default: true},
+		{Name: "retention.ms", Old: "3600000", New: "60000"},
+	}
+	if !reflect.DeepEqual(changes, expectedChanges) {
+		t.Errorf("Expected changes %v, got %v", expectedChanges, changes)
+	}
+	if line := formatConfigChange(changes[0]); line != "cleanup.policy: delete (not overridden) -> compact,delete" {
+		t.Errorf("Unexpected change line: %s", line)
+	}
+}
+
+func TestParseConfigSettings(t *testing.T) {
+	settings, err := parseConfigSettings([]string{"retention.ms=3600000", "cleanup.policy=compact,delete", "empty="})
+	if err != nil {
+		t.Fatal("Unexpected error: ", err)
+	}
+	expected := map[string]string{"retention.ms": "3600000", "cleanup.policy": "compact,delete", "empty": ""}
+	if !reflect.DeepEqual(settings, expected) {
+		t.Errorf("Expected settings %v, got %v", expected, settings)
+	}
+
+	for _, invalid := range [][]string{{"retention.ms"}, {"=1"}, {"a=1", "a=2"}} {
+		if _, err := parseConfigSettings(invalid); err == nil {
+			t.Errorf("Expected an error for %v", invalid)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Shopify/sarama"
+	"github.com/jurriaan/kafkatools"
+	"github.com/olekukonko/tablewriter"
+)
+
+// topicPartitions contains the partitions of a topic, the partition details are only set with --verbose
+type topicPartitions struct {
+	Topic      string
+	Partitions []partitionReplicas
+}
+
+// partitionReplicas contains the leader (-1 when there is none), replicas and in-sync replicas of a partition
+type partitionReplicas struct {
+	Partition int32
+	Leader    int32
+	Replicas  []int32
+	ISR       []int32
+}
+
+// listTopics prints the topics of the cluster sorted by name with their number of partitions, or with the leader and
+// replicas of every partition when verbose
+func listTopics(client sarama.Client, parsedOptions options) {
+	topics := matchingTopics(client, parsedOptions)
+	if len(topics) == 0 {
+		kafkatools.Log.Infof("No matching topics found")
+		return
+	}
+	sort.Strings(topics)
+
+	described := describeTopics(client, topics, parsedOptions.verbose)
+	table := tablewriter.NewWriter(os.Stdout)
+	if parsedOptions.verbose {
+		table.SetHeader([]string{"topic", "partition", "leader", "replicas", "isr"})
+	} else {
+		table.SetHeader([]string{"topic", "partitions"})
+	}
+	table.AppendBulk(topicRows(described, parsedOptions.verbose))
+	table.SetAlignment(tablewriter.ALIGN_LEFT)
+	table.Render()
+}
+
+// describeTopics fetches the partitions of the topics and, when verbose, their leaders and replicas. The replicas are
+// kept when a partition reports an error, like a replica which is not available.
+func describeTopics(client sarama.Client, topics []string, verbose bool) []topicPartitions {
+	described := make([]topicPartitions, 0, len(topics))
+	for _, topic := range topics {
+		partitions, err := client.Partitions(topic)
+		if err != nil {
+			log.Fatalf("Could not fetch the partitions of %s: %v", topic, err)
+		}
+		sort.Slice(partitions, func(i, j int) bool { return partitions[i] < partitions[j] })
+
+		result := topicPartitions{Topic: topic, Partitions: make([]partitionReplicas, 0, len(partitions))}
+		for _, partition := range partitions {
+			replicas := partitionReplicas{Partition: partition, Leader: -1}
+			if verbose {
+				if leader, err := client.Leader(topic, partition); err == nil {
+					replicas.Leader = leader.ID()
+				}
+				replicas.Replicas, _ = client.Replicas(topic, partition)
+				replicas.ISR, _ = client.InSyncReplicas(topic, partition)
+			}
+			result.Partitions = append(result.Partitions, replicas)
+		}
+		described = append(described, result)
+	}
+	return described
+}
+
+// topicRows returns the table rows of the topics, one per topic or one per partition when verbose
+func topicRows(topics []topicPartitions, verbose bool) [][]string {
+	var rows [][]string
+	for _, topic := range topics {
+		if !verbose {
+			rows = append(rows, []string{topic.Topic, strconv.Itoa(len(topic.Partitions))})
+			continue
+		}
+		for _, partition := range topic.Partitions {
+			leader := "none"
+			if partition.Leader >= 0 {
+				leader = strconv.Itoa(int(partition.Leader))
+			}
+			rows = append(rows, []string{topic.Topic, strconv.Itoa(int(partition.Partition)), leader, formatBrokerIDs(partition.Replicas), formatBrokerIDs(partition.ISR)})
+		}
+	}
+	return rows
+}
+
+// formatBrokerIDs formats a replica set as comma separated broker ids
+func formatBrokerIDs(ids []int32) string {
+	formatted := make([]string, len(ids))
+	for i, id := range ids {
+		formatted[i] = strconv.Itoa(int(id))
+	}
+	return strings.Join(formatted, ",")
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/Shopify/sarama"
+)
+
+func TestDescribeTopics(t *testing.T) {
+	broker := sarama.NewMockBroker(t, 1)
+	defer broker.Close()
+
+	response := &sarama.MetadataResponse{Version: 1}
+	response.AddBroker(broker.Addr(), broker.BrokerID())
+	response.AddTopicPartition("foo", 1, broker.BrokerID(), []int32{1, 2}, []int32{1}, nil, sarama.ErrNoError)
+	response.AddTopicPartition("foo", 0, broker.BrokerID(), []int32{2, 1}, []int32{2, 1}, nil, sarama.ErrNoError)
+	response.AddTopicPartition("bar", 0, broker.BrokerID(), []int32{1}, []int32{1}, nil, sarama.ErrNoError)
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": sarama.NewMockWrapper(response),
+	})
+
+	config := sarama.NewConfig()
+	config.Version = sarama.V0_10_0_0
+	client, err := sarama.NewClient([]string{broker.Addr()}, config)
+	if err != nil {
+		t.Fatal("Unexpected error: ", err)
+	}
+	defer client.Close()
+
+	expected := []topicPartitions{
+		{Topic: "bar", Partitions: []partitionReplicas{{Partition: 0, Leader: 1, Replicas: []int32{1}, ISR: []int32{1}}}},
+		{Topic: "foo", Partitions: []partitionReplicas{
+			{Partition: 0, Leader: 1, Replicas: []int32{2, 1}, ISR: []int32{2, 1}},
+			{Partition: 1, Leader: 1, Replicas: []int32{1, 2}, ISR: []int32{1}},
+		}},
+	}
+	if described := describeTopics(client, []string{"bar", "foo"}, true); !reflect.DeepEqual(described, expected) {
+		t.Errorf("Expected %v, got %v", expected, described)
+	}
+
+	if described := describeTopics(client, []string{"foo"}, false); len(described[0].Partitions) != 2 || described[0].Partitions[0].Leader != -1 {
+		t.Errorf("Expected only the partitions of foo, got %v", described)
+	}
+}
+
+func TestTopicRows(t *testing.T) {
+	topics := []topicPartitions{
+		{Topic: "bar", Partitions: []partitionReplicas{{Partition: 0, Leader: -1, Replicas: []int32{3}}}},
+		{Topic: "foo", Partitions: []partitionReplicas{
+			{Partition: 0, Leader: 1, Replicas: []int32{1, 2}, ISR: []int32{1, 2}},
+			{Partition: 1, Leader: 2, Replicas: []int32{2, 1}, ISR: []int32{2}},
+		}},
+	}
+
+	expected := [][]string{{"bar", "1"}, {"foo", "2"}}
+	if rows := topicRows(topics, false); !reflect.DeepEqual(rows, expected) {
+		t.Errorf("Expected %q, got %q", expected, rows)
+	}
+
+	expected = [][]string{
+		{"bar", "0", "none", "3", ""},
+		{"foo", "0", "1", "1,2", "1,2"},
+		{"foo", "1", "2", "2,1", "2"},
+	}
+	if rows := topicRows(topics, true); !reflect.DeepEqual(rows, expected) {
+		t.Errorf("Expected %q, got %q", expected, rows)
+	}
+}
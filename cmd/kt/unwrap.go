@@ -0,0 +1,44 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// newUnwrapper returns a function extracting the field at the dotted path from JSON envelopes. String fields are
+// returned as is (base64 decoded when base64Encoded is set), other fields as JSON.
+func newUnwrapper(path string, base64Encoded bool) func(value []byte) ([]byte, error) {
+	fields := strings.Split(path, ".")
+	return func(value []byte) ([]byte, error) {
+		decoder := json.NewDecoder(bytes.NewReader(value))
+		decoder.UseNumber()
+
+		var envelope interface{}
+		if err := decoder.Decode(&envelope); err != nil {
+			return nil, fmt.Errorf("value is not JSON: %v", err)
+		}
+
+		field := lookupField(envelope, fields)
+		switch field := field.(type) {
+		case nil:
+			return nil, fmt.Errorf("the envelope has no %s field", path)
+		case string:
+			if !base64Encoded {
+				return []byte(field), nil
+			}
+			decoded, err := base64.StdEncoding.DecodeString(field)
+			if err != nil {
+				return nil, fmt.Errorf("the %s field is not base64 encoded: %v", path, err)
+			}
+			return decoded, nil
+		default:
+			if base64Encoded {
+				return nil, fmt.Errorf("the %s field is not a base64 encoded string", path)
+			}
+			return json.Marshal(field)
+		}
+	}
+}
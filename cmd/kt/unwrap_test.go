@@ -0,0 +1,39 @@
+package main
+
+import "testing"
+
+func TestUnwrapper(t *testing.T) {
+	value := []byte(`{"schema": {}, "payload": {"data": "aGVsbG8=", "after": {"id": 1}}}`)
+
+	for _, test := range []struct {
+		path     string
+		base64   bool
+		expected string
+	}{
+		{"payload.data", false, "aGVsbG8="},
+		{"payload.data", true, "hello"},
+		{"payload.after", false, `{"id":1}`},
+	} {
+		unwrapped, err := newUnwrapper(test.path, test.base64)(value)
+		if err != nil {
+			t.Errorf("Unexpected error unwrapping %s: %v", test.path, err)
+		} else if string(unwrapped) != test.expected {
+			t.Errorf("Expected %s to unwrap to %s, got %s", test.path, test.expected, unwrapped)
+		}
+	}
+
+	for _, test := range []struct {
+		path   string
+		base64 bool
+		value  string
+	}{
+		{"payload.missing", false, string(value)},
+		{"payload.after", true, string(value)},
+		{"payload", false, "not json"},
+		{"payload", true, `{"payload": "not base64!"}`},
+	} {
+		if unwrapped, err := newUnwrapper(test.path, test.base64)([]byte(test.value)); err == nil {
+			t.Errorf("Expected an error unwrapping %s of %s, got %s", test.path, test.value, unwrapped)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/jurriaan/kafkatools"
+)
+
+// topicPollInterval is how often the metadata is refreshed while waiting for a topic to be created
+const topicPollInterval = 500 * time.Millisecond
+
+// waitForTopics blocks until all topics exist, for at most timeout
+func waitForTopics(client sarama.Client, topics []string, timeout time.Duration) {
+	partitions := func(topic string) ([]int32, error) {
+		// The client only refreshes the metadata of unknown topics once per lookup
+		if err := client.RefreshMetadata(topic); err != nil {
+			return nil, err
+		}
+		return client.Partitions(topic)
+	}
+
+	if err := pollTopics(partitions, topics, timeout, topicPollInterval, time.Sleep); err != nil {
+		log.Fatal("Could not find the topics: ", err)
+	}
+}
+
+// pollTopics looks up the partitions of every topic until it has some, it fails when a topic has none after waiting
+// timeout in total
+func pollTopics(partitions func(topic string) ([]int32, error), topics []string, timeout, interval time.Duration, sleep func(time.Duration)) error {
+	var waited time.Duration
+	for _, topic := range topics {
+		for attempt := 0; ; attempt++ {
+			found, err := partitions(topic)
+			if err == nil && len(found) > 0 {
+				break
+			}
+
+			if waited >= timeout {
+				if err == nil {
+					err = sarama.ErrUnknownTopicOrPartition
+				}
+				return fmt.Errorf("topic %s does not exist after waiting %v: %v", topic, timeout, err)
+			}
+			if attempt == 0 {
+				kafkatools.Log.Infof("Waiting up to %v for topic %s to be created", timeout, topic)
+			}
+			sleep(interval)
+			waited += interval
+		}
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+)
+
+func TestPollTopics(t *testing.T) {
+	lookups := map[string]int{}
+	partitions := func(topic string) ([]int32, error) {
+		lookups[topic]++
+		// bar is created after the third lookup
+		if topic == "bar" && lookups[topic] <= 3 {
+			return nil, sarama.ErrUnknownTopicOrPartition
+		}
+		return []int32{0}, nil
+	}
+
+	var slept time.Duration
+	sleep := func(d time.Duration) { slept += d }
+	if err := pollTopics(partitions, []string{"foo", "bar"}, time.Minute, time.Second, sleep); err != nil {
+		t.Fatal("Unexpected error: ", err)
+	}
+	if lookups["foo"] != 1 || lookups["bar"] != 4 || slept != 3*time.Second {
+		t.Errorf("Expected 1 and 4 lookups after sleeping 3s, got %v after %v", lookups, slept)
+	}
+
+	missing := func(topic string) ([]int32, error) { return nil, sarama.ErrUnknownTopicOrPartition }
+	slept = 0
+	err := pollTopics(missing, []string{"foo"}, 2*time.Second, time.Second, sleep)
+	if err == nil || !strings.Contains(err.Error(), "topic foo does not exist after waiting 2s") {
+		t.Errorf("Expected a timeout error, got %v", err)
+	}
+	if slept != 2*time.Second {
+		t.Errorf("Expected to wait 2s, waited %v", slept)
+	}
+}
@@ -0,0 +1,290 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/Shopify/sarama"
+)
+
+// whereExpression is a predicate over the fields of a JSON value: comparisons (== != < <= > >=) of dotted field paths
+// and string, number, true, false and null literals, combined with && || ! and parentheses
+type whereExpression interface {
+	// evaluate returns the value of the expression for the decoded JSON value
+	evaluate(object interface{}) interface{}
+}
+
+type whereLiteral struct{ value interface{} }
+
+type whereField struct{ path []string }
+
+type whereNot struct{ operand whereExpression }
+
+type whereBinary struct {
+	operator    string
+	left, right whereExpression
+}
+
+func (l whereLiteral) evaluate(interface{}) interface{} { return l.value }
+
+func (f whereField) evaluate(object interface{}) interface{} { return lookupField(object, f.path) }
+
+func (n whereNot) evaluate(object interface{}) interface{} {
+	return !isTrue(n.operand.evaluate(object))
+}
+
+func (b whereBinary) evaluate(object interface{}) interface{} {
+	switch b.operator {
+	case "&&":
+		return isTrue(b.left.evaluate(object)) && isTrue(b.right.evaluate(object))
+	case "||":
+		return isTrue(b.left.evaluate(object)) || isTrue(b.right.evaluate(object))
+	}
+
+	left, right := b.left.evaluate(object), b.right.evaluate(object)
+	switch b.operator {
+	// Objects and arrays can be compared as well
+	case "==":
+		return reflect.DeepEqual(left, right)
+	case "!=":
+		return !reflect.DeepEqual(left, right)
+	}
+
+	// Only numbers and strings are ordered, comparing anything else is false
+	var order int
+	switch left := left.(type) {
+	case float64:
+		right, ok := right.(float64)
+		if !ok {
+			return false
+		}
+		if left < right {
+			order = -1
+		} else if left > right {
+			order = 1
+		}
+	case string:
+		right, ok := right.(string)
+		if !ok {
+			return false
+		}
+		order = strings.Compare(left, right)
+	default:
+		return false
+	}
+
+	switch b.operator {
+	case "<":
+		return order < 0
+	case "<=":
+		return order <= 0
+	case ">":
+		return order > 0
+	default:
+		return order >= 0
+	}
+}
+
+// isTrue returns whether the value of an expression is the boolean true
+func isTrue(value interface{}) bool {
+	truth, ok := value.(bool)
+	return ok && truth
+}
+
+// parseWhere parses the expression of --where, e.g. amount>100 && status=="FAILED"
+func parseWhere(expr string) (whereExpression, error) {
+	tokens, err := tokenizeWhere(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	parser := whereParser{tokens: tokens}
+	parsed, err := parser.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if parser.position < len(tokens) {
+		return nil, fmt.Errorf("unexpected %q", tokens[parser.position])
+	}
+	return parsed, nil
+}
+
+// whereOperators are the operators of the expressions, longest first
+var whereOperators = []string{"&&", "||", "==", "!=", "<=", ">=", "<", ">", "!", "(", ")"}
+
+// whereOperatorChars are the characters of the operators, which end words and can't start an operand
+const whereOperatorChars = "&|=!<>()"
+
+// tokenizeWhere splits the expression into operators, quoted strings (kept quoted) and words (fields and literals)
+func tokenizeWhere(expr string) (tokens []string, err error) {
+	for i := 0; i < len(expr); {
+		if expr[i] == ' ' || expr[i] == '\t' {
+			i++
+			continue
+		}
+
+		if expr[i] == '"' || expr[i] == '\'' {
+			end := i + 1
+			for end < len(expr) && expr[end] != expr[i] {
+				if expr[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(expr) {
+				return nil, fmt.Errorf("unterminated string %s", expr[i:])
+			}
+			tokens = append(tokens, expr[i:end+1])
+			i = end + 1
+			continue
+		}
+
+		operator := ""
+		for _, candidate := range whereOperators {
+			if strings.HasPrefix(expr[i:], candidate) {
+				operator = candidate
+				break
+			}
+		}
+		if operator != "" {
+			tokens = append(tokens, operator)
+			i += len(operator)
+			continue
+		}
+
+		end := i
+		for end < len(expr) && strings.IndexByte(" \t\"'"+whereOperatorChars, expr[end]) == -1 {
+			end++
+		}
+		if end == i {
+			return nil, fmt.Errorf("unexpected %q", expr[i:])
+		}
+		tokens = append(tokens, expr[i:end])
+		i = end
+	}
+	return tokens, nil
+}
+
+type whereParser struct {
+	tokens   []string
+	position int
+}
+
+func (p *whereParser) peek() string {
+	if p.position < len(p.tokens) {
+		return p.tokens[p.position]
+	}
+	return ""
+}
+
+func (p *whereParser) parseOr() (whereExpression, error) {
+	left, err := p.parseAnd()
+	for err == nil && p.peek() == "||" {
+		p.position++
+		var right whereExpression
+		if right, err = p.parseAnd(); err == nil {
+			left = whereBinary{operator: "||", left: left, right: right}
+		}
+	}
+	return left, err
+}
+
+func (p *whereParser) parseAnd() (whereExpression, error) {
+	left, err := p.parseUnary()
+	for err == nil && p.peek() == "&&" {
+		p.position++
+		var right whereExpression
+		if right, err = p.parseUnary(); err == nil {
+			left = whereBinary{operator: "&&", left: left, right: right}
+		}
+	}
+	return left, err
+}
+
+func (p *whereParser) parseUnary() (whereExpression, error) {
+	switch p.peek() {
+	case "!":
+		p.position++
+		operand, err := p.parseUnary()
+		return whereNot{operand: operand}, err
+	case "(":
+		p.position++
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != ")" {
+			return nil, fmt.Errorf("missing )")
+		}
+		p.position++
+		return expr, nil
+	}
+
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	switch operator := p.peek(); operator {
+	case "==", "!=", "<", "<=", ">", ">=":
+		p.position++
+		right, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		return whereBinary{operator: operator, left: left, right: right}, nil
+	}
+	return left, nil
+}
+
+func (p *whereParser) parseOperand() (whereExpression, error) {
+	token := p.peek()
+	if token == "" {
+		return nil, fmt.Errorf("unexpected end of the expression")
+	}
+	p.position++
+
+	switch {
+	case token[0] == '"':
+		value, err := strconv.Unquote(token)
+		if err != nil {
+			return nil, fmt.Errorf("invalid string %s", token)
+		}
+		return whereLiteral{value: value}, nil
+	case token[0] == '\'':
+		return whereLiteral{value: strings.ReplaceAll(token[1:len(token)-1], `\'`, `'`)}, nil
+	case token == "true" || token == "false":
+		return whereLiteral{value: token == "true"}, nil
+	case token == "null":
+		return whereLiteral{value: nil}, nil
+	case strings.IndexByte("-.0123456789", token[0]) != -1:
+		number, err := strconv.ParseFloat(token, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %s", token)
+		}
+		return whereLiteral{value: number}, nil
+	case strings.Contains(whereOperatorChars, token[:1]):
+		return nil, fmt.Errorf("unexpected %q", token)
+	}
+	return whereField{path: strings.Split(token, ".")}, nil
+}
+
+// newWhereFilter returns a filter matching the messages whose decoded value is a JSON value the expression is true
+// for. Values which can't be decoded or are not JSON never match.
+func newWhereFilter(expr whereExpression, decoder *valueDecoder) messageFilter {
+	return func(msg *sarama.ConsumerMessage) bool {
+		value, ok := decoder.decodeQuietly(msg)
+		if !ok || value == nil {
+			return false
+		}
+
+		// Numbers are decoded as floats, so they compare the same whatever their notation
+		var object interface{}
+		if err := json.Unmarshal(value, &object); err != nil {
+			return false
+		}
+		return isTrue(expr.evaluate(object))
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/Shopify/sarama"
+)
+
+func TestWhereFilter(t *testing.T) {
+	value := `{"amount":150,"status":"FAILED","retry":false,"user":{"name":"ann","tags":["a","b"]},"note":null}`
+	tests := map[string]bool{
+		`amount>100 && status=="FAILED"`:                   true,
+		`amount > 100 && status == 'OK'`:                   false,
+		`amount>=150 && amount<=150`:                       true,
+		`amount<1e3 || missing`:                            true,
+		`user.name == "ann"`:                               true,
+		`user.tags.1 == "b"`:                               true,
+		`!(retry == true)`:                                 true,
+		`retry`:                                            false,
+		`!retry`:                                           true,
+		`missing == null && note == null`:                  true,
+		`missing != null`:                                  false,
+		`status > "E" && status < "G"`:                     true,
+		`status > 100`:                                     false,
+		`user.tags == user.tags`:                           true,
+		`amount == 150 || (x && y)`:                        true,
+		`(amount == 1 || amount == 150) && status != "OK"`: true,
+	}
+
+	msg := &sarama.ConsumerMessage{Value: []byte(value)}
+	for expr, expected := range tests {
+		parsed, err := parseWhere(expr)
+		if err != nil {
+			t.Errorf("Unexpected error parsing %s: %v", expr, err)
+			continue
+		}
+		if matched := newWhereFilter(parsed, newValueDecoder(""))(msg); matched != expected {
+			t.Errorf("Expected %s to be %v, got %v", expr, expected, matched)
+		}
+	}
+
+	parsed, _ := parseWhere(`amount > 100`)
+	for _, unmatched := range []*sarama.ConsumerMessage{{Value: []byte("not json")}, {}} {
+		if newWhereFilter(parsed, newValueDecoder(""))(unmatched) {
+			t.Errorf("Expected %q not to match", unmatched.Value)
+		}
+	}
+}
+
+func TestParseWhereErrors(t *testing.T) {
+	for _, expr := range []string{``, `amount >`, `amount = 1`, `(amount > 1`, `amount > 1)`, `status == "open`, `&& x`, `1.2.3 > 1`} {
+		if _, err := parseWhere(expr); err == nil {
+			t.Errorf("Expected an error parsing %q", expr)
+		}
+	}
+}
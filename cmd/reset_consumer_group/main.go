@@ -26,6 +26,7 @@ options:
   -h --help             show this screen.
   --version             show version.
   --broker [broker]     the kafka bootstrap broker
+  --kafka-version [version]  the kafka version of the brokers, e.g. 0.10.1.0 for older clusters [default: 2.1.0]
   --to-time [timestamp]   set offsets to a specific timestamp
   --partition [partition] only update a specific partition
   --offset [offset] update to a certain offset
@@ -47,8 +48,19 @@ func main() {
 	consumerGroup := docOpts["<group>"].(string)
 	partition := getPartition(docOpts)
 
-	client := kafkatools.GetSaramaClient(broker)
-	consumer := kafkatools.GetSaramaConsumer(broker, consumerGroup, topics)
+	kafkaVersion, err := sarama.ParseKafkaVersion(docOpts["--kafka-version"].(string))
+	if err != nil {
+		log.Fatalf("Invalid kafka version specified: %s", docOpts["--kafka-version"])
+	}
+
+	client, err := kafkatools.GetSaramaClientWithConfig(&kafkatools.ClientConfig{Version: kafkaVersion}, broker)
+	if err != nil {
+		log.Fatal("Failed to start client: ", err)
+	}
+	consumer, err := kafkatools.GetSaramaConsumer(broker, consumerGroup, topics)
+	if err != nil {
+		log.Fatal("Failed to start consumer: ", err)
+	}
 	defer func() {
 		err := consumer.Close()
 		if err != nil {
@@ -62,7 +74,10 @@ func main() {
 	}()
 
 	offset := getOffset(docOpts)
-	groupOffsets, topicOffsets := kafkatools.FetchOffsets(client, offset)
+	groupOffsets, topicOffsets, err := kafkatools.FetchOffsets(client, offset)
+	if err != nil {
+		log.Fatal("Could not fetch the offsets: ", err)
+	}
 
 	go func() {
 		for err := range consumer.Errors() {
@@ -0,0 +1,99 @@
+package kafkatools
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+
+	snappy "github.com/eapache/go-xerial-snappy"
+	framedsnappy "github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
+)
+
+// ErrNotCompressed is returned by DecompressValue for values which are not compressed by the codec
+var ErrNotCompressed = errors.New("value is not compressed")
+
+// The magic bytes at the start of the compressed values
+var (
+	gzipMagic         = []byte{0x1f, 0x8b}
+	zstdMagic         = []byte{0x28, 0xb5, 0x2f, 0xfd}
+	xerialSnappyMagic = []byte{0x82, 'S', 'N', 'A', 'P', 'P', 'Y', 0}
+	framedSnappyMagic = []byte{0xff, 0x06, 0x00, 0x00, 's', 'N', 'a', 'P', 'p', 'Y'}
+)
+
+// zstdDecoder decodes the zstd values, it is safe for concurrent use
+var zstdDecoder, _ = zstd.NewReader(nil, zstd.WithDecoderConcurrency(0))
+
+// DetectCompression returns the codec of the value from its magic bytes: gzip, zstd or snappy (in the xerial framing of
+// the Java client or the snappy framing format), empty when it does not start with any of those. Raw snappy blocks
+// have no magic bytes and are not detected.
+func DetectCompression(value []byte) string {
+	switch {
+	case bytes.HasPrefix(value, gzipMagic):
+		return "gzip"
+	case bytes.HasPrefix(value, zstdMagic):
+		return "zstd"
+	case bytes.HasPrefix(value, xerialSnappyMagic), bytes.HasPrefix(value, framedSnappyMagic):
+		return "snappy"
+	default:
+		return ""
+	}
+}
+
+// DecompressValue decompresses a value which was compressed by the application rather than in the record batch: gzip |
+// snappy (a raw block, the xerial framing or the snappy framing format) | zstd | auto (the codec detected by
+// DetectCompression). Values which are not compressed by the codec return ErrNotCompressed, auto returns them as is.
+func DecompressValue(value []byte, codec string) ([]byte, error) {
+	if codec == "auto" {
+		codec = DetectCompression(value)
+		if codec == "" {
+			return value, nil
+		}
+	}
+
+	switch codec {
+	case "gzip":
+		if !bytes.HasPrefix(value, gzipMagic) {
+			return nil, ErrNotCompressed
+		}
+		reader, err := gzip.NewReader(bytes.NewReader(value))
+		if err != nil {
+			return nil, fmt.Errorf("invalid gzip value: %v", err)
+		}
+		decompressed, err := io.ReadAll(reader)
+		if err != nil {
+			return nil, fmt.Errorf("invalid gzip value: %v", err)
+		}
+		return decompressed, nil
+	case "zstd":
+		if !bytes.HasPrefix(value, zstdMagic) {
+			return nil, ErrNotCompressed
+		}
+		decompressed, err := zstdDecoder.DecodeAll(value, nil)
+		if err != nil {
+			return nil, fmt.Errorf("invalid zstd value: %v", err)
+		}
+		return decompressed, nil
+	case "snappy":
+		if bytes.HasPrefix(value, framedSnappyMagic) {
+			decompressed, err := io.ReadAll(framedsnappy.NewReader(bytes.NewReader(value)))
+			if err != nil {
+				return nil, fmt.Errorf("invalid snappy value: %v", err)
+			}
+			return decompressed, nil
+		}
+		decompressed, err := snappy.Decode(value)
+		if err != nil {
+			if bytes.HasPrefix(value, xerialSnappyMagic) {
+				return nil, fmt.Errorf("invalid snappy value: %v", err)
+			}
+			// A raw block can only be recognized by decoding it
+			return nil, ErrNotCompressed
+		}
+		return decompressed, nil
+	default:
+		return nil, fmt.Errorf("invalid compression codec %q", codec)
+	}
+}
@@ -0,0 +1,79 @@
+package kafkatools
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"testing"
+
+	snappy "github.com/eapache/go-xerial-snappy"
+	framedsnappy "github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
+)
+
+func TestDecompressValue(t *testing.T) {
+	value := []byte(`{"id":1,"name":"compressed"}`)
+
+	var gzipped bytes.Buffer
+	writer := gzip.NewWriter(&gzipped)
+	writer.Write(value)
+	writer.Close()
+
+	var framed bytes.Buffer
+	framedWriter := framedsnappy.NewBufferedWriter(&framed)
+	framedWriter.Write(value)
+	framedWriter.Close()
+
+	encoder, _ := zstd.NewWriter(nil)
+	zstdValue := encoder.EncodeAll(value, nil)
+	encoder.Close()
+
+	fixtures := []struct {
+		codec      string
+		compressed []byte
+	}{
+		{"gzip", gzipped.Bytes()},
+		{"zstd", zstdValue},
+		{"snappy", snappy.EncodeStream(nil, value)},
+		{"snappy", framed.Bytes()},
+		{"snappy", snappy.Encode(value)},
+	}
+	for _, fixture := range fixtures {
+		decompressed, err := DecompressValue(fixture.compressed, fixture.codec)
+		if err != nil || !bytes.Equal(decompressed, value) {
+			t.Errorf("Expected %s to decompress %x to %q, got %q (%v)", fixture.codec, fixture.compressed, value, decompressed, err)
+		}
+	}
+
+	// Raw snappy blocks have no magic bytes to detect them by
+	for _, fixture := range fixtures[:4] {
+		if codec := DetectCompression(fixture.compressed); codec != fixture.codec {
+			t.Errorf("Expected %x to be detected as %s, got %q", fixture.compressed, fixture.codec, codec)
+		}
+		decompressed, err := DecompressValue(fixture.compressed, "auto")
+		if err != nil || !bytes.Equal(decompressed, value) {
+			t.Errorf("Expected auto to decompress %x, got %q (%v)", fixture.compressed, decompressed, err)
+		}
+	}
+}
+
+func TestDecompressUncompressedValue(t *testing.T) {
+	value := []byte("plain text value")
+
+	for _, codec := range []string{"gzip", "zstd", "snappy"} {
+		if _, err := DecompressValue(value, codec); !errors.Is(err, ErrNotCompressed) {
+			t.Errorf("Expected %s to report the value as not compressed, got %v", codec, err)
+		}
+	}
+	if decompressed, err := DecompressValue(value, "auto"); err != nil || !bytes.Equal(decompressed, value) {
+		t.Errorf("Expected auto to keep the value, got %q (%v)", decompressed, err)
+	}
+
+	// Values with the magic bytes which are not valid are errors
+	if _, err := DecompressValue([]byte{0x1f, 0x8b, 0x00}, "auto"); err == nil || errors.Is(err, ErrNotCompressed) {
+		t.Errorf("Expected a corrupt gzip value to fail, got %v", err)
+	}
+	if _, err := DecompressValue(value, "lz4"); err == nil {
+		t.Error("Expected an error for an unknown codec")
+	}
+}
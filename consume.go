@@ -0,0 +1,349 @@
+package kafkatools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/Shopify/sarama"
+)
+
+// MessageHandler handles a consumed message, returning an error stops the consumption
+type MessageHandler func(msg *sarama.ConsumerMessage) error
+
+// ErrStopConsuming can be returned by a MessageHandler to stop the consumption without an error
+var ErrStopConsuming = errors.New("stop consuming")
+
+// ErrEmptyRange is returned by Consumption.Add for a range without messages, the partition is not consumed
+var ErrEmptyRange = errors.New("empty range")
+
+// Consumer consumes ranges of partitions and merges their messages into one channel. The hooks are optional, they are
+// called concurrently for different partitions but in offset order within a partition.
+type Consumer struct {
+	consumer sarama.Consumer
+	// OnError is called with the errors of the partition consumers, which retry after an error. The errors are
+	// dropped when it is nil.
+	OnError func(err *sarama.ConsumerError)
+	// ResetOutOfRange consumes a partition whose offset is out of range from its oldest offset instead, when it starts
+	// and when the offset gets out of range while consuming (e.g. removed by retention). The out of range errors are
+	// passed to OnError.
+	ResetOutOfRange bool
+	// MaxMessagesPerPartition stops a partition after this number of its messages were sent, 0 does not limit them
+	MaxMessagesPerPartition int
+	// EndOffset returns the end offset of a partition once its partition consumer started, e.g. the high-water mark
+	// it looked up. It is passed the end offset of the range, nil when the partition is followed, and may return nil
+	// to follow it.
+	EndOffset func(pc sarama.PartitionConsumer, start TopicPartitionOffset, end *int64) *int64
+	// OnMessage is called with every message of the range before it is sent, the message is skipped when it returns
+	// false. It may block, e.g. to pause or throttle the partition, but has to return false once the context is done.
+	OnMessage func(ctx context.Context, pc sarama.PartitionConsumer, msg *sarama.ConsumerMessage) bool
+	// OnDone is called once a partition is done, with the error closing its partition consumer
+	OnDone func(topic string, partition int32, err error)
+	// OnFinished is called once every partition of a consumption is done, before its messages channel is closed
+	OnFinished func()
+}
+
+// NewConsumerFromClient returns a consumer using the client, closing the consumer leaves the client open
+func NewConsumerFromClient(client sarama.Client) (*Consumer, error) {
+	consumer, err := sarama.NewConsumerFromClient(client)
+	if err != nil {
+		return nil, err
+	}
+	return NewConsumer(consumer), nil
+}
+
+// NewConsumer returns a consumer using the sarama consumer, closing the consumer closes the sarama consumer
+func NewConsumer(consumer sarama.Consumer) *Consumer {
+	return &Consumer{consumer: consumer}
+}
+
+// Close closes the consumer, the partitions stop being consumed
+func (c *Consumer) Close() error {
+	return c.consumer.Close()
+}
+
+// Consume consumes the partitions of the topic from their start offsets (sarama.OffsetOldest, sarama.OffsetNewest or
+// an absolute offset). The partitions with an end offset are consumed until the message before it, the others are
+// followed. Messages of different partitions are interleaved, but the messages of a single partition are sent in
+// offset order. The channel is closed once every partition reached its end offset or the context is done.
+func (c *Consumer) Consume(ctx context.Context, topic string, start, end map[int32]TopicPartitionOffset) (<-chan *sarama.ConsumerMessage, error) {
+	consumption := c.Start(ctx)
+	for partition, offset := range start {
+		var endOffset *int64
+		if partitionEnd, ok := end[partition]; ok {
+			endOffset = &partitionEnd.Offset
+		}
+
+		if err := consumption.Add(offset, endOffset); err != nil && !errors.Is(err, ErrEmptyRange) {
+			consumption.cancel()
+			consumption.Finish()
+			return nil, fmt.Errorf("could not consume %s partition %d: %v", topic, partition, err)
+		}
+	}
+	consumption.Finish()
+	return consumption.Messages(), nil
+}
+
+// Consumption is a consumption started by Consumer.Start, the partitions added to it send their messages to one
+// channel. The channel is closed once Finish was called and every partition is done.
+type Consumption struct {
+	consumer *Consumer
+	ctx      context.Context
+	cancel   context.CancelFunc
+	messages chan *sarama.ConsumerMessage
+
+	mutex    sync.Mutex
+	finished bool
+	// partitions are the partitions which are not done, and the consumption itself until it is finished
+	partitions sync.WaitGroup
+}
+
+// Start starts a consumption without partitions, they are consumed until the context is done
+func (c *Consumer) Start(ctx context.Context) *Consumption {
+	ctx, cancel := context.WithCancel(ctx)
+	s := &Consumption{consumer: c, ctx: ctx, cancel: cancel, messages: make(chan *sarama.ConsumerMessage)}
+	s.partitions.Add(1)
+	go func() {
+		s.partitions.Wait()
+		if c.OnFinished != nil {
+			c.OnFinished()
+		}
+		close(s.messages)
+		cancel()
+	}()
+	return s
+}
+
+// Messages returns the messages of the partitions, in offset order within every partition
+func (s *Consumption) Messages() <-chan *sarama.ConsumerMessage {
+	return s.messages
+}
+
+// Add consumes the partition from the start offset until the message before the end offset, nil follows it. It
+// returns ErrEmptyRange without consuming the partition when the range has no messages, and the error of the
+// partition consumer when it could not be started.
+func (s *Consumption) Add(start TopicPartitionOffset, end *int64) error {
+	s.mutex.Lock()
+	if s.finished {
+		s.mutex.Unlock()
+		return errors.New("the consumption is finished")
+	}
+	s.partitions.Add(1)
+	s.mutex.Unlock()
+
+	pc, partitionEnd, err := s.startPartition(start, end)
+	if err != nil {
+		s.partitions.Done()
+		return err
+	}
+	go s.consume(pc, start, partitionEnd, end)
+	return nil
+}
+
+// Finish declares that no more partitions are added, the messages channel is closed once the partitions are done
+func (s *Consumption) Finish() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if !s.finished {
+		s.finished = true
+		s.partitions.Done()
+	}
+}
+
+// emptyRange reports whether a range has no messages, symbolic start offsets like sarama.OffsetOldest are resolved by
+// the partition consumer and never empty
+func emptyRange(start, end int64) bool {
+	return start >= 0 && start >= end
+}
+
+// startPartition starts the partition consumer of the range and resolves its end offset
+func (s *Consumption) startPartition(start TopicPartitionOffset, end *int64) (sarama.PartitionConsumer, *int64, error) {
+	c := s.consumer
+	// The end offset of EndOffset is only known once the partition consumer started
+	if end != nil && c.EndOffset == nil && emptyRange(start.Offset, *end) {
+		return nil, nil, ErrEmptyRange
+	}
+
+	pc, err := c.consumer.ConsumePartition(start.Topic, start.Partition, start.Offset)
+	if errors.Is(err, sarama.ErrOffsetOutOfRange) && c.ResetOutOfRange {
+		c.onError(&sarama.ConsumerError{Topic: start.Topic, Partition: start.Partition, Err: err})
+		pc, err = c.consumer.ConsumePartition(start.Topic, start.Partition, sarama.OffsetOldest)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if c.EndOffset != nil {
+		end = c.EndOffset(pc, start, end)
+	}
+	if end != nil && emptyRange(start.Offset, *end) {
+		pc.AsyncClose()
+		return nil, nil, ErrEmptyRange
+	}
+	return pc, end, nil
+}
+
+// consume consumes the partition until it is done, a partition whose offset got out of range is consumed again from
+// its oldest offset with ResetOutOfRange. rangeEnd is the end offset of the range before EndOffset resolved it.
+func (s *Consumption) consume(pc sarama.PartitionConsumer, start TopicPartitionOffset, end, rangeEnd *int64) {
+	defer s.partitions.Done()
+
+	var closeErr error
+	for {
+		outOfRange, err := s.consumePartition(pc, end)
+		if err != nil && closeErr == nil {
+			closeErr = err
+		}
+		if !outOfRange || !s.consumer.ResetOutOfRange || s.ctx.Err() != nil {
+			break
+		}
+
+		oldest := TopicPartitionOffset{Topic: start.Topic, Partition: start.Partition, Offset: sarama.OffsetOldest}
+		if pc, end, err = s.startPartition(oldest, rangeEnd); err != nil {
+			if !errors.Is(err, ErrEmptyRange) {
+				s.consumer.onError(&sarama.ConsumerError{Topic: start.Topic, Partition: start.Partition, Err: err})
+			}
+			break
+		}
+	}
+
+	if s.consumer.OnDone != nil {
+		s.consumer.OnDone(start.Topic, start.Partition, closeErr)
+	}
+}
+
+// consumePartition sends the messages of the partition until its end offset (nil follows it), the context is done or
+// the partition consumer shut down, e.g. because its offset got out of range. The last message of the range stops it
+// right away instead of waiting for a message beyond the range. It returns once the partition consumer released the
+// partition, with whether its offset got out of range and the error closing it.
+func (s *Consumption) consumePartition(pc sarama.PartitionConsumer, end *int64) (outOfRange bool, err error) {
+	c := s.consumer
+	errorsDone := make(chan struct{})
+	go func() {
+		defer close(errorsDone)
+		for err := range pc.Errors() {
+			if errors.Is(err, sarama.ErrOffsetOutOfRange) {
+				outOfRange = true
+			}
+			c.onError(err)
+		}
+	}()
+
+	sent := 0
+loop:
+	for {
+		select {
+		case msg, ok := <-pc.Messages():
+			if !ok || (end != nil && msg.Offset >= *end) {
+				break loop
+			}
+			last := end != nil && msg.Offset >= *end-1
+
+			if c.OnMessage == nil || c.OnMessage(s.ctx, pc, msg) {
+				select {
+				case s.messages <- msg:
+				case <-s.ctx.Done():
+					break loop
+				}
+
+				sent++
+				if c.MaxMessagesPerPartition > 0 && sent >= c.MaxMessagesPerPartition {
+					last = true
+				}
+			}
+			if last {
+				break loop
+			}
+		case <-s.ctx.Done():
+			break loop
+		}
+	}
+
+	// The errors are closed once the partition is released, so it can be consumed again
+	err = pc.Close()
+	<-errorsDone
+	return outOfRange, err
+}
+
+// onError passes the error to OnError, when set
+func (c *Consumer) onError(err *sarama.ConsumerError) {
+	if c.OnError != nil {
+		c.OnError(err)
+	}
+}
+
+// ConsumeTopicWithHandler consumes every partition of the topic from the offset (sarama.OffsetOldest,
+// sarama.OffsetNewest or an absolute offset) and passes the messages to the handler, one at a time and in order within
+// every partition. It consumes until the handler returns an error, which is returned unless it is ErrStopConsuming.
+func ConsumeTopicWithHandler(client sarama.Client, topic string, offset int64, handler MessageHandler) error {
+	return ConsumeTopicWithHandlerContext(context.Background(), client, topic, offset, handler)
+}
+
+// ConsumeTopicWithHandlerContext consumes the topic like ConsumeTopicWithHandler until the handler returns an error or
+// the context is done, which returns ctx.Err()
+func ConsumeTopicWithHandlerContext(ctx context.Context, client sarama.Client, topic string, offset int64, handler MessageHandler) error {
+	var partitions []int32
+	var err error
+	if ctxErr := runContext(ctx, client, func() { partitions, err = client.Partitions(topic) }); ctxErr != nil {
+		return ctxErr
+	}
+	if err != nil {
+		return err
+	}
+	start := make(map[int32]TopicPartitionOffset, len(partitions))
+	for _, partition := range partitions {
+		start[partition] = TopicPartitionOffset{Topic: topic, Partition: partition, Offset: offset}
+	}
+
+	consumer, err := NewConsumerFromClient(client)
+	if err != nil {
+		return err
+	}
+	defer consumer.Close()
+
+	consumeCtx, cancel := context.WithCancel(ctx)
+	messages, err := consumer.Consume(consumeCtx, topic, start, nil)
+	if err != nil {
+		cancel()
+		return err
+	}
+	// The partitions are done once the messages are closed
+	defer func() {
+		cancel()
+		for range messages {
+		}
+	}()
+
+	for msg := range messages {
+		if err := handler(msg); err != nil {
+			if err == ErrStopConsuming {
+				return nil
+			}
+			return err
+		}
+	}
+	// The messages are only closed early when the context is done
+	return ctx.Err()
+}
+
+// ConsumeTopicToWriter consumes the topic like ConsumeTopicWithHandler and writes the value of every message to the
+// output, followed by a newline. It consumes until writing fails.
+func ConsumeTopicToWriter(client sarama.Client, topic string, offset int64, out io.Writer) error {
+	return ConsumeTopicWithHandler(client, topic, offset, WriteMessageValues(out))
+}
+
+// ConsumeTopicToWriterContext consumes the topic like ConsumeTopicToWriter until writing fails or the context is done,
+// which returns ctx.Err()
+func ConsumeTopicToWriterContext(ctx context.Context, client sarama.Client, topic string, offset int64, out io.Writer) error {
+	return ConsumeTopicWithHandlerContext(ctx, client, topic, offset, WriteMessageValues(out))
+}
+
+// WriteMessageValues returns a handler writing the value of every message to the output, followed by a newline
+func WriteMessageValues(out io.Writer) MessageHandler {
+	return func(msg *sarama.ConsumerMessage) error {
+		_, err := out.Write(append(append([]byte{}, msg.Value...), '\n'))
+		return err
+	}
+}
@@ -0,0 +1,345 @@
+package kafkatools
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+)
+
+func newConsumeTestClient(t *testing.T) (sarama.Client, func()) {
+	broker := sarama.NewMockBroker(t, 1)
+	fetch := sarama.NewMockFetchResponse(t, 1)
+	for offset := int64(0); offset < 3; offset++ {
+		fetch.SetMessage("foo", 0, offset, sarama.StringEncoder([]string{"a", "b", "c"}[offset]))
+	}
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": sarama.NewMockMetadataResponse(t).
+			SetBroker(broker.Addr(), broker.BrokerID()).
+			SetLeader("foo", 0, broker.BrokerID()),
+		"OffsetRequest": sarama.NewMockOffsetResponse(t).
+			SetOffset("foo", 0, sarama.OffsetOldest, 0).
+			SetOffset("foo", 0, sarama.OffsetNewest, 3),
+		"FetchRequest": fetch,
+	})
+
+	client, err := sarama.NewClient([]string{broker.Addr()}, sarama.NewConfig())
+	if err != nil {
+		broker.Close()
+		t.Fatal("Unexpected error: ", err)
+	}
+	return client, func() {
+		client.Close()
+		broker.Close()
+	}
+}
+
+func TestConsumeTopicWithHandler(t *testing.T) {
+	client, closeClient := newConsumeTestClient(t)
+	defer closeClient()
+
+	var offsets []int64
+	failure := errors.New("failure")
+	err := ConsumeTopicWithHandler(client, "foo", sarama.OffsetOldest, func(msg *sarama.ConsumerMessage) error {
+		offsets = append(offsets, msg.Offset)
+		if msg.Offset == 1 {
+			return failure
+		}
+		return nil
+	})
+	if err != failure {
+		t.Errorf("Expected the error of the handler, got %v", err)
+	}
+	if len(offsets) != 2 || offsets[0] != 0 || offsets[1] != 1 {
+		t.Errorf("Expected offsets 0 and 1 to be handled, got %v", offsets)
+	}
+}
+
+func TestWriteMessageValues(t *testing.T) {
+	client, closeClient := newConsumeTestClient(t)
+	defer closeClient()
+
+	var out bytes.Buffer
+	write := WriteMessageValues(&out)
+	err := ConsumeTopicWithHandler(client, "foo", 1, func(msg *sarama.ConsumerMessage) error {
+		if err := write(msg); err != nil {
+			return err
+		}
+		if msg.Offset == 2 {
+			return ErrStopConsuming
+		}
+		return nil
+	})
+	if err != nil {
+		t.Errorf("Expected no error when the handler stops, got %v", err)
+	}
+	if out.String() != "b\nc\n" {
+		t.Errorf("Expected the values from offset 1, got %q", out.String())
+	}
+}
+
+func TestConsumerConsume(t *testing.T) {
+	client, closeClient := newConsumeTestClient(t)
+	defer closeClient()
+
+	consumer, err := NewConsumerFromClient(client)
+	if err != nil {
+		t.Fatal("Unexpected error: ", err)
+	}
+	defer consumer.Close()
+
+	// The range ends before offset 2, the channel is closed without waiting for another message
+	start := map[int32]TopicPartitionOffset{0: {Topic: "foo", Partition: 0, Offset: sarama.OffsetOldest}}
+	end := map[int32]TopicPartitionOffset{0: {Topic: "foo", Partition: 0, Offset: 2}}
+	messages, err := consumer.Consume(context.Background(), "foo", start, end)
+	if err != nil {
+		t.Fatal("Unexpected error: ", err)
+	}
+
+	var values []string
+	for msg := range messages {
+		values = append(values, string(msg.Value))
+	}
+	if len(values) != 2 || values[0] != "a" || values[1] != "b" {
+		t.Errorf("Expected the values a and b, got %q", values)
+	}
+}
+
+func TestConsumerConsumeCancel(t *testing.T) {
+	client, closeClient := newConsumeTestClient(t)
+	defer closeClient()
+
+	consumer, err := NewConsumerFromClient(client)
+	if err != nil {
+		t.Fatal("Unexpected error: ", err)
+	}
+	defer consumer.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	start := map[int32]TopicPartitionOffset{0: {Topic: "foo", Partition: 0, Offset: 1}}
+	messages, err := consumer.Consume(ctx, "foo", start, nil)
+	if err != nil {
+		t.Fatal("Unexpected error: ", err)
+	}
+
+	if msg := <-messages; msg == nil || string(msg.Value) != "b" {
+		t.Fatalf("Expected the value b, got %v", msg)
+	}
+	cancel()
+	for range messages {
+	}
+
+	// Empty ranges are not consumed at all
+	end := map[int32]TopicPartitionOffset{0: {Topic: "foo", Partition: 0, Offset: 1}}
+	messages, err = consumer.Consume(context.Background(), "foo", start, end)
+	if err != nil {
+		t.Fatal("Unexpected error: ", err)
+	}
+	if msg, ok := <-messages; ok {
+		t.Errorf("Expected no messages of an empty range, got %v", msg)
+	}
+}
+
+// fakePartitionConsumer is a partition consumer whose channels are filled by the test
+type fakePartitionConsumer struct {
+	sarama.PartitionConsumer
+	messages      chan *sarama.ConsumerMessage
+	errors        chan *sarama.ConsumerError
+	highWaterMark int64
+	closeErr      error
+	closeOnce     sync.Once
+	closed        bool
+}
+
+func newFakePartitionConsumer(offsets ...int64) *fakePartitionConsumer {
+	pc := &fakePartitionConsumer{messages: make(chan *sarama.ConsumerMessage, len(offsets)), errors: make(chan *sarama.ConsumerError, 1)}
+	for _, offset := range offsets {
+		pc.messages <- &sarama.ConsumerMessage{Topic: "foo", Offset: offset}
+	}
+	return pc
+}
+
+func (pc *fakePartitionConsumer) Messages() <-chan *sarama.ConsumerMessage { return pc.messages }
+func (pc *fakePartitionConsumer) Errors() <-chan *sarama.ConsumerError     { return pc.errors }
+func (pc *fakePartitionConsumer) HighWaterMarkOffset() int64               { return pc.highWaterMark }
+
+func (pc *fakePartitionConsumer) AsyncClose() {
+	pc.closeOnce.Do(func() {
+		pc.closed = true
+		close(pc.messages)
+		close(pc.errors)
+	})
+}
+
+func (pc *fakePartitionConsumer) Close() error {
+	pc.AsyncClose()
+	return pc.closeErr
+}
+
+// fakeConsumer hands out its partition consumers in order and records the offsets they were started at
+type fakeConsumer struct {
+	sarama.Consumer
+	mutex   sync.Mutex
+	pcs     []*fakePartitionConsumer
+	started []int64
+}
+
+func (c *fakeConsumer) ConsumePartition(topic string, partition int32, offset int64) (sarama.PartitionConsumer, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.started = append(c.started, offset)
+	if len(c.pcs) == 0 {
+		return nil, sarama.ErrNotLeaderForPartition
+	}
+	pc := c.pcs[0]
+	c.pcs = c.pcs[1:]
+	return pc, nil
+}
+
+func receiveOffsets(t *testing.T, messages <-chan *sarama.ConsumerMessage) (offsets []int64) {
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case msg, ok := <-messages:
+			if !ok {
+				return offsets
+			}
+			offsets = append(offsets, msg.Offset)
+		case <-timeout:
+			t.Fatalf("Expected the messages to be closed, received %v", offsets)
+		}
+	}
+}
+
+func TestConsumptionHooks(t *testing.T) {
+	pc := newFakePartitionConsumer(0, 1, 2, 3, 4)
+	pc.highWaterMark = 3
+	pc.closeErr = errors.New("broker went away")
+	consumer := NewConsumer(&fakeConsumer{pcs: []*fakePartitionConsumer{pc}})
+
+	var done []error
+	finished := false
+	// The partition ends at its high-water mark and skips offset 1
+	consumer.EndOffset = func(pc sarama.PartitionConsumer, start TopicPartitionOffset, end *int64) *int64 {
+		highWaterMark := pc.HighWaterMarkOffset()
+		return &highWaterMark
+	}
+	consumer.OnMessage = func(ctx context.Context, pc sarama.PartitionConsumer, msg *sarama.ConsumerMessage) bool {
+		return msg.Offset != 1
+	}
+	consumer.OnDone = func(topic string, partition int32, err error) { done = append(done, err) }
+	consumer.OnFinished = func() { finished = true }
+
+	consumption := consumer.Start(context.Background())
+	if err := consumption.Add(TopicPartitionOffset{Topic: "foo", Partition: 0, Offset: 0}, nil); err != nil {
+		t.Fatal("Unexpected error: ", err)
+	}
+	consumption.Finish()
+
+	if offsets := receiveOffsets(t, consumption.Messages()); !reflect.DeepEqual(offsets, []int64{0, 2}) {
+		t.Errorf("Expected offsets 0 and 2, received %v", offsets)
+	}
+	if len(done) != 1 || done[0] != pc.closeErr || !pc.closed {
+		t.Errorf("Expected the partition to be closed and done with the close error, got %v", done)
+	}
+	if !finished {
+		t.Error("Expected the consumption to be finished before the messages are closed")
+	}
+	if err := consumption.Add(TopicPartitionOffset{Topic: "foo", Partition: 1}, nil); err == nil {
+		t.Error("Expected an error adding a partition to a finished consumption")
+	}
+}
+
+func TestConsumptionMaxMessagesPerPartition(t *testing.T) {
+	consumer := NewConsumer(&fakeConsumer{pcs: []*fakePartitionConsumer{newFakePartitionConsumer(0, 1, 2)}})
+	consumer.MaxMessagesPerPartition = 2
+
+	consumption := consumer.Start(context.Background())
+	if err := consumption.Add(TopicPartitionOffset{Topic: "foo", Partition: 0, Offset: 0}, nil); err != nil {
+		t.Fatal("Unexpected error: ", err)
+	}
+	consumption.Finish()
+
+	// The partition is followed, it is only stopped by the limit
+	if offsets := receiveOffsets(t, consumption.Messages()); !reflect.DeepEqual(offsets, []int64{0, 1}) {
+		t.Errorf("Expected offsets 0 and 1, received %v", offsets)
+	}
+}
+
+func TestConsumptionResetOutOfRange(t *testing.T) {
+	outOfRange := newFakePartitionConsumer()
+	outOfRange.errors <- &sarama.ConsumerError{Topic: "foo", Partition: 0, Err: sarama.ErrOffsetOutOfRange}
+	// The partition consumer shuts down after an out of range offset
+	outOfRange.AsyncClose()
+	restarted := newFakePartitionConsumer(0)
+	restarted.AsyncClose()
+	fake := &fakeConsumer{pcs: []*fakePartitionConsumer{outOfRange, restarted}}
+
+	consumer := NewConsumer(fake)
+	consumer.ResetOutOfRange = true
+	var errs []error
+	consumer.OnError = func(err *sarama.ConsumerError) { errs = append(errs, err) }
+	done := 0
+	consumer.OnDone = func(topic string, partition int32, err error) { done++ }
+
+	consumption := consumer.Start(context.Background())
+	if err := consumption.Add(TopicPartitionOffset{Topic: "foo", Partition: 0, Offset: 5}, nil); err != nil {
+		t.Fatal("Unexpected error: ", err)
+	}
+	consumption.Finish()
+
+	if offsets := receiveOffsets(t, consumption.Messages()); !reflect.DeepEqual(offsets, []int64{0}) {
+		t.Errorf("Expected offset 0 of the restarted partition, received %v", offsets)
+	}
+	if !reflect.DeepEqual(fake.started, []int64{5, sarama.OffsetOldest}) {
+		t.Errorf("Expected the partition to be consumed again from the oldest offset, started at %v", fake.started)
+	}
+	if len(errs) != 1 || !errors.Is(errs[0], sarama.ErrOffsetOutOfRange) || done != 1 {
+		t.Errorf("Expected the out of range error and the partition to be done once, got %v and %d", errs, done)
+	}
+}
+
+func TestConsumptionEmptyRanges(t *testing.T) {
+	fake := &fakeConsumer{}
+	consumer := NewConsumer(fake)
+	consumption := consumer.Start(context.Background())
+	defer consumption.Finish()
+
+	end := int64(4)
+	if err := consumption.Add(TopicPartitionOffset{Topic: "foo", Partition: 0, Offset: 4}, &end); err != ErrEmptyRange {
+		t.Errorf("Expected ErrEmptyRange, got %v", err)
+	}
+	if len(fake.started) != 0 {
+		t.Errorf("Expected the partition not to be consumed, started at %v", fake.started)
+	}
+
+	// The end offset of EndOffset is only known once the partition consumer started, which is closed again
+	pc := newFakePartitionConsumer()
+	fake.pcs = []*fakePartitionConsumer{pc}
+	consumer.EndOffset = func(pc sarama.PartitionConsumer, start TopicPartitionOffset, end *int64) *int64 { return &start.Offset }
+	if err := consumption.Add(TopicPartitionOffset{Topic: "foo", Partition: 0, Offset: 7}, nil); err != ErrEmptyRange || !pc.closed {
+		t.Errorf("Expected ErrEmptyRange and the partition consumer to be closed, got %v", err)
+	}
+}
+
+func TestEmptyRange(t *testing.T) {
+	for _, test := range []struct {
+		start, end int64
+		empty      bool
+	}{
+		{0, 0, true},
+		{5, 3, true},
+		{0, 1, false},
+		{sarama.OffsetOldest, 0, false},
+		{sarama.OffsetNewest, 0, false},
+	} {
+		if empty := emptyRange(test.start, test.end); empty != test.empty {
+			t.Errorf("Expected the range from %d until %d to be empty %v, got %v", test.start, test.end, test.empty, empty)
+		}
+	}
+}
@@ -0,0 +1,250 @@
+package kafkatools
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// ConsumerOffsetsTopic is the internal topic in which kafka stores group offsets and metadata
+const ConsumerOffsetsTopic = "__consumer_offsets"
+
+// OffsetCommit is a decoded offset commit record of the __consumer_offsets topic
+type OffsetCommit struct {
+	Group     string
+	Topic     string
+	Partition int32
+	// Value is nil for tombstones (deleted or expired offsets)
+	Value *OffsetCommitValue
+}
+
+// OffsetCommitValue contains the committed offset of an offset commit record
+type OffsetCommitValue struct {
+	Version         int16
+	Offset          int64
+	LeaderEpoch     int32
+	Metadata        string
+	CommitTimestamp int64
+	ExpireTimestamp int64
+}
+
+// GroupMetadata is a decoded group metadata record of the __consumer_offsets topic
+type GroupMetadata struct {
+	Group string
+	// Value is nil for tombstones (deleted groups)
+	Value *GroupMetadataValue
+}
+
+// GroupMetadataValue contains the state of a consumer group
+type GroupMetadataValue struct {
+	Version               int16
+	ProtocolType          string
+	Generation            int32
+	Protocol              string
+	Leader                string
+	CurrentStateTimestamp int64
+	Members               []GroupMemberMetadata
+}
+
+// GroupMemberMetadata contains the metadata of a single consumer group member
+type GroupMemberMetadata struct {
+	MemberID         string
+	GroupInstanceID  string
+	ClientID         string
+	ClientHost       string
+	RebalanceTimeout int32
+	SessionTimeout   int32
+	Assignment       []TopicAssignment
+}
+
+// DecodeConsumerOffsetsRecord decodes a record of the __consumer_offsets topic into an *OffsetCommit or a *GroupMetadata
+func DecodeConsumerOffsetsRecord(key, value []byte) (interface{}, error) {
+	keyReader := &binaryReader{buf: bytes.NewBuffer(key)}
+	keyVersion := keyReader.int16()
+	if keyReader.err != nil {
+		return nil, fmt.Errorf("could not decode key version: %v", keyReader.err)
+	}
+
+	switch keyVersion {
+	case 0, 1:
+		commit := &OffsetCommit{
+			Group:     keyReader.string(),
+			Topic:     keyReader.string(),
+			Partition: keyReader.int32(),
+		}
+		if keyReader.err != nil {
+			return nil, fmt.Errorf("could not decode offset commit key: %v", keyReader.err)
+		}
+		if value != nil {
+			commitValue, err := decodeOffsetCommitValue(value)
+			if err != nil {
+				return nil, err
+			}
+			commit.Value = commitValue
+		}
+		return commit, nil
+	case 2:
+		metadata := &GroupMetadata{Group: keyReader.string()}
+		if keyReader.err != nil {
+			return nil, fmt.Errorf("could not decode group metadata key: %v", keyReader.err)
+		}
+		if value != nil {
+			metadataValue, err := decodeGroupMetadataValue(value)
+			if err != nil {
+				return nil, err
+			}
+			metadata.Value = metadataValue
+		}
+		return metadata, nil
+	default:
+		return nil, fmt.Errorf("unknown key version %d", keyVersion)
+	}
+}
+
+func decodeOffsetCommitValue(value []byte) (*OffsetCommitValue, error) {
+	reader := &binaryReader{buf: bytes.NewBuffer(value)}
+	commit := &OffsetCommitValue{Version: reader.int16(), LeaderEpoch: -1, ExpireTimestamp: -1}
+
+	switch commit.Version {
+	case 0, 1, 2:
+		commit.Offset = reader.int64()
+		commit.Metadata = reader.string()
+		commit.CommitTimestamp = reader.int64()
+		if commit.Version == 1 {
+			commit.ExpireTimestamp = reader.int64()
+		}
+	case 3:
+		commit.Offset = reader.int64()
+		commit.LeaderEpoch = reader.int32()
+		commit.Metadata = reader.string()
+		commit.CommitTimestamp = reader.int64()
+	default:
+		if reader.err == nil {
+			return nil, fmt.Errorf("unsupported offset commit value version %d", commit.Version)
+		}
+	}
+
+	if reader.err != nil {
+		return nil, fmt.Errorf("could not decode offset commit value: %v", reader.err)
+	}
+	return commit, nil
+}
+
+func decodeGroupMetadataValue(value []byte) (*GroupMetadataValue, error) {
+	reader := &binaryReader{buf: bytes.NewBuffer(value)}
+	group := &GroupMetadataValue{Version: reader.int16(), CurrentStateTimestamp: -1}
+	if reader.err == nil && (group.Version < 0 || group.Version > 3) {
+		return nil, fmt.Errorf("unsupported group metadata value version %d", group.Version)
+	}
+
+	group.ProtocolType = reader.string()
+	group.Generation = reader.int32()
+	group.Protocol = reader.string()
+	group.Leader = reader.string()
+	if group.Version >= 2 {
+		group.CurrentStateTimestamp = reader.int64()
+	}
+
+	members := reader.int32()
+	for i := int32(0); i < members && reader.err == nil; i++ {
+		var member GroupMemberMetadata
+		member.MemberID = reader.string()
+		if group.Version >= 3 {
+			member.GroupInstanceID = reader.string()
+		}
+		member.ClientID = reader.string()
+		member.ClientHost = reader.string()
+		if group.Version >= 1 {
+			member.RebalanceTimeout = reader.int32()
+		}
+		member.SessionTimeout = reader.int32()
+		reader.bytes() // subscription
+		member.Assignment = decodeAssignment(reader.bytes())
+		group.Members = append(group.Members, member)
+	}
+
+	if reader.err != nil {
+		return nil, fmt.Errorf("could not decode group metadata value: %v", reader.err)
+	}
+	return group, nil
+}
+
+// decodeAssignment decodes the consumer protocol assignment of a member, ignoring malformed assignments
+func decodeAssignment(assignment []byte) (assignments []TopicAssignment) {
+	if len(assignment) == 0 {
+		return nil
+	}
+
+	reader := &binaryReader{buf: bytes.NewBuffer(assignment)}
+	reader.int16() // version
+	topics := reader.int32()
+	for i := int32(0); i < topics && reader.err == nil; i++ {
+		topicAssignment := TopicAssignment{Topic: reader.string()}
+		partitions := reader.int32()
+		for j := int32(0); j < partitions && reader.err == nil; j++ {
+			topicAssignment.Partitions = append(topicAssignment.Partitions, reader.int32())
+		}
+		assignments = append(assignments, topicAssignment)
+	}
+
+	if reader.err != nil {
+		return nil
+	}
+	return assignments
+}
+
+// binaryReader reads big endian kafka protocol primitives, remembering the first error
+type binaryReader struct {
+	buf *bytes.Buffer
+	err error
+}
+
+func (r *binaryReader) read(val interface{}) {
+	if r.err == nil {
+		r.err = binary.Read(r.buf, binary.BigEndian, val)
+	}
+}
+
+func (r *binaryReader) int16() (val int16) {
+	r.read(&val)
+	return val
+}
+
+func (r *binaryReader) int32() (val int32) {
+	r.read(&val)
+	return val
+}
+
+func (r *binaryReader) int64() (val int64) {
+	r.read(&val)
+	return val
+}
+
+// string reads a (nullable) string, null strings are returned as empty strings
+func (r *binaryReader) string() string {
+	length := r.int16()
+	if r.err != nil || length < 0 {
+		return ""
+	}
+	return string(r.next(int(length)))
+}
+
+func (r *binaryReader) bytes() []byte {
+	length := r.int32()
+	if r.err != nil || length < 0 {
+		return nil
+	}
+	return r.next(int(length))
+}
+
+func (r *binaryReader) next(length int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if r.buf.Len() < length {
+		r.err = io.ErrUnexpectedEOF
+		return nil
+	}
+	return r.buf.Next(length)
+}
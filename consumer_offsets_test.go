@@ -0,0 +1,111 @@
+package kafkatools
+
+import (
+	"bytes"
+	"encoding/binary"
+	"reflect"
+	"testing"
+)
+
+func encode(values ...interface{}) []byte {
+	var buf bytes.Buffer
+	for _, value := range values {
+		switch v := value.(type) {
+		case string:
+			binary.Write(&buf, binary.BigEndian, int16(len(v)))
+			buf.WriteString(v)
+		case []byte:
+			binary.Write(&buf, binary.BigEndian, int32(len(v)))
+			buf.Write(v)
+		default:
+			binary.Write(&buf, binary.BigEndian, v)
+		}
+	}
+	return buf.Bytes()
+}
+
+func TestDecodeOffsetCommit(t *testing.T) {
+	key := encode(int16(1), "group", "topic", int32(3))
+	value := encode(int16(3), int64(42), int32(7), "meta", int64(1500000000000))
+
+	record, err := DecodeConsumerOffsetsRecord(key, value)
+	if err != nil {
+		t.Fatal("Unexpected error: ", err)
+	}
+
+	expected := &OffsetCommit{
+		Group:     "group",
+		Topic:     "topic",
+		Partition: 3,
+		Value: &OffsetCommitValue{
+			Version:         3,
+			Offset:          42,
+			LeaderEpoch:     7,
+			Metadata:        "meta",
+			CommitTimestamp: 1500000000000,
+			ExpireTimestamp: -1,
+		},
+	}
+
+	if !reflect.DeepEqual(record, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, record)
+	}
+}
+
+func TestDecodeOffsetCommitTombstone(t *testing.T) {
+	record, err := DecodeConsumerOffsetsRecord(encode(int16(0), "group", "topic", int32(0)), nil)
+	if err != nil {
+		t.Fatal("Unexpected error: ", err)
+	}
+
+	if commit, ok := record.(*OffsetCommit); !ok || commit.Value != nil {
+		t.Errorf("Expected an offset commit tombstone, got %+v", record)
+	}
+}
+
+func TestDecodeGroupMetadata(t *testing.T) {
+	assignment := encode(int16(0), int32(1), "topic", int32(2), int32(0), int32(1), []byte{})
+	value := encode(int16(1), "consumer", int32(5), "range", "member-1",
+		int32(1), "member-1", "client", "/127.0.0.1", int32(60000), int32(10000), []byte{1, 2}, assignment)
+
+	record, err := DecodeConsumerOffsetsRecord(encode(int16(2), "group"), value)
+	if err != nil {
+		t.Fatal("Unexpected error: ", err)
+	}
+
+	expected := &GroupMetadata{
+		Group: "group",
+		Value: &GroupMetadataValue{
+			Version:               1,
+			ProtocolType:          "consumer",
+			Generation:            5,
+			Protocol:              "range",
+			Leader:                "member-1",
+			CurrentStateTimestamp: -1,
+			Members: []GroupMemberMetadata{{
+				MemberID:         "member-1",
+				ClientID:         "client",
+				ClientHost:       "/127.0.0.1",
+				RebalanceTimeout: 60000,
+				SessionTimeout:   10000,
+				Assignment:       []TopicAssignment{{Topic: "topic", Partitions: []int32{0, 1}}},
+			}},
+		},
+	}
+
+	if !reflect.DeepEqual(record, expected) {
+		t.Errorf("Expected %+v, got %+v", expected.Value, record.(*GroupMetadata).Value)
+	}
+}
+
+func TestDecodeTruncatedRecord(t *testing.T) {
+	key := encode(int16(1), "group", "topic", int32(3))
+
+	if _, err := DecodeConsumerOffsetsRecord(key, encode(int16(3), int64(42))); err == nil {
+		t.Error("Expected an error for a truncated value")
+	}
+
+	if _, err := DecodeConsumerOffsetsRecord([]byte{0}, nil); err == nil {
+		t.Error("Expected an error for a truncated key")
+	}
+}
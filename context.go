@@ -0,0 +1,328 @@
+package kafkatools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+
+	"github.com/Shopify/sarama"
+	cluster "github.com/bsm/sarama-cluster"
+)
+
+// The Context variants of the library functions return ctx.Err() as soon as the context is done. Sarama can't cancel
+// a single request, so the requests in flight are aborted by closing the connections of the client: the clients and
+// consumers of this library dial through an abortingDialer. Requests of other goroutines sharing the client fail as
+// well, and the brokers reconnect when they are used again. Clients with a config which is not made by
+// NewSaramaConfig can't be aborted, their requests finish in the background within the network timeouts. The Context
+// variants of ConsumeTopicWithHandler and ConsumeTopicToWriter are in consume.go.
+
+// errAborted is returned when connecting while the requests of a done context are aborted
+var errAborted = errors.New("the connections were aborted because the context is done")
+
+// dialer is implemented by the dialers of sarama (proxy.Dialer)
+type dialer interface {
+	Dial(network, addr string) (net.Conn, error)
+}
+
+// abortingDialer keeps track of the connections it dials, so the requests in flight on them can be aborted
+type abortingDialer struct {
+	dialer dialer
+	mutex  sync.Mutex
+	conns  map[*abortableConn]bool
+	// aborting is the number of aborted functions which did not return yet, no connections are made meanwhile
+	aborting int
+}
+
+func newAbortingDialer(dialer dialer) *abortingDialer {
+	return &abortingDialer{dialer: dialer, conns: make(map[*abortableConn]bool)}
+}
+
+// Dial connects like the underlying dialer, unless the connections are being aborted
+func (d *abortingDialer) Dial(network, addr string) (net.Conn, error) {
+	if d.aborted() {
+		return nil, errAborted
+	}
+	conn, err := d.dialer.Dial(network, addr)
+	if err != nil {
+		return nil, err
+	}
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if d.aborting > 0 {
+		conn.Close()
+		return nil, errAborted
+	}
+	tracked := &abortableConn{Conn: conn, dialer: d}
+	d.conns[tracked] = true
+	return tracked, nil
+}
+
+func (d *abortingDialer) String() string {
+	if stringer, ok := d.dialer.(fmt.Stringer); ok {
+		return stringer.String()
+	}
+	return "direct connections"
+}
+
+func (d *abortingDialer) aborted() bool {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return d.aborting > 0
+}
+
+// abort closes all connections, which fails their requests in flight, and refuses new connections until resume
+func (d *abortingDialer) abort() {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.aborting++
+	for conn := range d.conns {
+		conn.Conn.Close()
+		delete(d.conns, conn)
+	}
+}
+
+// resume allows connecting again once an aborted function returned
+func (d *abortingDialer) resume() {
+	d.mutex.Lock()
+	d.aborting--
+	d.mutex.Unlock()
+}
+
+func (d *abortingDialer) forget(conn *abortableConn) {
+	d.mutex.Lock()
+	delete(d.conns, conn)
+	d.mutex.Unlock()
+}
+
+// abortableConn is a connection of an abortingDialer
+type abortableConn struct {
+	net.Conn
+	dialer *abortingDialer
+}
+
+func (c *abortableConn) Close() error {
+	c.dialer.forget(c)
+	return c.Conn.Close()
+}
+
+// abortingDialerOf returns the abortingDialer of the config, nil when it doesn't use one
+func abortingDialerOf(config *sarama.Config) *abortingDialer {
+	if !config.Net.Proxy.Enable {
+		return nil
+	}
+	d, _ := config.Net.Proxy.Dialer.(*abortingDialer)
+	return d
+}
+
+// GetSaramaClientContext sets up a kafka client, unless the context is done first
+func GetSaramaClientContext(ctx context.Context, brokers ...string) (sarama.Client, error) {
+	return GetSaramaClientWithConfigContext(ctx, nil, brokers...)
+}
+
+// GetSaramaClientWithConfigContext sets up a kafka client using the given client config, unless the context is done
+// first
+func GetSaramaClientWithConfigContext(ctx context.Context, clientConfig *ClientConfig, brokers ...string) (sarama.Client, error) {
+	config := NewSaramaConfig(clientConfig)
+	client, err := connectContext(ctx, abortingDialerOf(config), func() (io.Closer, error) {
+		return sarama.NewClient(brokers, config)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return client.(sarama.Client), nil
+}
+
+// GetClusterAdminContext sets up a kafka cluster admin, unless the context is done first
+func GetClusterAdminContext(ctx context.Context, brokers ...string) (sarama.ClusterAdmin, error) {
+	return GetClusterAdminWithConfigContext(ctx, nil, brokers...)
+}
+
+// GetClusterAdminWithConfigContext sets up a kafka cluster admin using the given client config, unless the context is
+// done first
+func GetClusterAdminWithConfigContext(ctx context.Context, clientConfig *ClientConfig, brokers ...string) (sarama.ClusterAdmin, error) {
+	client, err := GetSaramaClientWithConfigContext(ctx, clientConfig, brokers...)
+	if err != nil {
+		return nil, err
+	}
+
+	var admin sarama.ClusterAdmin
+	if ctxErr := runContext(ctx, client, func() { admin, err = GetClusterAdminFromClient(client) }); ctxErr != nil {
+		err = ctxErr
+	}
+	if err != nil {
+		client.Close()
+		return nil, err
+	}
+	return admin, nil
+}
+
+// GetSaramaConsumerContext returns a high-level kafka consumer like GetSaramaConsumer, unless the context is done first
+func GetSaramaConsumerContext(ctx context.Context, brokers string, consumerGroup string, topics []string) (*cluster.Consumer, error) {
+	config := newConsumerConfig()
+	config.Net.Proxy.Enable = true
+	config.Net.Proxy.Dialer = newAbortingDialer(&net.Dialer{Timeout: config.Net.DialTimeout, KeepAlive: config.Net.KeepAlive})
+
+	return newClusterConsumerContext(ctx, config, consumerGroup, topics, strings.Split(brokers, ","))
+}
+
+// GetSaramaConsumerWithConfigContext returns a high-level kafka consumer using the given client config, unless the
+// context is done first
+func GetSaramaConsumerWithConfigContext(ctx context.Context, clientConfig *ClientConfig, consumerGroup string, topics []string, brokers ...string) (*cluster.Consumer, error) {
+	config := cluster.NewConfig()
+	config.Config = *NewSaramaConfig(clientConfig)
+	config.Group.Return.Notifications = true
+	config.Consumer.Offsets.Initial = sarama.OffsetNewest
+
+	return newClusterConsumerContext(ctx, config, consumerGroup, topics, brokers)
+}
+
+func newClusterConsumerContext(ctx context.Context, config *cluster.Config, consumerGroup string, topics, brokers []string) (*cluster.Consumer, error) {
+	consumer, err := connectContext(ctx, abortingDialerOf(&config.Config), func() (io.Closer, error) {
+		return cluster.NewConsumer(brokers, consumerGroup, topics, config)
+	})
+	if err != nil {
+		if err == ctx.Err() {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to start consumer: %v", err)
+	}
+	return consumer.(*cluster.Consumer), nil
+}
+
+// FetchTopicOffsetsContext fetches topic offsets, unless the context is done first
+func FetchTopicOffsetsContext(ctx context.Context, client sarama.Client, offset int64, topic string) (map[int32]TopicPartitionOffset, error) {
+	var topicOffsets map[int32]TopicPartitionOffset
+	var err error
+	if ctxErr := runContext(ctx, client, func() { topicOffsets, err = FetchTopicOffsets(client, offset, topic) }); ctxErr != nil {
+		return nil, ctxErr
+	}
+	return topicOffsets, err
+}
+
+// FetchTopicOffsetsForTimeContext fetches the offsets of the topic at the time in milliseconds like
+// FetchTopicOffsetsForTime, unless the context is done first
+func FetchTopicOffsetsForTimeContext(ctx context.Context, client sarama.Client, topic string, timeMillis int64) (map[int32]TopicPartitionOffset, error) {
+	var topicOffsets map[int32]TopicPartitionOffset
+	var err error
+	if ctxErr := runContext(ctx, client, func() { topicOffsets, err = FetchTopicOffsetsForTime(client, topic, timeMillis) }); ctxErr != nil {
+		return nil, ctxErr
+	}
+	return topicOffsets, err
+}
+
+// FetchTopicsOffsetsContext fetches the offsets of multiple topics (or all topics when none are given), unless the
+// context is done first
+func FetchTopicsOffsetsContext(ctx context.Context, client sarama.Client, offset int64, topics ...string) (map[string]map[int32]TopicPartitionOffset, error) {
+	var topicOffsets map[string]map[int32]TopicPartitionOffset
+	var err error
+	if ctxErr := runContext(ctx, client, func() { topicOffsets, err = FetchTopicsOffsets(client, offset, topics...) }); ctxErr != nil {
+		return nil, ctxErr
+	}
+	return topicOffsets, err
+}
+
+// FetchOffsetsContext fetches group and topic offsets, unless the context is done first
+func FetchOffsetsContext(ctx context.Context, client sarama.Client, offset int64) (GroupOffsetSlice, map[string]map[int32]TopicPartitionOffset, error) {
+	var groupOffsets GroupOffsetSlice
+	var topicOffsets map[string]map[int32]TopicPartitionOffset
+	var err error
+	if ctxErr := runContext(ctx, client, func() { groupOffsets, topicOffsets, err = FetchOffsets(client, offset) }); ctxErr != nil {
+		return nil, nil, ctxErr
+	}
+	return groupOffsets, topicOffsets, err
+}
+
+// FetchGroupOffsetsContext fetches the committed offsets of the group from its coordinator, unless the context is done
+// first
+func FetchGroupOffsetsContext(ctx context.Context, client sarama.Client, group string) (GroupOffset, error) {
+	var groupOffset GroupOffset
+	var err error
+	if ctxErr := runContext(ctx, client, func() { groupOffset, err = FetchGroupOffsets(client, group) }); ctxErr != nil {
+		return GroupOffset{}, ctxErr
+	}
+	return groupOffset, err
+}
+
+// runContext runs the function against the client and waits for it to return. When the context is done first the
+// requests of the client are aborted and ctx.Err() is returned, the function returns in the background then, so it
+// must not touch anything the caller still uses.
+func runContext(ctx context.Context, client sarama.Client, run func()) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		run()
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		abortRequests(client, done)
+		return ctx.Err()
+	}
+}
+
+// abortRequests closes the connections of the client, once the aborted function is done the brokers are closed so
+// they reconnect when they are used again
+func abortRequests(client sarama.Client, done chan struct{}) {
+	dialer := abortingDialerOf(client.Config())
+	if dialer == nil {
+		return
+	}
+
+	dialer.abort()
+	go func() {
+		<-done
+		for _, broker := range client.Brokers() {
+			if connected, _ := broker.Connected(); connected {
+				broker.Close()
+			}
+		}
+		dialer.resume()
+	}()
+}
+
+// connectContext connects a client or consumer through the dialer, unless the context is done first. Connecting is
+// aborted then and a client or consumer which connects anyway is closed.
+func connectContext(ctx context.Context, dialer *abortingDialer, connect func() (io.Closer, error)) (io.Closer, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	type result struct {
+		connected io.Closer
+		err       error
+	}
+	results := make(chan result, 1)
+	go func() {
+		connected, err := connect()
+		results <- result{connected, err}
+	}()
+
+	select {
+	case r := <-results:
+		return r.connected, r.err
+	case <-ctx.Done():
+		if dialer != nil {
+			dialer.abort()
+		}
+		go func() {
+			if r := <-results; r.err == nil {
+				r.connected.Close()
+			}
+			if dialer != nil {
+				dialer.resume()
+			}
+		}()
+		return nil, ctx.Err()
+	}
+}
@@ -0,0 +1,191 @@
+package kafkatools
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+)
+
+func TestFetchTopicOffsetsContext(t *testing.T) {
+	broker := sarama.NewMockBroker(t, 1)
+	defer broker.Close()
+
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": sarama.NewMockMetadataResponse(t).
+			SetBroker(broker.Addr(), broker.BrokerID()).
+			SetLeader("foo", 0, broker.BrokerID()),
+		"OffsetRequest": sarama.NewMockOffsetResponse(t).
+			SetOffset("foo", 0, sarama.OffsetNewest, 12),
+	})
+
+	client, err := GetSaramaClient(broker.Addr())
+	if err != nil {
+		t.Fatal("Unexpected error: ", err)
+	}
+	defer client.Close()
+
+	offsets, err := FetchTopicOffsetsContext(context.Background(), client, sarama.OffsetNewest, "foo")
+	if err != nil {
+		t.Fatal("Unexpected error: ", err)
+	}
+	if offsets[0].Offset != 12 {
+		t.Errorf("Expected offset 12, got %v", offsets)
+	}
+
+	// A broker which doesn't answer in time
+	broker.SetLatency(time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := FetchTopicOffsetsContext(ctx, client, sarama.OffsetNewest, "foo"); err != context.DeadlineExceeded {
+		t.Errorf("Expected the deadline to be exceeded, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("Expected the fetch to be abandoned at the deadline, it took %v", elapsed)
+	}
+
+	// The aborted request is not left to finish within the latency, its connection is closed so the broker reconnects
+	// when it is used again
+	deadline := time.Now().Add(500 * time.Millisecond)
+	connected := true
+	for connected && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+		connected, _ = client.Brokers()[0].Connected()
+	}
+	if connected {
+		t.Error("Expected the connection of the aborted fetch to be closed")
+	}
+
+	cancelled, cancelNow := context.WithCancel(context.Background())
+	cancelNow()
+	if _, _, err := FetchOffsetsContext(cancelled, client, sarama.OffsetNewest); err != context.Canceled {
+		t.Errorf("Expected a cancelled context to fail right away, got %v", err)
+	}
+}
+
+func TestGetSaramaClientContext(t *testing.T) {
+	// A broker which accepts connections and never answers
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal("Unexpected error: ", err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := GetSaramaClientContext(ctx, listener.Addr().String()); err != context.DeadlineExceeded {
+		t.Errorf("Expected the deadline to be exceeded, got %v", err)
+	}
+}
+
+func TestAbortingDialer(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal("Unexpected error: ", err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	dialer := newAbortingDialer(&net.Dialer{})
+	conn, err := dialer.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatal("Unexpected error: ", err)
+	}
+	// A read which would block forever fails once the connections are aborted
+	read := make(chan error, 1)
+	go func() {
+		_, err := conn.Read(make([]byte, 1))
+		read <- err
+	}()
+	dialer.abort()
+	select {
+	case err := <-read:
+		if err == nil {
+			t.Error("Expected the read to fail")
+		}
+	case <-time.After(time.Second):
+		t.Error("Expected the read to be aborted")
+	}
+
+	if _, err := dialer.Dial("tcp", listener.Addr().String()); err != errAborted {
+		t.Errorf("Expected dialing to be refused while aborting, got %v", err)
+	}
+	dialer.resume()
+	conn, err = dialer.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatal("Expected dialing to work again after resume, got ", err)
+	}
+	conn.Close()
+	if len(dialer.conns) != 0 {
+		t.Errorf("Expected the closed connections to be forgotten, got %d", len(dialer.conns))
+	}
+}
+
+func TestContextVariants(t *testing.T) {
+	broker := sarama.NewMockBroker(t, 1)
+	defer broker.Close()
+
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": sarama.NewMockMetadataResponse(t).
+			SetBroker(broker.Addr(), broker.BrokerID()).
+			SetController(broker.BrokerID()).
+			SetLeader("foo", 0, broker.BrokerID()),
+		"OffsetRequest": sarama.NewMockOffsetResponse(t).
+			SetOffset("foo", 0, sarama.OffsetOldest, 0).
+			SetOffset("foo", 0, sarama.OffsetNewest, 1),
+		"FetchRequest": sarama.NewMockFetchResponse(t, 1).
+			SetMessage("foo", 0, 0, sarama.StringEncoder("hello")),
+	})
+
+	admin, err := GetClusterAdminContext(context.Background(), broker.Addr())
+	if err != nil {
+		t.Fatal("Unexpected error: ", err)
+	}
+	admin.Close()
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := GetClusterAdminContext(cancelled, broker.Addr()); err != context.Canceled {
+		t.Errorf("Expected a cancelled context to fail the admin, got %v", err)
+	}
+	if _, err := GetSaramaConsumerContext(cancelled, broker.Addr(), "group", []string{"foo"}); err != context.Canceled {
+		t.Errorf("Expected a cancelled context to fail the consumer, got %v", err)
+	}
+
+	client, err := GetSaramaClientContext(context.Background(), broker.Addr())
+	if err != nil {
+		t.Fatal("Unexpected error: ", err)
+	}
+	defer client.Close()
+
+	var out bytes.Buffer
+	ctx, cancelConsume := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancelConsume()
+	if err := ConsumeTopicToWriterContext(ctx, client, "foo", sarama.OffsetOldest, &out); err != context.DeadlineExceeded {
+		t.Errorf("Expected consuming to stop at the deadline, got %v", err)
+	}
+	if out.String() != "hello\n" {
+		t.Errorf("Expected the message to be written, got %q", out.String())
+	}
+}
@@ -0,0 +1,37 @@
+package kafkatools
+
+// PartitionLag is the lag of a consumer group on a partition
+type PartitionLag struct {
+	Group     string `json:"group"`
+	Topic     string `json:"topic"`
+	Partition int32  `json:"partition"`
+	// Current is the committed offset of the group, nil when the group did not commit an offset for the partition
+	Current *int64 `json:"current"`
+	End     int64  `json:"end"`
+	// Lag is the number of offsets between the committed offset and the end of the log, nil without a committed offset
+	Lag *int64 `json:"lag"`
+}
+
+// ComputeLags returns the lag of the groups on every partition they fetched offsets for, in the order of the group
+// offsets (see FetchOffsets)
+func ComputeLags(groupOffsets GroupOffsetSlice, topicOffsets map[string]map[int32]TopicPartitionOffset) (lags []PartitionLag) {
+	for _, groupOffset := range groupOffsets {
+		for _, topicOffset := range groupOffset.GroupTopicOffsets {
+			for _, partitionOffset := range topicOffset.TopicPartitionOffsets {
+				lag := PartitionLag{
+					Group:     groupOffset.Group,
+					Topic:     topicOffset.Topic,
+					Partition: partitionOffset.Partition,
+					End:       topicOffsets[topicOffset.Topic][partitionOffset.Partition].Offset,
+				}
+				// Partitions without a committed offset are returned with offset -1
+				if partitionOffset.Offset >= 0 {
+					current, behind := partitionOffset.Offset, lag.End-partitionOffset.Offset
+					lag.Current, lag.Lag = &current, &behind
+				}
+				lags = append(lags, lag)
+			}
+		}
+	}
+	return lags
+}
@@ -0,0 +1,33 @@
+package kafkatools
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestComputeLags(t *testing.T) {
+	groupOffsets := GroupOffsetSlice{{
+		Group: "group",
+		GroupTopicOffsets: GroupTopicOffsetSlice{{
+			Topic: "foo",
+			TopicPartitionOffsets: TopicPartitionOffsetSlice{
+				{Topic: "foo", Partition: 0, Offset: 40},
+				{Topic: "foo", Partition: 1, Offset: -1},
+			},
+		}},
+	}}
+	topicOffsets := map[string]map[int32]TopicPartitionOffset{
+		"foo": {0: {Topic: "foo", Partition: 0, Offset: 42}, 1: {Topic: "foo", Partition: 1, Offset: 7}},
+	}
+
+	data, err := json.Marshal(ComputeLags(groupOffsets, topicOffsets))
+	if err != nil {
+		t.Fatal("Unexpected error: ", err)
+	}
+
+	expected := `[{"group":"group","topic":"foo","partition":0,"current":40,"end":42,"lag":2},` +
+		`{"group":"group","topic":"foo","partition":1,"current":null,"end":7,"lag":null}]`
+	if string(data) != expected {
+		t.Errorf("Expected %s, got %s", expected, data)
+	}
+}
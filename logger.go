@@ -0,0 +1,70 @@
+package kafkatools
+
+import (
+	"fmt"
+	"log"
+	"sync/atomic"
+)
+
+// LogLevel is the severity of a log message
+type LogLevel int32
+
+// The log levels, from the least to the most severe. LogSilent discards all messages.
+const (
+	LogInfo LogLevel = iota
+	LogWarning
+	LogError
+	LogSilent
+)
+
+// Logger writes the messages of at least its level to a standard logger, it is safe for concurrent use
+type Logger struct {
+	level int32
+	// out is the logger the messages are written to, the standard logger of the log package when nil
+	out *log.Logger
+}
+
+// Log is the logger of the library and kt, it logs all messages to the standard logger by default
+var Log = &Logger{}
+
+// NewLogger returns a logger which writes the messages of at least the level to out
+func NewLogger(out *log.Logger, level LogLevel) *Logger {
+	return &Logger{level: int32(level), out: out}
+}
+
+// SetLevel sets the least severe level of the messages which are logged
+func (l *Logger) SetLevel(level LogLevel) {
+	atomic.StoreInt32(&l.level, int32(level))
+}
+
+// Enabled returns whether messages of the level are logged
+func (l *Logger) Enabled(level LogLevel) bool {
+	return level < LogSilent && int32(level) >= atomic.LoadInt32(&l.level)
+}
+
+// Infof logs an informational message, like the progress of a command
+func (l *Logger) Infof(format string, v ...interface{}) {
+	l.output(LogInfo, fmt.Sprintf(format, v...))
+}
+
+// Warnf logs a warning
+func (l *Logger) Warnf(format string, v ...interface{}) {
+	l.output(LogWarning, fmt.Sprintf(format, v...))
+}
+
+// Errorf logs an error which does not stop the command
+func (l *Logger) Errorf(format string, v ...interface{}) {
+	l.output(LogError, fmt.Sprintf(format, v...))
+}
+
+func (l *Logger) output(level LogLevel, message string) {
+	if !l.Enabled(level) {
+		return
+	}
+	// The call depth skips output and the exported method so the log flags report the caller
+	if l.out != nil {
+		l.out.Output(3, message)
+	} else {
+		log.Output(3, message)
+	}
+}
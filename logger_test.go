@@ -0,0 +1,39 @@
+package kafkatools
+
+import (
+	"bytes"
+	"log"
+	"testing"
+)
+
+func TestLogger(t *testing.T) {
+	var out bytes.Buffer
+	logger := NewLogger(log.New(&out, "", 0), LogInfo)
+
+	logger.Infof("Fetching offsets of %s", "foo")
+	logger.Warnf("WARNING: %d partitions are unavailable", 2)
+	logger.Errorf("error: %v", "broken")
+	expected := "Fetching offsets of foo\nWARNING: 2 partitions are unavailable\nerror: broken\n"
+	if out.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, out.String())
+	}
+
+	out.Reset()
+	logger.SetLevel(LogWarning)
+	logger.Infof("Connection closed. Bye.")
+	logger.Warnf("a warning")
+	logger.Errorf("an error")
+	if expected := "a warning\nan error\n"; out.String() != expected {
+		t.Errorf("Expected only the warnings and errors, got %q", out.String())
+	}
+
+	out.Reset()
+	logger.SetLevel(LogSilent)
+	logger.Errorf("an error")
+	if out.Len() != 0 {
+		t.Errorf("Expected a silent logger to discard the errors, got %q", out.String())
+	}
+	if logger.Enabled(LogError) || logger.Enabled(LogSilent) {
+		t.Error("Expected no level to be enabled on a silent logger")
+	}
+}
@@ -0,0 +1,51 @@
+package kafkatools
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// MarshalOffsets encodes the offsets per topic and partition as a JSON array, sorted by topic and partition
+func MarshalOffsets(offsets map[string]map[int32]TopicPartitionOffset) ([]byte, error) {
+	list := make([]TopicPartitionOffset, 0)
+	for _, partitionOffsets := range offsets {
+		for _, offset := range partitionOffsets {
+			list = append(list, offset)
+		}
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Topic != list[j].Topic {
+			return list[i].Topic < list[j].Topic
+		}
+		return list[i].Partition < list[j].Partition
+	})
+
+	return json.MarshalIndent(list, "", "  ")
+}
+
+// UnmarshalOffsets decodes offsets encoded by MarshalOffsets into a map per topic and partition
+func UnmarshalOffsets(data []byte) (map[string]map[int32]TopicPartitionOffset, error) {
+	var list []TopicPartitionOffset
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("could not decode offsets: %v", err)
+	}
+
+	offsets := make(map[string]map[int32]TopicPartitionOffset)
+	for _, offset := range list {
+		if offset.Topic == "" || offset.Partition < 0 {
+			return nil, fmt.Errorf("invalid offset %+v", offset)
+		}
+
+		if _, ok := offsets[offset.Topic]; !ok {
+			offsets[offset.Topic] = make(map[int32]TopicPartitionOffset)
+		}
+		if _, duplicate := offsets[offset.Topic][offset.Partition]; duplicate {
+			return nil, fmt.Errorf("duplicate offset for partition %d of topic %s", offset.Partition, offset.Topic)
+		}
+		offsets[offset.Topic][offset.Partition] = offset
+	}
+
+	return offsets, nil
+}
@@ -0,0 +1,60 @@
+package kafkatools
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMarshalOffsets(t *testing.T) {
+	offsets := map[string]map[int32]TopicPartitionOffset{
+		"foo": {1: {Topic: "foo", Partition: 1, Offset: 42}, 0: {Topic: "foo", Partition: 0, Offset: 7}},
+		"bar": {0: {Topic: "bar", Partition: 0, Offset: -1}},
+	}
+
+	data, err := MarshalOffsets(offsets)
+	if err != nil {
+		t.Fatal("Unexpected error: ", err)
+	}
+
+	expected := `[
+  {
+    "topic": "bar",
+    "partition": 0,
+    "offset": -1
+  },
+  {
+    "topic": "foo",
+    "partition": 0,
+    "offset": 7
+  },
+  {
+    "topic": "foo",
+    "partition": 1,
+    "offset": 42
+  }
+]`
+	if string(data) != expected {
+		t.Errorf("Expected %s, got %s", expected, data)
+	}
+
+	decoded, err := UnmarshalOffsets(data)
+	if err != nil {
+		t.Fatal("Unexpected error: ", err)
+	}
+	if !reflect.DeepEqual(decoded, offsets) {
+		t.Errorf("Expected %+v, got %+v", offsets, decoded)
+	}
+}
+
+func TestUnmarshalOffsetsErrors(t *testing.T) {
+	for _, data := range []string{
+		`{"topic": "foo"}`,
+		`[{"partition": 0, "offset": 1}]`,
+		`[{"topic": "foo", "partition": -1, "offset": 1}]`,
+		`[{"topic": "foo", "partition": 0, "offset": 1}, {"topic": "foo", "partition": 0, "offset": 2}]`,
+	} {
+		if _, err := UnmarshalOffsets([]byte(data)); err == nil {
+			t.Errorf("Expected an error for %s", data)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package kafkatools
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// schemaRegistryTimeout bounds every request to the schema registry
+const schemaRegistryTimeout = 10 * time.Second
+
+// schemaRetryBackoff is how long a schema which could not be fetched is not requested again
+const schemaRetryBackoff = 30 * time.Second
+
+// ErrNotSchemaRegistryFormat is returned by SchemaRegistry.Decode for data which does not start with the magic byte and
+// schema id of the schema registry wire format
+var ErrNotSchemaRegistryFormat = errors.New("not in the schema registry wire format")
+
+// SchemaRegistry looks up the Avro schemas of the messages in a Confluent schema registry, the schemas are cached by
+// id. A schema which could not be fetched is requested again after a backoff. It is safe for concurrent use, a schema
+// is fetched once for the concurrent lookups of its id while the lookups of the cached schemas keep going.
+type SchemaRegistry struct {
+	url    string
+	client *http.Client
+	// retryAfter is the backoff of the failed fetches
+	retryAfter time.Duration
+
+	mutex    sync.Mutex
+	schemas  map[int32]*avroSchema
+	failures map[int32]schemaFailure
+	fetches  map[int32]*schemaFetch
+}
+
+// schemaFailure is a fetch which failed, retryAt is when the schema may be requested again
+type schemaFailure struct {
+	err     error
+	retryAt time.Time
+}
+
+// schemaFetch is a fetch in progress, done is closed once the schema or the error is set
+type schemaFetch struct {
+	done   chan struct{}
+	schema *avroSchema
+	err    error
+}
+
+// NewSchemaRegistry returns the client of the schema registry at the url, e.g. http://localhost:8081
+func NewSchemaRegistry(url string) *SchemaRegistry {
+	return &SchemaRegistry{
+		url:        strings.TrimSuffix(url, "/"),
+		client:     &http.Client{Timeout: schemaRegistryTimeout},
+		retryAfter: schemaRetryBackoff,
+		schemas:    make(map[int32]*avroSchema),
+		failures:   make(map[int32]schemaFailure),
+		fetches:    make(map[int32]*schemaFetch),
+	}
+}
+
+// schema returns the schema with the id, fetching it from the registry the first time. During the backoff of a failed
+// fetch its error is returned.
+func (r *SchemaRegistry) schema(id int32) (*avroSchema, error) {
+	r.mutex.Lock()
+	if schema, ok := r.schemas[id]; ok {
+		r.mutex.Unlock()
+		return schema, nil
+	}
+	if failure, ok := r.failures[id]; ok && time.Now().Before(failure.retryAt) {
+		r.mutex.Unlock()
+		return nil, failure.err
+	}
+	if fetch, ok := r.fetches[id]; ok {
+		r.mutex.Unlock()
+		<-fetch.done
+		return fetch.schema, fetch.err
+	}
+
+	fetch := &schemaFetch{done: make(chan struct{})}
+	r.fetches[id] = fetch
+	r.mutex.Unlock()
+
+	fetch.schema, fetch.err = r.fetchSchema(id)
+
+	r.mutex.Lock()
+	delete(r.fetches, id)
+	if fetch.err != nil {
+		r.failures[id] = schemaFailure{err: fetch.err, retryAt: time.Now().Add(r.retryAfter)}
+	} else {
+		delete(r.failures, id)
+		r.schemas[id] = fetch.schema
+	}
+	r.mutex.Unlock()
+	close(fetch.done)
+	return fetch.schema, fetch.err
+}
+
+func (r *SchemaRegistry) fetchSchema(id int32) (*avroSchema, error) {
+	response, err := r.client.Get(fmt.Sprintf("%s/schemas/ids/%d", r.url, id))
+	if err != nil {
+		return nil, fmt.Errorf("could not fetch schema %d: %v", id, err)
+	}
+	defer response.Body.Close()
+
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		return nil, fmt.Errorf("could not fetch schema %d: %v", id, err)
+	}
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("could not fetch schema %d: %s: %s", id, response.Status, strings.TrimSpace(string(body)))
+	}
+
+	var registered struct {
+		Schema     string `json:"schema"`
+		SchemaType string `json:"schemaType"`
+	}
+	if err := json.Unmarshal(body, &registered); err != nil {
+		return nil, fmt.Errorf("invalid response for schema %d: %v", id, err)
+	}
+	// The schema type is only set for other formats than Avro
+	if registered.SchemaType != "" && registered.SchemaType != "AVRO" {
+		return nil, fmt.Errorf("schema %d is a %s schema, only avro is supported", id, registered.SchemaType)
+	}
+	return parseAvroSchema([]byte(registered.Schema))
+}
+
+// Decode renders data in the Confluent wire format as JSON: a zero magic byte and the 4-byte big-endian id of the
+// schema, followed by the Avro encoding
+func (r *SchemaRegistry) Decode(data []byte) ([]byte, error) {
+	if len(data) < 5 || data[0] != 0 {
+		return nil, ErrNotSchemaRegistryFormat
+	}
+
+	schema, err := r.schema(int32(binary.BigEndian.Uint32(data[1:5])))
+	if err != nil {
+		return nil, err
+	}
+	return decodeAvro(schema, data[5:])
+}
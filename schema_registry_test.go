@@ -0,0 +1,94 @@
+package kafkatools
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSchemaRegistryDecode(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		switch r.URL.Path {
+		case "/schemas/ids/3":
+			w.Write([]byte(`{"schema": "{\"type\": \"record\", \"name\": \"Key\", \"fields\": [{\"name\": \"id\", \"type\": \"string\"}]}"}`))
+		case "/schemas/ids/4":
+			w.Write([]byte(`{"schemaType": "PROTOBUF", "schema": "message Key {}"}`))
+		default:
+			http.Error(w, `{"error_code": 40403, "message": "Schema not found"}`, http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	registry := NewSchemaRegistry(server.URL + "/")
+
+	framed := func(id byte, data ...byte) []byte {
+		return append([]byte{0, 0, 0, 0, id}, data...)
+	}
+
+	// The partitions decode concurrently
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			decoded, err := registry.Decode(framed(3, avroString("c-1")...))
+			if err != nil || string(decoded) != `{"id":"c-1"}` {
+				t.Errorf("Expected the decoded record, got %s (%v)", decoded, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	for _, data := range [][]byte{framed(4, 0), framed(5, 0), framed(5, 0)} {
+		if _, err := registry.Decode(data); err == nil {
+			t.Errorf("Expected an error decoding %v", data)
+		}
+	}
+	if _, err := registry.Decode([]byte("plain")); !errors.Is(err, ErrNotSchemaRegistryFormat) {
+		t.Errorf("Expected ErrNotSchemaRegistryFormat, got %v", err)
+	}
+
+	// Every schema is only requested once, concurrently too, and a failed one not again during its backoff
+	if requests := atomic.LoadInt32(&requests); requests != 3 {
+		t.Errorf("Expected 3 requests to the registry, got %d", requests)
+	}
+}
+
+func TestSchemaRegistryRetriesFailedFetches(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The first request fails like a registry which is briefly unavailable
+		if atomic.AddInt32(&requests, 1) == 1 {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"schema": "\"string\""}`))
+	}))
+	defer server.Close()
+	registry := NewSchemaRegistry(server.URL)
+
+	data := append([]byte{0, 0, 0, 0, 1}, avroString("v")...)
+	if _, err := registry.Decode(data); err == nil {
+		t.Fatal("Expected the first fetch to fail")
+	}
+	// The failure is returned during the backoff without requesting the schema again
+	if _, err := registry.Decode(data); err == nil || atomic.LoadInt32(&requests) != 1 {
+		t.Fatalf("Expected the failure during the backoff, got %v after %d requests", err, requests)
+	}
+
+	// The backoff of the failure passes
+	registry.mutex.Lock()
+	registry.failures[1] = schemaFailure{err: registry.failures[1].err, retryAt: time.Now()}
+	registry.mutex.Unlock()
+	if decoded, err := registry.Decode(data); err != nil || string(decoded) != `"v"` {
+		t.Errorf("Expected the schema to be fetched again after the backoff, got %s (%v)", decoded, err)
+	}
+	if requests := atomic.LoadInt32(&requests); requests != 2 {
+		t.Errorf("Expected 2 requests to the registry, got %d", requests)
+	}
+}
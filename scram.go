@@ -0,0 +1,176 @@
+package kafkatools
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"strconv"
+	"strings"
+
+	"github.com/Shopify/sarama"
+)
+
+// scramHashes are the hash functions of the SCRAM mechanisms supported by kafka
+var scramHashes = map[sarama.SASLMechanism]func() hash.Hash{
+	sarama.SASLTypeSCRAMSHA256: sha256.New,
+	sarama.SASLTypeSCRAMSHA512: sha512.New,
+}
+
+// scramClient is the client side of a SCRAM authentication (RFC 5802) without channel binding, which is all kafka
+// supports
+type scramClient struct {
+	hash func() hash.Hash
+	// newNonce returns the client nonce, a random one when nil
+	newNonce func() (string, error)
+
+	user, password, authzID string
+	clientNonce             string
+	clientFirstBare         string
+	serverSignature         []byte
+	step                    int
+	done                    bool
+}
+
+// newSCRAMClientGenerator returns the generator of the SCRAM clients of the mechanism for sarama
+func newSCRAMClientGenerator(mechanism sarama.SASLMechanism) func() sarama.SCRAMClient {
+	return func() sarama.SCRAMClient {
+		return &scramClient{hash: scramHashes[mechanism]}
+	}
+}
+
+func (c *scramClient) Begin(user, password, authzID string) error {
+	newNonce := c.newNonce
+	if newNonce == nil {
+		newNonce = randomSCRAMNonce
+	}
+	nonce, err := newNonce()
+	if err != nil {
+		return err
+	}
+
+	c.user, c.password, c.authzID, c.clientNonce = user, password, authzID, nonce
+	c.step, c.done = 0, false
+	return nil
+}
+
+func randomSCRAMNonce() (string, error) {
+	nonce := make([]byte, 24)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	return base64.RawStdEncoding.EncodeToString(nonce), nil
+}
+
+// gs2Header is the header of the client messages, it tells channel binding is not supported
+func (c *scramClient) gs2Header() string {
+	if c.authzID == "" {
+		return "n,,"
+	}
+	return "n,a=" + escapeSCRAMName(c.authzID) + ","
+}
+
+func (c *scramClient) Step(challenge string) (string, error) {
+	c.step++
+	switch c.step {
+	case 1:
+		c.clientFirstBare = "n=" + escapeSCRAMName(c.user) + ",r=" + c.clientNonce
+		return c.gs2Header() + c.clientFirstBare, nil
+	case 2:
+		return c.clientFinal(challenge)
+	case 3:
+		attributes := parseSCRAMAttributes(challenge)
+		if message, ok := attributes["e"]; ok {
+			return "", fmt.Errorf("scram authentication failed: %s", message)
+		}
+		signature, err := base64.StdEncoding.DecodeString(attributes["v"])
+		if err != nil || !hmac.Equal(signature, c.serverSignature) {
+			return "", fmt.Errorf("invalid scram server signature")
+		}
+		c.done = true
+		return "", nil
+	default:
+		return "", fmt.Errorf("unexpected scram challenge %q", challenge)
+	}
+}
+
+// clientFinal proves the password to the server in response to the server-first message
+func (c *scramClient) clientFinal(serverFirst string) (string, error) {
+	attributes := parseSCRAMAttributes(serverFirst)
+	nonce := attributes["r"]
+	if !strings.HasPrefix(nonce, c.clientNonce) || len(nonce) == len(c.clientNonce) {
+		return "", fmt.Errorf("invalid scram server nonce")
+	}
+	salt, err := base64.StdEncoding.DecodeString(attributes["s"])
+	if err != nil {
+		return "", fmt.Errorf("invalid scram salt: %v", err)
+	}
+	iterations, err := strconv.Atoi(attributes["i"])
+	if err != nil || iterations < 1 {
+		return "", fmt.Errorf("invalid scram iteration count %q", attributes["i"])
+	}
+
+	saltedPassword := pbkdf2(c.hash, []byte(c.password), salt, iterations)
+	clientKey := c.hmac(saltedPassword, "Client Key")
+	storedKey := c.hash()
+	storedKey.Write(clientKey)
+
+	clientFinal := "c=" + base64.StdEncoding.EncodeToString([]byte(c.gs2Header())) + ",r=" + nonce
+	authMessage := c.clientFirstBare + "," + serverFirst + "," + clientFinal
+
+	proof := c.hmac(storedKey.Sum(nil), authMessage)
+	for i := range proof {
+		proof[i] ^= clientKey[i]
+	}
+	c.serverSignature = c.hmac(c.hmac(saltedPassword, "Server Key"), authMessage)
+	return clientFinal + ",p=" + base64.StdEncoding.EncodeToString(proof), nil
+}
+
+func (c *scramClient) Done() bool {
+	return c.done
+}
+
+func (c *scramClient) hmac(key []byte, message string) []byte {
+	mac := hmac.New(c.hash, key)
+	mac.Write([]byte(message))
+	return mac.Sum(nil)
+}
+
+// pbkdf2 derives a key of the size of the hash from the password (RFC 8018)
+func pbkdf2(hash func() hash.Hash, password, salt []byte, iterations int) []byte {
+	mac := hmac.New(hash, password)
+	mac.Write(salt)
+	mac.Write(binary.BigEndian.AppendUint32(nil, 1))
+	block := mac.Sum(nil)
+
+	key := append([]byte(nil), block...)
+	for i := 1; i < iterations; i++ {
+		mac.Reset()
+		mac.Write(block)
+		block = mac.Sum(block[:0])
+		for j := range key {
+			key[j] ^= block[j]
+		}
+	}
+	return key
+}
+
+// parseSCRAMAttributes parses the comma separated name=value attributes of a server message
+func parseSCRAMAttributes(message string) map[string]string {
+	attributes := make(map[string]string)
+	for _, attribute := range strings.Split(message, ",") {
+		if name, value, found := strings.Cut(attribute, "="); found {
+			attributes[name] = value
+		}
+	}
+	return attributes
+}
+
+// escapeSCRAMName escapes the , and = of a user name, which separate the attributes
+func escapeSCRAMName(name string) string {
+	return strings.NewReplacer("=", "=3D", ",", "=2C").Replace(name)
+}
@@ -0,0 +1,66 @@
+package kafkatools
+
+import (
+	"crypto/sha256"
+	"testing"
+)
+
+// The SCRAM-SHA-256 exchange of RFC 7677
+func TestSCRAMClient(t *testing.T) {
+	client := &scramClient{hash: sha256.New, newNonce: func() (string, error) { return "rOprNGfwEbeRWgbNEkqO", nil }}
+	if err := client.Begin("user", "pencil", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, step := range []struct{ challenge, expected string }{
+		{"", "n,,n=user,r=rOprNGfwEbeRWgbNEkqO"},
+		{"r=rOprNGfwEbeRWgbNEkqO%hvYDpWUa2RaTCAfuxFIlj)hNlF$k0,s=W22ZaJ0SNY7soEsUEjb6gQ==,i=4096",
+			"c=biws,r=rOprNGfwEbeRWgbNEkqO%hvYDpWUa2RaTCAfuxFIlj)hNlF$k0,p=dHzbZapWIk4jUhN+Ute9ytag9zjfMHgsqmmiz7AndVQ="},
+		{"v=6rriTRBi23WpRR/wtup+mMhUZUn/dB5nLTJRsjl95G4=", ""},
+	} {
+		if client.Done() {
+			t.Fatal("Expected the exchange to continue")
+		}
+		response, err := client.Step(step.challenge)
+		if err != nil {
+			t.Fatalf("Unexpected error for %q: %v", step.challenge, err)
+		}
+		if response != step.expected {
+			t.Errorf("Expected %q, got %q", step.expected, response)
+		}
+	}
+	if !client.Done() {
+		t.Error("Expected the exchange to be done")
+	}
+}
+
+func TestSCRAMClientRejectsServer(t *testing.T) {
+	for _, challenges := range [][]string{
+		// nonce not starting with the client nonce
+		{"", "r=other,s=W22ZaJ0SNY7soEsUEjb6gQ==,i=4096"},
+		{"", "r=nonceserver,s=W22ZaJ0SNY7soEsUEjb6gQ==,i=x"},
+		{"", "r=nonceserver,s=W22ZaJ0SNY7soEsUEjb6gQ==,i=4096", "v=c2lnbmF0dXJl"},
+		{"", "r=nonceserver,s=W22ZaJ0SNY7soEsUEjb6gQ==,i=4096", "e=invalid-proof"},
+	} {
+		client := &scramClient{hash: sha256.New, newNonce: func() (string, error) { return "nonce", nil }}
+		if err := client.Begin("user", "pencil", ""); err != nil {
+			t.Fatal(err)
+		}
+
+		var err error
+		for _, challenge := range challenges {
+			if _, err = client.Step(challenge); err != nil {
+				break
+			}
+		}
+		if err == nil || client.Done() {
+			t.Errorf("Expected the exchange %v to fail", challenges)
+		}
+	}
+}
+
+func TestEscapeSCRAMName(t *testing.T) {
+	if escaped := escapeSCRAMName("a=b,c"); escaped != "a=3Db=2Cc" {
+		t.Errorf("Expected a=3Db=2Cc, got %s", escaped)
+	}
+}
@@ -1,73 +1,65 @@
 package kafkatools
 
 import (
-	"log"
+	"errors"
+	"fmt"
 	"sort"
 	"strings"
 	"sync"
-	"time"
 
 	"github.com/Shopify/sarama"
 	"github.com/bsm/sarama-cluster"
 )
 
-// GetSaramaClient sets up a kafka client
-func GetSaramaClient(brokers ...string) sarama.Client {
-	config := sarama.NewConfig()
-	config.Version = sarama.V0_10_1_0
-	config.Consumer.Return.Errors = true
-	config.Metadata.RefreshFrequency = 1 * time.Minute
-	config.Metadata.Retry.Max = 10
-	config.Net.MaxOpenRequests = 10
+// ErrGroupNotFound is returned by FetchGroupOffsets for groups which do not exist
+var ErrGroupNotFound = errors.New("group not found")
 
-	client, err := sarama.NewClient(brokers, config)
+// GetSaramaClient sets up a kafka client
+func GetSaramaClient(brokers ...string) (sarama.Client, error) {
+	return GetSaramaClientWithConfig(nil, brokers...)
+}
 
+// GetSaramaConsumer returns a high-level kafka consumer
+func GetSaramaConsumer(brokers string, consumerGroup string, topics []string) (*cluster.Consumer, error) {
+	consumer, err := cluster.NewConsumer(strings.Split(brokers, ","), consumerGroup, topics, newConsumerConfig())
 	if err != nil {
-		log.Fatal("Failed to start client: ", err)
+		return nil, fmt.Errorf("failed to start consumer: %v", err)
 	}
 
-	return client
+	return consumer, nil
 }
 
-// GetSaramaConsumer returns a high-level kafka consumer
-func GetSaramaConsumer(brokers string, consumerGroup string, topics []string) *cluster.Consumer {
-	// Init config
+// newConsumerConfig returns the config of the consumers of GetSaramaConsumer
+func newConsumerConfig() *cluster.Config {
 	config := cluster.NewConfig()
 	config.Consumer.Return.Errors = true
 	config.Group.Return.Notifications = true
 
 	config.Consumer.Offsets.Initial = sarama.OffsetNewest
-	config.Version = sarama.V0_10_1_0
-
-	consumer, err := cluster.NewConsumer(strings.Split(brokers, ","), consumerGroup, topics, config)
-	if err != nil {
-		log.Fatalf("Failed to start consumer: %s", err)
-	}
-
-	return consumer
+	config.Version = sarama.V2_1_0_0
+	return config
 }
 
 // GenerateOffsetRequests generates the offset requests which can be used in the GetBrokerTopicOffsets function
-func GenerateOffsetRequests(client sarama.Client, time int64, topics ...string) (requests map[*sarama.Broker]*sarama.OffsetRequest) {
-	var err error
+func GenerateOffsetRequests(client sarama.Client, time int64, topics ...string) (requests map[*sarama.Broker]*sarama.OffsetRequest, err error) {
 	requests = make(map[*sarama.Broker]*sarama.OffsetRequest)
 
 	if len(topics) == 0 {
 		topics, err = client.Topics()
 		if err != nil {
-			log.Fatal("Failed to fetch topics: ", err)
+			return nil, fmt.Errorf("failed to fetch topics: %v", err)
 		}
 	}
 
 	for _, topic := range topics {
 		partitions, err := client.Partitions(topic)
 		if err != nil {
-			log.Fatal("Failed to fetch partitions: ", err)
+			return nil, fmt.Errorf("failed to fetch partitions of topic %s: %v", topic, err)
 		}
 		for _, partition := range partitions {
 			broker, err := client.Leader(topic, partition)
 			if err != nil {
-				log.Fatalf("Cannot fetch leader for partition %d of topic %s", partition, topic)
+				return nil, fmt.Errorf("cannot fetch leader for partition %d of topic %s: %v", partition, topic, err)
 			}
 
 			if _, ok := requests[broker]; !ok {
@@ -78,19 +70,19 @@ func GenerateOffsetRequests(client sarama.Client, time int64, topics ...string)
 		}
 	}
 
-	return requests
+	return requests, nil
 }
 
 // GetBrokerTopicOffsets fetches the offsets for all topics from a specific groker and sends them to the offset topic
-func GetBrokerTopicOffsets(broker *sarama.Broker, request *sarama.OffsetRequest, offsets chan TopicPartitionOffset) {
+func GetBrokerTopicOffsets(broker *sarama.Broker, request *sarama.OffsetRequest, offsets chan TopicPartitionOffset) error {
 	response, err := broker.GetAvailableOffsets(request)
 	if err != nil {
-		log.Fatalf("Cannot fetch offsets from broker %d: %v", broker.ID(), err)
+		return fmt.Errorf("cannot fetch offsets from broker %d: %v", broker.ID(), err)
 	}
 	for topic, partitions := range response.Blocks {
 		for partition, offsetResponse := range partitions {
 			if offsetResponse.Err != sarama.ErrNoError {
-				log.Printf("Error in OffsetResponse for topic %s:%d from broker %d: %s", topic, partition, broker.ID(), offsetResponse.Err.Error())
+				Log.Warnf("Error in OffsetResponse for topic %s:%d from broker %d: %s", topic, partition, broker.ID(), offsetResponse.Err.Error())
 				continue
 			}
 			if len(offsetResponse.Offsets) == 1 {
@@ -102,30 +94,85 @@ func GetBrokerTopicOffsets(broker *sarama.Broker, request *sarama.OffsetRequest,
 			}
 		}
 	}
+	return nil
 }
 
 // FetchTopicOffsets fetches topic offsets
-func FetchTopicOffsets(client sarama.Client, offset int64, topic string) (topicOffsets map[int32]TopicPartitionOffset) {
-	requests := GenerateOffsetRequests(client, offset, topic)
+func FetchTopicOffsets(client sarama.Client, offset int64, topic string) (map[int32]TopicPartitionOffset, error) {
+	topicsOffsets, err := FetchTopicsOffsets(client, offset, topic)
+	if err != nil {
+		return nil, err
+	}
+	topicOffsets := topicsOffsets[topic]
+	if topicOffsets == nil {
+		topicOffsets = make(map[int32]TopicPartitionOffset)
+	}
+
+	return topicOffsets, nil
+}
+
+// FetchTopicOffsetsForTime fetches the offsets of the first messages of the partitions of the topic with a timestamp
+// at or after the time in milliseconds. The partitions without a message that recent get their newest offset.
+func FetchTopicOffsetsForTime(client sarama.Client, topic string, timeMillis int64) (map[int32]TopicPartitionOffset, error) {
+	if timeMillis < 0 {
+		return nil, fmt.Errorf("invalid time %d, expected milliseconds since the epoch", timeMillis)
+	}
+
+	found, err := FetchTopicOffsets(client, timeMillis, topic)
+	if err != nil {
+		return nil, err
+	}
+	newest, err := FetchTopicOffsets(client, sarama.OffsetNewest, topic)
+	if err != nil {
+		return nil, err
+	}
+	return resolveTimestampOffsets(found, newest), nil
+}
+
+// resolveTimestampOffsets replaces the -1 the brokers return for the partitions without a message at or after the
+// timestamp by their newest offset
+func resolveTimestampOffsets(found, newest map[int32]TopicPartitionOffset) map[int32]TopicPartitionOffset {
+	resolved := make(map[int32]TopicPartitionOffset, len(found))
+	for partition, offset := range found {
+		if offset.Offset < 0 {
+			if newestOffset, ok := newest[partition]; ok {
+				offset.Offset = newestOffset.Offset
+			}
+		}
+		resolved[partition] = offset
+	}
+	return resolved
+}
+
+// FetchTopicsOffsets fetches the offsets of multiple topics (or all topics when none are given), batching the requests per broker
+func FetchTopicsOffsets(client sarama.Client, offset int64, topics ...string) (topicOffsets map[string]map[int32]TopicPartitionOffset, err error) {
+	requests, err := GenerateOffsetRequests(client, offset, topics...)
+	if err != nil {
+		return nil, err
+	}
 
 	var wg, wg2 sync.WaitGroup
+	var errs firstError
 	topicOffsetChannel := make(chan TopicPartitionOffset, 20)
 	wg.Add(len(requests))
 	for broker, request := range requests {
 		// Fetch topic offsets (log end)
 		go func(broker *sarama.Broker, request *sarama.OffsetRequest) {
 			defer wg.Done()
-			GetBrokerTopicOffsets(broker, request, topicOffsetChannel)
+			errs.set(GetBrokerTopicOffsets(broker, request, topicOffsetChannel))
 		}(broker, request)
 	}
 
 	// Setup lookup table for topic offsets
-	topicOffsets = make(map[int32]TopicPartitionOffset)
+	topicOffsets = make(map[string]map[int32]TopicPartitionOffset)
+	wg2.Add(1)
 	go func() {
 		defer wg2.Done()
-		wg2.Add(1)
 		for topicOffset := range topicOffsetChannel {
-			topicOffsets[topicOffset.Partition] = topicOffset
+			if _, ok := topicOffsets[topicOffset.Topic]; !ok {
+				topicOffsets[topicOffset.Topic] = make(map[int32]TopicPartitionOffset)
+			}
+			topicOffsets[topicOffset.Topic][topicOffset.Partition] = topicOffset
 		}
 	}()
 
@@ -133,14 +180,21 @@ func FetchTopicOffsets(client sarama.Client, offset int64, topic string) (topicO
 	close(topicOffsetChannel)
 	wg2.Wait()
 
-	return topicOffsets
+	if errs.err != nil {
+		return nil, errs.err
+	}
+	return topicOffsets, nil
 }
 
 // FetchOffsets fetches group and topic offsets (where the topic offset can be sarama.OffsetNewest/OffsetOldest or the time in milliseconds)
-func FetchOffsets(client sarama.Client, offset int64) (groupOffsets GroupOffsetSlice, topicOffsets map[string]map[int32]TopicPartitionOffset) {
-	requests := GenerateOffsetRequests(client, offset)
+func FetchOffsets(client sarama.Client, offset int64) (groupOffsets GroupOffsetSlice, topicOffsets map[string]map[int32]TopicPartitionOffset, err error) {
+	requests, err := GenerateOffsetRequests(client, offset)
+	if err != nil {
+		return nil, nil, err
+	}
 
 	var wg, wg2 sync.WaitGroup
+	var errs firstError
 	topicOffsetChannel := make(chan TopicPartitionOffset, 20)
 	groupOffsetChannel := make(chan GroupOffset, 10)
 
@@ -149,13 +203,13 @@ func FetchOffsets(client sarama.Client, offset int64) (groupOffsets GroupOffsetS
 		// Fetch topic offsets (log end)
 		go func(broker *sarama.Broker, request *sarama.OffsetRequest) {
 			defer wg.Done()
-			GetBrokerTopicOffsets(broker, request, topicOffsetChannel)
+			errs.set(GetBrokerTopicOffsets(broker, request, topicOffsetChannel))
 		}(broker, request)
 
 		// Fetch group offsets
 		go func(broker *sarama.Broker) {
 			defer wg.Done()
-			GetBrokerGroupOffsets(broker, groupOffsetChannel)
+			errs.set(GetBrokerGroupOffsets(broker, groupOffsetChannel))
 		}(broker)
 	}
 
@@ -187,14 +241,17 @@ func FetchOffsets(client sarama.Client, offset int64) (groupOffsets GroupOffsetS
 	close(groupOffsetChannel)
 	wg2.Wait()
 
-	return
+	if errs.err != nil {
+		return nil, nil, errs.err
+	}
+	return groupOffsets, topicOffsets, nil
 }
 
 // GetBrokerGroupOffsets fetches all group offsets for a specific broker
-func GetBrokerGroupOffsets(broker *sarama.Broker, groupOffsetChannel chan GroupOffset) {
+func GetBrokerGroupOffsets(broker *sarama.Broker, groupOffsetChannel chan GroupOffset) error {
 	groupsResponse, err := broker.ListGroups(&sarama.ListGroupsRequest{})
 	if err != nil {
-		log.Fatal("Failed to list groups: ", err)
+		return fmt.Errorf("failed to list groups: %v", err)
 	}
 	var groups []string
 	for group := range groupsResponse.Groups {
@@ -202,44 +259,98 @@ func GetBrokerGroupOffsets(broker *sarama.Broker, groupOffsetChannel chan GroupO
 	}
 	groupsDesc, err := broker.DescribeGroups(&sarama.DescribeGroupsRequest{Groups: groups})
 	if err != nil {
-		log.Fatal("Failed to describe groups: ", err)
+		return fmt.Errorf("failed to describe groups: %v", err)
 	}
 
 	var wg sync.WaitGroup
+	var errs firstError
 	wg.Add(len(groupsDesc.Groups))
 
 	for _, desc := range groupsDesc.Groups {
 		go func(desc *sarama.GroupDescription) {
 			defer wg.Done()
-			var offset GroupOffset
-			offset.Group = desc.GroupId
-
-			request := GetOffsetFetchRequest(desc)
-
-			offsets, err := broker.FetchOffset(request)
+			offset, err := fetchGroupOffset(broker, desc)
 			if err != nil {
-				log.Fatal("Failed to fetch offsets")
+				errs.set(err)
+				return
 			}
-
-			for topic, partitionmap := range offsets.Blocks {
-				groupTopic := GroupTopicOffset{Topic: topic}
-				for partition, block := range partitionmap {
-					topicPartition := TopicPartitionOffset{Partition: partition, Offset: block.Offset, Topic: topic}
-					groupTopic.TopicPartitionOffsets = append(groupTopic.TopicPartitionOffsets, topicPartition)
-				}
-				sort.Sort(groupTopic.TopicPartitionOffsets)
-				offset.GroupTopicOffsets = append(offset.GroupTopicOffsets, groupTopic)
-			}
-
-			sort.Sort(offset.GroupTopicOffsets)
 			groupOffsetChannel <- offset
 		}(desc)
 	}
 	wg.Wait()
+	return errs.err
+}
+
+// FetchGroupOffsets fetches the offsets of a single group from its coordinator, it returns ErrGroupNotFound when the
+// group does not exist
+func FetchGroupOffsets(client sarama.Client, group string) (GroupOffset, error) {
+	coordinator, err := client.Coordinator(group)
+	if err != nil {
+		return GroupOffset{}, fmt.Errorf("failed to find the coordinator of group %s: %v", group, err)
+	}
+	groupsDesc, err := coordinator.DescribeGroups(&sarama.DescribeGroupsRequest{Groups: []string{group}})
+	if err != nil {
+		return GroupOffset{}, fmt.Errorf("failed to describe group %s: %v", group, err)
+	}
+	if len(groupsDesc.Groups) != 1 {
+		return GroupOffset{}, fmt.Errorf("failed to describe group %s: %d descriptions returned", group, len(groupsDesc.Groups))
+	}
+
+	desc := groupsDesc.Groups[0]
+	// The brokers describe unknown groups as dead groups
+	if desc.Err == sarama.ErrGroupIDNotFound || desc.State == "Dead" {
+		return GroupOffset{}, ErrGroupNotFound
+	}
+	if desc.Err != sarama.ErrNoError {
+		return GroupOffset{}, fmt.Errorf("failed to describe group %s: %v", group, desc.Err)
+	}
+	return fetchGroupOffset(coordinator, desc)
+}
+
+// fetchGroupOffset fetches the offsets of the partitions assigned to the members of the group from its coordinator
+func fetchGroupOffset(broker *sarama.Broker, desc *sarama.GroupDescription) (GroupOffset, error) {
+	offset := GroupOffset{Group: desc.GroupId}
+
+	request, err := GetOffsetFetchRequest(desc)
+	if err != nil {
+		return offset, fmt.Errorf("failed to parse the assignments of group %s: %v", desc.GroupId, err)
+	}
+
+	offsets, err := broker.FetchOffset(request)
+	if err != nil {
+		return offset, fmt.Errorf("failed to fetch offsets of group %s: %v", desc.GroupId, err)
+	}
+
+	for topic, partitionmap := range offsets.Blocks {
+		groupTopic := GroupTopicOffset{Topic: topic}
+		for partition, block := range partitionmap {
+			topicPartition := TopicPartitionOffset{Partition: partition, Offset: block.Offset, Topic: topic}
+			groupTopic.TopicPartitionOffsets = append(groupTopic.TopicPartitionOffsets, topicPartition)
+		}
+		sort.Sort(groupTopic.TopicPartitionOffsets)
+		offset.GroupTopicOffsets = append(offset.GroupTopicOffsets, groupTopic)
+	}
+
+	sort.Sort(offset.GroupTopicOffsets)
+	return offset, nil
+}
+
+// firstError keeps the first error of concurrent requests
+type firstError struct {
+	mutex sync.Mutex
+	err   error
+}
+
+func (e *firstError) set(err error) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	if e.err == nil {
+		e.err = err
+	}
 }
 
 // GetOffsetFetchRequest generates a request for the offsets of a specific group
-func GetOffsetFetchRequest(desc *sarama.GroupDescription) *sarama.OffsetFetchRequest {
+func GetOffsetFetchRequest(desc *sarama.GroupDescription) (*sarama.OffsetFetchRequest, error) {
 	request := new(sarama.OffsetFetchRequest)
 	request.Version = 1
 	request.ConsumerGroup = desc.GroupId
@@ -250,7 +361,10 @@ func GetOffsetFetchRequest(desc *sarama.GroupDescription) *sarama.OffsetFetchReq
 			continue
 		}
 
-		assignment := ParseMemberAssignment(assignArr)
+		assignment, err := ParseMemberAssignment(assignArr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse the assignment of member %s: %v", memberDesc.MemberId, err)
+		}
 		for _, topicAssignment := range assignment.Assignments {
 			for _, partition := range topicAssignment.Partitions {
 				request.AddPartition(topicAssignment.Topic, partition)
@@ -258,5 +372,5 @@ func GetOffsetFetchRequest(desc *sarama.GroupDescription) *sarama.OffsetFetchReq
 		}
 	}
 
-	return request
+	return request, nil
 }
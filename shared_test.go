@@ -0,0 +1,163 @@
+package kafkatools
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/Shopify/sarama"
+)
+
+func TestFetchTopicOffsets(t *testing.T) {
+	broker := sarama.NewMockBroker(t, 1)
+	defer broker.Close()
+
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": sarama.NewMockMetadataResponse(t).
+			SetBroker(broker.Addr(), broker.BrokerID()).
+			SetLeader("foo", 0, broker.BrokerID()).
+			SetLeader("foo", 1, broker.BrokerID()),
+		"OffsetRequest": sarama.NewMockOffsetResponse(t).
+			SetOffset("foo", 0, sarama.OffsetNewest, 12).
+			SetOffset("foo", 1, sarama.OffsetNewest, 30),
+	})
+
+	client, err := GetSaramaClient(broker.Addr())
+	if err != nil {
+		t.Fatal("Unexpected error: ", err)
+	}
+	defer client.Close()
+
+	offsets, err := FetchTopicOffsets(client, sarama.OffsetNewest, "foo")
+	if err != nil {
+		t.Fatal("Unexpected error: ", err)
+	}
+	if len(offsets) != 2 || offsets[0].Offset != 12 || offsets[1].Offset != 30 {
+		t.Errorf("Expected offsets 12 and 30, got %v", offsets)
+	}
+
+	if _, err := FetchTopicOffsets(client, sarama.OffsetNewest, "bar"); err == nil {
+		t.Error("Expected an error fetching the offsets of an unknown topic")
+	}
+}
+
+func TestGetSaramaClientReturnsErrors(t *testing.T) {
+	broker := sarama.NewMockBroker(t, 1)
+	addr := broker.Addr()
+	broker.Close()
+
+	if _, err := GetSaramaClient(addr); err == nil {
+		t.Error("Expected an error connecting to a closed broker")
+	}
+}
+
+func TestResolveTimestampOffsets(t *testing.T) {
+	found := map[int32]TopicPartitionOffset{
+		0: {Topic: "foo", Partition: 0, Offset: 12},
+		1: {Topic: "foo", Partition: 1, Offset: -1},
+	}
+	newest := map[int32]TopicPartitionOffset{
+		0: {Topic: "foo", Partition: 0, Offset: 20},
+		1: {Topic: "foo", Partition: 1, Offset: 30},
+	}
+
+	expected := map[int32]TopicPartitionOffset{
+		0: {Topic: "foo", Partition: 0, Offset: 12},
+		1: {Topic: "foo", Partition: 1, Offset: 30},
+	}
+	if resolved := resolveTimestampOffsets(found, newest); !reflect.DeepEqual(resolved, expected) {
+		t.Errorf("Expected %v, got %v", expected, resolved)
+	}
+}
+
+func TestFetchTopicOffsetsForTime(t *testing.T) {
+	broker := sarama.NewMockBroker(t, 1)
+	defer broker.Close()
+
+	// Partition 1 has no message at or after the time
+	const timeMillis = 1500000000000
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": sarama.NewMockMetadataResponse(t).
+			SetBroker(broker.Addr(), broker.BrokerID()).
+			SetLeader("foo", 0, broker.BrokerID()).
+			SetLeader("foo", 1, broker.BrokerID()),
+		"OffsetRequest": sarama.NewMockOffsetResponse(t).
+			SetOffset("foo", 0, timeMillis, 12).
+			SetOffset("foo", 0, sarama.OffsetNewest, 20).
+			SetOffset("foo", 1, timeMillis, -1).
+			SetOffset("foo", 1, sarama.OffsetNewest, 30),
+	})
+
+	client, err := GetSaramaClient(broker.Addr())
+	if err != nil {
+		t.Fatal("Unexpected error: ", err)
+	}
+	defer client.Close()
+
+	offsets, err := FetchTopicOffsetsForTime(client, "foo", timeMillis)
+	if err != nil {
+		t.Fatal("Unexpected error: ", err)
+	}
+	if len(offsets) != 2 || offsets[0].Offset != 12 || offsets[1].Offset != 30 {
+		t.Errorf("Expected offsets 12 and 30, got %v", offsets)
+	}
+
+	if _, err := FetchTopicOffsetsForTime(client, "foo", sarama.OffsetNewest); err == nil {
+		t.Error("Expected an error for a negative time")
+	}
+}
+
+func TestFetchGroupOffsets(t *testing.T) {
+	broker := sarama.NewMockBroker(t, 1)
+	defer broker.Close()
+
+	// A member assigned partitions 0 and 1 of foo
+	assignment := []byte{0, 0, 0, 0, 0, 1, 0, 3, 'f', 'o', 'o', 0, 0, 0, 2, 0, 0, 0, 0, 0, 0, 0, 1}
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": sarama.NewMockMetadataResponse(t).
+			SetBroker(broker.Addr(), broker.BrokerID()),
+		"FindCoordinatorRequest": sarama.NewMockFindCoordinatorResponse(t).
+			SetCoordinator(sarama.CoordinatorGroup, "group", broker).
+			SetCoordinator(sarama.CoordinatorGroup, "unknown", broker),
+		"DescribeGroupsRequest": sarama.NewMockDescribeGroupsResponse(t).
+			AddGroupDescription("group", &sarama.GroupDescription{
+				GroupId: "group",
+				State:   "Stable",
+				Members: map[string]*sarama.GroupMemberDescription{"member": {MemberAssignment: assignment}},
+			}),
+		"OffsetFetchRequest": sarama.NewMockOffsetFetchResponse(t).
+			SetOffset("group", "foo", 0, 5, "", sarama.ErrNoError).
+			SetOffset("group", "foo", 1, 7, "", sarama.ErrNoError),
+	})
+
+	client, err := GetSaramaClient(broker.Addr())
+	if err != nil {
+		t.Fatal("Unexpected error: ", err)
+	}
+	defer client.Close()
+
+	offset, err := FetchGroupOffsets(client, "group")
+	if err != nil {
+		t.Fatal("Unexpected error: ", err)
+	}
+	expected := GroupOffset{Group: "group", GroupTopicOffsets: GroupTopicOffsetSlice{{
+		Topic: "foo",
+		TopicPartitionOffsets: TopicPartitionOffsetSlice{
+			{Topic: "foo", Partition: 0, Offset: 5},
+			{Topic: "foo", Partition: 1, Offset: 7},
+		},
+	}}}
+	if !reflect.DeepEqual(offset, expected) {
+		t.Errorf("Expected %v, got %v", expected, offset)
+	}
+
+	// Only the requested group is fetched, without listing the groups of the brokers
+	for _, request := range broker.History() {
+		if _, ok := request.Request.(*sarama.ListGroupsRequest); ok {
+			t.Error("Expected the groups not to be listed")
+		}
+	}
+
+	if _, err := FetchGroupOffsets(client, "unknown"); err != ErrGroupNotFound {
+		t.Errorf("Expected ErrGroupNotFound, got %v", err)
+	}
+}
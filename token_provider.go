@@ -0,0 +1,137 @@
+package kafkatools
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Shopify/sarama"
+)
+
+const (
+	tokenCommandTimeout = 30 * time.Second
+	// tokens are refreshed this long before they expire
+	tokenRefreshMargin = 30 * time.Second
+	// lifetime assumed for tokens that don't carry an expiry
+	defaultTokenLifetime = 5 * time.Minute
+)
+
+// StaticTokenProvider provides the same OAUTHBEARER token on every call
+type StaticTokenProvider struct {
+	AccessToken string
+}
+
+// Token returns the static access token
+func (p StaticTokenProvider) Token() (*sarama.AccessToken, error) {
+	return &sarama.AccessToken{Token: p.AccessToken}, nil
+}
+
+// CommandTokenProvider fetches OAUTHBEARER tokens by running a shell command.
+// The command should print either a raw token or a JSON object containing
+// "access_token" and (optionally) "expires_in" to stdout. Tokens are cached
+// and the command is run again shortly before the token expires.
+type CommandTokenProvider struct {
+	Command string
+
+	mutex  sync.Mutex
+	token  *sarama.AccessToken
+	expiry time.Time
+}
+
+// NewCommandTokenProvider returns a token provider for the given shell command
+func NewCommandTokenProvider(command string) *CommandTokenProvider {
+	return &CommandTokenProvider{Command: command}
+}
+
+// Token returns a cached token, running the command when it is (about to be) expired
+func (p *CommandTokenProvider) Token() (*sarama.AccessToken, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	now := time.Now()
+	if p.token != nil && now.Add(tokenRefreshMargin).Before(p.expiry) {
+		return p.token, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), tokenCommandTimeout)
+	defer cancel()
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "sh", "-c", p.Command)
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("token command failed: %v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	token, expiry, err := parseTokenOutput(output, now)
+	if err != nil {
+		return nil, err
+	}
+
+	p.token = &sarama.AccessToken{Token: token}
+	p.expiry = expiry
+	return p.token, nil
+}
+
+// parseTokenOutput extracts the token and its expiry from the output of a token command
+func parseTokenOutput(output []byte, now time.Time) (token string, expiry time.Time, err error) {
+	trimmed := bytes.TrimSpace(output)
+	if len(trimmed) == 0 {
+		return "", expiry, errors.New("token command returned an empty token")
+	}
+
+	if trimmed[0] == '{' {
+		var response struct {
+			AccessToken string `json:"access_token"`
+			ExpiresIn   int64  `json:"expires_in"`
+		}
+		if err = json.Unmarshal(trimmed, &response); err != nil {
+			return "", expiry, fmt.Errorf("could not parse token command output: %v", err)
+		}
+		if response.AccessToken == "" {
+			return "", expiry, errors.New("token command output does not contain an access_token")
+		}
+		token = response.AccessToken
+		if response.ExpiresIn > 0 {
+			return token, now.Add(time.Duration(response.ExpiresIn) * time.Second), nil
+		}
+	} else {
+		token = string(trimmed)
+	}
+
+	if exp, ok := jwtExpiry(token); ok {
+		return token, exp, nil
+	}
+
+	return token, now.Add(defaultTokenLifetime), nil
+}
+
+// jwtExpiry returns the exp claim of a JWT, if the token is one
+func jwtExpiry(token string) (expiry time.Time, ok bool) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return expiry, false
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return expiry, false
+	}
+
+	var claims struct {
+		Exp int64 `json:"exp"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil || claims.Exp == 0 {
+		return expiry, false
+	}
+
+	return time.Unix(claims.Exp, 0), true
+}
@@ -0,0 +1,46 @@
+package kafkatools
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseTokenOutput(t *testing.T) {
+	now := time.Unix(1500000000, 0)
+
+	// header.{"exp":1500000600}.signature
+	jwt := "eyJhbGciOiJub25lIn0.eyJleHAiOjE1MDAwMDA2MDB9.c2ln"
+
+	tests := []struct {
+		output string
+		token  string
+		expiry time.Time
+	}{
+		{"raw-token\n", "raw-token", now.Add(defaultTokenLifetime)},
+		{`{"access_token": "json-token", "expires_in": 3600}`, "json-token", now.Add(time.Hour)},
+		{`{"access_token": "json-token"}`, "json-token", now.Add(defaultTokenLifetime)},
+		{jwt, jwt, time.Unix(1500000600, 0)},
+	}
+
+	for _, test := range tests {
+		token, expiry, err := parseTokenOutput([]byte(test.output), now)
+		if err != nil {
+			t.Errorf("Unexpected error for %q: %v", test.output, err)
+			continue
+		}
+		if token != test.token {
+			t.Errorf("Expected token %q, got %q", test.token, token)
+		}
+		if !expiry.Equal(test.expiry) {
+			t.Errorf("Expected expiry %v for %q, got %v", test.expiry, test.output, expiry)
+		}
+	}
+}
+
+func TestParseTokenOutputErrors(t *testing.T) {
+	for _, output := range []string{"", "  \n", `{"expires_in": 10}`, `{"access_token":`} {
+		if _, _, err := parseTokenOutput([]byte(output), time.Now()); err == nil {
+			t.Errorf("Expected an error for %q", output)
+		}
+	}
+}
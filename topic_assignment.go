@@ -3,8 +3,8 @@ package kafkatools
 import (
 	"bytes"
 	"encoding/binary"
+	"fmt"
 	"io"
-	"log"
 )
 
 // TopicAssignment contains the assigned partitions of a topic
@@ -20,48 +20,74 @@ type MemberAssignment struct {
 }
 
 // ParseMemberAssignment parses a binary byteArr
-func ParseMemberAssignment(byteArr []byte) (assignments MemberAssignment) {
+func ParseMemberAssignment(byteArr []byte) (assignments MemberAssignment, err error) {
 	buf := bytes.NewBuffer(byteArr)
-	assignments.Version = int(readInt16(buf))
-	elements := int(readInt32(buf))
+	version, err := readInt16(buf)
+	if err != nil {
+		return assignments, fmt.Errorf("failed to read the version of the assignment: %v", err)
+	}
+	assignments.Version = int(version)
+	elements, err := readLength(buf)
+	if err != nil {
+		return assignments, fmt.Errorf("failed to read the number of topics of the assignment: %v", err)
+	}
 	assignments.Assignments = make([]TopicAssignment, elements)
 	for i := range assignments.Assignments {
-		assignments.Assignments[i].Topic = readString(buf)
-		assignments.Assignments[i].Partitions = readInt32Arr(buf)
+		if assignments.Assignments[i].Topic, err = readString(buf); err != nil {
+			return assignments, fmt.Errorf("failed to read the topic of the assignment: %v", err)
+		}
+		if assignments.Assignments[i].Partitions, err = readInt32Arr(buf); err != nil {
+			return assignments, fmt.Errorf("failed to read the partitions of topic %s: %v", assignments.Assignments[i].Topic, err)
+		}
 	}
 
-	return assignments
+	return assignments, nil
 }
 
-func readInt16(buf io.Reader) (val int16) {
-	if err := binary.Read(buf, binary.BigEndian, &val); err != nil {
-		log.Fatal("binary.Read failed:", err)
-	}
-	return val
+func readInt16(buf io.Reader) (val int16, err error) {
+	err = binary.Read(buf, binary.BigEndian, &val)
+	return val, err
+}
+
+func readInt32(buf io.Reader) (val int32, err error) {
+	err = binary.Read(buf, binary.BigEndian, &val)
+	return val, err
 }
 
-func readInt32(buf io.Reader) (val int32) {
-	if err := binary.Read(buf, binary.BigEndian, &val); err != nil {
-		log.Fatal("binary.Read failed:", err)
+// readLength reads the int32 length of an array
+func readLength(buf io.Reader) (int32, error) {
+	length, err := readInt32(buf)
+	if err == nil && length < 0 {
+		err = fmt.Errorf("invalid length %d", length)
 	}
-	return val
+	return length, err
 }
 
-func readInt32Arr(buf io.Reader) (val []int32) {
-	length := readInt32(buf)
+func readInt32Arr(buf io.Reader) (val []int32, err error) {
+	length, err := readLength(buf)
+	if err != nil {
+		return nil, err
+	}
 	val = make([]int32, length)
 	for i := range val {
-		val[i] = readInt32(buf)
+		if val[i], err = readInt32(buf); err != nil {
+			return nil, err
+		}
 	}
-	return val
+	return val, nil
 }
 
-func readString(buf io.Reader) (val string) {
-	length := readInt16(buf)
+func readString(buf io.Reader) (val string, err error) {
+	length, err := readInt16(buf)
+	if err != nil {
+		return "", err
+	}
+	if length < 0 {
+		return "", fmt.Errorf("invalid length %d", length)
+	}
 	bytes := make([]byte, length)
-	if _, err := buf.Read(bytes); err != nil {
-		log.Fatal("buf.Read failed:", err)
+	if _, err := io.ReadFull(buf, bytes); err != nil {
+		return "", err
 	}
-	val = string(bytes)
-	return val
+	return string(bytes), nil
 }
@@ -8,7 +8,10 @@ import (
 func TestComplexTopicAssignment(t *testing.T) {
 	data := []byte{0, 0, 0, 0, 0, 4, 0, 8, 116, 101, 115, 116, 105, 110, 103, 50, 0, 0, 0, 9, 0, 0, 0, 0, 0, 0, 0, 1, 0, 0, 0, 2, 0, 0, 0, 3, 0, 0, 0, 4, 0, 0, 0, 5, 0, 0, 0, 6, 0, 0, 0, 7, 0, 0, 0, 8, 0, 7, 116, 101, 115, 116, 105, 110, 103, 0, 0, 0, 9, 0, 0, 0, 0, 0, 0, 0, 1, 0, 0, 0, 2, 0, 0, 0, 3, 0, 0, 0, 4, 0, 0, 0, 5, 0, 0, 0, 6, 0, 0, 0, 7, 0, 0, 0, 8, 0, 8, 116, 101, 115, 116, 105, 110, 103, 51, 0, 0, 0, 9, 0, 0, 0, 0, 0, 0, 0, 1, 0, 0, 0, 2, 0, 0, 0, 3, 0, 0, 0, 4, 0, 0, 0, 5, 0, 0, 0, 6, 0, 0, 0, 7, 0, 0, 0, 8, 0, 8, 116, 101, 115, 116, 105, 110, 103, 52, 0, 0, 0, 15, 0, 0, 0, 0, 0, 0, 0, 1, 0, 0, 0, 2, 0, 0, 0, 3, 0, 0, 0, 4, 0, 0, 0, 5, 0, 0, 0, 6, 0, 0, 0, 7, 0, 0, 0, 8, 0, 0, 0, 9, 0, 0, 0, 10, 0, 0, 0, 11, 0, 0, 0, 12, 0, 0, 0, 13, 0, 0, 0, 14, 0, 0, 0, 0}
 
-	out := ParseMemberAssignment(data)
+	out, err := ParseMemberAssignment(data)
+	if err != nil {
+		t.Fatal("Expected the assignment to parse, got ", err)
+	}
 	if out.Version != 0 {
 		t.Error("Expected version to equal 0, got ", out.Version)
 	}
@@ -29,3 +32,16 @@ func TestComplexTopicAssignment(t *testing.T) {
 		t.Errorf("Expected the topics %v, got %v", expect, topicNames)
 	}
 }
+
+func TestTruncatedTopicAssignment(t *testing.T) {
+	// The topic name is 8 bytes long but only "test" follows
+	data := []byte{0, 0, 0, 0, 0, 1, 0, 8, 116, 101, 115, 116}
+	if _, err := ParseMemberAssignment(data); err == nil {
+		t.Error("Expected an error parsing a truncated assignment")
+	}
+
+	// A negative number of topics
+	if _, err := ParseMemberAssignment([]byte{0, 0, 255, 255, 255, 255}); err == nil {
+		t.Error("Expected an error parsing a negative length")
+	}
+}
@@ -2,9 +2,9 @@ package kafkatools
 
 // TopicPartitionOffset information
 type TopicPartitionOffset struct {
-	Topic     string
-	Partition int32
-	Offset    int64
+	Topic     string `json:"topic"`
+	Partition int32  `json:"partition"`
+	Offset    int64  `json:"offset"`
 }
 
 // GroupTopicOffset contains the partition offset of a topic